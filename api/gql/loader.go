@@ -0,0 +1,218 @@
+package gql
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+type loaderKey struct{}
+
+// fetchFunc fetches the results of the given ids at once. The results must
+// contain all ids, ids without results are mapped to empty results.
+type fetchFunc func(ctx context.Context, ids []uint64) (map[uint64]interface{}, error)
+
+// loader batches lookups by id. Ids requested via load are collected until the
+// first returned thunk is called, then the results of all collected ids are
+// fetched in a single query. A loader caches its results and must only be
+// used for a single request.
+type loader struct {
+	fetch fetchFunc
+
+	mu      sync.Mutex
+	pending map[uint64]struct{}
+	results map[uint64]interface{}
+	errs    map[uint64]error
+}
+
+func newLoader(fetch fetchFunc) *loader {
+	return &loader{
+		fetch:   fetch,
+		pending: make(map[uint64]struct{}),
+		results: make(map[uint64]interface{}),
+		errs:    make(map[uint64]error),
+	}
+}
+
+// loaders are the loaders of a request. Players are loaded by roster, aliases
+// and transfers by player, matches by roster and lineups by match.
+type loaders struct {
+	players, aliases, transfers, matches, lineups *loader
+}
+
+func newLoaders(ps playerStore, ts transferStore, ms matchStore) *loaders {
+	return &loaders{
+		players:   newLoader(playersByRoster(ps)),
+		aliases:   newLoader(aliasesByPlayer(ps)),
+		transfers: newLoader(transfersByPlayer(ts)),
+		matches:   newLoader(matchesByRoster(ms)),
+		lineups:   newLoader(lineupsByMatch(ms)),
+	}
+}
+
+// withLoaders returns a copy of ctx holding the loaders.
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+// loadersFromContext returns the loaders of the request.
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loaderKey{}).(*loaders)
+}
+
+// load schedules the results of the id to be fetched with the next batch and
+// returns a thunk returning them.
+func (l *loader) load(ctx context.Context, id uint64) func() (interface{}, error) {
+	l.mu.Lock()
+	_, loaded := l.results[id]
+	if !loaded && l.errs[id] == nil {
+		l.pending[id] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.fetchPending(ctx)
+		}
+		return l.results[id], l.errs[id]
+	}
+}
+
+// fetchPending fetches the results of all pending ids. Must be called with the
+// lock held.
+func (l *loader) fetchPending(ctx context.Context) {
+	ids := make([]uint64, 0, len(l.pending))
+	for id := range l.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	l.pending = make(map[uint64]struct{})
+	results, err := l.fetch(ctx, ids)
+	for _, id := range ids {
+		if err != nil {
+			l.errs[id] = err
+			continue
+		}
+		l.results[id] = results[id]
+	}
+}
+
+// playersByRoster fetches the players of rosters.
+func playersByRoster(ps playerStore) fetchFunc {
+	return func(ctx context.Context, rosterIDs []uint64) (map[uint64]interface{}, error) {
+		players, err := ps.Find(ctx, store.PlayerFilter{RosterIDs: rosterIDs})
+		if err != nil {
+			return nil, err
+		}
+		byRoster := make(map[uint64][]store.Player, len(rosterIDs))
+		for _, id := range rosterIDs {
+			// rosters without players are cached as well
+			byRoster[id] = make([]store.Player, 0)
+		}
+		for _, p := range players {
+			byRoster[p.RosterID] = append(byRoster[p.RosterID], p)
+		}
+		results := make(map[uint64]interface{}, len(byRoster))
+		for id, players := range byRoster {
+			results[id] = players
+		}
+		return results, nil
+	}
+}
+
+// aliasesByPlayer fetches the former aliases of players.
+func aliasesByPlayer(ps playerStore) fetchFunc {
+	return func(ctx context.Context, playerIDs []uint64) (map[uint64]interface{}, error) {
+		aliases, err := ps.Aliases(ctx, playerIDs)
+		if err != nil {
+			return nil, err
+		}
+		byPlayer := make(map[uint64][]store.AliasChange, len(playerIDs))
+		for _, id := range playerIDs {
+			byPlayer[id] = make([]store.AliasChange, 0)
+		}
+		for _, a := range aliases {
+			byPlayer[a.PlayerID] = append(byPlayer[a.PlayerID], a)
+		}
+		results := make(map[uint64]interface{}, len(byPlayer))
+		for id, aliases := range byPlayer {
+			results[id] = aliases
+		}
+		return results, nil
+	}
+}
+
+// transfersByPlayer fetches the transfers of players.
+func transfersByPlayer(ts transferStore) fetchFunc {
+	return func(ctx context.Context, playerIDs []uint64) (map[uint64]interface{}, error) {
+		transfers, err := ts.ListPlayers(ctx, playerIDs)
+		if err != nil {
+			return nil, err
+		}
+		byPlayer := make(map[uint64][]store.Transfer, len(playerIDs))
+		for _, id := range playerIDs {
+			byPlayer[id] = make([]store.Transfer, 0)
+		}
+		for _, t := range transfers {
+			byPlayer[t.PlayerID] = append(byPlayer[t.PlayerID], t)
+		}
+		results := make(map[uint64]interface{}, len(byPlayer))
+		for id, transfers := range byPlayer {
+			results[id] = transfers
+		}
+		return results, nil
+	}
+}
+
+// matchesByRoster fetches the matches of rosters. Matches belong to both of
+// their rosters.
+func matchesByRoster(ms matchStore) fetchFunc {
+	return func(ctx context.Context, rosterIDs []uint64) (map[uint64]interface{}, error) {
+		matches, err := ms.ListRosters(ctx, rosterIDs)
+		if err != nil {
+			return nil, err
+		}
+		byRoster := make(map[uint64][]store.Match, len(rosterIDs))
+		for _, id := range rosterIDs {
+			byRoster[id] = make([]store.Match, 0)
+		}
+		for _, m := range matches {
+			for _, id := range []uint64{m.RosterID, m.OpponentID} {
+				if _, ok := byRoster[id]; ok {
+					byRoster[id] = append(byRoster[id], m)
+				}
+			}
+		}
+		results := make(map[uint64]interface{}, len(byRoster))
+		for id, matches := range byRoster {
+			results[id] = matches
+		}
+		return results, nil
+	}
+}
+
+// lineupsByMatch fetches the submitted lineups of matches.
+func lineupsByMatch(ms matchStore) fetchFunc {
+	return func(ctx context.Context, matchIDs []uint64) (map[uint64]interface{}, error) {
+		lineups, err := ms.ListLineups(ctx, matchIDs)
+		if err != nil {
+			return nil, err
+		}
+		byMatch := make(map[uint64][]store.Lineup, len(matchIDs))
+		for _, id := range matchIDs {
+			byMatch[id] = make([]store.Lineup, 0)
+		}
+		for _, l := range lineups {
+			byMatch[l.MatchID] = append(byMatch[l.MatchID], l)
+		}
+		results := make(map[uint64]interface{}, len(byMatch))
+		for id, lineups := range byMatch {
+			results[id] = lineups
+		}
+		return results, nil
+	}
+}
@@ -0,0 +1,538 @@
+// Package gql provides a GraphQL schema over rosters and players, the history
+// of players and the matches of rosters. Queries resolve the players, history
+// and matches in batches, so that a list of rosters with their players is
+// fetched with two queries to the datastore and each further level of the
+// query adds a single query.
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/rs/zerolog/log"
+)
+
+// rosterStore handles operations on rosters.
+type rosterStore interface {
+	Get(ctx context.Context, rosterID uint64) (*store.Roster, error)
+	List(ctx context.Context, name string) ([]store.Roster, error)
+}
+
+// playerStore provides methods to operate on the players store.
+type playerStore interface {
+	Insert(ctx context.Context, player store.Player) (*store.Player, error)
+	Update(ctx context.Context, player store.Player) (*store.Player, error)
+	ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error)
+	Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error)
+	Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error)
+}
+
+// transferStore provides the transfers of players.
+type transferStore interface {
+	ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error)
+}
+
+// matchStore provides the matches of rosters and their lineups.
+type matchStore interface {
+	ListRosters(ctx context.Context, rosterIDs []uint64) ([]store.Match, error)
+	ListLineups(ctx context.Context, matchIDs []uint64) ([]store.Lineup, error)
+}
+
+// Schema executes GraphQL requests against the stores.
+type Schema struct {
+	schema graphql.Schema
+	rs     rosterStore
+	ps     playerStore
+	ts     transferStore
+	ms     matchStore
+}
+
+// NewSchema creates the GraphQL schema resolving rosters and players from the
+// given stores.
+func NewSchema(rs rosterStore, ps playerStore, ts transferStore, ms matchStore) (*Schema, error) {
+	s := &Schema{rs: rs, ps: ps, ts: ts, ms: ms}
+
+	statusType := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "Status",
+		Description: "Status of a player within a roster.",
+		Values: graphql.EnumValueConfigMap{
+			"ACTIVE":  &graphql.EnumValueConfig{Value: store.Active},
+			"BENCHED": &graphql.EnumValueConfig{Value: store.Benched},
+		},
+	})
+
+	aliasType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "AliasChange",
+		Description: "Former alias of a player.",
+		Fields: graphql.Fields{
+			"alias":     field(graphql.String, func(v interface{}) interface{} { return v.(store.AliasChange).Alias }),
+			"renamedAt": field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.AliasChange).RenamedAt }),
+		},
+	})
+
+	transferType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Transfer",
+		Description: "Transfer of a player between rosters.",
+		Fields: graphql.Fields{
+			"id":           field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Transfer).TransferID) }),
+			"fromRosterId": field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Transfer).FromRosterID) }),
+			"toRosterId":   field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Transfer).ToRosterID) }),
+			"status":       field(graphql.String, func(v interface{}) interface{} { return v.(store.Transfer).Status }),
+			"requestedBy":  field(graphql.String, func(v interface{}) interface{} { return v.(store.Transfer).RequestedBy }),
+			"createdAt":    field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.Transfer).CreatedAt }),
+			"expiresAt":    field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.Transfer).ExpiresAt }),
+		},
+	})
+
+	historyType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PlayerHistory",
+		Description: "Former aliases and transfers of a player, most recent first.",
+		Fields: graphql.Fields{
+			"aliases": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(aliasType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return batch(p, loadersFromContext(p.Context).aliases, p.Source.(store.Player).PlayerID), nil
+				},
+			},
+			"transfers": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(transferType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return batch(p, loadersFromContext(p.Context).transfers, p.Source.(store.Player).PlayerID), nil
+				},
+			},
+		},
+	})
+
+	playerType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Player",
+		Fields: graphql.Fields{
+			"id":        playerField(graphql.ID, func(p store.Player) interface{} { return formatID(p.PlayerID) }),
+			"rosterId":  playerField(graphql.ID, func(p store.Player) interface{} { return formatID(p.RosterID) }),
+			"firstName": playerField(graphql.String, func(p store.Player) interface{} { return p.FirstName }),
+			"lastName":  playerField(graphql.String, func(p store.Player) interface{} { return p.LastName }),
+			"alias":     playerField(graphql.String, func(p store.Player) interface{} { return p.Alias }),
+			"status":    playerField(statusType, func(p store.Player) interface{} { return p.Status }),
+			// the history is resolved from the player
+			"history": playerField(historyType, func(p store.Player) interface{} { return p }),
+		},
+	})
+	playerList := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(playerType)))
+
+	lineupType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Lineup",
+		Description: "Snapshot of the active players a roster submitted for a match.",
+		Fields: graphql.Fields{
+			"rosterId":    field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Lineup).RosterID) }),
+			"submittedAt": field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.Lineup).SubmittedAt }),
+			"players":     field(graphql.NewList(graphql.NewNonNull(playerType)), func(v interface{}) interface{} { return v.(store.Lineup).Players }),
+		},
+	})
+
+	matchType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Match",
+		Description: "Match between a roster and its opponent.",
+		Fields: graphql.Fields{
+			"id":             field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Match).MatchID) }),
+			"rosterId":       field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Match).RosterID) }),
+			"opponentId":     field(graphql.ID, func(v interface{}) interface{} { return formatID(v.(store.Match).OpponentID) }),
+			"scheduledAt":    field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.Match).ScheduledAt }),
+			"lineupDeadline": field(graphql.DateTime, func(v interface{}) interface{} { return v.(store.Match).LineupDeadline }),
+			"status":         field(graphql.String, func(v interface{}) interface{} { return v.(store.Match).Status }),
+			"lineups": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(lineupType))),
+				Description: "Submitted lineups of the match ordered by submission.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return batch(p, loadersFromContext(p.Context).lineups, p.Source.(store.Match).MatchID), nil
+				},
+			},
+		},
+	})
+
+	rosterType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Roster",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return formatID(p.Source.(store.Roster).RosterID), nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(store.Roster).Name, nil
+				},
+			},
+			"players": &graphql.Field{
+				Type:        playerList,
+				Description: "Players of the roster, optionally filtered by status.",
+				Args: graphql.FieldConfigArgument{
+					"status": &graphql.ArgumentConfig{Type: statusType},
+				},
+				Resolve: s.rosterPlayers,
+			},
+			"matches": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(matchType))),
+				Description: "Matches the roster plays in ordered by scheduled time.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return batch(p, loadersFromContext(p.Context).matches, p.Source.(store.Roster).RosterID), nil
+				},
+			},
+		},
+	})
+
+	changeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PlayerChange",
+		Fields: graphql.Fields{
+			"active": &graphql.Field{
+				Type: graphql.NewNonNull(playerType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(store.PlayerChange).Active, nil
+				},
+			},
+			"benched": &graphql.Field{
+				Type: graphql.NewNonNull(playerType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(store.PlayerChange).Benched, nil
+				},
+			},
+		},
+	})
+
+	playerInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PlayerInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"rosterId":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+			"firstName": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"lastName":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"alias":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"roster": &graphql.Field{
+				Type: rosterType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: s.roster,
+			},
+			"rosters": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rosterType))),
+				Description: "Rosters ordered by id, optionally filtered by a part of their name.",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: s.rosters,
+			},
+			"players": &graphql.Field{
+				Type:        playerList,
+				Description: "Players ordered by roster and id, optionally filtered by roster, status and a part of their alias.",
+				Args: graphql.FieldConfigArgument{
+					"rosterId": &graphql.ArgumentConfig{Type: graphql.ID},
+					"status":   &graphql.ArgumentConfig{Type: statusType},
+					"alias":    &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: s.players,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"addPlayer": &graphql.Field{
+				Type:        graphql.NewNonNull(playerType),
+				Description: "Adds a benched player to a roster.",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(playerInput)},
+				},
+				Resolve: s.addPlayer,
+			},
+			"updatePlayer": &graphql.Field{
+				Type:        graphql.NewNonNull(playerType),
+				Description: "Moves a player to the bench of a roster.",
+				Args: graphql.FieldConfigArgument{
+					"id":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"rosterId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: s.updatePlayer,
+			},
+			"swapPlayers": &graphql.Field{
+				Type:        graphql.NewNonNull(changeType),
+				Description: "Swaps the statuses of an active and a benched player of a roster.",
+				Args: graphql.FieldConfigArgument{
+					"rosterId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"active":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"benched":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: s.swapPlayers,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+// Do executes the GraphQL request. Each request uses its own loader, so
+// results are never shared between requests.
+func (s *Schema) Do(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  query,
+		OperationName:  operationName,
+		VariableValues: variables,
+		Context:        withLoaders(ctx, newLoaders(s.ps, s.ts, s.ms)),
+	})
+}
+
+// playerField returns a non-null field of a player resolved by fn.
+func playerField(t graphql.Output, fn func(store.Player) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return fn(p.Source.(store.Player)), nil
+		},
+	}
+}
+
+// field returns a non-null field resolved by fn from the source.
+func field(t graphql.Output, fn func(source interface{}) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return fn(p.Source), nil
+		},
+	}
+}
+
+// batch returns a thunk resolving the results of the id from the loader, so
+// that the results of all ids of a level of the query are fetched at once.
+func batch(p graphql.ResolveParams, l *loader, id uint64) func() (interface{}, error) {
+	thunk := l.load(p.Context, id)
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if err != nil {
+			return nil, resolveError(p.Context, err)
+		}
+		return v, nil
+	}
+}
+
+// roster resolves a single roster. The roster is null if it does not exist.
+func (s *Schema) roster(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.rs.Get(p.Context, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	// the players of the roster are already loaded and are resolved from
+	// the roster to not fetch them again
+	return *r, nil
+}
+
+func (s *Schema) rosters(p graphql.ResolveParams) (interface{}, error) {
+	name, _ := p.Args["name"].(string)
+	rosters, err := s.rs.List(p.Context, name)
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	return rosters, nil
+}
+
+// rosterPlayers resolves the players of a roster. Rosters without loaded
+// players get their players from the loader of the request.
+func (s *Schema) rosterPlayers(p graphql.ResolveParams) (interface{}, error) {
+	r := p.Source.(store.Roster)
+	status, _ := p.Args["status"].(string)
+	if r.Players.Active != nil || r.Players.Benched != nil {
+		players := make([]store.Player, 0, len(r.Players.Active)+len(r.Players.Benched))
+		players = append(append(players, r.Players.Active...), r.Players.Benched...)
+		return filterStatus(players, status), nil
+	}
+	thunk := batch(p, loadersFromContext(p.Context).players, r.RosterID)
+	return func() (interface{}, error) {
+		players, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return filterStatus(players.([]store.Player), status), nil
+	}, nil
+}
+
+func (s *Schema) players(p graphql.ResolveParams) (interface{}, error) {
+	var filter store.PlayerFilter
+	if _, ok := p.Args["rosterId"]; ok {
+		id, err := parseID(p.Args, "rosterId")
+		if err != nil {
+			return nil, err
+		}
+		filter.RosterIDs = []uint64{id}
+	}
+	filter.Status, _ = p.Args["status"].(string)
+	filter.Alias, _ = p.Args["alias"].(string)
+	players, err := s.ps.Find(p.Context, filter)
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	return players, nil
+}
+
+// addPlayer adds a new player. Like in the HTTP API, new players are benched.
+func (s *Schema) addPlayer(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	rosterID, err := parseID(input, "rosterId")
+	if err != nil {
+		return nil, err
+	}
+	player, err := s.ps.Insert(p.Context, store.Player{
+		RosterID:  rosterID,
+		FirstName: input["firstName"].(string),
+		LastName:  input["lastName"].(string),
+		Alias:     input["alias"].(string),
+		Status:    store.Benched,
+	})
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	return *player, nil
+}
+
+// updatePlayer moves a player to a roster. Like in the HTTP API, only admins
+// may move players directly and players always get benched when they are
+// added to a roster.
+func (s *Schema) updatePlayer(p graphql.ResolveParams) (interface{}, error) {
+	if principal, ok := auth.FromContext(p.Context); !ok || !principal.Admin {
+		return nil, errors.New("only admins may move players to another roster, propose a transfer instead")
+	}
+	id, err := parseID(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+	rosterID, err := parseID(p.Args, "rosterId")
+	if err != nil {
+		return nil, err
+	}
+	player, err := s.ps.Update(p.Context, store.Player{
+		PlayerID: id,
+		RosterID: rosterID,
+		Status:   store.Benched,
+	})
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	return *player, nil
+}
+
+func (s *Schema) swapPlayers(p graphql.ResolveParams) (interface{}, error) {
+	ids := make(map[string]uint64, 3)
+	for _, name := range []string{"rosterId", "active", "benched"} {
+		id, err := parseID(p.Args, name)
+		if err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	change, err := s.ps.ChangePlayers(p.Context, store.PlayerChange{
+		Active:  store.Player{PlayerID: ids["active"], RosterID: ids["rosterId"]},
+		Benched: store.Player{PlayerID: ids["benched"], RosterID: ids["rosterId"]},
+	})
+	if err != nil {
+		return nil, resolveError(p.Context, err)
+	}
+	return *change, nil
+}
+
+// filterStatus returns the players with the given status or all players if
+// status is empty.
+func filterStatus(players []store.Player, status string) []store.Player {
+	if status == "" {
+		return players
+	}
+	filtered := make([]store.Player, 0, len(players))
+	for _, p := range players {
+		if p.Status == status {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// parseID parses the ID argument with the given name.
+func parseID(args map[string]interface{}, name string) (uint64, error) {
+	s, _ := args[name].(string)
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an unsigned integer", name)
+	}
+	return id, nil
+}
+
+func formatID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// resolveError converts store errors to errors reported to the client.
+// Unknown errors are logged and hidden from the client.
+func resolveError(ctx context.Context, err error) error {
+	var conflict *store.ConflictError
+	var locked *store.LockedError
+	var roleErr *store.RoleError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return errors.New("not found")
+	case errors.As(err, &conflict):
+		return conflict
+	case errors.As(err, &locked):
+		return locked
+	case errors.As(err, &roleErr):
+		return roleErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return errors.New("deadline exceeded")
+	}
+	log.Ctx(ctx).Error().Err(err).Msg("unexpected graphql error")
+	return errors.New("internal error")
+}
+
+// IsMutation reports whether the operation of the request with the given name
+// is a mutation. Requests that cannot be parsed are reported as queries, their
+// errors are reported on execution.
+func IsMutation(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation == ast.OperationTypeMutation
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/testdata"
+)
+
+type mockRosterStore struct{}
+
+// returns the test roster or store.ErrNotFound.
+func (rs *mockRosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster, error) {
+	if r, ok := testdata.Rosters[rosterID]; ok {
+		return r.R, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+// returns two rosters without players.
+func (rs *mockRosterStore) List(ctx context.Context, name string) ([]store.Roster, error) {
+	return []store.Roster{{RosterID: 1, Name: "foo"}, {RosterID: 2, Name: "bar"}}, nil
+}
+
+// records the calls of the store.
+type mockPlayerStore struct {
+	filters  []store.PlayerFilter
+	aliases  [][]uint64
+	inserted store.Player
+	changed  store.PlayerChange
+}
+
+func (ps *mockPlayerStore) Insert(ctx context.Context, player store.Player) (*store.Player, error) {
+	ps.inserted = player
+	player.PlayerID = 3
+	return &player, nil
+}
+
+func (ps *mockPlayerStore) Update(ctx context.Context, player store.Player) (*store.Player, error) {
+	return nil, store.ErrNotFound
+}
+
+func (ps *mockPlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
+	ps.changed = players
+	return nil, &store.ConflictError{RosterID: 1, Reason: "player 1 must be active"}
+}
+
+// returns a player for each of the requested rosters.
+func (ps *mockPlayerStore) Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error) {
+	ps.filters = append(ps.filters, filter)
+	var players []store.Player
+	for _, id := range filter.RosterIDs {
+		players = append(players, store.Player{PlayerID: id * 10, RosterID: id, Status: store.Benched})
+	}
+	return players, nil
+}
+
+// returns a former alias of each player.
+func (ps *mockPlayerStore) Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error) {
+	ps.aliases = append(ps.aliases, playerIDs)
+	var aliases []store.AliasChange
+	for _, id := range playerIDs {
+		aliases = append(aliases, store.AliasChange{PlayerID: id, Alias: fmt.Sprintf("alias%d", id)})
+	}
+	return aliases, nil
+}
+
+// records the requested players.
+type mockTransferStore struct {
+	players [][]uint64
+}
+
+// returns an accepted transfer of each player.
+func (ts *mockTransferStore) ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error) {
+	ts.players = append(ts.players, playerIDs)
+	var transfers []store.Transfer
+	for _, id := range playerIDs {
+		transfers = append(transfers, store.Transfer{TransferID: id + 1, PlayerID: id, Status: store.TransferAccepted})
+	}
+	return transfers, nil
+}
+
+// records the requested rosters and matches.
+type mockMatchStore struct {
+	rosters [][]uint64
+	matches [][]uint64
+}
+
+// returns match 7 of rosters 1 and 2 and match 8 of roster 1.
+func (ms *mockMatchStore) ListRosters(ctx context.Context, rosterIDs []uint64) ([]store.Match, error) {
+	ms.rosters = append(ms.rosters, rosterIDs)
+	return []store.Match{
+		{MatchID: 7, RosterID: 1, OpponentID: 2},
+		{MatchID: 8, RosterID: 3, OpponentID: 1},
+	}, nil
+}
+
+// returns the lineup of roster 2 for match 7.
+func (ms *mockMatchStore) ListLineups(ctx context.Context, matchIDs []uint64) ([]store.Lineup, error) {
+	ms.matches = append(ms.matches, matchIDs)
+	return []store.Lineup{{MatchID: 7, RosterID: 2, Players: []store.Player{{PlayerID: 20, RosterID: 2, Status: store.Active}}}}, nil
+}
+
+var schemaTests = []struct {
+	d string               // description of test case
+	q string               // graphql request
+	f []store.PlayerFilter // expected calls of Find
+	a bool                 // whether the client is an admin
+	b string               // expected result
+}{
+	{
+		d: "expect players of all rosters to be loaded in one batch",
+		q: `{rosters{id players{id} active: players(status: ACTIVE){id}}}`,
+		f: []store.PlayerFilter{{RosterIDs: []uint64{1, 2}}},
+		b: `{"data":{"rosters":[{"active":[],"id":"1","players":[{"id":"10"}]},{"active":[],"id":"2","players":[{"id":"20"}]}]}}`,
+	},
+	{
+		d: "expect players of a single roster to be resolved from the roster",
+		q: `{roster(id: "382574876546039808"){name players(status: BENCHED){alias}}}`,
+		b: `{"data":{"roster":{"name":"foo","players":[{"alias":"Smaayo"}]}}}`,
+	},
+	{
+		d: "expect missing roster to be null",
+		q: `{roster(id: "1"){name}}`,
+		b: `{"data":{"roster":null}}`,
+	},
+	{
+		d: "expect players to be filtered",
+		q: `{players(rosterId: "2", status: BENCHED, alias: "foo"){id}}`,
+		f: []store.PlayerFilter{{RosterIDs: []uint64{2}, Status: store.Benched, Alias: "foo"}},
+		b: `{"data":{"players":[{"id":"20"}]}}`,
+	},
+	{
+		d: "expect invalid ids to be rejected",
+		q: `{roster(id: "foo"){name}}`,
+		b: `{"data":{"roster":null},"errors":[{"message":"id must be an unsigned integer","locations":[{"line":1,"column":2}],"path":["roster"]}]}`,
+	},
+	{
+		d: "expect new players to be benched",
+		q: `mutation{addPlayer(input: {rosterId: "1", firstName: "foo", lastName: "bar", alias: "foobar"}){id status}}`,
+		b: `{"data":{"addPlayer":{"id":"3","status":"BENCHED"}}}`,
+	},
+	{
+		d: "expect store errors to be reported",
+		q: `mutation{updatePlayer(id: "1", rosterId: "2"){id}}`,
+		a: true,
+		b: `{"data":null,"errors":[{"message":"not found","locations":[{"line":1,"column":10}],"path":["updatePlayer"]}]}`,
+	},
+	{
+		d: "expect players to be moved by admins only",
+		q: `mutation{updatePlayer(id: "1", rosterId: "2"){id}}`,
+		b: `{"data":null,"errors":[{"message":"only admins may move players to another roster, propose a transfer instead","locations":[{"line":1,"column":10}],"path":["updatePlayer"]}]}`,
+	},
+	{
+		d: "expect conflicts to be reported",
+		q: `mutation{swapPlayers(rosterId: "1", active: "2", benched: "3"){active{id}}}`,
+		b: `{"data":null,"errors":[{"message":"roster 1: player 1 must be active","locations":[{"line":1,"column":10}],"path":["swapPlayers"]}]}`,
+	},
+}
+
+func TestSchema(t *testing.T) {
+	for _, tc := range schemaTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			ps := &mockPlayerStore{}
+			s, err := NewSchema(&mockRosterStore{}, ps, &mockTransferStore{}, &mockMatchStore{})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			ctx := context.Background()
+			if tt.a {
+				ctx = auth.WithPrincipal(ctx, auth.Principal{Subject: "alice", Admin: true})
+			}
+			b, err := json.Marshal(s.Do(ctx, tt.q, "", nil))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if want, got := tt.b, string(b); want != got {
+				t.Errorf("want result\n%s\ngot\n%s", want, got)
+			}
+			if want, got := tt.f, ps.filters; !reflect.DeepEqual(want, got) {
+				t.Errorf("want calls of Find\n%+v\ngot\n%+v", want, got)
+			}
+		})
+	}
+}
+
+func TestMutationArguments(t *testing.T) {
+	ps := &mockPlayerStore{}
+	s, err := NewSchema(&mockRosterStore{}, ps, &mockTransferStore{}, &mockMatchStore{})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	s.Do(context.Background(), `mutation{addPlayer(input: {rosterId: "1", firstName: "foo", lastName: "bar", alias: "foobar"}){id}}`, "", nil)
+	if want, got := (store.Player{RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: store.Benched}), ps.inserted; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	s.Do(context.Background(), `mutation{swapPlayers(rosterId: "1", active: "2", benched: "3"){active{id}}}`, "", nil)
+	want := store.PlayerChange{
+		Active:  store.Player{PlayerID: 2, RosterID: 1},
+		Benched: store.Player{PlayerID: 3, RosterID: 1},
+	}
+	if got := ps.changed; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+}
+
+// resolves each level of the query with a single call of the stores
+func TestBatches(t *testing.T) {
+	ps, ts, ms := &mockPlayerStore{}, &mockTransferStore{}, &mockMatchStore{}
+	s, err := NewSchema(&mockRosterStore{}, ps, ts, ms)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	q := `{rosters{id matches{id lineups{rosterId players{id}}} players{id history{aliases{alias} transfers{id status}}}}}`
+	b, err := json.Marshal(s.Do(context.Background(), q, "", nil))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := `{"data":{"rosters":[` +
+		`{"id":"1","matches":[{"id":"7","lineups":[{"players":[{"id":"20"}],"rosterId":"2"}]},{"id":"8","lineups":[]}],` +
+		`"players":[{"history":{"aliases":[{"alias":"alias10"}],"transfers":[{"id":"11","status":"accepted"}]},"id":"10"}]},` +
+		`{"id":"2","matches":[{"id":"7","lineups":[{"players":[{"id":"20"}],"rosterId":"2"}]}],` +
+		`"players":[{"history":{"aliases":[{"alias":"alias20"}],"transfers":[{"id":"21","status":"accepted"}]},"id":"20"}]}]}}`
+	if got := string(b); want != got {
+		t.Errorf("want result\n%s\ngot\n%s", want, got)
+	}
+	calls := []struct {
+		d         string
+		want, got interface{}
+	}{
+		{"Find", []store.PlayerFilter{{RosterIDs: []uint64{1, 2}}}, ps.filters},
+		{"Aliases", [][]uint64{{10, 20}}, ps.aliases},
+		{"ListPlayers", [][]uint64{{10, 20}}, ts.players},
+		{"ListRosters", [][]uint64{{1, 2}}, ms.rosters},
+		{"ListLineups", [][]uint64{{7, 8}}, ms.matches},
+	}
+	for _, c := range calls {
+		if !reflect.DeepEqual(c.want, c.got) {
+			t.Errorf("want calls of %s %v got %v", c.d, c.want, c.got)
+		}
+	}
+}
+
+func TestIsMutation(t *testing.T) {
+	tests := map[string]struct {
+		q string // graphql request
+		o string // operation name
+		m bool   // expected result
+	}{
+		"query":          {q: `{rosters{id}}`},
+		"mutation":       {q: `mutation{addPlayer{id}}`, m: true},
+		"named mutation": {q: `query a{rosters{id}} mutation b{addPlayer{id}}`, o: "b", m: true},
+		"named query":    {q: `query a{rosters{id}} mutation b{addPlayer{id}}`, o: "a"},
+		"invalid":        {q: `mutation{`},
+	}
+	for d, tt := range tests {
+		if want, got := tt.m, IsMutation(tt.q, tt.o); want != got {
+			t.Errorf("%s: want %v got %v", d, want, got)
+		}
+	}
+}
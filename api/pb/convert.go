@@ -0,0 +1,149 @@
+// Package pb holds the protocol buffer representations of the store types.
+// The messages are generated from roster.proto, see the proto target of the
+// Makefile.
+package pb
+
+import (
+	"errors"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrUnsupportedType is returned for types without a protocol buffer
+// representation.
+var ErrUnsupportedType = errors.New("type has no protocol buffer representation")
+
+// FromPlayer converts a store player to its protocol buffer representation.
+func FromPlayer(p store.Player) *Player {
+	return &Player{
+		PlayerId:  p.PlayerID,
+		RosterId:  p.RosterID,
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		Alias:     p.Alias,
+		Status:    p.Status,
+	}
+}
+
+// Store converts the player to a store player.
+func (m *Player) Store() store.Player {
+	return store.Player{
+		PlayerID:  m.GetPlayerId(),
+		RosterID:  m.GetRosterId(),
+		FirstName: m.GetFirstName(),
+		LastName:  m.GetLastName(),
+		Alias:     m.GetAlias(),
+		Status:    m.GetStatus(),
+	}
+}
+
+// FromPlayerChange converts a store player change to its protocol buffer
+// representation.
+func FromPlayerChange(c store.PlayerChange) *PlayerChange {
+	return &PlayerChange{
+		Active:  FromPlayer(c.Active),
+		Benched: FromPlayer(c.Benched),
+	}
+}
+
+// Store converts the player change to a store player change.
+func (m *PlayerChange) Store() store.PlayerChange {
+	return store.PlayerChange{
+		Active:  m.GetActive().Store(),
+		Benched: m.GetBenched().Store(),
+	}
+}
+
+// FromPlayers converts a list of store players to its protocol buffer
+// representation.
+func FromPlayers(players []store.Player) *PlayerList {
+	l := &PlayerList{Players: make([]*Player, len(players))}
+	for i, p := range players {
+		l.Players[i] = FromPlayer(p)
+	}
+	return l
+}
+
+// Store converts the player list to a list of store players.
+func (m *PlayerList) Store() []store.Player {
+	players := make([]store.Player, len(m.GetPlayers()))
+	for i, p := range m.GetPlayers() {
+		players[i] = p.Store()
+	}
+	return players
+}
+
+// FromRoster converts a store roster to its protocol buffer representation.
+func FromRoster(r store.Roster) *Roster {
+	return &Roster{
+		RosterId: r.RosterID,
+		Name:     r.Name,
+		Players: &Players{
+			Active:  FromPlayers(r.Players.Active).Players,
+			Benched: FromPlayers(r.Players.Benched).Players,
+		},
+	}
+}
+
+// Store converts the roster to a store roster.
+func (m *Roster) Store() store.Roster {
+	return store.Roster{
+		RosterID: m.GetRosterId(),
+		Name:     m.GetName(),
+		Players: store.Players{
+			Active:  (&PlayerList{Players: m.GetPlayers().GetActive()}).Store(),
+			Benched: (&PlayerList{Players: m.GetPlayers().GetBenched()}).Store(),
+		},
+	}
+}
+
+// Message returns the protocol buffer representation of a store value. Returns
+// false for unsupported types.
+func Message(v interface{}) (proto.Message, bool) {
+	switch t := v.(type) {
+	case store.Player:
+		return FromPlayer(t), true
+	case *store.Player:
+		return FromPlayer(*t), true
+	case store.PlayerChange:
+		return FromPlayerChange(t), true
+	case *store.PlayerChange:
+		return FromPlayerChange(*t), true
+	case []store.Player:
+		return FromPlayers(t), true
+	case store.Roster:
+		return FromRoster(t), true
+	case *store.Roster:
+		return FromRoster(*t), true
+	}
+	return nil, false
+}
+
+// Unmarshal decodes the protocol buffer representation of a store value into
+// v, which must be a pointer to a supported store type.
+func Unmarshal(b []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *store.Player:
+		var m Player
+		if err := proto.Unmarshal(b, &m); err != nil {
+			return err
+		}
+		*t = m.Store()
+	case *store.PlayerChange:
+		var m PlayerChange
+		if err := proto.Unmarshal(b, &m); err != nil {
+			return err
+		}
+		*t = m.Store()
+	case *store.Roster:
+		var m Roster
+		if err := proto.Unmarshal(b, &m); err != nil {
+			return err
+		}
+		*t = m.Store()
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
@@ -0,0 +1,777 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: api/pb/roster.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Player represents a player of a roster.
+type Player struct {
+	PlayerId             uint64   `protobuf:"varint,1,opt,name=player_id,json=playerId,proto3" json:"player_id,omitempty"`
+	RosterId             uint64   `protobuf:"varint,2,opt,name=roster_id,json=rosterId,proto3" json:"roster_id,omitempty"`
+	FirstName            string   `protobuf:"bytes,3,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName             string   `protobuf:"bytes,4,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Alias                string   `protobuf:"bytes,5,opt,name=alias,proto3" json:"alias,omitempty"`
+	Status               string   `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Player) Reset()         { *m = Player{} }
+func (m *Player) String() string { return proto.CompactTextString(m) }
+func (*Player) ProtoMessage()    {}
+func (*Player) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{0}
+}
+
+func (m *Player) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Player.Unmarshal(m, b)
+}
+func (m *Player) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Player.Marshal(b, m, deterministic)
+}
+func (m *Player) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Player.Merge(m, src)
+}
+func (m *Player) XXX_Size() int {
+	return xxx_messageInfo_Player.Size(m)
+}
+func (m *Player) XXX_DiscardUnknown() {
+	xxx_messageInfo_Player.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Player proto.InternalMessageInfo
+
+func (m *Player) GetPlayerId() uint64 {
+	if m != nil {
+		return m.PlayerId
+	}
+	return 0
+}
+
+func (m *Player) GetRosterId() uint64 {
+	if m != nil {
+		return m.RosterId
+	}
+	return 0
+}
+
+func (m *Player) GetFirstName() string {
+	if m != nil {
+		return m.FirstName
+	}
+	return ""
+}
+
+func (m *Player) GetLastName() string {
+	if m != nil {
+		return m.LastName
+	}
+	return ""
+}
+
+func (m *Player) GetAlias() string {
+	if m != nil {
+		return m.Alias
+	}
+	return ""
+}
+
+func (m *Player) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+// PlayerChange holds two players of a roster whose statuses get swapped.
+type PlayerChange struct {
+	Active               *Player  `protobuf:"bytes,1,opt,name=active,proto3" json:"active,omitempty"`
+	Benched              *Player  `protobuf:"bytes,2,opt,name=benched,proto3" json:"benched,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PlayerChange) Reset()         { *m = PlayerChange{} }
+func (m *PlayerChange) String() string { return proto.CompactTextString(m) }
+func (*PlayerChange) ProtoMessage()    {}
+func (*PlayerChange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{1}
+}
+
+func (m *PlayerChange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PlayerChange.Unmarshal(m, b)
+}
+func (m *PlayerChange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PlayerChange.Marshal(b, m, deterministic)
+}
+func (m *PlayerChange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PlayerChange.Merge(m, src)
+}
+func (m *PlayerChange) XXX_Size() int {
+	return xxx_messageInfo_PlayerChange.Size(m)
+}
+func (m *PlayerChange) XXX_DiscardUnknown() {
+	xxx_messageInfo_PlayerChange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PlayerChange proto.InternalMessageInfo
+
+func (m *PlayerChange) GetActive() *Player {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+func (m *PlayerChange) GetBenched() *Player {
+	if m != nil {
+		return m.Benched
+	}
+	return nil
+}
+
+// Players holds the players of a roster by status.
+type Players struct {
+	Active               []*Player `protobuf:"bytes,1,rep,name=active,proto3" json:"active,omitempty"`
+	Benched              []*Player `protobuf:"bytes,2,rep,name=benched,proto3" json:"benched,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Players) Reset()         { *m = Players{} }
+func (m *Players) String() string { return proto.CompactTextString(m) }
+func (*Players) ProtoMessage()    {}
+func (*Players) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{2}
+}
+
+func (m *Players) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Players.Unmarshal(m, b)
+}
+func (m *Players) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Players.Marshal(b, m, deterministic)
+}
+func (m *Players) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Players.Merge(m, src)
+}
+func (m *Players) XXX_Size() int {
+	return xxx_messageInfo_Players.Size(m)
+}
+func (m *Players) XXX_DiscardUnknown() {
+	xxx_messageInfo_Players.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Players proto.InternalMessageInfo
+
+func (m *Players) GetActive() []*Player {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+func (m *Players) GetBenched() []*Player {
+	if m != nil {
+		return m.Benched
+	}
+	return nil
+}
+
+// PlayerList holds a list of players, e.g. all active players of a roster.
+type PlayerList struct {
+	Players              []*Player `protobuf:"bytes,1,rep,name=players,proto3" json:"players,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *PlayerList) Reset()         { *m = PlayerList{} }
+func (m *PlayerList) String() string { return proto.CompactTextString(m) }
+func (*PlayerList) ProtoMessage()    {}
+func (*PlayerList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{3}
+}
+
+func (m *PlayerList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PlayerList.Unmarshal(m, b)
+}
+func (m *PlayerList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PlayerList.Marshal(b, m, deterministic)
+}
+func (m *PlayerList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PlayerList.Merge(m, src)
+}
+func (m *PlayerList) XXX_Size() int {
+	return xxx_messageInfo_PlayerList.Size(m)
+}
+func (m *PlayerList) XXX_DiscardUnknown() {
+	xxx_messageInfo_PlayerList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PlayerList proto.InternalMessageInfo
+
+func (m *PlayerList) GetPlayers() []*Player {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
+// Roster represents a roster with all of its players.
+type Roster struct {
+	RosterId             uint64   `protobuf:"varint,1,opt,name=roster_id,json=rosterId,proto3" json:"roster_id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Players              *Players `protobuf:"bytes,3,opt,name=players,proto3" json:"players,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Roster) Reset()         { *m = Roster{} }
+func (m *Roster) String() string { return proto.CompactTextString(m) }
+func (*Roster) ProtoMessage()    {}
+func (*Roster) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{4}
+}
+
+func (m *Roster) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Roster.Unmarshal(m, b)
+}
+func (m *Roster) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Roster.Marshal(b, m, deterministic)
+}
+func (m *Roster) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Roster.Merge(m, src)
+}
+func (m *Roster) XXX_Size() int {
+	return xxx_messageInfo_Roster.Size(m)
+}
+func (m *Roster) XXX_DiscardUnknown() {
+	xxx_messageInfo_Roster.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Roster proto.InternalMessageInfo
+
+func (m *Roster) GetRosterId() uint64 {
+	if m != nil {
+		return m.RosterId
+	}
+	return 0
+}
+
+func (m *Roster) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Roster) GetPlayers() *Players {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
+type GetRosterRequest struct {
+	RosterId             uint64   `protobuf:"varint,1,opt,name=roster_id,json=rosterId,proto3" json:"roster_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRosterRequest) Reset()         { *m = GetRosterRequest{} }
+func (m *GetRosterRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRosterRequest) ProtoMessage()    {}
+func (*GetRosterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{5}
+}
+
+func (m *GetRosterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRosterRequest.Unmarshal(m, b)
+}
+func (m *GetRosterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRosterRequest.Marshal(b, m, deterministic)
+}
+func (m *GetRosterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRosterRequest.Merge(m, src)
+}
+func (m *GetRosterRequest) XXX_Size() int {
+	return xxx_messageInfo_GetRosterRequest.Size(m)
+}
+func (m *GetRosterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRosterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRosterRequest proto.InternalMessageInfo
+
+func (m *GetRosterRequest) GetRosterId() uint64 {
+	if m != nil {
+		return m.RosterId
+	}
+	return 0
+}
+
+type ListPlayersRequest struct {
+	RosterId uint64 `protobuf:"varint,1,opt,name=roster_id,json=rosterId,proto3" json:"roster_id,omitempty"`
+	// active or benched, all players are returned if empty
+	Status               string   `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPlayersRequest) Reset()         { *m = ListPlayersRequest{} }
+func (m *ListPlayersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPlayersRequest) ProtoMessage()    {}
+func (*ListPlayersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{6}
+}
+
+func (m *ListPlayersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPlayersRequest.Unmarshal(m, b)
+}
+func (m *ListPlayersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPlayersRequest.Marshal(b, m, deterministic)
+}
+func (m *ListPlayersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPlayersRequest.Merge(m, src)
+}
+func (m *ListPlayersRequest) XXX_Size() int {
+	return xxx_messageInfo_ListPlayersRequest.Size(m)
+}
+func (m *ListPlayersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPlayersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPlayersRequest proto.InternalMessageInfo
+
+func (m *ListPlayersRequest) GetRosterId() uint64 {
+	if m != nil {
+		return m.RosterId
+	}
+	return 0
+}
+
+func (m *ListPlayersRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+type WatchRosterRequest struct {
+	RosterId             uint64   `protobuf:"varint,1,opt,name=roster_id,json=rosterId,proto3" json:"roster_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRosterRequest) Reset()         { *m = WatchRosterRequest{} }
+func (m *WatchRosterRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRosterRequest) ProtoMessage()    {}
+func (*WatchRosterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c9aa31d0a7e07f9, []int{7}
+}
+
+func (m *WatchRosterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRosterRequest.Unmarshal(m, b)
+}
+func (m *WatchRosterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRosterRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRosterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRosterRequest.Merge(m, src)
+}
+func (m *WatchRosterRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRosterRequest.Size(m)
+}
+func (m *WatchRosterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRosterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRosterRequest proto.InternalMessageInfo
+
+func (m *WatchRosterRequest) GetRosterId() uint64 {
+	if m != nil {
+		return m.RosterId
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Player)(nil), "roster.Player")
+	proto.RegisterType((*PlayerChange)(nil), "roster.PlayerChange")
+	proto.RegisterType((*Players)(nil), "roster.Players")
+	proto.RegisterType((*PlayerList)(nil), "roster.PlayerList")
+	proto.RegisterType((*Roster)(nil), "roster.Roster")
+	proto.RegisterType((*GetRosterRequest)(nil), "roster.GetRosterRequest")
+	proto.RegisterType((*ListPlayersRequest)(nil), "roster.ListPlayersRequest")
+	proto.RegisterType((*WatchRosterRequest)(nil), "roster.WatchRosterRequest")
+}
+
+func init() { proto.RegisterFile("api/pb/roster.proto", fileDescriptor_5c9aa31d0a7e07f9) }
+
+var fileDescriptor_5c9aa31d0a7e07f9 = []byte{
+	// 452 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6b, 0xd4, 0x40,
+	0x14, 0x25, 0xbb, 0xdb, 0xb4, 0xb9, 0xdb, 0xaa, 0x5c, 0x8b, 0x84, 0x88, 0x50, 0x02, 0xea, 0x8a,
+	0xb0, 0xd1, 0x8a, 0xbe, 0x2c, 0x3e, 0xa8, 0x0f, 0xb2, 0x20, 0x22, 0x11, 0x11, 0xf4, 0xa1, 0x4e,
+	0x92, 0x69, 0x32, 0x90, 0x2f, 0x67, 0x66, 0x0b, 0xfe, 0x27, 0x7f, 0x92, 0x3f, 0x46, 0x32, 0x37,
+	0xe9, 0x6e, 0xb2, 0x5a, 0xf2, 0x96, 0x7b, 0xce, 0xfd, 0x3a, 0xe7, 0x86, 0x81, 0xbb, 0xac, 0x16,
+	0x41, 0x1d, 0x05, 0xb2, 0x52, 0x9a, 0xcb, 0x65, 0x2d, 0x2b, 0x5d, 0xa1, 0x4d, 0x91, 0xff, 0xdb,
+	0x02, 0xfb, 0x53, 0xce, 0x7e, 0x71, 0x89, 0xf7, 0xc1, 0xa9, 0xcd, 0xd7, 0x85, 0x48, 0x5c, 0xeb,
+	0xcc, 0x5a, 0xcc, 0xc2, 0x23, 0x02, 0xd6, 0x49, 0x43, 0x52, 0x45, 0x43, 0x4e, 0x88, 0x24, 0x60,
+	0x9d, 0xe0, 0x03, 0x80, 0x4b, 0x21, 0x95, 0xbe, 0x28, 0x59, 0xc1, 0xdd, 0xe9, 0x99, 0xb5, 0x70,
+	0x42, 0xc7, 0x20, 0x1f, 0x59, 0xc1, 0x9b, 0xda, 0x9c, 0x75, 0xec, 0xcc, 0xb0, 0x47, 0x39, 0x6b,
+	0xc9, 0x53, 0x38, 0x60, 0xb9, 0x60, 0xca, 0x3d, 0x30, 0x04, 0x05, 0x78, 0x0f, 0x6c, 0xa5, 0x99,
+	0xde, 0x28, 0xd7, 0x36, 0x70, 0x1b, 0xf9, 0x3f, 0xe0, 0x98, 0xb6, 0x7d, 0x97, 0xb1, 0x32, 0xe5,
+	0xf8, 0x08, 0x6c, 0x16, 0x6b, 0x71, 0xc5, 0xcd, 0xc2, 0xf3, 0xf3, 0x5b, 0xcb, 0x56, 0x25, 0x65,
+	0x85, 0x2d, 0x8b, 0x0b, 0x38, 0x8c, 0x78, 0x19, 0x67, 0x9c, 0x96, 0xdf, 0x4f, 0xec, 0x68, 0xff,
+	0x3b, 0x1c, 0x12, 0xa4, 0x7a, 0xcd, 0xa7, 0x63, 0x9b, 0x4f, 0x6f, 0x6a, 0xfe, 0x0a, 0x80, 0xa0,
+	0x0f, 0x42, 0xe9, 0xa6, 0x8e, 0xfc, 0x55, 0xff, 0x19, 0xd0, 0xd1, 0x7e, 0x02, 0x76, 0x68, 0x98,
+	0xfe, 0x1d, 0xac, 0xc1, 0x1d, 0x10, 0x66, 0xc6, 0xe3, 0x89, 0xf1, 0xcc, 0x7c, 0xe3, 0x93, 0xed,
+	0x90, 0xa9, 0x51, 0x7e, 0xbb, 0x3f, 0x44, 0x6d, 0xa7, 0x04, 0x70, 0xe7, 0x3d, 0xd7, 0x34, 0x28,
+	0xe4, 0x3f, 0x37, 0x5c, 0xe9, 0x1b, 0xe7, 0xf9, 0x6b, 0xc0, 0x46, 0x48, 0xd7, 0x68, 0x44, 0xc9,
+	0xce, 0x61, 0x27, 0xbd, 0xc3, 0x3e, 0x07, 0xfc, 0xca, 0x74, 0x9c, 0x8d, 0x9f, 0x7e, 0xfe, 0x67,
+	0x02, 0x27, 0x94, 0xfe, 0x99, 0xcb, 0x2b, 0x11, 0x73, 0x7c, 0x09, 0xce, 0xb5, 0x00, 0x74, 0x3b,
+	0x9d, 0x43, 0x4d, 0xde, 0xb5, 0xcd, 0x6d, 0xe6, 0x6b, 0x98, 0xef, 0xc8, 0x40, 0xaf, 0xa3, 0xf7,
+	0xb5, 0x79, 0xd8, 0x37, 0xcf, 0x9c, 0xf1, 0x29, 0x38, 0x6f, 0x92, 0x84, 0x00, 0x1c, 0x9c, 0xd0,
+	0x1b, 0xc4, 0xb8, 0x84, 0xe3, 0x2f, 0x75, 0xc2, 0x34, 0x1f, 0x99, 0xbf, 0x82, 0x13, 0xfa, 0xd5,
+	0xbb, 0xed, 0x4e, 0xfb, 0x09, 0x44, 0x7a, 0xff, 0x44, 0x71, 0x05, 0xf3, 0x1d, 0x53, 0xb7, 0xc2,
+	0xf6, 0x9d, 0x1e, 0x7a, 0xf2, 0xcc, 0x7a, 0xfb, 0xf8, 0xdb, 0xc3, 0x54, 0xe8, 0x6c, 0x13, 0x2d,
+	0xe3, 0xaa, 0x08, 0x2e, 0x53, 0x29, 0x8a, 0x82, 0x07, 0x35, 0xd3, 0xb2, 0x2a, 0xd3, 0x34, 0xa0,
+	0x47, 0x65, 0x55, 0x47, 0x91, 0x6d, 0x5e, 0x94, 0x17, 0x7f, 0x07, 0x00, 0xed, 0xb0, 0x17, 0xa6,
+	0x68, 0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RosterServiceClient is the client API for RosterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RosterServiceClient interface {
+	// GetRoster returns the roster with all of its players.
+	GetRoster(ctx context.Context, in *GetRosterRequest, opts ...grpc.CallOption) (*Roster, error)
+	// ListPlayers returns the players of a roster, optionally filtered by status.
+	ListPlayers(ctx context.Context, in *ListPlayersRequest, opts ...grpc.CallOption) (*PlayerList, error)
+	// AddPlayer adds a new player. New players are benched, the player id is
+	// generated.
+	AddPlayer(ctx context.Context, in *Player, opts ...grpc.CallOption) (*Player, error)
+	// UpdatePlayer is rejected, players are moved to another roster by
+	// transfers of the HTTP API.
+	UpdatePlayer(ctx context.Context, in *Player, opts ...grpc.CallOption) (*Player, error)
+	// ChangePlayers swaps the statuses of an active and a benched player of the
+	// same roster.
+	ChangePlayers(ctx context.Context, in *PlayerChange, opts ...grpc.CallOption) (*PlayerChange, error)
+	// WatchRoster streams the roster whenever it changes, starting with its
+	// current state.
+	WatchRoster(ctx context.Context, in *WatchRosterRequest, opts ...grpc.CallOption) (RosterService_WatchRosterClient, error)
+}
+
+type rosterServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRosterServiceClient(cc *grpc.ClientConn) RosterServiceClient {
+	return &rosterServiceClient{cc}
+}
+
+func (c *rosterServiceClient) GetRoster(ctx context.Context, in *GetRosterRequest, opts ...grpc.CallOption) (*Roster, error) {
+	out := new(Roster)
+	err := c.cc.Invoke(ctx, "/roster.RosterService/GetRoster", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rosterServiceClient) ListPlayers(ctx context.Context, in *ListPlayersRequest, opts ...grpc.CallOption) (*PlayerList, error) {
+	out := new(PlayerList)
+	err := c.cc.Invoke(ctx, "/roster.RosterService/ListPlayers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rosterServiceClient) AddPlayer(ctx context.Context, in *Player, opts ...grpc.CallOption) (*Player, error) {
+	out := new(Player)
+	err := c.cc.Invoke(ctx, "/roster.RosterService/AddPlayer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rosterServiceClient) UpdatePlayer(ctx context.Context, in *Player, opts ...grpc.CallOption) (*Player, error) {
+	out := new(Player)
+	err := c.cc.Invoke(ctx, "/roster.RosterService/UpdatePlayer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rosterServiceClient) ChangePlayers(ctx context.Context, in *PlayerChange, opts ...grpc.CallOption) (*PlayerChange, error) {
+	out := new(PlayerChange)
+	err := c.cc.Invoke(ctx, "/roster.RosterService/ChangePlayers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rosterServiceClient) WatchRoster(ctx context.Context, in *WatchRosterRequest, opts ...grpc.CallOption) (RosterService_WatchRosterClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RosterService_serviceDesc.Streams[0], "/roster.RosterService/WatchRoster", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rosterServiceWatchRosterClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RosterService_WatchRosterClient interface {
+	Recv() (*Roster, error)
+	grpc.ClientStream
+}
+
+type rosterServiceWatchRosterClient struct {
+	grpc.ClientStream
+}
+
+func (x *rosterServiceWatchRosterClient) Recv() (*Roster, error) {
+	m := new(Roster)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RosterServiceServer is the server API for RosterService service.
+type RosterServiceServer interface {
+	// GetRoster returns the roster with all of its players.
+	GetRoster(context.Context, *GetRosterRequest) (*Roster, error)
+	// ListPlayers returns the players of a roster, optionally filtered by status.
+	ListPlayers(context.Context, *ListPlayersRequest) (*PlayerList, error)
+	// AddPlayer adds a new player. New players are benched, the player id is
+	// generated.
+	AddPlayer(context.Context, *Player) (*Player, error)
+	// UpdatePlayer is rejected, players are moved to another roster by
+	// transfers of the HTTP API.
+	UpdatePlayer(context.Context, *Player) (*Player, error)
+	// ChangePlayers swaps the statuses of an active and a benched player of the
+	// same roster.
+	ChangePlayers(context.Context, *PlayerChange) (*PlayerChange, error)
+	// WatchRoster streams the roster whenever it changes, starting with its
+	// current state.
+	WatchRoster(*WatchRosterRequest, RosterService_WatchRosterServer) error
+}
+
+// UnimplementedRosterServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedRosterServiceServer struct {
+}
+
+func (*UnimplementedRosterServiceServer) GetRoster(ctx context.Context, req *GetRosterRequest) (*Roster, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoster not implemented")
+}
+func (*UnimplementedRosterServiceServer) ListPlayers(ctx context.Context, req *ListPlayersRequest) (*PlayerList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPlayers not implemented")
+}
+func (*UnimplementedRosterServiceServer) AddPlayer(ctx context.Context, req *Player) (*Player, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPlayer not implemented")
+}
+func (*UnimplementedRosterServiceServer) UpdatePlayer(ctx context.Context, req *Player) (*Player, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePlayer not implemented")
+}
+func (*UnimplementedRosterServiceServer) ChangePlayers(ctx context.Context, req *PlayerChange) (*PlayerChange, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePlayers not implemented")
+}
+func (*UnimplementedRosterServiceServer) WatchRoster(req *WatchRosterRequest, srv RosterService_WatchRosterServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRoster not implemented")
+}
+
+func RegisterRosterServiceServer(s *grpc.Server, srv RosterServiceServer) {
+	s.RegisterService(&_RosterService_serviceDesc, srv)
+}
+
+func _RosterService_GetRoster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRosterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RosterServiceServer).GetRoster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/roster.RosterService/GetRoster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RosterServiceServer).GetRoster(ctx, req.(*GetRosterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RosterService_ListPlayers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPlayersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RosterServiceServer).ListPlayers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/roster.RosterService/ListPlayers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RosterServiceServer).ListPlayers(ctx, req.(*ListPlayersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RosterService_AddPlayer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Player)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RosterServiceServer).AddPlayer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/roster.RosterService/AddPlayer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RosterServiceServer).AddPlayer(ctx, req.(*Player))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RosterService_UpdatePlayer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Player)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RosterServiceServer).UpdatePlayer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/roster.RosterService/UpdatePlayer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RosterServiceServer).UpdatePlayer(ctx, req.(*Player))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RosterService_ChangePlayers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlayerChange)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RosterServiceServer).ChangePlayers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/roster.RosterService/ChangePlayers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RosterServiceServer).ChangePlayers(ctx, req.(*PlayerChange))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RosterService_WatchRoster_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRosterRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RosterServiceServer).WatchRoster(m, &rosterServiceWatchRosterServer{stream})
+}
+
+type RosterService_WatchRosterServer interface {
+	Send(*Roster) error
+	grpc.ServerStream
+}
+
+type rosterServiceWatchRosterServer struct {
+	grpc.ServerStream
+}
+
+func (x *rosterServiceWatchRosterServer) Send(m *Roster) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _RosterService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "roster.RosterService",
+	HandlerType: (*RosterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRoster",
+			Handler:    _RosterService_GetRoster_Handler,
+		},
+		{
+			MethodName: "ListPlayers",
+			Handler:    _RosterService_ListPlayers_Handler,
+		},
+		{
+			MethodName: "AddPlayer",
+			Handler:    _RosterService_AddPlayer_Handler,
+		},
+		{
+			MethodName: "UpdatePlayer",
+			Handler:    _RosterService_UpdatePlayer_Handler,
+		},
+		{
+			MethodName: "ChangePlayers",
+			Handler:    _RosterService_ChangePlayers_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRoster",
+			Handler:       _RosterService_WatchRoster_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/pb/roster.proto",
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unaryInterceptor attaches the logger to the request context, recovers from
+// panics and logs every request, like the middleware of the HTTP server.
+func unaryInterceptor(logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		l := logger.With().Str("method", info.FullMethod).Logger()
+		ctx = l.WithContext(ctx)
+		start := time.Now()
+		defer func() {
+			if p := recover(); p != nil {
+				l.Error().Interface("err", p).Msg("PANIC")
+				err = status.Error(codes.Internal, "internal error")
+			}
+			access(l, err, start)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor is the equivalent of unaryInterceptor for streams.
+func streamInterceptor(logger zerolog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		l := logger.With().Str("method", info.FullMethod).Logger()
+		ctx := l.WithContext(ss.Context())
+		start := time.Now()
+		defer func() {
+			if p := recover(); p != nil {
+				l.Error().Interface("err", p).Msg("PANIC")
+				err = status.Error(codes.Internal, "internal error")
+			}
+			access(l, err, start)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// access logs a finished request.
+func access(logger zerolog.Logger, err error, start time.Time) {
+	logger.Info().
+		Str("code", status.Code(err).String()).
+		Dur("duration", time.Since(start)).
+		Msg("")
+}
+
+// serverStream overrides the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,75 @@
+// Package rpc provides the gRPC API of the roster service.
+package rpc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/fgrimme/patrongg/api/pb"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// GRPCServer represents a grpc-server.
+type GRPCServer struct {
+	server   *grpc.Server
+	addr     string
+	shutdown chan struct{}
+	logger   zerolog.Logger
+}
+
+// New returns a GRPCServer instance with the roster service registered.
+func New(grpcAddr string, timeout time.Duration, rs rosterStore, ps playerStore, events subscriber, logger zerolog.Logger) (*GRPCServer, error) {
+	shutdown := make(chan struct{})
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor(logger)),
+		grpc.StreamInterceptor(streamInterceptor(logger)),
+	)
+	pb.RegisterRosterServiceServer(server, &rosterService{
+		rs:       rs,
+		ps:       ps,
+		events:   events,
+		timeout:  timeout,
+		shutdown: shutdown,
+	})
+	return &GRPCServer{
+		server:   server,
+		addr:     grpcAddr,
+		shutdown: shutdown,
+		logger:   logger,
+	}, nil
+}
+
+func (s *GRPCServer) Run() {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.logger.Fatal().Err(err).Msg("grpc server failed to listen")
+	}
+	s.logger.Info().Msgf("grpc server listening on %s", s.addr)
+	if err := s.server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		s.logger.Fatal().Err(err).Msg("grpc server exited with error")
+	}
+}
+
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	s.logger.Info().Msg("shutting down grpc server")
+
+	// streams watching rosters never finish on their own, we end them first
+	close(s.shutdown)
+
+	// this stops accepting new requests and waits for the running ones to
+	// finish before returning. if the context expires first, the remaining
+	// requests are canceled.
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Error().Err(ctx.Err()).Msg("grpc server shutdown error")
+		s.server.Stop()
+	}
+}
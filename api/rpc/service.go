@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fgrimme/patrongg/api/pb"
+	"github.com/fgrimme/patrongg/events"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rosterStore handles operations on rosters.
+type rosterStore interface {
+	Get(ctx context.Context, rosterID uint64) (*store.Roster, error)
+}
+
+// playerStore provides methods to operate on the players store.
+type playerStore interface {
+	Insert(ctx context.Context, player store.Player) (*store.Player, error)
+	ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error)
+}
+
+// subscriber provides notifications about roster changes.
+type subscriber interface {
+	Subscribe(rosterID uint64) (<-chan events.Event, func())
+}
+
+// rosterService implements the gRPC roster service on top of the stores. It
+// mirrors the behavior of the HTTP API.
+type rosterService struct {
+	rs       rosterStore
+	ps       playerStore
+	events   subscriber
+	timeout  time.Duration
+	shutdown <-chan struct{} // closed when the server shuts down
+}
+
+// GetRoster returns the roster with all of its players.
+func (s *rosterService) GetRoster(ctx context.Context, req *pb.GetRosterRequest) (*pb.Roster, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	roster, err := s.rs.Get(ctx, req.GetRosterId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return pb.FromRoster(*roster), nil
+}
+
+// ListPlayers returns the players of a roster with the requested status or all
+// players if no status is given.
+func (s *rosterService) ListPlayers(ctx context.Context, req *pb.ListPlayersRequest) (*pb.PlayerList, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	roster, err := s.rs.Get(ctx, req.GetRosterId())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	switch req.GetStatus() {
+	case store.Active:
+		return pb.FromPlayers(roster.Players.Active), nil
+	case store.Benched:
+		return pb.FromPlayers(roster.Players.Benched), nil
+	case "":
+		// rosters might be shared, so we must not append to their players
+		players := make([]store.Player, 0, len(roster.Players.Active)+len(roster.Players.Benched))
+		players = append(append(players, roster.Players.Active...), roster.Players.Benched...)
+		return pb.FromPlayers(players), nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "status must be %s or %s", store.Active, store.Benched)
+}
+
+// AddPlayer adds a new player. New players are benched by default and the
+// player id is generated by the datastore.
+func (s *rosterService) AddPlayer(ctx context.Context, req *pb.Player) (*pb.Player, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	player := req.Store()
+	player.PlayerID = 0
+	player.Status = store.Benched
+	p, err := s.ps.Insert(ctx, player)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return pb.FromPlayer(*p), nil
+}
+
+// UpdatePlayer is rejected: players are moved to another roster by accepted
+// transfers of the HTTP API, and clients of the gRPC API are not
+// authenticated to move them directly like admins.
+func (s *rosterService) UpdatePlayer(ctx context.Context, req *pb.Player) (*pb.Player, error) {
+	return nil, status.Error(codes.PermissionDenied, "players are moved to another roster by transfers")
+}
+
+// ChangePlayers swaps the statuses of an active and a benched player.
+func (s *rosterService) ChangePlayers(ctx context.Context, req *pb.PlayerChange) (*pb.PlayerChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	p, err := s.ps.ChangePlayers(ctx, req.Store())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return pb.FromPlayerChange(*p), nil
+}
+
+// WatchRoster sends the current state of the roster and then a new state
+// whenever the roster changes, until the client cancels the stream or the
+// server shuts down.
+func (s *rosterService) WatchRoster(req *pb.WatchRosterRequest, stream pb.RosterService_WatchRosterServer) error {
+	ctx := stream.Context()
+	// we subscribe before reading the roster to not miss changes
+	changes, cancel := s.events.Subscribe(req.GetRosterId())
+	defer cancel()
+
+	send := func() error {
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		roster, err := s.rs.Get(ctx, req.GetRosterId())
+		if err != nil {
+			return statusError(ctx, err)
+		}
+		return stream.Send(pb.FromRoster(*roster))
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-changes:
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statusError converts store errors to gRPC status errors. Unknown errors are
+// logged and hidden from the client.
+func statusError(ctx context.Context, err error) error {
+	var conflict *store.ConflictError
+	var locked *store.LockedError
+	var roleErr *store.RoleError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.As(err, &conflict):
+		return status.Error(codes.FailedPrecondition, conflict.Error())
+	case errors.As(err, &locked):
+		return status.Error(codes.FailedPrecondition, locked.Error())
+	case errors.As(err, &roleErr):
+		return status.Error(codes.FailedPrecondition, roleErr.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "canceled")
+	}
+	log.Ctx(ctx).Error().Err(err).Msg("unexpected grpc error")
+	return status.Error(codes.Internal, "internal error")
+}
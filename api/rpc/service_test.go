@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/api/pb"
+	"github.com/fgrimme/patrongg/events"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/testdata"
+	"github.com/golang/protobuf/proto"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// uses the roster id to get the test data.
+type mockRosterStore struct {
+	rosters map[uint64]*store.Roster
+}
+
+func (rs *mockRosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster, error) {
+	if r, ok := rs.rosters[rosterID]; ok {
+		return r, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+// records the players it gets called with.
+type mockPlayerStore struct {
+	inserted store.Player
+	err      error
+}
+
+func (ps *mockPlayerStore) Insert(ctx context.Context, player store.Player) (*store.Player, error) {
+	ps.inserted = player
+	player.PlayerID = 1
+	return &player, ps.err
+}
+
+func (ps *mockPlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
+	return nil, ps.err
+}
+
+// newClient starts a server on an in-memory connection and returns a client.
+func newClient(t *testing.T, rs rosterStore, ps playerStore, b *events.Broker) (pb.RosterServiceClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	s, err := New("bufconn", 200*time.Millisecond, rs, ps, b, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	go s.server.Serve(lis)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	return pb.NewRosterServiceClient(conn), func() {
+		conn.Close()
+		s.Shutdown(context.Background())
+	}
+}
+
+func TestGetRoster(t *testing.T) {
+	roster := testdata.Rosters[382574876546039808].R
+	rs := &mockRosterStore{rosters: map[uint64]*store.Roster{roster.RosterID: roster}}
+	c, stop := newClient(t, rs, &mockPlayerStore{}, events.NewBroker())
+	defer stop()
+
+	got, err := c.GetRoster(context.Background(), &pb.GetRosterRequest{RosterId: roster.RosterID})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want := pb.FromRoster(*roster); !proto.Equal(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	_, err = c.GetRoster(context.Background(), &pb.GetRosterRequest{RosterId: 1})
+	if want, got := codes.NotFound, status.Code(err); want != got {
+		t.Errorf("want code %s got %s", want, got)
+	}
+
+	list, err := c.ListPlayers(context.Background(), &pb.ListPlayersRequest{RosterId: roster.RosterID, Status: "benched"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want := pb.FromPlayers(roster.Players.Benched); !proto.Equal(want, list) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, list)
+	}
+}
+
+func TestPlayers(t *testing.T) {
+	ps := &mockPlayerStore{}
+	c, stop := newClient(t, &mockRosterStore{}, ps, events.NewBroker())
+	defer stop()
+
+	// new players are benched and ids are generated
+	_, err := c.AddPlayer(context.Background(), &pb.Player{PlayerId: 7, RosterId: 1, Alias: "foo", Status: "active"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want, got := (store.Player{RosterID: 1, Alias: "foo", Status: "benched"}), ps.inserted; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	// players are moved to another roster by transfers only
+	_, err = c.UpdatePlayer(context.Background(), &pb.Player{PlayerId: 7, RosterId: 2})
+	if want, got := codes.PermissionDenied, status.Code(err); want != got {
+		t.Errorf("want code %s got %s", want, got)
+	}
+
+	// conflicts are reported as failed preconditions
+	ps.err = &store.ConflictError{RosterID: 1, Reason: "player 1 must be active"}
+	_, err = c.ChangePlayers(context.Background(), &pb.PlayerChange{})
+	if want, got := codes.FailedPrecondition, status.Code(err); want != got {
+		t.Errorf("want code %s got %s", want, got)
+	}
+}
+
+func TestWatchRoster(t *testing.T) {
+	roster := *testdata.Rosters[382574876546039808].R
+	rs := &mockRosterStore{rosters: map[uint64]*store.Roster{roster.RosterID: &roster}}
+	b := events.NewBroker()
+	c, stop := newClient(t, rs, &mockPlayerStore{}, b)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.WatchRoster(ctx, &pb.WatchRosterRequest{RosterId: roster.RosterID})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// the current state is sent first
+	got, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want, got := "foo", got.GetName(); want != got {
+		t.Errorf("want roster name %s got %s", want, got)
+	}
+
+	// the roster is sent again after a change
+	changed := roster
+	changed.Name = "bar"
+	rs.rosters[roster.RosterID] = &changed
+	b.Publish(events.Event{RosterID: roster.RosterID})
+	got, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want, got := "bar", got.GetName(); want != got {
+		t.Errorf("want roster name %s got %s", want, got)
+	}
+}
@@ -2,13 +2,19 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/bulk"
 	"github.com/fgrimme/patrongg/store"
 	"github.com/gorilla/mux"
 )
@@ -18,6 +24,7 @@ var (
 	errInternal   = errors.New("internal_error")
 	errNotFound   = errors.New("not_found")
 	errBadRequest = errors.New("bad_request")
+	errConflict   = errors.New("conflict")
 )
 
 const (
@@ -27,13 +34,19 @@ const (
 
 // rosterStore handles operations on rosters.
 type rosterStore interface {
+	Create(ctx context.Context, roster store.Roster) (*store.Roster, error)
 	Get(ctx context.Context, rosterID uint64) (*store.Roster, error)
+	List(ctx context.Context, name string) ([]store.Roster, error)
+	rosterExporter
 }
 
 // rosterService provides API methods to operate on rosters.
 type rosterService struct {
 	rosterStore
 	timeout time.Duration
+	maxAge  time.Duration // max age of cached responses
+	orgs    orgStore
+	titles  titleStore
 }
 
 // ServeHTTP serves requests to the roster enpoint.
@@ -44,6 +57,19 @@ func (rs *rosterService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// to pass it as an parameter
 	ctx = loggerFromRequest(r).WithContext(ctx)
 
+	// create a roster
+	if r.Method == http.MethodPost {
+		// we expect a request body that represents a roster with its
+		// players or we consider the request as invalid
+		var roster store.Roster
+		if err := decode(r, &roster); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		rs.create(ctx, w, r, roster)
+		return
+	}
+
 	// query param validation is currently performed by mux only
 	vars := mux.Vars(r)
 	rosterID, err := strconv.ParseUint(vars["id"], 10, 64)
@@ -65,15 +91,74 @@ func (rs *rosterService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// create creates a roster along with its players. Responds with the newly
+// created roster with generated ids or an error (and thus is POST compliant).
+func (rs *rosterService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, roster store.Roster) {
+	if n := utf8.RuneCountInString(roster.Name); n == 0 || n > 32 {
+		writeError(w, r, invalidParam("name", "must have 1 to 32 characters"), http.StatusBadRequest)
+		return
+	}
+	// the datastore enforces the rules of the game title too, but we can
+	// report violations as invalid request early
+	rules := store.DefaultRules
+	if roster.TitleID != 0 {
+		t, err := rs.titles.Get(ctx, roster.TitleID)
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, r, invalidParam("title_id", "must be a game title of the catalog"), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		rules = t.Rules
+	}
+	if len(roster.Players.Active) != rules.ActiveSize {
+		writeError(w, r, invalidParam("players.active", fmt.Sprintf("must contain %d players", rules.ActiveSize)), http.StatusBadRequest)
+		return
+	}
+	if rules.MaxBenched != nil && len(roster.Players.Benched) > *rules.MaxBenched {
+		writeError(w, r, invalidParam("players.benched", fmt.Sprintf("must not contain more than %d players", *rules.MaxBenched)), http.StatusBadRequest)
+		return
+	}
+	// the roles of the players must be valid, whether they cover the role
+	// requirements of the title is checked by the store
+	for _, players := range [][]store.Player{roster.Players.Active, roster.Players.Benched} {
+		for _, p := range players {
+			if err := normalizeRoles("players.roles", p.Roles); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	// rosters of organizations are created by their managers
+	if roster.OrgID != 0 {
+		if err := authorizeOrg(ctx, r, rs.orgs, roster.OrgID); err != nil {
+			writeError(w, r, err, http.StatusForbidden)
+			return
+		}
+	}
+	created, err := rs.Create(ctx, roster)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", rosterPath(r, created.RosterID))
+	encode(w, r, created, http.StatusCreated)
+}
+
 // getRoster responds with a representation of the entire roster for the given
 // id or an error.
 func (rs *rosterService) getRoster(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
-	players, err := rs.Get(ctx, rosterID)
+	roster, err := rs.Get(ctx, rosterID)
 	if err != nil {
 		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	encodeJSON(w, r, players, http.StatusOK)
+	if notModified(w, r, roster, rs.maxAge) {
+		return
+	}
+	encode(w, r, roster, http.StatusOK)
 }
 
 // getPlayers responds with a representation of the players with the given status
@@ -84,30 +169,50 @@ func (rs *rosterService) getPlayers(ctx context.Context, w http.ResponseWriter,
 		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	if status == Active {
-		encodeJSON(w, r, roster.Players.Active, http.StatusOK)
+	var players []store.Player
+	switch status {
+	case Active:
+		players = roster.Players.Active
+	case Benched:
+		players = roster.Players.Benched
+	default:
+		writeError(w, r, errNotFound, http.StatusNotFound)
 		return
 	}
-	if status == Benched {
-		encodeJSON(w, r, roster.Players.Benched, http.StatusOK)
+	if notModified(w, r, players, rs.maxAge) {
 		return
 	}
-	writeError(w, r, errNotFound, http.StatusNotFound)
+	encode(w, r, players, http.StatusOK)
 }
 
 // playerStore provides methods to operate on the players store.
 type playerStore interface {
+	Get(ctx context.Context, playerID uint64) (*store.Player, error)
 	Insert(ctx context.Context, player store.Player) (*store.Player, error)
 	Update(ctx context.Context, player store.Player) (*store.Player, error)
+	Delete(ctx context.Context, playerID uint64) (*store.Player, error)
 	ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error)
+	Trade(ctx context.Context, trade store.Trade) (*store.Trade, error)
+	Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error)
+	Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error)
+	FindByAlias(ctx context.Context, alias string) ([]store.AliasMatch, error)
+	Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error)
 }
 
 type playerService struct {
 	playerStore
-	timeout time.Duration
+	managers managerStore
+	timeout  time.Duration
 }
 
-// ServeHTTP serves requests to the players enpoint.
+// errMoveDetail is the detail of the problem of clients moving players to
+// another roster without being admins.
+const errMoveDetail = "only admins may move players to another roster, propose a transfer instead"
+
+// ServeHTTP serves requests to the players endpoints. Resource routes carry
+// the id of a player, or of a roster for swaps, in the URL path. Legacy routes
+// are dispatched by the last segment of the URL path. Players are moved to
+// another roster by accepted transfers; only admins may move them directly.
 func (ps *playerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// we attach the logger from the request to the context so we do need
 	// to pass it as an parameter
@@ -115,35 +220,155 @@ func (ps *playerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	ctx = loggerFromRequest(r).WithContext(ctx)
 
-	// add a player
-	// new players are benched by default
-	if r.Method == http.MethodPost {
-		// we expect a request body that represents a player or we consider
-		// the request as invalid
-		var player store.Player
-		decoder := json.NewDecoder(r.Body)
-		decoder.DisallowUnknownFields() // catch unwanted fields
-		if err := decoder.Decode(&player); err != nil {
+	// admins may change the players of locked rosters
+	ctx, err := lockOverride(ctx, r)
+	if err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	_, route := path.Split(r.URL.Path)
+
+	// operate on a player or swap players of a roster
+	if v, ok := mux.Vars(r)["id"]; ok {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
 			writeError(w, r, errBadRequest, http.StatusBadRequest)
 			return
 		}
-		player.Status = Benched // benched by default
-		ps.insert(ctx, w, r, player)
+		switch {
+		case route == "swaps" && r.Method == http.MethodPost:
+			// we expect a request body that contains two players of the
+			// roster or we consider the request as invalid
+			var players store.PlayerChange
+			if err := decode(r, &players); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+			players.Active.RosterID = id
+			players.Benched.RosterID = id
+			ps.change(ctx, w, r, players)
+			return
+
+		case r.Method == http.MethodGet:
+			ps.get(ctx, w, r, id)
+			return
+
+		case r.Method == http.MethodPatch:
+			// we expect a merge patch of the player or we consider the
+			// request as invalid
+			var player store.Player
+			if err := decode(r, &player); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+			if player.PlayerID != 0 && player.PlayerID != id {
+				writeError(w, r, invalidParam("player_id", "must match the id of the URL path"), http.StatusBadRequest)
+				return
+			}
+			if player.Status != "" {
+				writeError(w, r, invalidParam("status", "can not be changed, swap players instead"), http.StatusBadRequest)
+				return
+			}
+			if player.Roles != nil {
+				writeError(w, r, invalidParam("roles", "can not be patched, put the roles of the player instead"), http.StatusBadRequest)
+				return
+			}
+			if player.Profile != nil {
+				writeError(w, r, invalidParam("profile", "can not be patched here, patch the profile of the player instead"), http.StatusBadRequest)
+				return
+			}
+			player.PlayerID = id
+			// players always get benched when they are added to a roster
+			if player.RosterID != 0 {
+				if err := requireAdmin(r, errMoveDetail); err != nil {
+					writeError(w, r, err, http.StatusForbidden)
+					return
+				}
+				player.Status = Benched
+			}
+			ps.update(ctx, w, r, player)
+			return
+
+		case r.Method == http.MethodDelete:
+			ps.delete(ctx, w, r, id)
+			return
+		}
+	}
+
+	// look up players by their current or former alias
+	if alias, ok := mux.Vars(r)["alias"]; ok {
+		ps.findByAlias(ctx, w, r, alias)
 		return
 	}
 
+	// list players
+	if r.Method == http.MethodGet && route == "players" {
+		ps.find(ctx, w, r)
+		return
+	}
+
+	// add players
+	if r.Method == http.MethodPost {
+		switch route {
+		case "players", "add":
+			// we expect a request body that represents a player or we consider
+			// the request as invalid
+			var player store.Player
+			if err := decode(r, &player); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+			// new players are benched by default
+			player.Status = Benched
+			if route == "players" {
+				ps.create(ctx, w, r, player)
+				return
+			}
+			ps.insert(ctx, w, r, player)
+			return
+
+		case "import":
+			// we expect a request body in one of the bulk formats, dry runs
+			// are requested via query parameter
+			dryRun := false
+			if v := r.URL.Query().Get("dry_run"); v != "" {
+				var err error
+				if dryRun, err = strconv.ParseBool(v); err != nil {
+					writeError(w, r, invalidParam("dry_run", "must be a boolean"), http.StatusBadRequest)
+					return
+				}
+			}
+			ps.importPlayers(ctx, w, r, dryRun)
+			return
+
+		case "trades":
+			// we expect a request body that represents a trade or we
+			// consider the request as invalid
+			var trade store.Trade
+			if err := decode(r, &trade); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+			ps.trade(ctx, w, r, trade)
+			return
+		}
+	}
+
 	// modify a player
 	if r.Method == http.MethodPatch {
-		_, route := path.Split(r.URL.Path)
 		switch route {
 		case "update":
 			// we expect a request body that represents a player with the new
 			// rosterID or we consider the request as invalid
 			var player store.Player
-			decoder := json.NewDecoder(r.Body)
-			decoder.DisallowUnknownFields() // catch unwanted fields
-			if err := decoder.Decode(&player); err != nil {
-				writeError(w, r, err, http.StatusInternalServerError)
+			if err := decode(r, &player); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+			if err := requireAdmin(r, errMoveDetail); err != nil {
+				writeError(w, r, err, http.StatusForbidden)
 				return
 			}
 			// here we chose which fields we want to update
@@ -160,10 +385,8 @@ func (ps *playerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// we expect a request body that contains two players or we
 			// consider the request as invalid
 			var players store.PlayerChange
-			decoder := json.NewDecoder(r.Body)
-			decoder.DisallowUnknownFields() // catch unwanted fields
-			if err := decoder.Decode(&players); err != nil {
-				writeError(w, r, err, http.StatusInternalServerError)
+			if err := decode(r, &players); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
 				return
 			}
 			ps.change(ctx, w, r, players)
@@ -175,6 +398,109 @@ func (ps *playerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	writeError(w, r, errNotFound, http.StatusNotFound)
 }
 
+// find responds with the players matching the filter given by the query
+// parameters roster_id, which may be repeated, status, alias, nationality,
+// platform and custom.<field>.
+func (ps *playerService) find(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	rosterIDs, status, err := rosterFilter(q)
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	filter := store.PlayerFilter{
+		RosterIDs:   rosterIDs,
+		Status:      status,
+		Alias:       q.Get("alias"),
+		Nationality: strings.ToUpper(q.Get("nationality")),
+		Platform:    strings.ToLower(q.Get("platform")),
+	}
+	for k := range q {
+		if field := strings.TrimPrefix(k, "custom."); field != k {
+			if filter.Custom == nil {
+				filter.Custom = make(map[string]string)
+			}
+			filter.Custom[field] = q.Get(k)
+		}
+	}
+	players, err := ps.Find(ctx, filter)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, players, http.StatusOK)
+}
+
+// rosterFilter returns the roster ids and the status players are filtered by
+// in the query or a problem if they are invalid.
+func rosterFilter(q url.Values) ([]uint64, string, error) {
+	var rosterIDs []uint64
+	for _, v := range q["roster_id"] {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, "", invalidParam("roster_id", "must be an id")
+		}
+		rosterIDs = append(rosterIDs, id)
+	}
+	status := q.Get("status")
+	if status != "" && status != Active && status != Benched && status != store.Released {
+		return nil, "", invalidParam("status", fmt.Sprintf("must be %s, %s or %s", Active, Benched, store.Released))
+	}
+	return rosterIDs, status, nil
+}
+
+// findByAlias responds with the players holding the alias or having held it
+// before, current holders first. Responds with 404 if no player ever had the
+// alias.
+func (ps *playerService) findByAlias(ctx context.Context, w http.ResponseWriter, r *http.Request, alias string) {
+	alias = strings.TrimSpace(alias)
+	if n := utf8.RuneCountInString(alias); n == 0 || n > 32 {
+		writeError(w, r, invalidParam("alias", "must have 1 to 32 characters"), http.StatusBadRequest)
+		return
+	}
+	matches, err := ps.FindByAlias(ctx, alias)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(matches) == 0 {
+		writeError(w, r, fmt.Errorf("alias %s: %w", alias, store.ErrNotFound), http.StatusNotFound)
+		return
+	}
+	encode(w, r, matches, http.StatusOK)
+}
+
+// get responds with the player with the given id or an error.
+func (ps *playerService) get(ctx context.Context, w http.ResponseWriter, r *http.Request, playerID uint64) {
+	p, err := ps.Get(ctx, playerID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, p, http.StatusOK)
+}
+
+// create inserts a new player to the datastore. Responds with 201, the
+// location and the representation of the newly created player or an error.
+func (ps *playerService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, player store.Player) {
+	p, err := ps.Insert(ctx, player)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", playerPath(r, p.PlayerID))
+	encode(w, r, p, http.StatusCreated)
+}
+
+// delete deletes the player with the given id. Responds with 204 or an error.
+func (ps *playerService) delete(ctx context.Context, w http.ResponseWriter, r *http.Request, playerID uint64) {
+	if _, err := ps.Delete(ctx, playerID); err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // insert inserts a new player to the datastore. Responds with the newly created
 // player with a generated player id or an error (and thus is POST compliant).
 func (ps *playerService) insert(ctx context.Context, w http.ResponseWriter, r *http.Request, player store.Player) {
@@ -183,7 +509,7 @@ func (ps *playerService) insert(ctx context.Context, w http.ResponseWriter, r *h
 		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	encodeJSON(w, r, p, http.StatusOK)
+	encode(w, r, p, http.StatusOK)
 }
 
 // update updates the given player. Responds with the updated/patched player or
@@ -194,7 +520,7 @@ func (ps *playerService) update(ctx context.Context, w http.ResponseWriter, r *h
 		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	encodeJSON(w, r, p, http.StatusOK)
+	encode(w, r, p, http.StatusOK)
 }
 
 // change swaps two players statuses. Responds the updated/patched
@@ -205,5 +531,110 @@ func (ps *playerService) change(ctx context.Context, w http.ResponseWriter, r *h
 		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	encodeJSON(w, r, p, http.StatusOK)
+	encode(w, r, p, http.StatusOK)
+}
+
+// trade exchanges players between two rosters in a single transaction. Only
+// admins and managers of both rosters may trade. Responds with the moved
+// players or an error.
+func (ps *playerService) trade(ctx context.Context, w http.ResponseWriter, r *http.Request, trade store.Trade) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	if err := validateTrade(trade); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	for _, rosterID := range []uint64{trade.RosterID, trade.PartnerID} {
+		if err := authorize(ctx, ps.managers, p, rosterID); err != nil {
+			writeError(w, r, err, http.StatusForbidden)
+			return
+		}
+	}
+	t, err := ps.Trade(ctx, trade)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, t, http.StatusOK)
+}
+
+// validateTrade returns a problem if the trade does not move any player, a
+// player is traded twice or the status of a player is missing.
+func validateTrade(trade store.Trade) error {
+	switch {
+	case trade.RosterID == 0:
+		return invalidParam("roster_id", "must not be empty")
+	case trade.PartnerID == 0:
+		return invalidParam("partner_id", "must not be empty")
+	case trade.PartnerID == trade.RosterID:
+		return invalidParam("partner_id", "must not be the roster")
+	case len(trade.Players)+len(trade.PartnerPlayers) == 0:
+		return invalidParam("players", "must not be empty")
+	}
+	traded := make(map[uint64]bool)
+	sides := []struct {
+		name    string
+		players []store.Player
+	}{
+		{"players", trade.Players},
+		{"partner_players", trade.PartnerPlayers},
+	}
+	for _, side := range sides {
+		name := side.name
+		for _, p := range side.players {
+			switch {
+			case p.PlayerID == 0:
+				return invalidParam(name, "must have a player_id")
+			case traded[p.PlayerID]:
+				return invalidParam(name, fmt.Sprintf("must not contain player %d twice", p.PlayerID))
+			case p.Status != Active && p.Status != Benched:
+				return invalidParam(name, fmt.Sprintf("must have a status of %s or %s", Active, Benched))
+			}
+			traded[p.PlayerID] = true
+		}
+	}
+	return nil
+}
+
+// importPlayers imports players in bulk from a CSV or NDJSON payload. Responds
+// with the import result. Rejected rows are reported in the result of a dry
+// run; otherwise nothing is imported and the rejected rows are reported in the
+// errors extension member of the problem details.
+func (ps *playerService) importPlayers(ctx context.Context, w http.ResponseWriter, r *http.Request, dryRun bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	result, err := bulk.ImportPlayers(ctx, ps, r.Body, mediaType, dryRun)
+	var formatErr *bulk.FormatError
+	switch {
+	case errors.Is(err, bulk.ErrUnsupportedFormat):
+		writeError(w, r, unsupportedMediaType(bulk.CSV, bulk.NDJSON), http.StatusUnsupportedMediaType)
+		return
+	case errors.As(err, &formatErr):
+		writeError(w, r, &api.Error{
+			Type:   api.InvalidProblemType,
+			Title:  "Invalid request",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	case err != nil:
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun && len(result.Errors) > 0 {
+		writeError(w, r, &api.Error{
+			Type:   api.InvalidImportProblemType,
+			Title:  "Invalid import",
+			Status: http.StatusUnprocessableEntity,
+			Detail: fmt.Sprintf("%d errors in import, no players were imported", len(result.Errors)),
+			Extensions: map[string]interface{}{
+				"errors": result.Errors,
+			},
+		}, http.StatusUnprocessableEntity)
+		return
+	}
+	encode(w, r, result, http.StatusOK)
 }
@@ -11,6 +11,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
 	"github.com/fgrimme/patrongg/store"
 	"github.com/fgrimme/patrongg/testdata"
 	"github.com/gorilla/mux"
@@ -23,6 +25,11 @@ func (rs *mockRosterStore) Get(ctx context.Context, rosterID uint64) (*store.Ros
 	return rosterTests[rosterID].r, rosterTests[rosterID].e
 }
 
+// uses the roster name to get the test data.
+func (rs *mockRosterStore) Create(ctx context.Context, roster store.Roster) (*store.Roster, error) {
+	return createTests[roster.Name].r, createTests[roster.Name].e
+}
+
 // test cases indexed by roster id
 var rosterTests = map[uint64]struct {
 	d string        // description of test case
@@ -51,14 +58,21 @@ var rosterTests = map[uint64]struct {
 		p: "roster/2", // roster id is the test case id used by the mock store
 		e: errInternal,
 		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		b: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`),
 	},
 	3: { // 500
 		d: "expect store error to result in 500 when requesting active players",
 		p: "roster/3/active",
 		e: errInternal,
 		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		b: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`),
+	},
+	7: { // 404
+		d: "expect missing roster to result in 404",
+		p: "roster/7",
+		e: store.ErrNotFound,
+		s: http.StatusNotFound,
+		b: []byte(`{"type":"about:blank","title":"Not Found","status":404}`),
 	},
 	// success
 	4: { // 200
@@ -90,6 +104,9 @@ func TestGet(t *testing.T) {
 	rs := &rosterService{
 		&mockRosterStore{},
 		200 * time.Millisecond,
+		0,
+		&mockOrgStore{},
+		&mockTitleStore{},
 	}
 
 	router := mux.NewRouter()
@@ -127,6 +144,131 @@ func TestGet(t *testing.T) {
 	}
 }
 
+// five active players
+const activePlayers = `[{"first_name":"a"},{"first_name":"b"},{"first_name":"c"},{"first_name":"d"},{"first_name":"e"}]`
+
+// test cases indexed by roster name
+var createTests = map[string]struct {
+	d string        // description of test case
+	r *store.Roster // mock store response
+	e error         // mock store error
+	p string        // request payload
+	s int           // expected http status code
+	l string        // expected location header
+	b string        // expected payload
+}{
+	"": { // 400
+		d: "expect missing name to result in 400",
+		p: `{"players":{"active":` + activePlayers + `}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"name must have 1 to 32 characters","invalid-params":[{"name":"name","reason":"must have 1 to 32 characters"}]}`,
+	},
+	"few": { // 400
+		d: "expect missing active players to result in 400",
+		p: `{"name":"few","players":{"active":[{"first_name":"a"}]}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"players.active must contain 5 players","invalid-params":[{"name":"players.active","reason":"must contain 5 players"}]}`,
+	},
+	"exists": { // 409
+		d: "expect existing roster to result in 409",
+		e: fmt.Errorf("roster exists: %w", store.ErrExists),
+		p: `{"name":"exists","players":{"active":` + activePlayers + `}}`,
+		s: http.StatusConflict,
+		b: `{"type":"about:blank","title":"Conflict","status":409,"detail":"roster exists: already exists"}`,
+	},
+	"org": { // 401
+		d: "expect anonymous roster of organization to result in 401",
+		p: `{"name":"org","org_id":1,"players":{"active":` + activePlayers + `}}`,
+		s: http.StatusUnauthorized,
+		b: `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"API key required"}`,
+	},
+	"title": { // 201
+		d: "expect 201 when creating roster of title",
+		r: &store.Roster{RosterID: 2, TitleID: 1, Name: "title"},
+		p: `{"name":"title","title_id":1,"players":{"active":[{"first_name":"a"},{"first_name":"b"},{"first_name":"c"}],"benched":[{"first_name":"d"}]}}`,
+		s: http.StatusCreated,
+		l: "/roster/2",
+		b: `{"roster_id":2,"title_id":1,"name":"title","players":{"active":null,"benched":null}}`,
+	},
+	"size": { // 400
+		d: "expect active players not matching title to result in 400",
+		p: `{"name":"size","title_id":1,"players":{"active":` + activePlayers + `}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"players.active must contain 3 players","invalid-params":[{"name":"players.active","reason":"must contain 3 players"}]}`,
+	},
+	"bench": { // 400
+		d: "expect benched players exceeding title to result in 400",
+		p: `{"name":"bench","title_id":1,"players":{"active":[{"first_name":"a"},{"first_name":"b"},{"first_name":"c"}],"benched":[{"first_name":"d"},{"first_name":"e"}]}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"players.benched must not contain more than 1 players","invalid-params":[{"name":"players.benched","reason":"must not contain more than 1 players"}]}`,
+	},
+	"unknown": { // 400
+		d: "expect unknown title to result in 400",
+		p: `{"name":"unknown","title_id":2,"players":{"active":` + activePlayers + `}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"title_id must be a game title of the catalog","invalid-params":[{"name":"title_id","reason":"must be a game title of the catalog"}]}`,
+	},
+	"roles": { // 400
+		d: "expect duplicate roles of player to result in 400",
+		p: `{"name":"roles","players":{"active":[{"first_name":"a","roles":["igl","IGL"]},{"first_name":"b"},{"first_name":"c"},{"first_name":"d"},{"first_name":"e"}]}}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"players.roles must not contain igl twice","invalid-params":[{"name":"players.roles","reason":"must not contain igl twice"}]}`,
+	},
+	"foo": { // 201
+		d: "expect 201 when creating roster",
+		r: &store.Roster{RosterID: 1, Name: "foo"},
+		p: `{"name":"foo","players":{"active":` + activePlayers + `}}`,
+		s: http.StatusCreated,
+		l: "/roster/1",
+		b: `{"roster_id":1,"name":"foo","players":{"active":null,"benched":null}}`,
+	},
+}
+
+func TestCreate(t *testing.T) {
+	rs := &rosterService{
+		&mockRosterStore{},
+		200 * time.Millisecond,
+		0,
+		&mockOrgStore{},
+		&mockTitleStore{},
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/rosters", rs).Methods("POST")
+
+	s := httptest.NewServer(router)
+	defer s.Close()
+	c := s.Client()
+
+	for _, tc := range createTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, s.URL+"/rosters", strings.NewReader(tt.p))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			defer resp.Body.Close()
+			if want, got := tt.s, resp.StatusCode; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, resp.Header.Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if want, got := tt.b, strings.TrimSpace(string(body)); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
+
 // we use the player id to detemine the return values.
 type mockPlayerStore struct{}
 
@@ -167,7 +309,7 @@ var insertTests = map[uint64]struct {
 		d: "expect missing body to result in 400 when adding player",
 		u: "players/add",
 		s: http.StatusBadRequest,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errBadRequest.Error())),
+		b: []byte(`{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"missing request body"}`),
 	},
 	// store errors
 	2: { // 500
@@ -176,7 +318,7 @@ var insertTests = map[uint64]struct {
 		u: "players/add",
 		p: `{"player_id":2}`, // id is the testcase-id used by the mock store
 		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		b: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`),
 	},
 	// success
 	3: {
@@ -201,6 +343,7 @@ func TestInsert(t *testing.T) {
 	// control the data and errors we return
 	ps := &playerService{
 		&mockPlayerStore{},
+		&mockManagerStore{},
 		200 * time.Millisecond,
 	}
 
@@ -246,16 +389,17 @@ var updateTests = map[uint64]struct {
 	e error         // mock store error
 	u string        // request url path
 	p string        // request payload
+	k string        // API key
 	s int           // expected http status code
 	b []byte        // expected payload
 }{
 	// url path errors
-	0: { // 500
-		d: "expect malformed JSON payload to result in 500 when updating player",
+	0: { // 400
+		d: "expect malformed JSON payload to result in 400 when updating player",
 		u: "players/update",
 		p: `{"player_id":1`,
-		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		s: http.StatusBadRequest,
+		b: []byte(`{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"malformed request body"}`),
 	},
 	// store errors
 	1: { // 500
@@ -263,8 +407,9 @@ var updateTests = map[uint64]struct {
 		e: errInternal,
 		u: "players/update",
 		p: `{"player_id":1}`,
+		k: "admin",
 		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		b: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`),
 	},
 	// success
 	2: { // 200
@@ -279,9 +424,19 @@ var updateTests = map[uint64]struct {
 			Status:    "benched",
 		},
 		p: `{"player_id":2,"roster_id":1,"first_name":"foo","last_name":"bar","alias":"foobar","status":"active"}`,
+		k: "admin",
 		s: http.StatusOK,
 		b: []byte(`{"player_id":2,"roster_id":0,"first_name":"foo","last_name":"bar","alias":"foobar","status":"benched"}`),
 	},
+	// authorization errors
+	3: { // 403
+		d: "expect update of user to result in 403 when updating player",
+		u: "players/update",
+		p: `{"player_id":3,"roster_id":1}`,
+		k: "user",
+		s: http.StatusForbidden,
+		b: []byte(`{"type":"about:blank","title":"Forbidden","status":403,"detail":"only admins may move players to another roster, propose a transfer instead"}`),
+	},
 }
 
 func TestUpdate(t *testing.T) {
@@ -289,11 +444,13 @@ func TestUpdate(t *testing.T) {
 	// control the data and errors we return
 	ps := &playerService{
 		&mockPlayerStore{},
+		&mockManagerStore{},
 		200 * time.Millisecond,
 	}
 
+	keys := auth.Keys{"admin": {Subject: "alice", Admin: true}, "user": {Subject: "bob"}}
 	router := mux.NewRouter()
-	router.Handle("/players/update", ps).Methods("PATCH")
+	router.Handle("/players/update", keys.Middleware(http.HandlerFunc(unauthorized))(ps)).Methods("PATCH")
 
 	s := httptest.NewServer(router)
 	defer s.Close()
@@ -306,6 +463,9 @@ func TestUpdate(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
+			if tt.k != "" {
+				req.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
 			resp, err := c.Do(req)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
@@ -337,20 +497,21 @@ var changeTests = map[uint64]struct {
 	b []byte              // expected payload
 }{
 	// url path errors
-	0: { // 500
-		d: "expect malformed JSON payload to result in 500 when updating player",
+	0: { // 400
+		d: "expect malformed JSON payload to result in 400 when changing players",
 		u: "players/change",
 		p: `{"player_id":1`,
-		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		s: http.StatusBadRequest,
+		b: []byte(`{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"malformed request body"}`),
 	},
 	// store errors
 	1: { // 500
-		d: "expect store error to result in 500 when updating player",
+		d: "expect store error to result in 500 when changing players",
 		e: errInternal,
 		u: "players/change",
+		p: `{"active":{"player_id":1},"benched":{"player_id":4}}`,
 		s: http.StatusInternalServerError,
-		b: []byte(fmt.Sprintf(`{"error":"%s"}`, errInternal.Error())),
+		b: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`),
 	},
 	// success
 	2: { // 200
@@ -379,6 +540,7 @@ func TestChange(t *testing.T) {
 	// control the data and errors we return
 	ps := &playerService{
 		&mockPlayerStore{},
+		&mockManagerStore{},
 		200 * time.Millisecond,
 	}
 
@@ -415,3 +577,131 @@ func TestChange(t *testing.T) {
 		})
 	}
 }
+
+// uses the roster id of the first row to get the test data.
+func (ps *mockPlayerStore) Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error) {
+	var rosterID uint64
+	if len(rows) > 0 {
+		rosterID = rows[0].Player.RosterID
+	}
+	return importTests[rosterID].r, importTests[rosterID].e
+}
+
+// test cases indexed by the roster id of the first imported player
+var importTests = map[uint64]struct {
+	d string              // description of test case
+	r *store.ImportResult // mock store response
+	e error               // mock store error
+	u string              // request url path
+	t string              // request content type
+	p string              // request payload
+	s int                 // expected HTTP status code
+	b string              // expected payload
+}{
+	// request errors
+	0: { // 415
+		d: "expect unsupported content type to result in 415",
+		u: "players/import",
+		t: "application/json",
+		p: `{}`,
+		s: http.StatusUnsupportedMediaType,
+		b: `{"type":"about:blank","title":"Unsupported Media Type","status":415,"detail":"supported media types are [text/csv application/x-ndjson]"}`,
+	},
+	1: { // 400
+		d: "expect invalid dry run parameter to result in 400",
+		u: "players/import?dry_run=maybe",
+		t: "text/csv",
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"dry_run must be a boolean","invalid-params":[{"name":"dry_run","reason":"must be a boolean"}]}`,
+	},
+	// store errors
+	2: { // 500
+		d: "expect store error to result in 500 when importing players",
+		e: errInternal,
+		u: "players/import",
+		t: "text/csv",
+		p: "roster_id,first_name,last_name,alias\n2,foo,bar,foobar\n",
+		s: http.StatusInternalServerError,
+		b: `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+	},
+	// validation errors
+	3: { // 422
+		d: "expect rejected rows to result in 422",
+		r: &store.ImportResult{
+			Players: []store.Player{{RosterID: 3, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "active"}},
+			Errors:  []store.RowError{{Row: 1, Field: "status", Reason: "roster 3 must have exactly 5 active players, not 6"}},
+		},
+		u: "players/import",
+		t: "application/x-ndjson",
+		p: `{"roster_id":3,"first_name":"foo","last_name":"bar","alias":"foobar","status":"active"}` + "\n" + `{"roster_id":3}`,
+		s: http.StatusUnprocessableEntity,
+		b: `{"type":"/problems/invalid-import","title":"Invalid import","status":422,"detail":"4 errors in import, no players were imported","errors":[{"row":1,"field":"status","reason":"roster 3 must have exactly 5 active players, not 6"},{"row":2,"field":"first_name","reason":"is required"},{"row":2,"field":"last_name","reason":"is required"},{"row":2,"field":"alias","reason":"is required"}]}`,
+	},
+	4: { // 200
+		d: "expect rejected rows to be reported in dry run",
+		r: &store.ImportResult{
+			Players: []store.Player{{RosterID: 4, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}},
+		},
+		u: "players/import?dry_run=true",
+		t: "text/csv",
+		p: "roster_id,first_name,last_name,alias\n4,foo,bar,foobar\nfour,foo,bar,foobar\n",
+		s: http.StatusOK,
+		b: `{"dry_run":true,"players":[{"player_id":0,"roster_id":4,"first_name":"foo","last_name":"bar","alias":"foobar","status":"benched"}],"errors":[{"row":2,"field":"roster_id","reason":"must be a positive integer"}]}`,
+	},
+	// success
+	5: { // 200
+		d: "expect 200 when importing players",
+		r: &store.ImportResult{
+			Players: []store.Player{{PlayerID: 1, RosterID: 5, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}},
+		},
+		u: "players/import",
+		t: "text/csv; charset=utf-8",
+		p: "roster_id,first_name,last_name,alias\n5,foo,bar,foobar\n",
+		s: http.StatusOK,
+		b: `{"dry_run":false,"players":[{"player_id":1,"roster_id":5,"first_name":"foo","last_name":"bar","alias":"foobar","status":"benched"}],"errors":[]}`,
+	},
+}
+
+func TestImport(t *testing.T) {
+	// service initialized with a mock store to
+	// control the data and errors we return
+	ps := &playerService{
+		&mockPlayerStore{},
+		&mockManagerStore{},
+		200 * time.Millisecond,
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/players/import", ps).Methods("POST")
+
+	s := httptest.NewServer(router)
+	defer s.Close()
+	c := s.Client()
+
+	for _, tc := range importTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s", s.URL, tt.u), strings.NewReader(tt.p))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			req.Header.Set("Content-Type", tt.t)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			// expected result
+			if want, got := tt.s, resp.StatusCode; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp.Body.Close()
+			if want, got := tt.b, strings.TrimSpace(string(body)); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
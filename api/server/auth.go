@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/auth"
+)
+
+// principal returns the principal of the request or a 401 problem if the
+// client is anonymous.
+func principal(r *http.Request) (auth.Principal, error) {
+	p, ok := auth.FromContext(r.Context())
+	if !ok {
+		return p, &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusUnauthorized),
+			Status: http.StatusUnauthorized,
+			Detail: "API key required",
+		}
+	}
+	return p, nil
+}
+
+// forbidden returns a 403 problem with the given detail.
+func forbidden(detail string) *api.Error {
+	return &api.Error{
+		Type:   api.BlankProblemType,
+		Title:  http.StatusText(http.StatusForbidden),
+		Status: http.StatusForbidden,
+		Detail: detail,
+	}
+}
+
+// unauthorized responds to requests with an unknown API key.
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &api.Error{
+		Type:   api.BlankProblemType,
+		Title:  http.StatusText(http.StatusUnauthorized),
+		Status: http.StatusUnauthorized,
+		Detail: "unknown API key",
+	}, http.StatusUnauthorized)
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// maxNoteLength is the maximum number of characters of an availability note.
+const maxNoteLength = 256
+
+// availabilityStore handles operations on the availability of players.
+type availabilityStore interface {
+	Get(ctx context.Context, playerID uint64) (*store.Availability, error)
+	Set(ctx context.Context, availability store.Availability) (*store.Availability, error)
+	Suggestions(ctx context.Context, rosterID uint64) ([]store.Suggestion, error)
+}
+
+// availabilityService provides API methods to track the availability of
+// players and to suggest swaps of the active players that are not available.
+type availabilityService struct {
+	availabilityStore
+	players  playerStore
+	managers managerStore
+	timeout  time.Duration
+}
+
+// ServeHTTP serves requests to the availability endpoints. The id of the URL
+// path is the id of a roster for suggestions and of a player for its
+// availability. Only admins and managers of the roster of a player may change
+// its availability.
+func (as *availabilityService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), as.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	_, route := path.Split(r.URL.Path)
+	switch {
+	case route == "suggestions":
+		suggestions, err := as.Suggestions(ctx, id)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, suggestions, http.StatusOK)
+
+	case r.Method == http.MethodPut:
+		// we expect a request body that represents the availability of the
+		// player or we consider the request as invalid
+		var availability store.Availability
+		if err := decode(r, &availability); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		as.set(ctx, w, r, id, availability)
+
+	default:
+		a, err := as.Get(ctx, id)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, a, http.StatusOK)
+	}
+}
+
+// set sets the availability of the player. Responds with the availability or
+// an error.
+func (as *availabilityService) set(ctx context.Context, w http.ResponseWriter, r *http.Request, playerID uint64, a store.Availability) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	if a.PlayerID != 0 && a.PlayerID != playerID {
+		writeError(w, r, invalidParam("player_id", "must match the id of the URL path"), http.StatusBadRequest)
+		return
+	}
+	a.PlayerID = playerID
+	if err := validateAvailability(a, time.Now()); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	player, err := as.players.Get(ctx, playerID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := authorize(ctx, as.managers, p, player.RosterID); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	availability, err := as.Set(ctx, a)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, availability, http.StatusOK)
+}
+
+// validateAvailability validates the availability set at now. Unavailable
+// players must be expected back after today, injured players may be.
+func validateAvailability(a store.Availability, now time.Time) error {
+	switch a.Status {
+	case store.Available:
+		if a.Until != "" {
+			return invalidParam("until", fmt.Sprintf("must be empty if %s", store.Available))
+		}
+	case store.Injured:
+	case store.Unavailable:
+		if a.Until == "" {
+			return invalidParam("until", fmt.Sprintf("must not be empty if %s", store.Unavailable))
+		}
+	default:
+		return invalidParam("status", fmt.Sprintf("must be one of %s, %s or %s", store.Available, store.Injured, store.Unavailable))
+	}
+	if a.Until != "" {
+		if _, err := time.Parse(dateLayout, a.Until); err != nil {
+			return invalidParam("until", "must be a date like 2006-01-02")
+		}
+		// dates of the layout are ordered like strings
+		if a.Until <= now.Format(dateLayout) {
+			return invalidParam("until", "must be after today")
+		}
+	}
+	if utf8.RuneCountInString(a.Note) > maxNoteLength {
+		return invalidParam("note", fmt.Sprintf("must not have more than %d characters", maxNoteLength))
+	}
+	return nil
+}
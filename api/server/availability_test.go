@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// player 1 is injured and replaced by benched player 3 in roster 2.
+type mockAvailabilityStore struct{}
+
+func (as *mockAvailabilityStore) Get(ctx context.Context, playerID uint64) (*store.Availability, error) {
+	if playerID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return &store.Availability{PlayerID: 1, Status: store.Injured, Note: "wrist"}, nil
+}
+
+func (as *mockAvailabilityStore) Set(ctx context.Context, availability store.Availability) (*store.Availability, error) {
+	if availability.PlayerID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return &availability, nil
+}
+
+func (as *mockAvailabilityStore) Suggestions(ctx context.Context, rosterID uint64) ([]store.Suggestion, error) {
+	if rosterID != 2 {
+		return nil, store.ErrNotFound
+	}
+	a, _ := as.Get(ctx, 1)
+	return []store.Suggestion{{
+		Change: store.PlayerChange{
+			Active:  store.Player{PlayerID: 1, RosterID: 2, Status: store.Active, Roles: []string{"igl"}},
+			Benched: store.Player{PlayerID: 3, RosterID: 2, Status: store.Benched, Roles: []string{"igl"}},
+		},
+		Availability: *a,
+		Roles:        []string{"igl"},
+	}}, nil
+}
+
+func TestAvailability(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+		"carol": {Subject: "carol"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect availability of player",
+			m: http.MethodGet,
+			u: "/players/1/availability",
+			s: http.StatusOK,
+			b: `{"player_id":1,"status":"injured","note":"wrist"}`,
+		},
+		{
+			d: "expect availability of missing player to result in 404",
+			m: http.MethodGet,
+			u: "/v2/players/2/availability",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect manager to set player unavailable",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"unavailable","until":"2999-01-01","note":"exams"}`,
+			k: "bob",
+			s: http.StatusOK,
+			b: `{"player_id":1,"status":"unavailable","until":"2999-01-01","note":"exams"}`,
+		},
+		{
+			d: "expect admin to set player available",
+			m: http.MethodPut,
+			u: "/v2/players/1/availability",
+			p: `{"status":"available"}`,
+			k: "admin",
+			s: http.StatusOK,
+			b: `{"player_id":"1","status":"available"}`,
+		},
+		{
+			d: "expect anonymous change to result in 401",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"injured"}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect change of manager of another roster to result in 403",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"injured"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect change of missing player to result in 404",
+			m: http.MethodPut,
+			u: "/players/2/availability",
+			p: `{"status":"injured"}`,
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect invalid status to result in 400",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"sick"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+		{
+			d: "expect unavailable player without return to result in 400",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"unavailable"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"until"`,
+		},
+		{
+			d: "expect return in the past to result in 400",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"status":"injured","until":"2019-01-01"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"reason":"must be after today"`,
+		},
+		{
+			d: "expect availability of another player to result in 400",
+			m: http.MethodPut,
+			u: "/players/1/availability",
+			p: `{"player_id":3,"status":"injured"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"player_id"`,
+		},
+		{
+			d: "expect suggestions executable by the change endpoint",
+			m: http.MethodGet,
+			u: "/roster/2/suggestions",
+			s: http.StatusOK,
+			b: `[{"change":{"active":{"player_id":1,"roster_id":2,"first_name":"","last_name":"","alias":"","status":"active","roles":["igl"]},"benched":{"player_id":3,`,
+		},
+		{
+			d: "expect suggestions of missing roster to result in 404",
+			m: http.MethodGet,
+			u: "/v2/rosters/3/suggestions",
+			s: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etag returns a weak entity tag of the value. The tag does not depend on the
+// representation of the value, which is negotiated separately.
+func etag(v interface{}) (string, error) {
+	h := fnv.New64a()
+	if err := json.NewEncoder(h).Encode(v); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum64()), nil
+}
+
+// cacheControl returns the Cache-Control header for responses that may be
+// cached for maxAge. Responses with a maxAge of 0 must be revalidated.
+func cacheControl(maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+// notModified sets the ETag and Cache-Control headers of a response holding
+// v. Responds with 304 and returns true if the entity tag matches the
+// If-None-Match header of the request.
+func notModified(w http.ResponseWriter, r *http.Request, v interface{}, maxAge time.Duration) bool {
+	tag, err := etag(v)
+	if err != nil {
+		loggerFromRequest(r).Error().Err(err).Msg("failed to compute etag")
+		return false
+	}
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", cacheControl(maxAge))
+
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimSpace(match)
+		// we use the weak comparison, see RFC7232 section 2.3.2
+		if match == "*" || strings.TrimPrefix(match, "W/") == strings.TrimPrefix(tag, "W/") {
+			// the same headers as for the full response are sent
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", IDFormatHeader)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
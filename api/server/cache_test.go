@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/testdata"
+)
+
+func TestNotModified(t *testing.T) {
+	roster := testdata.Rosters[382574876546039808].R
+	tag, err := etag(roster)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string        // description of test case
+		m string        // If-None-Match header of the request
+		a time.Duration // max age
+		s int           // expected status code
+		c string        // expected Cache-Control header
+	}{
+		{d: "expect full response without If-None-Match", s: http.StatusOK, c: "no-cache"},
+		{d: "expect full response for other tags", m: `W/"foo", "bar"`, s: http.StatusOK, c: "no-cache"},
+		{d: "expect 304 for matching tag", m: `"foo", ` + tag, s: http.StatusNotModified, c: "no-cache"},
+		{d: "expect 304 for strong tag", m: tag[2:], a: time.Minute, s: http.StatusNotModified, c: "max-age=60"},
+		{d: "expect 304 for any tag", m: "*", s: http.StatusNotModified, c: "no-cache"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.m != "" {
+			r.Header.Set("If-None-Match", tt.m)
+		}
+		if !notModified(w, r, roster, tt.a) {
+			encode(w, r, roster, http.StatusOK)
+		}
+		if want, got := tt.s, w.Code; want != got {
+			t.Errorf("%s: want status code %d got %d", tt.d, want, got)
+		}
+		if want, got := tag, w.Header().Get("ETag"); want != got {
+			t.Errorf("%s: want ETag %s got %s", tt.d, want, got)
+		}
+		if want, got := tt.c, w.Header().Get("Cache-Control"); want != got {
+			t.Errorf("%s: want Cache-Control %s got %s", tt.d, want, got)
+		}
+		if tt.s == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: unexpected body %s", tt.d, w.Body)
+		}
+	}
+}
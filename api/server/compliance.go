@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// complianceStore evaluates rosters against their rules and handles their
+// compliance modes.
+type complianceStore interface {
+	Get(ctx context.Context, rosterID uint64) (*store.Compliance, error)
+	List(ctx context.Context, mode string) ([]store.Compliance, error)
+	SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error)
+}
+
+// complianceService provides API methods to report the violations of the
+// rules of rosters and to set whether violations block writes.
+type complianceService struct {
+	complianceStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the compliance endpoints. Only admins may
+// change the compliance mode of a roster.
+func (cs *complianceService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), cs.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	v, ok := mux.Vars(r)["id"]
+	if !ok {
+		mode := r.URL.Query().Get("mode")
+		if mode != "" {
+			if err := validateMode(mode); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+		}
+		reports, err := cs.List(ctx, mode)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, reports, http.StatusOK)
+		return
+	}
+	rosterID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		report, err := cs.Get(ctx, rosterID)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, report, http.StatusOK)
+		return
+	}
+
+	if err := requireAdmin(r, "only admins may change the compliance mode of rosters"); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	// we expect a request body that contains the mode or we consider the
+	// request as invalid
+	var body struct {
+		Mode string `json:"mode"`
+	}
+	if err := decode(r, &body); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := validateMode(body.Mode); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	report, err := cs.SetMode(ctx, rosterID, body.Mode)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, report, http.StatusOK)
+}
+
+// validateMode returns a problem if mode is not a compliance mode.
+func validateMode(mode string) error {
+	if mode != store.Strict && mode != store.Lenient {
+		return invalidParam("mode", fmt.Sprintf("must be %s or %s", store.Strict, store.Lenient))
+	}
+	return nil
+}
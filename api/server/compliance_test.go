@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// roster 1 is lenient and lacks an active player, roster 2 is compliant.
+type mockComplianceStore struct{}
+
+var mockViolation = store.Violation{Rule: store.RuleActiveSize, Detail: "must have exactly 5 active players, not 4"}
+
+func (cs *mockComplianceStore) Get(ctx context.Context, rosterID uint64) (*store.Compliance, error) {
+	switch rosterID {
+	case 1:
+		return &store.Compliance{RosterID: 1, Mode: store.Lenient, Violations: []store.Violation{mockViolation}}, nil
+	case 2:
+		return &store.Compliance{RosterID: 2, Mode: store.Strict, Compliant: true, Violations: []store.Violation{}}, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (cs *mockComplianceStore) List(ctx context.Context, mode string) ([]store.Compliance, error) {
+	reports := make([]store.Compliance, 0)
+	if mode == "" || mode == store.Lenient {
+		c, _ := cs.Get(ctx, 1)
+		reports = append(reports, *c)
+	}
+	return reports, nil
+}
+
+func (cs *mockComplianceStore) SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error) {
+	c, err := cs.Get(ctx, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	if mode == store.Strict && !c.Compliant {
+		return nil, &store.ConflictError{RosterID: rosterID, Reason: "strict rosters must comply with their rules, roster " + c.Violations[0].Detail}
+	}
+	c.Mode = mode
+	return c, nil
+}
+
+func TestCompliance(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect violations of roster",
+			m: http.MethodGet,
+			u: "/roster/1/compliance",
+			s: http.StatusOK,
+			b: `{"roster_id":1,"mode":"lenient","compliant":false,"violations":[{"rule":"active_size","detail":"must have exactly 5 active players, not 4"}]}`,
+		},
+		{
+			d: "expect compliant roster",
+			m: http.MethodGet,
+			u: "/v2/rosters/2/compliance",
+			s: http.StatusOK,
+			b: `{"roster_id":"2","mode":"strict","compliant":true,"violations":[]}`,
+		},
+		{
+			d: "expect compliance of missing roster to result in 404",
+			m: http.MethodGet,
+			u: "/roster/3/compliance",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect non-compliant rosters",
+			m: http.MethodGet,
+			u: "/rosters/compliance",
+			s: http.StatusOK,
+			b: `[{"roster_id":1,`,
+		},
+		{
+			d: "expect non-compliant rosters filtered by mode",
+			m: http.MethodGet,
+			u: "/v2/rosters/compliance?mode=strict",
+			s: http.StatusOK,
+			b: `[]`,
+		},
+		{
+			d: "expect invalid mode filter to result in 400",
+			m: http.MethodGet,
+			u: "/rosters/compliance?mode=loose",
+			s: http.StatusBadRequest,
+			b: `"name":"mode"`,
+		},
+		{
+			d: "expect admin to make roster lenient",
+			m: http.MethodPut,
+			u: "/roster/2/compliance",
+			p: `{"mode":"lenient"}`,
+			k: "admin",
+			s: http.StatusOK,
+			b: `"mode":"lenient"`,
+		},
+		{
+			d: "expect mode changed by user to result in 403",
+			m: http.MethodPut,
+			u: "/roster/2/compliance",
+			p: `{"mode":"lenient"}`,
+			k: "bob",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect strict mode of violating roster to result in 409",
+			m: http.MethodPut,
+			u: "/v2/rosters/1/compliance",
+			p: `{"mode":"strict"}`,
+			k: "admin",
+			s: http.StatusConflict,
+			b: `must have exactly 5 active players, not 4`,
+		},
+		{
+			d: "expect invalid mode to result in 400",
+			m: http.MethodPut,
+			u: "/roster/2/compliance",
+			p: `{"mode":"loose"}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"mode"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// dateLayout is the layout of the dates contracts start and end on.
+const dateLayout = "2006-01-02"
+
+// Limits of the expirations window and the terms of a contract.
+const (
+	defaultExpirationDays = 30
+	maxExpirationDays     = 365
+	maxTermsSize          = 4096 // bytes of the JSON encoded terms
+)
+
+// contractStore handles operations on the contracts of players.
+type contractStore interface {
+	Sign(ctx context.Context, contract store.Contract) (*store.Contract, error)
+	Get(ctx context.Context, contractID uint64) (*store.Contract, error)
+	List(ctx context.Context, rosterID uint64) ([]store.Contract, error)
+	Expiring(ctx context.Context, rosterID uint64, days int) ([]store.Contract, error)
+}
+
+// contractService provides API methods to sign the contracts of players and
+// to list the contracts of rosters. Contracts are signed by the managers of
+// the roster, their expiry is handled by a background job.
+type contractService struct {
+	contractStore
+	players  playerStore
+	managers managerStore
+	timeout  time.Duration
+}
+
+// ServeHTTP serves requests to the contract endpoints.
+func (cs *contractService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), cs.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	vars := mux.Vars(r)
+	if v, ok := vars["roster_id"]; ok {
+		rosterID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
+			writeError(w, r, errBadRequest, http.StatusBadRequest)
+			return
+		}
+		if _, route := path.Split(r.URL.Path); route == "expirations" {
+			cs.expiring(ctx, w, r, rosterID)
+			return
+		}
+		contracts, err := cs.List(ctx, rosterID)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, contracts, http.StatusOK)
+		return
+	}
+	v, ok := vars["id"]
+	if !ok {
+		// we expect a request body that represents a contract or we consider
+		// the request as invalid, terms named like ids are kept as given
+		var contract store.Contract
+		if err := decode(r, &contract); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		cs.sign(ctx, w, r, contract)
+		return
+	}
+	contractID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	c, err := cs.Get(ctx, contractID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, c, http.StatusOK)
+}
+
+// sign signs the contract of a player with its roster. Only admins and
+// managers of the roster may sign contracts. Contracts start today unless
+// given otherwise and must not have ended yet. Responds with 201, the location
+// and the representation of the contract or an error.
+func (cs *contractService) sign(ctx context.Context, w http.ResponseWriter, r *http.Request, contract store.Contract) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	if err := validateContract(&contract, time.Now()); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	player, err := cs.players.Get(ctx, contract.PlayerID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if contract.RosterID == 0 {
+		contract.RosterID = player.RosterID
+	}
+	if err := authorize(ctx, cs.managers, p, contract.RosterID); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	c, err := cs.Sign(ctx, contract)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/contracts/%d", versionPrefix(r), c.ContractID))
+	encode(w, r, c, http.StatusCreated)
+}
+
+// expiring responds with the contracts of the roster that end within the
+// number of days given by the query parameter days and the overdue ones.
+func (cs *contractService) expiring(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
+	days := defaultExpirationDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil || days < 1 || days > maxExpirationDays {
+			writeError(w, r, invalidParam("days", fmt.Sprintf("must be between 1 and %d", maxExpirationDays)), http.StatusBadRequest)
+			return
+		}
+	}
+	contracts, err := cs.Expiring(ctx, rosterID, days)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, contracts, http.StatusOK)
+}
+
+// validateContract validates the contract to be signed at now. The start
+// defaults to the date of now.
+func validateContract(c *store.Contract, now time.Time) error {
+	today := now.Format(dateLayout)
+	switch {
+	case c.ContractID != 0:
+		return invalidParam("contract_id", "must be empty")
+	case c.Status != "":
+		return invalidParam("status", "must be empty")
+	case c.ReplacementID != 0:
+		return invalidParam("replacement_id", "must be empty")
+	case c.ReleasedAt != nil:
+		return invalidParam("released_at", "must be empty")
+	case c.PlayerID == 0:
+		return invalidParam("player_id", "must not be empty")
+	case c.StartsOn == "":
+		c.StartsOn = today
+	}
+	if _, err := time.Parse(dateLayout, c.StartsOn); err != nil {
+		return invalidParam("starts_on", "must be a date like 2006-01-02")
+	}
+	if _, err := time.Parse(dateLayout, c.EndsOn); err != nil {
+		return invalidParam("ends_on", "must be a date like 2006-01-02")
+	}
+	// dates of the layout are ordered like strings
+	switch {
+	case c.EndsOn < c.StartsOn:
+		return invalidParam("ends_on", "must not be before starts_on")
+	case c.EndsOn < today:
+		return invalidParam("ends_on", "must not be in the past")
+	}
+	if b, err := json.Marshal(c.Terms); err != nil || len(b) > maxTermsSize {
+		return invalidParam("terms", fmt.Sprintf("must not exceed %d bytes", maxTermsSize))
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// player 1 of roster 2 has contract 1, contract 2 is overdue.
+type mockContractStore struct{}
+
+var mockContracts = []store.Contract{
+	{ContractID: 1, PlayerID: 1, RosterID: 2, StartsOn: "2020-01-01", EndsOn: "2999-12-31", Status: store.ContractSigned},
+	{ContractID: 2, PlayerID: 5, RosterID: 2, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractOverdue},
+}
+
+func (cs *mockContractStore) Sign(ctx context.Context, contract store.Contract) (*store.Contract, error) {
+	if contract.RosterID != 2 {
+		return nil, &store.ConflictError{RosterID: contract.RosterID, Reason: fmt.Sprintf("player %d is not a member of the roster", contract.PlayerID)}
+	}
+	contract.ContractID = 3
+	contract.Status = store.ContractSigned
+	return &contract, nil
+}
+
+func (cs *mockContractStore) Get(ctx context.Context, contractID uint64) (*store.Contract, error) {
+	for _, c := range mockContracts {
+		if c.ContractID == contractID {
+			return &c, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (cs *mockContractStore) List(ctx context.Context, rosterID uint64) ([]store.Contract, error) {
+	contracts := make([]store.Contract, 0)
+	for _, c := range mockContracts {
+		if c.RosterID == rosterID {
+			contracts = append(contracts, c)
+		}
+	}
+	return contracts, nil
+}
+
+func (cs *mockContractStore) Expiring(ctx context.Context, rosterID uint64, days int) ([]store.Contract, error) {
+	contracts := make([]store.Contract, 0)
+	for _, c := range mockContracts {
+		if c.RosterID == rosterID && c.Status == store.ContractOverdue {
+			contracts = append(contracts, c)
+		}
+	}
+	return contracts, nil
+}
+
+func TestContracts(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+		"carol": {Subject: "carol"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect anonymous contract to result in 401",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"ends_on":"2999-12-31"}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect contract signed by manager of another roster to result in 403",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"ends_on":"2999-12-31"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect 201 when manager signs contract starting today",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"ends_on":"2999-12-31","terms":{"salary":1000}}`,
+			k: "bob",
+			s: http.StatusCreated,
+			l: "/contracts/3",
+			b: fmt.Sprintf(`"roster_id":2,"starts_on":"%s","ends_on":"2999-12-31","terms":{"salary":1000},"status":"signed"`, time.Now().Format(dateLayout)),
+		},
+		{
+			d: "expect terms named like ids to be kept by v2",
+			m: http.MethodPost,
+			u: "/v2/contracts",
+			p: `{"player_id":"1","roster_id":"2","starts_on":"2020-01-01","ends_on":"2999-12-31","terms":{"clause_id":"0042","option_id":7}}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/contracts/3",
+			b: `"terms":{"clause_id":"0042","option_id":7}`,
+		},
+		{
+			d: "expect versioned location when signing contract",
+			m: http.MethodPost,
+			u: "/v2/contracts",
+			p: `{"player_id":"1","roster_id":"2","starts_on":"2020-01-01","ends_on":"2999-12-31"}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/contracts/3",
+			b: `"player_id":"1"`,
+		},
+		{
+			d: "expect contract with another roster to result in 409",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"roster_id":3,"ends_on":"2999-12-31"}`,
+			k: "admin",
+			s: http.StatusConflict,
+		},
+		{
+			d: "expect contract of missing player to result in 404",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":2,"ends_on":"2999-12-31"}`,
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect invalid end to result in 400",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"ends_on":"31.12.2999"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"ends_on"`,
+		},
+		{
+			d: "expect end before start to result in 400",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"starts_on":"2999-12-31","ends_on":"2999-01-01"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"reason":"must not be before starts_on"`,
+		},
+		{
+			d: "expect end in the past to result in 400",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"starts_on":"2019-01-01","ends_on":"2019-12-31"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"reason":"must not be in the past"`,
+		},
+		{
+			d: "expect status of new contract to result in 400",
+			m: http.MethodPost,
+			u: "/contracts",
+			p: `{"player_id":1,"ends_on":"2999-12-31","status":"overdue"}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+		{
+			d: "expect contract",
+			m: http.MethodGet,
+			u: "/contracts/1",
+			s: http.StatusOK,
+			b: `"contract_id":1`,
+		},
+		{
+			d: "expect missing contract to result in 404",
+			m: http.MethodGet,
+			u: "/contracts/9",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect contracts of roster",
+			m: http.MethodGet,
+			u: "/v2/rosters/2/contracts",
+			s: http.StatusOK,
+			b: `"contract_id":"2"`,
+		},
+		{
+			d: "expect expirations of roster",
+			m: http.MethodGet,
+			u: "/roster/2/expirations?days=90",
+			s: http.StatusOK,
+			b: `[{"contract_id":2,"player_id":5,"roster_id":2,"starts_on":"2019-01-01","ends_on":"2019-12-31","status":"overdue"}]`,
+		},
+		{
+			d: "expect invalid days to result in 400",
+			m: http.MethodGet,
+			u: "/roster/2/expirations?days=0",
+			s: http.StatusBadRequest,
+			b: `"name":"days"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/api/pb"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Media types of request and response payloads.
+const (
+	jsonMediaType     = "application/json"
+	msgpackMediaType  = "application/msgpack"
+	protobufMediaType = "application/x-protobuf"
+
+	// mergePatchMediaType is the media type of JSON merge patches, see RFC7396.
+	mergePatchMediaType = "application/merge-patch+json"
+)
+
+// encode encodes v to w in the format negotiated via the Accept header of the
+// request. JSON is preferred, Protocol Buffers are only offered for values that
+// have a protocol buffer representation. Responds with 406 if none of the
+// formats is acceptable.
+func encode(w http.ResponseWriter, r *http.Request, v interface{}, status int) {
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", IDFormatHeader)
+	offers := []string{jsonMediaType, msgpackMediaType}
+	msg, ok := pb.Message(v)
+	if ok {
+		offers = append(offers, protobufMediaType)
+	}
+
+	var b []byte
+	var err error
+	mediaType := negotiate(r, offers...)
+	switch mediaType {
+	case jsonMediaType:
+		encodeJSON(w, r, v, status)
+		return
+	case msgpackMediaType:
+		var buf bytes.Buffer
+		err = msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(v)
+		b = buf.Bytes()
+	case protobufMediaType:
+		b, err = proto.Marshal(msg)
+	default:
+		writeError(w, r, &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusNotAcceptable),
+			Status: http.StatusNotAcceptable,
+			Detail: fmt.Sprintf("acceptable media types are %v", offers),
+		}, http.StatusNotAcceptable)
+		return
+	}
+	if err != nil {
+		loggerFromRequest(r).Error().Err(err).Interface("value", v).Msg("failed to encode value to http response")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	if _, err := w.Write(b); err != nil {
+		loggerFromRequest(r).Error().Err(err).Msg("failed to write http response")
+	}
+}
+
+// decode decodes the request body into v according to the Content-Type header
+// of the request. Requests without content type are decoded as JSON. Ids of
+// JSON payloads may be encoded as numbers or strings. Unknown fields are
+// rejected. Returns problem details describing why the body could
+// not be decoded.
+func decode(r *http.Request, v interface{}) error {
+	return decodeBody(r, v, true)
+}
+
+// decodeVerbatim decodes the request body like decode, but leaves ids of JSON
+// payloads as they are. It is used for documents with arbitrary members.
+func decodeVerbatim(r *http.Request, v interface{}) error {
+	return decodeBody(r, v, false)
+}
+
+func decodeBody(r *http.Request, v interface{}, ids bool) error {
+	mediaType := jsonMediaType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return invalidParam("Content-Type", "must be a valid media type")
+		}
+	}
+
+	var err error
+	switch mediaType {
+	case jsonMediaType, mergePatchMediaType:
+		// ids are accepted as numbers and strings, documents that can not be
+		// rewritten are left to the decoder to report
+		var b []byte
+		if b, err = ioutil.ReadAll(r.Body); err != nil {
+			break
+		}
+		if unquoted, err := unquoteIDs(b); err == nil && ids {
+			b = unquoted
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields() // catch unwanted fields
+		err = decoder.Decode(v)
+	case msgpackMediaType:
+		decoder := msgpack.NewDecoder(r.Body).UseJSONTag(true)
+		decoder.DisallowUnknownFields() // catch unwanted fields
+		err = decoder.Decode(v)
+	case protobufMediaType:
+		var b []byte
+		if b, err = ioutil.ReadAll(r.Body); err == nil {
+			err = pb.Unmarshal(b, v)
+		}
+		if err == pb.ErrUnsupportedType {
+			return unsupportedMediaType(jsonMediaType, msgpackMediaType)
+		}
+	default:
+		return unsupportedMediaType(jsonMediaType, mergePatchMediaType, msgpackMediaType, protobufMediaType)
+	}
+	if err != nil {
+		return invalidRequest(err)
+	}
+	return nil
+}
+
+// unsupportedMediaType returns problem details for a request body in a format
+// that is not supported.
+func unsupportedMediaType(supported ...string) *api.Error {
+	return &api.Error{
+		Type:   api.BlankProblemType,
+		Title:  http.StatusText(http.StatusUnsupportedMediaType),
+		Status: http.StatusUnsupportedMediaType,
+		Detail: fmt.Sprintf("supported media types are %v", supported),
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fgrimme/patrongg/api/pb"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/testdata"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+var encodeTests = []struct {
+	d string      // description of test case
+	v interface{} // value to encode
+	a string      // Accept header of the request
+	s int         // expected HTTP status code
+	t string      // expected content type
+}{
+	{
+		d: "expect JSON by default",
+		v: testdata.Rosters[382574876546039808].R,
+		s: http.StatusOK,
+		t: "application/json",
+	},
+	{
+		d: "expect roster in MessagePack",
+		v: testdata.Rosters[382574876546039808].R,
+		a: "application/msgpack",
+		s: http.StatusOK,
+		t: "application/msgpack",
+	},
+	{
+		d: "expect roster in Protocol Buffers",
+		v: testdata.Rosters[382574876546039808].R,
+		a: "application/x-protobuf, application/json;q=0.5",
+		s: http.StatusOK,
+		t: "application/x-protobuf",
+	},
+	{
+		d: "expect players in Protocol Buffers",
+		v: testdata.Rosters[382574876546039808].R.Players.Active,
+		a: "application/x-protobuf",
+		s: http.StatusOK,
+		t: "application/x-protobuf",
+	},
+	{
+		d: "expect 406 for types without protocol buffer representation",
+		v: &store.ImportResult{},
+		a: "application/x-protobuf",
+		s: http.StatusNotAcceptable,
+		t: "application/problem+json",
+	},
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range encodeTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.a != "" {
+				r.Header.Set("Accept", tt.a)
+			}
+			encode(w, r, tt.v, http.StatusOK)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.t, w.Header().Get("Content-Type"); want != got {
+				t.Errorf("want content type %s got %s", want, got)
+			}
+			// we decode the response to make sure it holds the value
+			switch tt.t {
+			case msgpackMediaType:
+				var got store.Roster
+				if err := msgpack.NewDecoder(w.Body).UseJSONTag(true).Decode(&got); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if want := tt.v.(*store.Roster); !reflect.DeepEqual(*want, got) {
+					t.Errorf("want\n%+v\ngot\n%+v", *want, got)
+				}
+			case protobufMediaType:
+				want, _ := pb.Message(tt.v)
+				got := proto.Clone(want)
+				got.Reset()
+				if err := proto.Unmarshal(w.Body.Bytes(), got); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if !proto.Equal(want, got) {
+					t.Errorf("want\n%+v\ngot\n%+v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func mustMarshal(m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func mustMsgpack(v interface{}) []byte {
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(v); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+var change = store.PlayerChange{
+	Active:  store.Player{PlayerID: 1, RosterID: 2},
+	Benched: store.Player{PlayerID: 3, RosterID: 2},
+}
+
+var decodeTests = []struct {
+	d string // description of test case
+	t string // content type of the request
+	p []byte // request payload
+	s int    // expected status code of the problem, 0 on success
+}{
+	{
+		d: "expect JSON without content type",
+		p: []byte(`{"active":{"player_id":1,"roster_id":2},"benched":{"player_id":3,"roster_id":2}}`),
+	},
+	{
+		d: "expect JSON merge patch",
+		t: "application/merge-patch+json",
+		p: []byte(`{"active":{"player_id":1,"roster_id":2},"benched":{"player_id":3,"roster_id":2}}`),
+	},
+	{
+		d: "expect JSON with string ids",
+		p: []byte(`{"active":{"player_id":"1","roster_id":"2"},"benched":{"player_id":3,"roster_id":"2"}}`),
+	},
+	{
+		d: "expect JSON with non-numeric string ids to be rejected",
+		p: []byte(`{"active":{"player_id":"one","roster_id":2},"benched":{"player_id":3,"roster_id":2}}`),
+		s: http.StatusBadRequest,
+	},
+	{
+		d: "expect malformed JSON to be rejected",
+		p: []byte(`{"active":`),
+		s: http.StatusBadRequest,
+	},
+	{
+		d: "expect MessagePack payload",
+		t: "application/msgpack",
+		p: mustMsgpack(change),
+	},
+	{
+		d: "expect unknown MessagePack fields to be rejected",
+		t: "application/msgpack",
+		p: mustMsgpack(map[string]int{"foo": 1}),
+		s: http.StatusBadRequest,
+	},
+	{
+		d: "expect Protocol Buffers payload",
+		t: "application/x-protobuf",
+		p: mustMarshal(pb.FromPlayerChange(change)),
+	},
+	{
+		d: "expect unsupported content type to be rejected",
+		t: "application/xml",
+		p: []byte(`<change/>`),
+		s: http.StatusUnsupportedMediaType,
+	},
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range decodeTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", bytes.NewReader(tt.p))
+			if tt.t != "" {
+				r.Header.Set("Content-Type", tt.t)
+			}
+			var got store.PlayerChange
+			err := decode(r, &got)
+			if tt.s != 0 {
+				if want, got := tt.s, newProblem(err, 0).Status; want != got {
+					t.Errorf("want status %d got %d", want, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(change, got) {
+				t.Errorf("want\n%+v\ngot\n%+v", change, got)
+			}
+		})
+	}
+}
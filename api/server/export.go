@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fgrimme/patrongg/bulk"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// rosterExporter streams roster rows from the datastore.
+type rosterExporter interface {
+	Export(ctx context.Context, rosterID uint64, fn func(store.RosterRow) error) error
+}
+
+// exportService provides API methods to export rosters in bulk formats.
+// Exports are streamed and may take longer than other requests, the timeout
+// bounds the entire export.
+type exportService struct {
+	rosterExporter
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the export endpoints. Exports all rosters if the
+// URL path does not contain a roster id.
+func (es *exportService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), es.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	var rosterID uint64
+	if id, ok := mux.Vars(r)["id"]; ok {
+		var err error
+		if rosterID, err = strconv.ParseUint(id, 10, 64); err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
+			writeError(w, r, errBadRequest, http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = bulk.FormatCSV
+	case bulk.FormatCSV, bulk.FormatNDJSON, bulk.FormatMarkdown:
+	default:
+		writeError(w, r, invalidParam("format", fmt.Sprintf("must be one of %s, %s or %s",
+			bulk.FormatCSV, bulk.FormatNDJSON, bulk.FormatMarkdown)), http.StatusBadRequest)
+		return
+	}
+	es.export(ctx, w, r, rosterID, format)
+}
+
+// export streams the rows of the roster with the given id, or of all rosters,
+// in the given format. The response is started with the first row, errors that
+// occur afterwards are logged and abort the connection, so that clients do not
+// mistake a truncated export for a complete one.
+func (es *exportService) export(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64, format string) {
+	var bw bulk.Writer
+	start := func() error {
+		filename := "rosters"
+		if rosterID != 0 {
+			filename = fmt.Sprintf("roster-%d", rosterID)
+		}
+		w.Header().Set("Content-Type", bulk.ContentType(format))
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="%s.%s"`, filename, bulk.FileExtension(format)))
+		w.WriteHeader(http.StatusOK)
+		var err error
+		bw, err = bulk.NewWriter(w, format)
+		return err
+	}
+
+	err := es.Export(ctx, rosterID, func(row store.RosterRow) error {
+		if bw == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return bw.Write(row)
+	})
+	if err != nil && bw == nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err == nil && bw == nil {
+		// nothing to export, we still respond with an empty document
+		err = start()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		loggerFromRequest(r).Error().Err(err).Msg("failed to export rosters")
+		if bw != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
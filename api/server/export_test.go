@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// uses the roster id to get the test data.
+func (rs *mockRosterStore) Export(ctx context.Context, rosterID uint64, fn func(store.RosterRow) error) error {
+	for _, row := range exportTests[rosterID].r {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return exportTests[rosterID].e
+}
+
+var exportRows = []store.RosterRow{
+	{
+		RosterName: "foo",
+		Player: store.Player{
+			PlayerID:  1,
+			RosterID:  3,
+			FirstName: "Dominic",
+			LastName:  "Luklowski",
+			Alias:     "DataSlayer9",
+			Status:    "active",
+		},
+	},
+	{
+		RosterName: "foo",
+		Player: store.Player{
+			PlayerID:  2,
+			RosterID:  3,
+			FirstName: "Oliver, Jr.",
+			LastName:  "Fieldbutter",
+			Alias:     "=Smaayo|",
+			Status:    "benched",
+		},
+	},
+}
+
+// test cases indexed by roster id, 0 exports all rosters
+var exportTests = map[uint64]struct {
+	d string            // description of test case
+	r []store.RosterRow // mock store response
+	e error             // mock store error
+	p string            // url path for test requests
+	s int               // expected http status code
+	t string            // expected content type
+	b string            // expected payload of response
+	a bool              // expect the response to be aborted
+}{
+	// request errors
+	1: { // 400
+		d: "expect invalid format to result in 400",
+		p: "roster/1/export?format=xlsx",
+		s: http.StatusBadRequest,
+		t: "application/problem+json",
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"format must be one of csv, ndjson or markdown","invalid-params":[{"name":"format","reason":"must be one of csv, ndjson or markdown"}]}` + "\n",
+	},
+	// store errors
+	2: { // 404
+		d: "expect missing roster to result in 404",
+		p: "roster/2/export",
+		e: store.ErrNotFound,
+		s: http.StatusNotFound,
+		t: "application/problem+json",
+		b: `{"type":"about:blank","title":"Not Found","status":404}` + "\n",
+	},
+	// success
+	3: { // 200
+		d: "expect CSV export to be escaped",
+		p: "roster/3/export?format=csv",
+		r: exportRows,
+		s: http.StatusOK,
+		t: "text/csv; charset=utf-8",
+		b: "roster_id,roster_name,player_id,first_name,last_name,alias,status\n" +
+			"3,foo,1,Dominic,Luklowski,DataSlayer9,active\n" +
+			"3,foo,2,\"Oliver, Jr.\",Fieldbutter,'=Smaayo|,benched\n",
+	},
+	0: { // 200
+		d: "expect empty markdown export of all rosters",
+		p: "rosters/export?format=markdown",
+		s: http.StatusOK,
+		t: "text/markdown; charset=utf-8",
+		b: "| roster_id | roster_name | player_id | first_name | last_name | alias | status |\n" +
+			"| --- | --- | --- | --- | --- | --- | --- |\n",
+	},
+	4: { // 200
+		d: "expect NDJSON export in column order",
+		p: "roster/4/export?format=ndjson",
+		r: exportRows[:1],
+		s: http.StatusOK,
+		t: "application/x-ndjson",
+		b: `{"roster_id":3,"roster_name":"foo","player_id":1,"first_name":"Dominic","last_name":"Luklowski","alias":"DataSlayer9","status":"active"}` + "\n",
+	},
+	6: { // 200
+		d: "expect failed export to abort the response",
+		p: "roster/6/export",
+		r: exportRows,
+		e: errors.New("connection reset by peer"),
+		a: true,
+	},
+	5: { // 200
+		d: "expect markdown export to be escaped",
+		p: "roster/5/export?format=markdown",
+		r: exportRows[1:],
+		s: http.StatusOK,
+		t: "text/markdown; charset=utf-8",
+		b: "| roster_id | roster_name | player_id | first_name | last_name | alias | status |\n" +
+			"| --- | --- | --- | --- | --- | --- | --- |\n" +
+			"| 3 | foo | 2 | Oliver, Jr. | Fieldbutter | =Smaayo\\| | benched |\n",
+	},
+}
+
+func TestExport(t *testing.T) {
+	// service initialized with a mock store to
+	// control the rosters and errors we return
+	es := &exportService{
+		&mockRosterStore{},
+		200 * time.Millisecond,
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/roster/{id:[0-9]+}/export", es).Methods("GET")
+	router.Handle("/rosters/export", es).Methods("GET")
+
+	s := httptest.NewServer(router)
+	defer s.Close()
+	c := s.Client()
+
+	for _, tc := range exportTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", s.URL, tt.p), nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp, err := c.Do(req)
+			// truncated exports are not completed
+			if tt.a {
+				if err == nil {
+					_, err = ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+				if err == nil {
+					t.Error("want aborted response")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			// expected result
+			if want, got := tt.s, resp.StatusCode; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.t, resp.Header.Get("Content-Type"); want != got {
+				t.Errorf("want content type %s got %s", want, got)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp.Body.Close()
+			if want, got := tt.b, string(body); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
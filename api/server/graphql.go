@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/api/gql"
+)
+
+// graphqlRequest is the payload of a GraphQL request.
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlService provides the GraphQL endpoint.
+type graphqlService struct {
+	schema  *gql.Schema
+	timeout time.Duration
+}
+
+// ServeHTTP serves GraphQL requests. Queries are accepted via GET with the
+// query parameters query, operationName and variables or via POST with a JSON
+// payload. Mutations are only executed for POST requests. Errors that occur
+// while executing the request are reported in the errors member of the result.
+func (gs *graphqlService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), gs.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, r, invalidParam("variables", "must be a JSON object"), http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := decode(r, &req); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		writeError(w, r, invalidParam("query", "must not be empty"), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet && gql.IsMutation(req.Query, req.OperationName) {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusMethodNotAllowed),
+			Status: http.StatusMethodNotAllowed,
+			Detail: "mutations must be sent via POST",
+		}, http.StatusMethodNotAllowed)
+		return
+	}
+
+	encodeJSON(w, r, gs.schema.Do(ctx, req.Query, req.OperationName, req.Variables), http.StatusOK)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/api/gql"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/testdata"
+)
+
+// returns the test roster without players.
+func (rs *mockRosterStore) List(ctx context.Context, name string) ([]store.Roster, error) {
+	r := testdata.Rosters[382574876546039808].R
+	return []store.Roster{{RosterID: r.RosterID, Name: r.Name}}, nil
+}
+
+// returns the benched players of the test roster.
+func (ps *mockPlayerStore) Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error) {
+	return testdata.Rosters[382574876546039808].R.Players.Benched, nil
+}
+
+// returns a former alias of the first player.
+func (ps *mockPlayerStore) Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error) {
+	renamed := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []store.AliasChange{{PlayerID: playerIDs[0], Alias: "Smaayo_", RenamedAt: renamed}}, nil
+}
+
+// returns no transfers.
+func (ts *mockTransferStore) ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error) {
+	return nil, nil
+}
+
+// returns match 1 for the first roster.
+func (ms *mockMatchStore) ListRosters(ctx context.Context, rosterIDs []uint64) ([]store.Match, error) {
+	m, _ := ms.Get(ctx, 1)
+	m.RosterID = rosterIDs[0]
+	return []store.Match{*m}, nil
+}
+
+// returns a lineup of the first match.
+func (ms *mockMatchStore) ListLineups(ctx context.Context, matchIDs []uint64) ([]store.Lineup, error) {
+	return ms.Lineups(ctx, matchIDs[0])
+}
+
+var graphqlTests = []struct {
+	d string // description of test case
+	m string // http method of the request
+	q string // url query of the request
+	p string // request payload
+	s int    // expected http status code
+	b string // expected payload of response
+}{
+	{
+		d: "expect query via GET",
+		m: http.MethodGet,
+		q: "query=" + url.QueryEscape(`{rosters{id name players{alias}}}`),
+		s: http.StatusOK,
+		b: `{"data":{"rosters":[{"id":"382574876546039808","name":"foo","players":[{"alias":"Smaayo"}]}]}}` + "\n",
+	},
+	{
+		d: "expect query via POST",
+		m: http.MethodPost,
+		p: `{"query":"query($alias: String){players(alias: $alias){id status}}","variables":{"alias":"smaa"}}`,
+		s: http.StatusOK,
+		b: `{"data":{"players":[{"id":"184315303323238400","status":"BENCHED"}]}}` + "\n",
+	},
+	{
+		d: "expect history of players and matches of rosters",
+		m: http.MethodPost,
+		p: `{"query":"{rosters{matches{id lineups{players{alias}}} players{history{aliases{alias renamedAt} transfers{id}}}}}"}`,
+		s: http.StatusOK,
+		b: `{"data":{"rosters":[{"matches":[{"id":"1","lineups":[{"players":[{"alias":"foobar"}]}]}],"players":[{"history":{"aliases":[{"alias":"Smaayo_","renamedAt":"2020-01-01T12:00:00Z"}],"transfers":[]}}]}]}}` + "\n",
+	},
+	{
+		d: "expect errors of the query to be reported in the result",
+		m: http.MethodPost,
+		p: `{"query":"{foo}"}`,
+		s: http.StatusOK,
+		b: `{"data":null,"errors":[{"message":"Cannot query field \"foo\" on type \"Query\".","locations":[{"line":1,"column":2}]}]}` + "\n",
+	},
+	{
+		d: "expect missing query to result in 400",
+		m: http.MethodPost,
+		p: `{}`,
+		s: http.StatusBadRequest,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"query must not be empty","invalid-params":[{"name":"query","reason":"must not be empty"}]}` + "\n",
+	},
+	{
+		d: "expect mutation via GET to result in 405",
+		m: http.MethodGet,
+		q: "query=" + url.QueryEscape(`mutation{updatePlayer(id: "1", rosterId: "2"){id}}`),
+		s: http.StatusMethodNotAllowed,
+		b: `{"type":"about:blank","title":"Method Not Allowed","status":405,"detail":"mutations must be sent via POST"}` + "\n",
+	},
+}
+
+func TestGraphQL(t *testing.T) {
+	schema, err := gql.NewSchema(&mockRosterStore{}, &mockPlayerStore{}, &mockTransferStore{}, &mockMatchStore{})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	s := httptest.NewServer(&graphqlService{schema, 200 * time.Millisecond})
+	defer s.Close()
+	c := s.Client()
+
+	for _, tc := range graphqlTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			req, err := http.NewRequest(tt.m, fmt.Sprintf("%s/graphql?%s", s.URL, tt.q), strings.NewReader(tt.p))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			// expected result
+			if want, got := tt.s, resp.StatusCode; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp.Body.Close()
+			if want, got := tt.b, string(body); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
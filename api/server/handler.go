@@ -1,49 +1,397 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/api/gql"
+	"github.com/fgrimme/patrongg/auth"
 	"github.com/fgrimme/patrongg/middleware"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 )
 
-// newHandler creates an http handler that operates on rosters and players.
-func newHandler(rs rosterStore, ps playerStore, timeout time.Duration, logger zerolog.Logger) (http.Handler, error) {
+// Stores are the datastores the API operates on.
+type Stores struct {
+	Rosters      rosterStore
+	Players      playerStore
+	Locks        lockStore
+	Matches      matchStore
+	Transfers    transferStore
+	Managers     managerStore
+	Roles        roleStore
+	Orgs         orgStore
+	Titles       titleStore
+	Profiles     profileStore
+	Search       searchStore
+	Contracts    contractStore
+	Compliance   complianceStore
+	Availability availabilityStore
+}
+
+// newHandler creates an http handler that operates on the stores. Exports of
+// rosters are bounded by exportTimeout, all other requests by timeout. Roster
+// responses may be cached by clients for maxAge. API version 1 is removed at
+// sunset, if not zero. Requests are limited per client and route if a rate
+// limiter is given. Clients are identified by their known API keys, if any.
+func newHandler(stores Stores, timeout, exportTimeout, maxAge time.Duration, sunset time.Time, limiter *middleware.RateLimiter, keys auth.Keys, logger zerolog.Logger) (http.Handler, error) {
+	rs, ps := stores.Rosters, stores.Players
 	var mw []middleware.Middleware
 	mw = append(mw, middleware.NewRecoverHandler())
+	// the limiter runs after the keys are validated, so that clients are
+	// only identified by known API keys
+	if limiter != nil {
+		mw = append(mw, limiter.Middleware(routeName, http.HandlerFunc(tooManyRequests)))
+	}
+	if len(keys) > 0 {
+		mw = append(mw, keys.Middleware(http.HandlerFunc(unauthorized)))
+	}
 	mw = append(mw, middleware.NewContextLog(logger)...)
 
-	// services handle http requests and hold a store to operate on a database
-	rosterSrvc := middleware.Use(&rosterService{rs, timeout}, mw...)
-	playerSrvc := middleware.Use(&playerService{ps, timeout}, mw...)
+	// services handle http requests and hold a store to operate on a
+	// database, they are shared by all API versions
+	s := services{
+		roster:       &rosterService{rs, timeout, maxAge, stores.Orgs, stores.Titles},
+		player:       &playerService{ps, stores.Managers, timeout},
+		export:       &exportService{rs, exportTimeout},
+		id:           &idService{},
+		lock:         &lockService{stores.Locks, stores.Managers, timeout},
+		match:        &matchService{stores.Matches, stores.Managers, timeout},
+		transfer:     &transferService{stores.Transfers, ps, stores.Managers, timeout},
+		manager:      &managerService{stores.Managers, timeout},
+		role:         &roleService{stores.Roles, timeout},
+		org:          &orgService{stores.Orgs, timeout},
+		title:        &titleService{stores.Titles, timeout},
+		profile:      &profileService{stores.Profiles, timeout},
+		search:       &searchService{stores.Search, timeout},
+		contract:     &contractService{stores.Contracts, ps, stores.Managers, timeout},
+		compliance:   &complianceService{stores.Compliance, timeout},
+		availability: &availabilityService{stores.Availability, ps, stores.Managers, timeout},
+	}
+
+	schema, err := gql.NewSchema(rs, ps, stores.Transfers, stores.Matches)
+	if err != nil {
+		return nil, err
+	}
+	graphqlSrvc := middleware.Use(&graphqlService{schema, timeout}, mw...)
 
 	router := mux.NewRouter()
 	router.Handle("/ready", &readinessHandler{}).Methods("GET")
 
+	// graphql
+	router.Handle("/graphql", graphqlSrvc).Methods("GET", "POST")
+
+	// the version is attached to the request by the outermost middleware, so
+	// that all errors are represented according to the version
+	v1mw := append(append([]middleware.Middleware{}, mw...), withVersion(v1, sunset))
+	v2mw := append(append([]middleware.Middleware{}, mw...), withVersion(v2, sunset))
+	s.v1(router.PathPrefix("/v1").Subrouter(), v1mw)
+	s.v2(router.PathPrefix("/v2").Subrouter(), v2mw)
+	// unversioned routes are served by version 1
+	s.v1(router, v1mw)
+
+	return router, nil
+}
+
+// services are the http services shared by the API versions.
+type services struct {
+	roster, player, export, id                http.Handler
+	lock, match, transfer, manager, role, org http.Handler
+	title, profile, search, contract          http.Handler
+	compliance, availability                  http.Handler
+}
+
+// v1 registers the routes of API version 1. The verb routes of players are
+// kept as aliases of the resource routes.
+func (s *services) v1(router *mux.Router, mw []middleware.Middleware) {
+	rosterSrvc := middleware.Use(s.roster, mw...)
+	playerSrvc := middleware.Use(s.player, mw...)
+	exportSrvc := middleware.Use(s.export, mw...)
+	lockSrvc := middleware.Use(s.lock, mw...)
+	matchSrvc := middleware.Use(s.match, mw...)
+	transferSrvc := middleware.Use(s.transfer, mw...)
+	managerSrvc := middleware.Use(s.manager, mw...)
+	roleSrvc := middleware.Use(s.role, mw...)
+	orgSrvc := middleware.Use(s.org, mw...)
+	titleSrvc := middleware.Use(s.title, mw...)
+	profileSrvc := middleware.Use(s.profile, mw...)
+	contractSrvc := middleware.Use(s.contract, mw...)
+	complianceSrvc := middleware.Use(s.compliance, mw...)
+	availabilitySrvc := middleware.Use(s.availability, mw...)
+
 	// roster store
-	router.Handle("/roster/{id:[0-9]+}", rosterSrvc).Methods("GET")
-	router.Handle(fmt.Sprintf("/roster/{id:[0-9]+}/{status:(?:%s|%s)}", Active, Benched), rosterSrvc).Methods("GET")
+	handle(router, "/roster/{id:[0-9]+}", rosterSrvc, mw, "GET")
+	handle(router, fmt.Sprintf("/roster/{id:[0-9]+}/{status:(?:%s|%s)}", Active, Benched), rosterSrvc, mw, "GET")
+	handle(router, "/roster/{id:[0-9]+}/export", exportSrvc, mw, "GET")
+	handle(router, "/roster/{id:[0-9]+}/swaps", playerSrvc, mw, "POST")
+	handle(router, "/roster/{id:[0-9]+}/suggestions", availabilitySrvc, mw, "GET")
+	handle(router, "/rosters/export", exportSrvc, mw, "GET")
+	handle(router, "/rosters", rosterSrvc, mw, "POST")
+
+	// lock store
+	handle(router, "/roster/{id:[0-9]+}/locks", lockSrvc, mw, "GET", "POST")
+	handle(router, "/roster/{id:[0-9]+}/locks/{lock_id:[0-9]+}", lockSrvc, mw, "DELETE")
+	handle(router, "/roster/{id:[0-9]+}/unlock", lockSrvc, mw, "POST")
+	handle(router, "/roster/{id:[0-9]+}/overrides", lockSrvc, mw, "GET")
+
+	// match store
+	handle(router, "/roster/{roster_id:[0-9]+}/matches", matchSrvc, mw, "GET")
+	s.matches(router, matchSrvc, mw)
+
+	// transfer and manager stores
+	handle(router, "/roster/{roster_id:[0-9]+}/transfers", transferSrvc, mw, "GET")
+	handle(router, "/roster/{id:[0-9]+}/managers", managerSrvc, mw, "GET")
+	handle(router, "/roster/{id:[0-9]+}/managers/{subject}", managerSrvc, mw, "PUT", "DELETE")
+	s.transfers(router, transferSrvc, mw)
+
+	// contract store
+	handle(router, "/roster/{roster_id:[0-9]+}/contracts", contractSrvc, mw, "GET")
+	handle(router, "/roster/{roster_id:[0-9]+}/expirations", contractSrvc, mw, "GET")
+	s.contracts(router, contractSrvc, mw)
+
+	// compliance store
+	handle(router, "/roster/{id:[0-9]+}/compliance", complianceSrvc, mw, "GET", "PUT")
+	handle(router, "/rosters/compliance", complianceSrvc, mw, "GET")
+
+	// role store
+	handle(router, "/roster/{id:[0-9]+}/requirements", roleSrvc, mw, "GET", "PUT")
+	handle(router, "/players/{id:[0-9]+}/roles", roleSrvc, mw, "PUT")
+
+	// profile store
+	handle(router, "/players/{id:[0-9]+}/profile", profileSrvc, mw, "GET", "PATCH")
+
+	// availability store
+	handle(router, "/players/{id:[0-9]+}/availability", availabilitySrvc, mw, "GET", "PUT")
+
+	// organization store
+	s.organizations(router, orgSrvc, mw)
+
+	// title store
+	s.titles(router, titleSrvc, mw)
 
 	// player store
-	router.Handle("/players/add", playerSrvc).Methods("POST")
-	router.Handle("/players/update", playerSrvc).Methods("PATCH")
-	router.Handle("/players/change", playerSrvc).Methods("PATCH")
+	handle(router, "/players", playerSrvc, mw, "GET", "POST")
+	handle(router, "/players/{id:[0-9]+}", playerSrvc, mw, "GET", "PATCH", "DELETE")
+	handle(router, "/players/import", playerSrvc, mw, "POST")
+	handle(router, "/players/by-alias/{alias}", playerSrvc, mw, "GET")
+	handle(router, "/players/search", middleware.Use(s.search, mw...), mw, "GET")
+	handle(router, "/trades", playerSrvc, mw, "POST")
+	handle(router, "/players/add", playerSrvc, mw, "POST")
+	handle(router, "/players/update", playerSrvc, mw, "PATCH")
+	handle(router, "/players/change", playerSrvc, mw, "PATCH")
 
-	return router, nil
+	// ids
+	handle(router, "/ids/{id:[0-9]+}", middleware.Use(s.id, mw...), mw, "GET")
+}
+
+// v2 registers the routes of API version 2. All rosters are found under
+// /rosters, players are only operated on by resource routes.
+func (s *services) v2(router *mux.Router, mw []middleware.Middleware) {
+	rosterSrvc := middleware.Use(s.roster, mw...)
+	playerSrvc := middleware.Use(s.player, mw...)
+	exportSrvc := middleware.Use(s.export, mw...)
+	lockSrvc := middleware.Use(s.lock, mw...)
+	matchSrvc := middleware.Use(s.match, mw...)
+	transferSrvc := middleware.Use(s.transfer, mw...)
+	managerSrvc := middleware.Use(s.manager, mw...)
+	roleSrvc := middleware.Use(s.role, mw...)
+	orgSrvc := middleware.Use(s.org, mw...)
+	titleSrvc := middleware.Use(s.title, mw...)
+	profileSrvc := middleware.Use(s.profile, mw...)
+	contractSrvc := middleware.Use(s.contract, mw...)
+	complianceSrvc := middleware.Use(s.compliance, mw...)
+	availabilitySrvc := middleware.Use(s.availability, mw...)
+
+	// roster store
+	handle(router, "/rosters", rosterSrvc, mw, "POST")
+	handle(router, "/rosters/export", exportSrvc, mw, "GET")
+	handle(router, "/rosters/{id:[0-9]+}", rosterSrvc, mw, "GET")
+	handle(router, fmt.Sprintf("/rosters/{id:[0-9]+}/players/{status:(?:%s|%s)}", Active, Benched), rosterSrvc, mw, "GET")
+	handle(router, "/rosters/{id:[0-9]+}/export", exportSrvc, mw, "GET")
+	handle(router, "/rosters/{id:[0-9]+}/swaps", playerSrvc, mw, "POST")
+	handle(router, "/rosters/{id:[0-9]+}/suggestions", availabilitySrvc, mw, "GET")
+
+	// lock store
+	handle(router, "/rosters/{id:[0-9]+}/locks", lockSrvc, mw, "GET", "POST")
+	handle(router, "/rosters/{id:[0-9]+}/locks/{lock_id:[0-9]+}", lockSrvc, mw, "DELETE")
+	handle(router, "/rosters/{id:[0-9]+}/unlock", lockSrvc, mw, "POST")
+	handle(router, "/rosters/{id:[0-9]+}/overrides", lockSrvc, mw, "GET")
+
+	// match store
+	handle(router, "/rosters/{roster_id:[0-9]+}/matches", matchSrvc, mw, "GET")
+	s.matches(router, matchSrvc, mw)
+
+	// transfer and manager stores
+	handle(router, "/rosters/{roster_id:[0-9]+}/transfers", transferSrvc, mw, "GET")
+	handle(router, "/rosters/{id:[0-9]+}/managers", managerSrvc, mw, "GET")
+	handle(router, "/rosters/{id:[0-9]+}/managers/{subject}", managerSrvc, mw, "PUT", "DELETE")
+	s.transfers(router, transferSrvc, mw)
+
+	// contract store
+	handle(router, "/rosters/{roster_id:[0-9]+}/contracts", contractSrvc, mw, "GET")
+	handle(router, "/rosters/{roster_id:[0-9]+}/expirations", contractSrvc, mw, "GET")
+	s.contracts(router, contractSrvc, mw)
+
+	// compliance store
+	handle(router, "/rosters/{id:[0-9]+}/compliance", complianceSrvc, mw, "GET", "PUT")
+	handle(router, "/rosters/compliance", complianceSrvc, mw, "GET")
+
+	// role store
+	handle(router, "/rosters/{id:[0-9]+}/requirements", roleSrvc, mw, "GET", "PUT")
+	handle(router, "/players/{id:[0-9]+}/roles", roleSrvc, mw, "PUT")
+
+	// profile store
+	handle(router, "/players/{id:[0-9]+}/profile", profileSrvc, mw, "GET", "PATCH")
+
+	// availability store
+	handle(router, "/players/{id:[0-9]+}/availability", availabilitySrvc, mw, "GET", "PUT")
+
+	// organization store
+	s.organizations(router, orgSrvc, mw)
+
+	// title store
+	s.titles(router, titleSrvc, mw)
+
+	// player store
+	handle(router, "/players", playerSrvc, mw, "GET", "POST")
+	handle(router, "/players/{id:[0-9]+}", playerSrvc, mw, "GET", "PATCH", "DELETE")
+	handle(router, "/players/import", playerSrvc, mw, "POST")
+	handle(router, "/players/by-alias/{alias}", playerSrvc, mw, "GET")
+	handle(router, "/players/search", middleware.Use(s.search, mw...), mw, "GET")
+	handle(router, "/trades", playerSrvc, mw, "POST")
+
+	// ids
+	handle(router, "/ids/{id:[0-9]+}", middleware.Use(s.id, mw...), mw, "GET")
+}
+
+// matches registers the match routes, which are alike in all API versions.
+func (s *services) matches(router *mux.Router, matchSrvc http.Handler, mw []middleware.Middleware) {
+	handle(router, "/matches", matchSrvc, mw, "POST")
+	handle(router, "/matches/{id:[0-9]+}", matchSrvc, mw, "GET", "PATCH")
+	handle(router, "/matches/{id:[0-9]+}/lineups", matchSrvc, mw, "GET", "POST")
+	handle(router, "/matches/{id:[0-9]+}/lineups/{roster_id:[0-9]+}", matchSrvc, mw, "GET")
+}
+
+// transfers registers the transfer routes, which are alike in all API
+// versions.
+func (s *services) transfers(router *mux.Router, transferSrvc http.Handler, mw []middleware.Middleware) {
+	handle(router, "/transfers", transferSrvc, mw, "POST")
+	handle(router, "/transfers/{id:[0-9]+}", transferSrvc, mw, "GET", "PATCH")
+}
+
+// contracts registers the contract routes, which are alike in all API
+// versions.
+func (s *services) contracts(router *mux.Router, contractSrvc http.Handler, mw []middleware.Middleware) {
+	handle(router, "/contracts", contractSrvc, mw, "POST")
+	handle(router, "/contracts/{id:[0-9]+}", contractSrvc, mw, "GET")
+}
+
+// organizations registers the organization routes, which are alike in all
+// API versions.
+func (s *services) organizations(router *mux.Router, orgSrvc http.Handler, mw []middleware.Middleware) {
+	handle(router, "/organizations", orgSrvc, mw, "GET", "POST")
+	handle(router, "/organizations/{id:[0-9]+}", orgSrvc, mw, "GET")
+	handle(router, "/organizations/{id:[0-9]+}/rosters", orgSrvc, mw, "GET")
+	handle(router, "/organizations/{id:[0-9]+}/managers", orgSrvc, mw, "GET")
+	handle(router, "/organizations/{id:[0-9]+}/managers/{subject}", orgSrvc, mw, "PUT", "DELETE")
+}
+
+// titles registers the game title routes, which are alike in all API
+// versions.
+func (s *services) titles(router *mux.Router, titleSrvc http.Handler, mw []middleware.Middleware) {
+	handle(router, "/titles", titleSrvc, mw, "GET", "POST")
+	handle(router, "/titles/{id:[0-9]+}", titleSrvc, mw, "GET")
+}
+
+// handle registers h for the given methods of the path. Requests with other
+// methods are answered with 405 and the allowed methods.
+func handle(router *mux.Router, path string, h http.Handler, mw []middleware.Middleware, methods ...string) {
+	router.Handle(path, h).Methods(methods...)
+	router.Handle(path, middleware.Use(methodNotAllowed(methods...), mw...))
+}
+
+// methodNotAllowed responds with 405 and the Allow header listing the given
+// methods.
+func methodNotAllowed(methods ...string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeError(w, r, &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusMethodNotAllowed),
+			Status: http.StatusMethodNotAllowed,
+			Detail: fmt.Sprintf("allowed methods are %s", allow),
+		}, http.StatusMethodNotAllowed)
+	})
+}
+
+// routeVar matches the regular expressions of route variables.
+var routeVar = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// routeName returns the method and path template of the route matching the
+// request without the regular expressions of route variables, e.g.
+// "GET /roster/{id}". Routes of API version 1 are named alike with and without
+// version prefix.
+func routeName(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.Method + " " + unversioned(r.URL.Path)
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return r.Method + " " + unversioned(r.URL.Path)
+	}
+	return r.Method + " " + unversioned(routeVar.ReplaceAllString(tpl, "{$1}"))
+}
+
+// tooManyRequests responds to requests exceeding the rate limit.
+func tooManyRequests(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &api.Error{
+		Type:   api.BlankProblemType,
+		Title:  http.StatusText(http.StatusTooManyRequests),
+		Status: http.StatusTooManyRequests,
+		Detail: fmt.Sprintf("rate limit exceeded, retry after %s seconds", w.Header().Get("Retry-After")),
+	}, http.StatusTooManyRequests)
 }
 
 // encodeJSON encodes v to w in JSON format.
 func encodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
+	writeJSON(w, r, "application/json", v, status)
+}
+
+// writeJSON encodes v to w in JSON format using the given JSON based media type.
+// Ids are encoded as strings if requested.
+func writeJSON(w http.ResponseWriter, r *http.Request, mediaType string, v interface{}, status int) {
+	w.Header().Set("Content-Type", mediaType)
+	if !stringIDs(r) {
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			loggerFromRequest(r).Error().Err(err).Interface("value", v).Msg("failed to encode value to http response")
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	var b []byte
+	if err == nil {
+		b, err = quoteIDs(buf.Bytes())
+	}
+	if err != nil {
 		loggerFromRequest(r).Error().Err(err).Interface("value", v).Msg("failed to encode value to http response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		loggerFromRequest(r).Error().Err(err).Msg("failed to write http response")
 	}
 }
 
@@ -54,20 +402,3 @@ func loggerFromRequest(r *http.Request) *zerolog.Logger {
 		Logger()
 	return &logger
 }
-
-// writeError writes an error to the http response in JSON format.
-func writeError(w http.ResponseWriter, r *http.Request, err error, code int) {
-	// prepare log
-	logger := loggerFromRequest(r).With().
-		Err(err).
-		Int("status", code).
-		Logger()
-	// hide error from client if it's internal
-	if code == http.StatusInternalServerError {
-		logger.Error().Msg("unexpected http error")
-		err = errInternal
-	} else {
-		logger.Debug().Msg("http error")
-	}
-	encodeJSON(w, r, &api.Error{Err: err.Error()}, code)
-}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/rs/zerolog"
+)
+
+// testStores returns the mock stores of the handler tests. Tests replace the
+// stores that need to behave differently.
+func testStores() Stores {
+	return Stores{
+		Rosters:      &mockRosterStore{},
+		Players:      &mockPlayerStore{},
+		Locks:        &mockLockStore{},
+		Matches:      &mockMatchStore{},
+		Transfers:    &mockTransferStore{},
+		Managers:     &mockManagerStore{},
+		Roles:        &mockRoleStore{},
+		Orgs:         &mockOrgStore{},
+		Titles:       &mockTitleStore{},
+		Profiles:     &mockProfileStore{},
+		Search:       testSearchStore,
+		Contracts:    &mockContractStore{},
+		Compliance:   &mockComplianceStore{},
+		Availability: &mockAvailabilityStore{},
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), map[string]middleware.Limit{
+		"GET /roster/{id}/{status}": {Rate: 0.1, Burst: 1},
+	})
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, limiter, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		p string // url path
+		k string // api key
+		s int    // expected status code, 0 if the request is not limited
+		r string // expected Retry-After header
+		d string // expected detail of the problem
+	}{
+		{p: "/roster/1/active"},
+		{
+			p: "/roster/2/benched",
+			s: http.StatusTooManyRequests,
+			r: "10",
+			d: "rate limit exceeded, retry after 10 seconds",
+		},
+		// unknown api keys do not identify clients
+		{
+			p: "/roster/3/active",
+			k: "made-up",
+			s: http.StatusTooManyRequests,
+			r: "10",
+		},
+		// the limit applies per route
+		{p: "/roster/1"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.p, nil)
+		if tt.k != "" {
+			r.Header.Set(middleware.APIKeyHeader, tt.k)
+		}
+		h.ServeHTTP(w, r)
+		if tt.s == 0 && w.Code == http.StatusTooManyRequests {
+			t.Errorf("%s: unexpected status code %d", tt.p, w.Code)
+		}
+		if tt.s != 0 && tt.s != w.Code {
+			t.Errorf("%s: want status code %d got %d", tt.p, tt.s, w.Code)
+		}
+		if want, got := tt.r, w.Header().Get("Retry-After"); want != got {
+			t.Errorf("%s: want Retry-After %q got %q", tt.p, want, got)
+		}
+		if tt.d == "" {
+			continue
+		}
+		var problem api.Error
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want, got := tt.d, problem.Detail; want != got {
+			t.Errorf("%s: want detail %q got %q", tt.p, want, got)
+		}
+	}
+}
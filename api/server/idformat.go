@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// IDFormatHeader is the header to request the representation of ids in JSON
+// payloads. The query parameter idFormatParam takes precedence.
+const IDFormatHeader = "X-Id-Format"
+
+const (
+	idFormatParam = "id_format"
+
+	// ID formats of JSON payloads. 64-bit ids exceed the range of integers
+	// JavaScript can represent exactly, so clients may request them as strings.
+	idFormatNumber = "number"
+	idFormatString = "string"
+)
+
+// stringIDs reports whether the ids of JSON responses to the request are
+// encoded as strings. Requests may ask for either format, by default ids are
+// encoded as strings since API version 2.
+func stringIDs(r *http.Request) bool {
+	format := r.URL.Query().Get(idFormatParam)
+	if format == "" {
+		format = r.Header.Get(IDFormatHeader)
+	}
+	if format == "" {
+		return versionFromRequest(r) >= v2
+	}
+	return strings.EqualFold(format, idFormatString)
+}
+
+// opaqueMembers are the names of members holding documents of arbitrary
+// members. Their values are kept as they are, even if named like ids.
+var opaqueMembers = map[string]bool{
+	"custom":   true,
+	"accounts": true,
+	"socials":  true,
+	"terms":    true,
+}
+
+// isIDKey reports whether the member of a JSON object with the given name
+// holds an id or a list of ids.
+func isIDKey(key string) bool {
+	return key == "id" || strings.HasSuffix(key, "_id") || strings.HasSuffix(key, "_ids")
+}
+
+// quoteIDs encodes the numeric ids of a JSON document as strings. Members are
+// kept in order.
+func quoteIDs(b []byte) ([]byte, error) {
+	return rewriteIDs(b, func(id interface{}) interface{} {
+		if n, ok := id.(json.Number); ok {
+			return n.String()
+		}
+		return id
+	})
+}
+
+// unquoteIDs decodes the ids of a JSON document that are encoded as strings
+// of digits to numbers. Members are kept in order.
+func unquoteIDs(b []byte) ([]byte, error) {
+	return rewriteIDs(b, func(id interface{}) interface{} {
+		if s, ok := id.(string); ok && isDigits(s) {
+			return json.Number(s)
+		}
+		return id
+	})
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// frame is an object or array of a JSON document being rewritten.
+type frame struct {
+	object bool // the frame is an object, otherwise an array
+	key    bool // the next token of an object is a member name
+	ids    bool // the elements of the array or the current member are ids
+	opaque bool // the frame is part of the value of an opaque member
+	skip   bool // the value of the current member is opaque
+	n      int  // number of members or elements written
+}
+
+// rewriteIDs rewrites a JSON document token by token, replacing the scalar
+// values of ids and of lists of ids by the results of fn. The values of
+// opaqueMembers are kept as they are.
+func rewriteIDs(b []byte, fn func(id interface{}) interface{}) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	var stack []*frame
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			buf.WriteRune(rune(d))
+			continue
+		}
+
+		id := false
+		switch {
+		case top == nil:
+		case top.object && top.key:
+			// member names are always strings
+			if top.n > 0 {
+				buf.WriteByte(',')
+			}
+			top.n++
+			top.key = false
+			writeToken(&buf, tok)
+			buf.WriteByte(':')
+			// the value of the member follows
+			top.ids = !top.opaque && isIDKey(tok.(string))
+			top.skip = opaqueMembers[tok.(string)]
+			continue
+		case top.object:
+			top.key = true
+			id = top.ids
+		default:
+			if top.n > 0 {
+				buf.WriteByte(',')
+			}
+			top.n++
+			id = top.ids
+		}
+
+		opaque := top != nil && (top.opaque || top.skip)
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &frame{object: true, key: true, opaque: opaque})
+			buf.WriteByte('{')
+		case json.Delim('['):
+			stack = append(stack, &frame{ids: id && !opaque, opaque: opaque})
+			buf.WriteByte('[')
+		default:
+			if id {
+				tok = fn(tok)
+			}
+			writeToken(&buf, tok)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeToken writes a scalar JSON token.
+func writeToken(buf *bytes.Buffer, tok json.Token) {
+	switch v := tok.(type) {
+	case json.Number:
+		buf.WriteString(v.String())
+	case nil:
+		buf.WriteString("null")
+	default:
+		// strings and booleans can not fail to encode
+		b, _ := json.Marshal(v)
+		buf.Write(b)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+func TestRewriteIDs(t *testing.T) {
+	tests := []struct {
+		d string                         // description of test case
+		f func(b []byte) ([]byte, error) // rewrite function
+		p string                         // JSON document
+		b string                         // expected document
+	}{
+		{
+			d: "expect ids to be quoted",
+			f: quoteIDs,
+			p: `{"roster_id":382574876546039808,"name":"foo","players":{"active":[{"player_id":1,"roster_id":2,"age":3}],"benched":null}}`,
+			b: `{"roster_id":"382574876546039808","name":"foo","players":{"active":[{"player_id":"1","roster_id":"2","age":3}],"benched":null}}`,
+		},
+		{
+			d: "expect lists of ids to be quoted",
+			f: quoteIDs,
+			p: `[{"id":1,"player_ids":[1,2],"scores":[1,2],"ok":true}]`,
+			b: `[{"id":"1","player_ids":["1","2"],"scores":[1,2],"ok":true}]`,
+		},
+		{
+			d: "expect nested objects of id members to be left as is",
+			f: quoteIDs,
+			p: `{"roster_id":{"value":1}}`,
+			b: `{"roster_id":{"value":1}}`,
+		},
+		{
+			d: "expect members of opaque members to be left as is",
+			f: quoteIDs,
+			p: `{"player_id":1,"custom":{"faceit_id":2,"team":{"id":3},"ids":[{"id":4}]},"accounts":{"steam_id":5},"roster_id":6}`,
+			b: `{"player_id":"1","custom":{"faceit_id":2,"team":{"id":3},"ids":[{"id":4}]},"accounts":{"steam_id":5},"roster_id":"6"}`,
+		},
+		{
+			d: "expect string members of opaque members to be left as is",
+			f: unquoteIDs,
+			p: `[{"player_id":"1","socials":{"twitch_id":"0042"},"custom":{"team_ids":["2"]}},{"player_id":"3"}]`,
+			b: `[{"player_id":1,"socials":{"twitch_id":"0042"},"custom":{"team_ids":["2"]}},{"player_id":3}]`,
+		},
+		{
+			d: "expect string ids to be unquoted",
+			f: unquoteIDs,
+			p: `{"active":{"player_id":"1","alias":"7"},"benched":{"player_id":2},"player_ids":["3","x"]}`,
+			b: `{"active":{"player_id":1,"alias":"7"},"benched":{"player_id":2},"player_ids":[3,"x"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			got, err := tt.f([]byte(tt.p))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if want := tt.b; want != string(got) {
+				t.Errorf("want\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestStringIDs(t *testing.T) {
+	tests := []struct {
+		d string // description of test case
+		u string // request url
+		h string // id format header
+		b string // expected payload
+	}{
+		{
+			d: "expect numeric ids by default",
+			u: "/",
+			b: `{"player_id":382574876546039808,"roster_id":1,"first_name":"","last_name":"","alias":"","status":""}`,
+		},
+		{
+			d: "expect string ids requested by header",
+			u: "/",
+			h: "string",
+			b: `{"player_id":"382574876546039808","roster_id":"1","first_name":"","last_name":"","alias":"","status":""}`,
+		},
+		{
+			d: "expect query parameter to take precedence",
+			u: "/?id_format=number",
+			h: "string",
+			b: `{"player_id":382574876546039808,"roster_id":1,"first_name":"","last_name":"","alias":"","status":""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.u, nil)
+			if tt.h != "" {
+				r.Header.Set(IDFormatHeader, tt.h)
+			}
+			encode(w, r, &store.Player{PlayerID: 382574876546039808, RosterID: 1}, http.StatusOK)
+			if want, got := tt.b, strings.TrimSpace(w.Body.String()); want != got {
+				t.Errorf("want\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
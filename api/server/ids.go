@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fgrimme/patrongg/snowflake"
+	"github.com/gorilla/mux"
+)
+
+// idService decodes the ids of rosters and players.
+type idService struct{}
+
+// ServeHTTP responds with the creation time, worker id and sequence number
+// encoded in the id.
+func (is *idService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, r, invalidParam("id", "must be an unsigned 64-bit integer"), http.StatusBadRequest)
+		return
+	}
+	encode(w, r, snowflake.Decode(id), http.StatusOK)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDecodeID(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/ids/{id:[0-9]+}", &idService{}).Methods("GET")
+
+	tests := []struct {
+		d string // description of test case
+		p string // url path for test requests
+		s int    // expected http status code
+		b string // expected payload of response
+	}{
+		{
+			d: "expect seed roster id to be decoded",
+			p: "/ids/382574876546039808",
+			s: http.StatusOK,
+			b: `{"id":382574876546039808,"created_at":"2017-11-21T16:55:58.466Z","worker_id":64,"sequence":0}`,
+		},
+		{
+			d: "expect id overflowing 64 bits to result in 400",
+			p: "/ids/18446744073709551616",
+			s: http.StatusBadRequest,
+			b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"id must be an unsigned 64-bit integer","invalid-params":[{"name":"id","reason":"must be an unsigned 64-bit integer"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.p, nil))
+			if want, got := tt.s, rec.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			body, _ := ioutil.ReadAll(rec.Body)
+			if want, got := tt.b, strings.TrimSpace(string(body)); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
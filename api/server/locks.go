@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// LockOverrideHeader is the request header of admins to change the players of
+// locked rosters. It holds the reason of the override, which is recorded.
+const LockOverrideHeader = "X-Lock-Override"
+
+// lockStore handles operations on the locks of rosters.
+type lockStore interface {
+	Create(ctx context.Context, lock store.Lock) (*store.Lock, error)
+	List(ctx context.Context, rosterID uint64) ([]store.Lock, error)
+	Delete(ctx context.Context, rosterID, lockID uint64) error
+	Unlock(ctx context.Context, rosterID uint64) ([]store.Lock, error)
+	Overrides(ctx context.Context, rosterID uint64) ([]store.Override, error)
+}
+
+// lockService provides API methods to lock rosters.
+type lockService struct {
+	lockStore
+	managers managerStore
+	timeout  time.Duration
+}
+
+// ServeHTTP serves requests to the lock endpoints of a roster. Only admins and
+// managers of the roster may lock and unlock it.
+func (ls *lockService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ls.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	vars := mux.Vars(r)
+	rosterID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		if err := ls.authorize(ctx, r, rosterID); err != nil {
+			writeError(w, r, err, http.StatusForbidden)
+			return
+		}
+	}
+	if v, ok := vars["lock_id"]; ok {
+		lockID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
+			writeError(w, r, errBadRequest, http.StatusBadRequest)
+			return
+		}
+		ls.delete(ctx, w, r, rosterID, lockID)
+		return
+	}
+
+	_, route := path.Split(r.URL.Path)
+	switch {
+	case route == "locks" && r.Method == http.MethodGet:
+		ls.list(ctx, w, r, rosterID)
+	case route == "locks" && r.Method == http.MethodPost:
+		// we expect a request body that represents a lock window or we
+		// consider the request as invalid
+		var lock store.Lock
+		if err := decode(r, &lock); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		lock.RosterID = rosterID
+		ls.create(ctx, w, r, lock)
+	case route == "unlock":
+		ls.unlock(ctx, w, r, rosterID)
+	case route == "overrides":
+		ls.overrides(ctx, w, r, rosterID)
+	default:
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errNotFound, http.StatusNotFound)
+	}
+}
+
+// create locks the roster for the given window. Locks without start start
+// now, locks without end last until the roster is unlocked. Responds with 201,
+// the location and the representation of the lock or an error.
+func (ls *lockService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, lock store.Lock) {
+	start := lock.StartsAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	if lock.EndsAt != nil && !lock.EndsAt.After(start) {
+		writeError(w, r, invalidParam("ends_at", "must be after the start of the lock"), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(lock.Reason) > 256 {
+		writeError(w, r, invalidParam("reason", "must not have more than 256 characters"), http.StatusBadRequest)
+		return
+	}
+	l, err := ls.Create(ctx, lock)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/locks/%d", rosterPath(r, l.RosterID), l.LockID))
+	encode(w, r, l, http.StatusCreated)
+}
+
+// list responds with the current and upcoming locks of the roster.
+func (ls *lockService) list(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
+	locks, err := ls.List(ctx, rosterID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, locks, http.StatusOK)
+}
+
+// delete deletes a lock of the roster. Responds with 204 or an error.
+func (ls *lockService) delete(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID, lockID uint64) {
+	if err := ls.Delete(ctx, rosterID, lockID); err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unlock ends the current locks of the roster. Responds with the ended locks.
+func (ls *lockService) unlock(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
+	locks, err := ls.Unlock(ctx, rosterID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, locks, http.StatusOK)
+}
+
+// overrides responds with the recorded lock overrides of the roster.
+func (ls *lockService) overrides(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
+	overrides, err := ls.Overrides(ctx, rosterID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, overrides, http.StatusOK)
+}
+
+// authorize returns a 401 problem for anonymous clients and a 403 problem if
+// the principal of the request is neither an admin nor a manager of the
+// roster.
+func (ls *lockService) authorize(ctx context.Context, r *http.Request, rosterID uint64) error {
+	p, err := principal(r)
+	if err != nil {
+		return err
+	}
+	return authorize(ctx, ls.managers, p, rosterID)
+}
+
+// lockOverride returns a context permitting changes of locked rosters if the
+// request asks for it. Only admins may override locks.
+func lockOverride(ctx context.Context, r *http.Request) (context.Context, error) {
+	reason := r.Header.Get(LockOverrideHeader)
+	if reason == "" {
+		return ctx, nil
+	}
+	p, ok := auth.FromContext(r.Context())
+	if !ok || !p.Admin {
+		return ctx, forbidden("only admins may override roster locks")
+	}
+	return store.WithLockOverride(ctx, store.LockOverride{Subject: p.Subject, Reason: reason}), nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// roster 2 exists, it is locked by lock 1.
+type mockLockStore struct{}
+
+func (ls *mockLockStore) Create(ctx context.Context, lock store.Lock) (*store.Lock, error) {
+	if lock.RosterID != 2 {
+		return nil, store.ErrNotFound
+	}
+	lock.LockID = 2
+	return &lock, nil
+}
+
+func (ls *mockLockStore) List(ctx context.Context, rosterID uint64) ([]store.Lock, error) {
+	if rosterID != 2 {
+		return []store.Lock{}, nil
+	}
+	return []store.Lock{{LockID: 1, RosterID: 2, Reason: "match"}}, nil
+}
+
+func (ls *mockLockStore) Delete(ctx context.Context, rosterID, lockID uint64) error {
+	if rosterID != 2 || lockID != 1 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (ls *mockLockStore) Unlock(ctx context.Context, rosterID uint64) ([]store.Lock, error) {
+	return ls.List(ctx, rosterID)
+}
+
+func (ls *mockLockStore) Overrides(ctx context.Context, rosterID uint64) ([]store.Override, error) {
+	return []store.Override{{OverrideID: 3, LockID: 1, RosterID: rosterID, Subject: "alice", Reason: "injury"}}, nil
+}
+
+// lockedPlayerStore rejects changes of players unless the lock is overridden.
+type lockedPlayerStore struct {
+	mockPlayerStore
+}
+
+func (ps *lockedPlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
+	o, ok := store.LockOverrideFromContext(ctx)
+	if !ok {
+		return nil, &store.LockedError{RosterID: 1, Lock: store.Lock{LockID: 1, RosterID: 1, Reason: "match"}}
+	}
+	players.Active.Alias = o.Subject
+	return &players, nil
+}
+
+func TestLocks(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"user":  {Subject: "bob"},
+		"carol": {Subject: "carol"},
+	}
+	stores := testStores()
+	stores.Players = &lockedPlayerStore{}
+	h, err := newHandler(stores, 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		o string // lock override header
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect locks of roster",
+			m: http.MethodGet,
+			u: "/roster/2/locks",
+			s: http.StatusOK,
+			b: `"reason":"match"`,
+		},
+		{
+			d: "expect 201 when manager locks roster manually",
+			m: http.MethodPost,
+			u: "/roster/2/locks",
+			p: `{}`,
+			k: "user",
+			s: http.StatusCreated,
+			l: "/roster/2/locks/2",
+			b: `"lock_id":2`,
+		},
+		{
+			d: "expect versioned location when locking roster",
+			m: http.MethodPost,
+			u: "/v2/rosters/2/locks",
+			p: `{"starts_at":"2030-01-01T12:00:00Z","ends_at":"2030-01-01T14:00:00Z","reason":"final"}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/rosters/2/locks/2",
+			b: `"lock_id":"2"`,
+		},
+		{
+			d: "expect anonymous lock to result in 401",
+			m: http.MethodPost,
+			u: "/roster/2/locks",
+			p: `{}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect lock of manager of another roster to result in 403",
+			m: http.MethodPost,
+			u: "/roster/2/locks",
+			p: `{}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect lock ending before its start to result in 400",
+			m: http.MethodPost,
+			u: "/roster/2/locks",
+			p: `{"starts_at":"2030-01-01T12:00:00Z","ends_at":"2030-01-01T11:00:00Z"}`,
+			k: "user",
+			s: http.StatusBadRequest,
+			b: `"name":"ends_at"`,
+		},
+		{
+			d: "expect lock of missing roster to result in 404",
+			m: http.MethodPost,
+			u: "/roster/4/locks",
+			p: `{}`,
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect 204 when deleting lock",
+			m: http.MethodDelete,
+			u: "/roster/2/locks/1",
+			k: "user",
+			s: http.StatusNoContent,
+		},
+		{
+			d: "expect anonymous deletion of lock to result in 401",
+			m: http.MethodDelete,
+			u: "/roster/2/locks/1",
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect deleting missing lock to result in 404",
+			m: http.MethodDelete,
+			u: "/roster/2/locks/2",
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect ended locks when unlocking roster",
+			m: http.MethodPost,
+			u: "/roster/2/unlock",
+			k: "admin",
+			s: http.StatusOK,
+			b: `"lock_id":1`,
+		},
+		{
+			d: "expect anonymous unlock to result in 401",
+			m: http.MethodPost,
+			u: "/roster/2/unlock",
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect unlock of manager of another roster to result in 403",
+			m: http.MethodPost,
+			u: "/v2/rosters/2/unlock",
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect overrides of roster",
+			m: http.MethodGet,
+			u: "/roster/2/overrides",
+			s: http.StatusOK,
+			b: `"subject":"alice"`,
+		},
+		{
+			d: "expect swap of locked roster to result in 423",
+			m: http.MethodPost,
+			u: "/roster/1/swaps",
+			p: `{"active":{"player_id":2},"benched":{"player_id":3}}`,
+			s: http.StatusLocked,
+			b: `"type":"/problems/roster-locked"`,
+		},
+		{
+			d: "expect anonymous override to result in 403",
+			m: http.MethodPost,
+			u: "/roster/1/swaps",
+			p: `{"active":{"player_id":2},"benched":{"player_id":3}}`,
+			o: "injury",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect override of user to result in 403",
+			m: http.MethodPost,
+			u: "/roster/1/swaps",
+			p: `{"active":{"player_id":2},"benched":{"player_id":3}}`,
+			k: "user",
+			o: "injury",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect admin to override lock",
+			m: http.MethodPost,
+			u: "/roster/1/swaps",
+			p: `{"active":{"player_id":2},"benched":{"player_id":3}}`,
+			k: "admin",
+			o: "injury",
+			s: http.StatusOK,
+			b: `"alias":"alice"`,
+		},
+		{
+			d: "expect unknown API key to result in 401",
+			m: http.MethodGet,
+			u: "/roster/1/locks",
+			k: "unknown",
+			s: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			if tt.o != "" {
+				r.Header.Set(LockOverrideHeader, tt.o)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// matchStore handles operations on matches and their lineups.
+type matchStore interface {
+	Create(ctx context.Context, match store.Match) (*store.Match, error)
+	Get(ctx context.Context, matchID uint64) (*store.Match, error)
+	List(ctx context.Context, rosterID uint64) ([]store.Match, error)
+	ListRosters(ctx context.Context, rosterIDs []uint64) ([]store.Match, error)
+	SetStatus(ctx context.Context, matchID uint64, status string, from ...string) (*store.Match, error)
+	SubmitLineup(ctx context.Context, lineup store.Lineup) (*store.Lineup, error)
+	Lineups(ctx context.Context, matchID uint64) ([]store.Lineup, error)
+	ListLineups(ctx context.Context, matchIDs []uint64) ([]store.Lineup, error)
+}
+
+// matchTransitions are the statuses a match can change from indexed by the
+// status it changes to.
+var matchTransitions = map[string][]string{
+	store.MatchLive:      {store.MatchScheduled},
+	store.MatchCompleted: {store.MatchLive},
+	store.MatchCancelled: {store.MatchScheduled, store.MatchLive},
+}
+
+// matchService provides API methods to schedule matches and submit lineups.
+// Lineups are snapshots of the active players of the rosters. Matches are
+// scheduled and changed by the managers of either roster, lineups are
+// submitted by the managers of their roster.
+type matchService struct {
+	matchStore
+	managers managerStore
+	timeout  time.Duration
+}
+
+// ServeHTTP serves requests to the match endpoints.
+func (ms *matchService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ms.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	vars := mux.Vars(r)
+	ids := make(map[string]uint64, len(vars))
+	for _, name := range []string{"id", "roster_id"} {
+		v, ok := vars[name]
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
+			writeError(w, r, errBadRequest, http.StatusBadRequest)
+			return
+		}
+		ids[name] = id
+	}
+
+	_, route := path.Split(r.URL.Path)
+	_, isMatch := ids["id"]
+	_, isRoster := ids["roster_id"]
+	switch {
+	case !isMatch && route == "matches" && r.Method == http.MethodGet:
+		ms.list(ctx, w, r, ids["roster_id"])
+
+	case !isMatch && r.Method == http.MethodPost:
+		// we expect a request body that represents a match or we consider
+		// the request as invalid
+		var match store.Match
+		if err := decode(r, &match); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		ms.create(ctx, w, r, match)
+
+	case route == "lineups" && r.Method == http.MethodPost:
+		// the players of the lineup are taken from the roster, so we only
+		// expect the id of the roster
+		var lineup store.Lineup
+		if err := decode(r, &lineup); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		if lineup.RosterID == 0 {
+			writeError(w, r, invalidParam("roster_id", "must not be empty"), http.StatusBadRequest)
+			return
+		}
+		if len(lineup.Players) > 0 {
+			writeError(w, r, invalidParam("players", "are the active players of the roster and must not be given"), http.StatusBadRequest)
+			return
+		}
+		lineup.MatchID = ids["id"]
+		ms.submit(ctx, w, r, lineup)
+
+	case route == "lineups" || isRoster:
+		ms.lineups(ctx, w, r, ids["id"], ids["roster_id"])
+
+	case r.Method == http.MethodGet:
+		ms.get(ctx, w, r, ids["id"])
+
+	case r.Method == http.MethodPatch:
+		// we expect a merge patch of the status or we consider the request
+		// as invalid
+		var match store.Match
+		if err := decode(r, &match); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		from, ok := matchTransitions[match.Status]
+		if !ok {
+			writeError(w, r, invalidParam("status", fmt.Sprintf("must be one of %s, %s or %s", store.MatchLive, store.MatchCompleted, store.MatchCancelled)), http.StatusBadRequest)
+			return
+		}
+		ms.setStatus(ctx, w, r, ids["id"], match.Status, from)
+
+	default:
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errNotFound, http.StatusNotFound)
+	}
+}
+
+// create schedules a match. Responds with 201, the location and the
+// representation of the match or an error.
+func (ms *matchService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, match store.Match) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	switch {
+	case match.RosterID == 0:
+		err = invalidParam("roster_id", "must not be empty")
+	case match.OpponentID == 0:
+		err = invalidParam("opponent_id", "must not be empty")
+	case match.OpponentID == match.RosterID:
+		err = invalidParam("opponent_id", "must not be the roster")
+	case !match.ScheduledAt.After(now):
+		err = invalidParam("scheduled_at", "must be in the future")
+	case !match.LineupDeadline.IsZero() && !match.LineupDeadline.After(now):
+		err = invalidParam("lineup_deadline", "must be in the future")
+	case match.LineupDeadline.After(match.ScheduledAt):
+		err = invalidParam("lineup_deadline", "must not be after the scheduled time")
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := ms.authorize(ctx, p, match); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	m, err := ms.Create(ctx, match)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", matchPath(r, m.MatchID))
+	encode(w, r, m, http.StatusCreated)
+}
+
+// get responds with the match.
+func (ms *matchService) get(ctx context.Context, w http.ResponseWriter, r *http.Request, matchID uint64) {
+	m, err := ms.Get(ctx, matchID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, m, http.StatusOK)
+}
+
+// list responds with the matches the roster plays in.
+func (ms *matchService) list(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64) {
+	matches, err := ms.List(ctx, rosterID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, matches, http.StatusOK)
+}
+
+// setStatus changes the status of the match. Responds with the updated match
+// or an error.
+func (ms *matchService) setStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, matchID uint64, status string, from []string) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	m, err := ms.Get(ctx, matchID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := ms.authorize(ctx, p, *m); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	m, err = ms.SetStatus(ctx, matchID, status, from...)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, m, http.StatusOK)
+}
+
+// submit snapshots the active players of the roster as its lineup for the
+// match. Lineups are final, so only managers of the roster may submit them.
+// Responds with 201, the location and the representation of the lineup or an
+// error.
+func (ms *matchService) submit(ctx context.Context, w http.ResponseWriter, r *http.Request, lineup store.Lineup) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	if err := authorize(ctx, ms.managers, p, lineup.RosterID); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	l, err := ms.SubmitLineup(ctx, lineup)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/lineups/%d", matchPath(r, l.MatchID), l.RosterID))
+	encode(w, r, l, http.StatusCreated)
+}
+
+// authorize returns a 403 problem if the principal is neither an admin nor a
+// manager of one of the rosters of the match.
+func (ms *matchService) authorize(ctx context.Context, p auth.Principal, match store.Match) error {
+	if p.Admin {
+		return nil
+	}
+	for _, rosterID := range []uint64{match.RosterID, match.OpponentID} {
+		ok, err := ms.managers.Manages(ctx, p.Subject, rosterID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return forbidden(fmt.Sprintf("%s manages neither roster %d nor %d", p.Subject, match.RosterID, match.OpponentID))
+}
+
+// lineups responds with the submitted lineups of the match or, if rosterID is
+// not 0, with the lineup of the roster.
+func (ms *matchService) lineups(ctx context.Context, w http.ResponseWriter, r *http.Request, matchID, rosterID uint64) {
+	lineups, err := ms.Lineups(ctx, matchID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if rosterID == 0 {
+		encode(w, r, lineups, http.StatusOK)
+		return
+	}
+	for _, l := range lineups {
+		if l.RosterID == rosterID {
+			encode(w, r, l, http.StatusOK)
+			return
+		}
+	}
+	writeError(w, r, store.ErrNotFound, http.StatusNotFound)
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// match 1 of rosters 4 and 5 is scheduled, roster 4 submitted its lineup for
+// match 2 already. Lineups consist of the active player DataSlayer9.
+type mockMatchStore struct{}
+
+func (ms *mockMatchStore) Create(ctx context.Context, match store.Match) (*store.Match, error) {
+	if match.OpponentID == 7 {
+		return nil, store.ErrNotFound
+	}
+	match.MatchID = 3
+	match.Status = store.MatchScheduled
+	return &match, nil
+}
+
+func (ms *mockMatchStore) Get(ctx context.Context, matchID uint64) (*store.Match, error) {
+	if matchID != 1 {
+		return nil, store.ErrNotFound
+	}
+	at := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	return &store.Match{MatchID: 1, RosterID: 4, OpponentID: 5, ScheduledAt: at, LineupDeadline: at, Status: store.MatchScheduled}, nil
+}
+
+func (ms *mockMatchStore) List(ctx context.Context, rosterID uint64) ([]store.Match, error) {
+	m, _ := ms.Get(ctx, 1)
+	return []store.Match{*m}, nil
+}
+
+func (ms *mockMatchStore) SetStatus(ctx context.Context, matchID uint64, status string, from ...string) (*store.Match, error) {
+	m, err := ms.Get(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range from {
+		if s == m.Status {
+			m.Status = status
+			return m, nil
+		}
+	}
+	return nil, &store.ConflictError{RosterID: m.RosterID, Reason: fmt.Sprintf("match %d is %s and can not be %s", matchID, m.Status, status)}
+}
+
+func (ms *mockMatchStore) SubmitLineup(ctx context.Context, lineup store.Lineup) (*store.Lineup, error) {
+	if lineup.MatchID == 2 {
+		return nil, fmt.Errorf("lineup of roster %d for match %d: %w", lineup.RosterID, lineup.MatchID, store.ErrExists)
+	}
+	m, err := ms.Get(ctx, lineup.MatchID)
+	if err != nil {
+		return nil, err
+	}
+	if lineup.RosterID != m.RosterID && lineup.RosterID != m.OpponentID {
+		return nil, &store.ConflictError{RosterID: lineup.RosterID, Reason: "roster does not play match 1"}
+	}
+	lineup.Players = []store.Player{{PlayerID: 1, RosterID: lineup.RosterID, Alias: "DataSlayer9", Status: store.Active}}
+	return &lineup, nil
+}
+
+func (ms *mockMatchStore) Lineups(ctx context.Context, matchID uint64) ([]store.Lineup, error) {
+	if _, err := ms.Get(ctx, matchID); err != nil {
+		return nil, err
+	}
+	return []store.Lineup{{MatchID: matchID, RosterID: 4, Players: []store.Player{{PlayerID: 1, Alias: "foobar"}}}}, nil
+}
+
+func TestMatches(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"carol": {Subject: "carol"},
+		"dave":  {Subject: "dave"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // api key
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect 201 when scheduling match",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusCreated,
+			l: "/matches/3",
+			b: `"status":"scheduled"`,
+		},
+		{
+			d: "expect versioned location when scheduling match",
+			m: http.MethodPost,
+			u: "/v2/matches",
+			p: `{"roster_id":"4","opponent_id":"5","scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusCreated,
+			l: "/v2/matches/3",
+			b: `"match_id":"3"`,
+		},
+		{
+			d: "expect scheduling match without api key to result in 401",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect scheduling match of other rosters to result in 403",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+			b: `"detail":"carol manages neither roster 4 nor 5"`,
+		},
+		{
+			d: "expect admin to schedule match of any roster",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/matches/3",
+		},
+		{
+			d: "expect manager of opponent to schedule match",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":3,"opponent_id":4,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusCreated,
+			l: "/matches/3",
+		},
+		{
+			d: "expect match against itself to result in 400",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":4,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusBadRequest,
+			b: `"name":"opponent_id"`,
+		},
+		{
+			d: "expect match in the past to result in 400",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2010-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusBadRequest,
+			b: `"name":"scheduled_at"`,
+		},
+		{
+			d: "expect lineup deadline after match to result in 400",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":5,"scheduled_at":"2030-01-01T18:00:00Z","lineup_deadline":"2030-01-01T19:00:00Z"}`,
+			k: "dave",
+			s: http.StatusBadRequest,
+			b: `"name":"lineup_deadline"`,
+		},
+		{
+			d: "expect match of missing roster to result in 404",
+			m: http.MethodPost,
+			u: "/matches",
+			p: `{"roster_id":4,"opponent_id":7,"scheduled_at":"2030-01-01T18:00:00Z"}`,
+			k: "dave",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect match",
+			m: http.MethodGet,
+			u: "/matches/1",
+			s: http.StatusOK,
+			b: `"opponent_id":5`,
+		},
+		{
+			d: "expect matches of roster",
+			m: http.MethodGet,
+			u: "/v2/rosters/5/matches",
+			s: http.StatusOK,
+			b: `"match_id":"1"`,
+		},
+		{
+			d: "expect match to go live",
+			m: http.MethodPatch,
+			u: "/matches/1",
+			p: `{"status":"live"}`,
+			k: "dave",
+			s: http.StatusOK,
+			b: `"status":"live"`,
+		},
+		{
+			d: "expect status change without api key to result in 401",
+			m: http.MethodPatch,
+			u: "/matches/1",
+			p: `{"status":"live"}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect status change of match of other rosters to result in 403",
+			m: http.MethodPatch,
+			u: "/matches/1",
+			p: `{"status":"live"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect status change of missing match to result in 404",
+			m: http.MethodPatch,
+			u: "/matches/2",
+			p: `{"status":"live"}`,
+			k: "dave",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect invalid transition to result in 409",
+			m: http.MethodPatch,
+			u: "/matches/1",
+			p: `{"status":"completed"}`,
+			k: "dave",
+			s: http.StatusConflict,
+			b: `"detail":"match 1 is scheduled and can not be completed"`,
+		},
+		{
+			d: "expect unknown status to result in 400",
+			m: http.MethodPatch,
+			u: "/matches/1",
+			p: `{"status":"scheduled"}`,
+			k: "dave",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+		{
+			d: "expect active players to be submitted as lineup",
+			m: http.MethodPost,
+			u: "/matches/1/lineups",
+			p: `{"roster_id":4}`,
+			k: "dave",
+			s: http.StatusCreated,
+			l: "/matches/1/lineups/4",
+			b: `"alias":"DataSlayer9"`,
+		},
+		{
+			d: "expect lineup without api key to result in 401",
+			m: http.MethodPost,
+			u: "/matches/1/lineups",
+			p: `{"roster_id":4}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect lineup of other roster to result in 403",
+			m: http.MethodPost,
+			u: "/matches/1/lineups",
+			p: `{"roster_id":4}`,
+			k: "carol",
+			s: http.StatusForbidden,
+			b: `"detail":"carol does not manage roster 4"`,
+		},
+		{
+			d: "expect lineup with players to result in 400",
+			m: http.MethodPost,
+			u: "/matches/1/lineups",
+			p: `{"roster_id":4,"players":[{"player_id":1}]}`,
+			k: "dave",
+			s: http.StatusBadRequest,
+			b: `"name":"players"`,
+		},
+		{
+			d: "expect lineup of roster not playing the match to result in 409",
+			m: http.MethodPost,
+			u: "/matches/1/lineups",
+			p: `{"roster_id":7}`,
+			k: "admin",
+			s: http.StatusConflict,
+		},
+		{
+			d: "expect lineup of missing match to result in 404",
+			m: http.MethodPost,
+			u: "/matches/3/lineups",
+			p: `{"roster_id":4}`,
+			k: "dave",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect resubmitted lineup to result in 409",
+			m: http.MethodPost,
+			u: "/matches/2/lineups",
+			p: `{"roster_id":4}`,
+			k: "dave",
+			s: http.StatusConflict,
+			b: `"detail":"lineup of roster 4 for match 2: already exists"`,
+		},
+		{
+			d: "expect lineups of match",
+			m: http.MethodGet,
+			u: "/matches/1/lineups",
+			s: http.StatusOK,
+			b: `"alias":"foobar"`,
+		},
+		{
+			d: "expect lineup of roster",
+			m: http.MethodGet,
+			u: "/matches/1/lineups/4",
+			s: http.StatusOK,
+			b: `"roster_id":4`,
+		},
+		{
+			d: "expect missing lineup to result in 404",
+			m: http.MethodGet,
+			u: "/matches/1/lineups/5",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect lineups to be final",
+			m: http.MethodDelete,
+			u: "/matches/1/lineups/4",
+			s: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			r.Header.Set(middleware.APIKeyHeader, tt.k)
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
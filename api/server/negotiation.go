@@ -0,0 +1,84 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges of an Accept header. Invalid entries are
+// skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		i := strings.Index(mediaType, "/")
+		if i < 0 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{
+			typ:     mediaType[:i],
+			subtype: mediaType[i+1:],
+			q:       q,
+		})
+	}
+	return ranges
+}
+
+// quality returns the quality value of the most specific media range matching
+// the given media type, or zero if none matches.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	i := strings.Index(mediaType, "/")
+	typ, subtype := mediaType[:i], mediaType[i+1:]
+	q, specificity := 0.0, 0
+	for _, mr := range ranges {
+		s := 0
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 3
+		case mr.typ == typ && mr.subtype == "*":
+			s = 2
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// negotiate returns the offered media type that best matches the Accept header
+// of the request. Offers are given in order of server preference which breaks
+// ties. The first offer is returned if the request has no Accept header; an
+// empty string is returned if none of the offers is acceptable.
+func negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// orgStore handles operations on organizations and their managers.
+type orgStore interface {
+	Create(ctx context.Context, org store.Organization) (*store.Organization, error)
+	Get(ctx context.Context, orgID uint64) (*store.Organization, error)
+	List(ctx context.Context) ([]store.Organization, error)
+	Rosters(ctx context.Context, orgID uint64) ([]store.Roster, error)
+	Managers(ctx context.Context, orgID uint64) ([]string, error)
+	AddManager(ctx context.Context, orgID uint64, subject string) error
+	RemoveManager(ctx context.Context, orgID uint64, subject string) error
+	Manages(ctx context.Context, subject string, orgID uint64) (bool, error)
+}
+
+// orgService provides API methods to operate on organizations. Managers of an
+// organization manage all of its rosters.
+type orgService struct {
+	orgStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the organization endpoints. Only admins may
+// create organizations and change their managers.
+func (o *orgService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), o.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	vars := mux.Vars(r)
+	v, ok := vars["id"]
+	if !ok {
+		if r.Method == http.MethodGet {
+			orgs, err := o.List(ctx)
+			if err != nil {
+				writeError(w, r, err, http.StatusInternalServerError)
+				return
+			}
+			encode(w, r, orgs, http.StatusOK)
+			return
+		}
+		// we expect a request body that represents an organization or we
+		// consider the request as invalid
+		var org store.Organization
+		if err := decode(r, &org); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		o.create(ctx, w, r, org)
+		return
+	}
+
+	// query param validation is currently performed by mux only
+	orgID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	var resp interface{}
+	switch _, route := path.Split(r.URL.Path); {
+	case vars["subject"] != "":
+		o.setManager(ctx, w, r, orgID, vars["subject"])
+		return
+	case route == "rosters":
+		resp, err = o.Rosters(ctx, orgID)
+	case route == "managers":
+		resp, err = o.Managers(ctx, orgID)
+	default:
+		resp, err = o.Get(ctx, orgID)
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, resp, http.StatusOK)
+}
+
+// create creates an organization. Responds with 201, the location and the
+// representation of the organization or an error.
+func (o *orgService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, org store.Organization) {
+	if err := requireAdmin(r, "only admins may create organizations"); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	if n := utf8.RuneCountInString(org.Name); n == 0 || n > 64 {
+		writeError(w, r, invalidParam("name", "must have 1 to 64 characters"), http.StatusBadRequest)
+		return
+	}
+	created, err := o.Create(ctx, org)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/organizations/%d", versionPrefix(r), created.OrgID))
+	encode(w, r, created, http.StatusCreated)
+}
+
+// setManager adds the subject to or removes it from the managers of the
+// organization. Responds with 204 or an error.
+func (o *orgService) setManager(ctx context.Context, w http.ResponseWriter, r *http.Request, orgID uint64, subject string) {
+	if err := requireAdmin(r, "only admins may change the managers of organizations"); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	var err error
+	if r.Method == http.MethodDelete {
+		err = o.RemoveManager(ctx, orgID, subject)
+	} else {
+		err = o.AddManager(ctx, orgID, subject)
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeOrg returns a 401 problem for anonymous clients and a 403 problem
+// if the principal is neither an admin nor a manager of the organization.
+func authorizeOrg(ctx context.Context, r *http.Request, orgs orgStore, orgID uint64) error {
+	p, err := principal(r)
+	if err != nil {
+		return err
+	}
+	if p.Admin {
+		return nil
+	}
+	ok, err := orgs.Manages(ctx, p.Subject, orgID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return forbidden(fmt.Sprintf("%s does not manage organization %d", p.Subject, orgID))
+	}
+	return nil
+}
+
+// requireAdmin returns a 401 problem for anonymous clients and a 403 problem
+// with the given detail if the principal is not an admin.
+func requireAdmin(r *http.Request, detail string) error {
+	p, err := principal(r)
+	if err != nil {
+		return err
+	}
+	if !p.Admin {
+		return forbidden(detail)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// organization 1 owns roster 1 and is managed by carol.
+type mockOrgStore struct{}
+
+func (s *mockOrgStore) Create(ctx context.Context, org store.Organization) (*store.Organization, error) {
+	if org.Name == "exists" {
+		return nil, fmt.Errorf("organization %s: %w", org.Name, store.ErrExists)
+	}
+	org.OrgID = 2
+	return &org, nil
+}
+
+func (s *mockOrgStore) Get(ctx context.Context, orgID uint64) (*store.Organization, error) {
+	if orgID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return &store.Organization{OrgID: 1, Name: "esl"}, nil
+}
+
+func (s *mockOrgStore) List(ctx context.Context) ([]store.Organization, error) {
+	return []store.Organization{{OrgID: 1, Name: "esl"}}, nil
+}
+
+func (s *mockOrgStore) Rosters(ctx context.Context, orgID uint64) ([]store.Roster, error) {
+	if _, err := s.Get(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return []store.Roster{{RosterID: 1, OrgID: 1, Name: "foo"}}, nil
+}
+
+func (s *mockOrgStore) Managers(ctx context.Context, orgID uint64) ([]string, error) {
+	return []string{"carol"}, nil
+}
+
+func (s *mockOrgStore) AddManager(ctx context.Context, orgID uint64, subject string) error {
+	_, err := s.Get(ctx, orgID)
+	return err
+}
+
+func (s *mockOrgStore) RemoveManager(ctx context.Context, orgID uint64, subject string) error {
+	if orgID != 1 || subject != "carol" {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *mockOrgStore) Manages(ctx context.Context, subject string, orgID uint64) (bool, error) {
+	return orgID == 1 && subject == "carol", nil
+}
+
+func TestOrganizations(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+		"carol": {Subject: "carol"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect 201 when admin creates organization",
+			m: http.MethodPost,
+			u: "/v2/organizations",
+			p: `{"name":"faze"}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/organizations/2",
+			b: `"org_id":"2"`,
+		},
+		{
+			d: "expect organization created by user to result in 403",
+			m: http.MethodPost,
+			u: "/organizations",
+			p: `{"name":"faze"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect existing organization to result in 409",
+			m: http.MethodPost,
+			u: "/organizations",
+			p: `{"name":"exists"}`,
+			k: "admin",
+			s: http.StatusConflict,
+		},
+		{
+			d: "expect organizations",
+			m: http.MethodGet,
+			u: "/organizations",
+			s: http.StatusOK,
+			b: `[{"org_id":1,"name":"esl"}]`,
+		},
+		{
+			d: "expect missing organization to result in 404",
+			m: http.MethodGet,
+			u: "/organizations/3",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect rosters of organization",
+			m: http.MethodGet,
+			u: "/organizations/1/rosters",
+			s: http.StatusOK,
+			b: `"org_id":1,"name":"foo"`,
+		},
+		{
+			d: "expect managers of organization",
+			m: http.MethodGet,
+			u: "/v2/organizations/1/managers",
+			s: http.StatusOK,
+			b: `["carol"]`,
+		},
+		{
+			d: "expect 204 when admin adds manager",
+			m: http.MethodPut,
+			u: "/organizations/1/managers/bob",
+			k: "admin",
+			s: http.StatusNoContent,
+		},
+		{
+			d: "expect manager added by user to result in 403",
+			m: http.MethodPut,
+			u: "/organizations/1/managers/bob",
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect removing missing manager to result in 404",
+			m: http.MethodDelete,
+			u: "/organizations/1/managers/bob",
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect manager of organization to create its roster",
+			m: http.MethodPost,
+			u: "/rosters",
+			p: `{"name":"foo","org_id":1,"players":{"active":` + activePlayers + `}}`,
+			k: "carol",
+			s: http.StatusCreated,
+			l: "/roster/1",
+		},
+		{
+			d: "expect roster of organization created by user to result in 403",
+			m: http.MethodPost,
+			u: "/rosters",
+			p: `{"name":"foo","org_id":1,"players":{"active":` + activePlayers + `}}`,
+			k: "bob",
+			s: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
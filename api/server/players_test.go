@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// player 1 exists, deleting player 2 conflicts with the active players of its
+// roster.
+func (ps *mockPlayerStore) Get(ctx context.Context, playerID uint64) (*store.Player, error) {
+	if playerID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return &store.Player{PlayerID: 1, RosterID: 2, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}, nil
+}
+
+func (ps *mockPlayerStore) Delete(ctx context.Context, playerID uint64) (*store.Player, error) {
+	switch playerID {
+	case 1:
+		return ps.Get(ctx, playerID)
+	case 2:
+		return nil, &store.ConflictError{RosterID: 2, Reason: "roster id=2 must have exactly 5 active players, not 4"}
+	}
+	return nil, store.ErrNotFound
+}
+
+// trades of player 4 leave the partner without enough active players.
+func (ps *mockPlayerStore) Trade(ctx context.Context, trade store.Trade) (*store.Trade, error) {
+	for _, p := range trade.Players {
+		if p.PlayerID == 4 {
+			return nil, &store.ConflictError{RosterID: trade.PartnerID, Reason: "roster must have exactly 5 active players"}
+		}
+	}
+	for i := range trade.Players {
+		trade.Players[i].RosterID = trade.PartnerID
+	}
+	for i := range trade.PartnerPlayers {
+		trade.PartnerPlayers[i].RosterID = trade.RosterID
+	}
+	return &trade, nil
+}
+
+// foobar is the alias of player 1 and a former alias of player 3.
+func (ps *mockPlayerStore) FindByAlias(ctx context.Context, alias string) ([]store.AliasMatch, error) {
+	if strings.ToLower(alias) != "foobar" {
+		return nil, nil
+	}
+	renamed := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []store.AliasMatch{
+		{Player: store.Player{PlayerID: 1, RosterID: 2, Alias: "foobar", Status: "benched"}, Current: true},
+		{Player: store.Player{PlayerID: 3, RosterID: 2, Alias: "bazqux", Status: "active"}, RenamedAt: &renamed},
+	}, nil
+}
+
+func TestPlayerResources(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		l string // expected Location header
+		a string // expected Allow header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect 201 when creating player",
+			m: http.MethodPost,
+			u: "/players",
+			p: `{"first_name":"foo","last_name":"bar","alias":"foobar","player_id":3}`,
+			s: http.StatusCreated,
+			l: "/players/3",
+			b: `"player_id":3`,
+		},
+		{
+			d: "expect versioned location when creating player",
+			m: http.MethodPost,
+			u: "/v2/players",
+			p: `{"first_name":"foo","last_name":"bar","alias":"foobar","player_id":"3"}`,
+			s: http.StatusCreated,
+			l: "/v2/players/3",
+			b: `"player_id":"3"`,
+		},
+		{
+			d: "expect legacy route to respond with 200 when adding player",
+			m: http.MethodPost,
+			u: "/players/add",
+			p: `{"player_id":3}`,
+			s: http.StatusOK,
+			b: `"player_id":3`,
+		},
+		{
+			d: "expect player",
+			m: http.MethodGet,
+			u: "/players/1",
+			s: http.StatusOK,
+			b: `"alias":"foobar"`,
+		},
+		{
+			d: "expect missing player to result in 404",
+			m: http.MethodGet,
+			u: "/v1/players/4",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect player to be moved by admin",
+			m: http.MethodPatch,
+			u: "/players/2",
+			p: `{"roster_id":1}`,
+			k: "admin",
+			s: http.StatusOK,
+			b: `"status":"benched"`,
+		},
+		{
+			d: "expect player to be moved by admins only",
+			m: http.MethodPatch,
+			u: "/players/2",
+			p: `{"roster_id":1}`,
+			k: "bob",
+			s: http.StatusForbidden,
+			b: `"detail":"only admins may move players to another roster, propose a transfer instead"`,
+		},
+		{
+			d: "expect anonymous move of player to result in 401",
+			m: http.MethodPatch,
+			u: "/v1/players/update",
+			p: `{"player_id":2,"roster_id":1}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect status patch to result in 400",
+			m: http.MethodPatch,
+			u: "/players/2",
+			p: `{"status":"active"}`,
+			s: http.StatusBadRequest,
+			b: `"detail":"status can not be changed, swap players instead"`,
+		},
+		{
+			d: "expect patch of another player to result in 400",
+			m: http.MethodPatch,
+			u: "/players/2",
+			p: `{"player_id":3}`,
+			s: http.StatusBadRequest,
+			b: `"detail":"player_id must match the id of the URL path"`,
+		},
+		{
+			d: "expect 204 when deleting player",
+			m: http.MethodDelete,
+			u: "/players/1",
+			s: http.StatusNoContent,
+		},
+		{
+			d: "expect deleting active player to result in 409",
+			m: http.MethodDelete,
+			u: "/v2/players/2",
+			s: http.StatusConflict,
+			b: `"roster_id":"2"`,
+		},
+		{
+			d: "expect players of a roster to be swapped",
+			m: http.MethodPost,
+			u: "/roster/1/swaps",
+			p: `{"active":{"player_id":2},"benched":{"player_id":3}}`,
+			s: http.StatusOK,
+			b: `"status":"active"`,
+		},
+		{
+			d: "expect players to be traded",
+			m: http.MethodPost,
+			u: "/v2/trades",
+			p: `{"roster_id":"1","partner_id":"2","players":[{"player_id":"2","status":"active"}],"partner_players":[{"player_id":"3","status":"active"}]}`,
+			k: "admin",
+			s: http.StatusOK,
+			b: `"partner_players":[{"player_id":"3","roster_id":"1"`,
+		},
+		{
+			d: "expect anonymous trade to result in 401",
+			m: http.MethodPost,
+			u: "/trades",
+			p: `{"roster_id":1,"partner_id":2,"players":[{"player_id":2,"status":"active"}]}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect trade of manager of only one roster to result in 403",
+			m: http.MethodPost,
+			u: "/trades",
+			p: `{"roster_id":1,"partner_id":2,"players":[{"player_id":2,"status":"active"}]}`,
+			k: "bob",
+			s: http.StatusForbidden,
+			b: `"detail":"bob does not manage roster 1"`,
+		},
+		{
+			d: "expect trade without status to result in 400",
+			m: http.MethodPost,
+			u: "/trades",
+			p: `{"roster_id":1,"partner_id":2,"players":[{"player_id":2}]}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"players"`,
+		},
+		{
+			d: "expect player traded twice to result in 400",
+			m: http.MethodPost,
+			u: "/trades",
+			p: `{"roster_id":1,"partner_id":2,"players":[{"player_id":2,"status":"active"}],"partner_players":[{"player_id":2,"status":"active"}]}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"partner_players"`,
+		},
+		{
+			d: "expect trade violating the active players to result in 409",
+			m: http.MethodPost,
+			u: "/trades",
+			p: `{"roster_id":1,"partner_id":2,"players":[{"player_id":4,"status":"benched"}]}`,
+			k: "admin",
+			s: http.StatusConflict,
+		},
+		{
+			d: "expect current and former holders of an alias",
+			m: http.MethodGet,
+			u: "/players/by-alias/FooBar",
+			s: http.StatusOK,
+			b: `"alias":"foobar","status":"benched","current":true},{"player_id":3,"roster_id":2,"first_name":"","last_name":"","alias":"bazqux","status":"active","current":false,"renamed_at":"2020-01-01T12:00:00Z"}`,
+		},
+		{
+			d: "expect versioned ids of alias holders",
+			m: http.MethodGet,
+			u: "/v2/players/by-alias/foobar",
+			s: http.StatusOK,
+			b: `"player_id":"3"`,
+		},
+		{
+			d: "expect unknown alias to result in 404",
+			m: http.MethodGet,
+			u: "/players/by-alias/nobody",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect too long alias to result in 400",
+			m: http.MethodGet,
+			u: "/players/by-alias/" + strings.Repeat("x", 33),
+			s: http.StatusBadRequest,
+			b: `"name":"alias"`,
+		},
+		{
+			d: "expect wrong method to result in 405",
+			m: http.MethodPut,
+			u: "/players/1",
+			s: http.StatusMethodNotAllowed,
+			a: "GET, PATCH, DELETE",
+			b: `"detail":"allowed methods are GET, PATCH, DELETE"`,
+		},
+		{
+			d: "expect wrong method of legacy route to result in 405",
+			m: http.MethodGet,
+			u: "/v1/players/change",
+			s: http.StatusMethodNotAllowed,
+			a: "PATCH",
+		},
+		{
+			d: "expect legacy routes not to be served by v2",
+			m: http.MethodPost,
+			u: "/v2/players/add",
+			p: `{"player_id":3}`,
+			s: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if want, got := tt.a, w.Header().Get("Allow"); want != got {
+				t.Errorf("want allow %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog/hlog"
+)
+
+// legacyErrors are the errors of the legacy error representation indexed by
+// HTTP status code.
+var legacyErrors = map[int]error{
+	http.StatusBadRequest:          errBadRequest,
+	http.StatusNotFound:            errNotFound,
+	http.StatusConflict:            errConflict,
+	http.StatusInternalServerError: errInternal,
+}
+
+// newProblem returns problem details for the given error. The status of known
+// errors takes precedence over the given code.
+func newProblem(err error, code int) *api.Error {
+	var p *api.Error
+	var conflict *store.ConflictError
+	var locked *store.LockedError
+	var roleErr *store.RoleError
+	switch {
+	case errors.As(err, &p):
+		problem := *p
+		if problem.Status == 0 {
+			problem.Status = code
+		}
+		return &problem
+	case errors.Is(err, store.ErrNotFound):
+		return &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+		}
+	case errors.Is(err, store.ErrExists):
+		return &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(http.StatusConflict),
+			Status: http.StatusConflict,
+			Detail: err.Error(),
+		}
+	case errors.As(err, &locked):
+		return &api.Error{
+			Type:   api.LockedProblemType,
+			Title:  "Roster locked",
+			Status: http.StatusLocked,
+			Detail: locked.Error(),
+			Extensions: map[string]interface{}{
+				"roster_id": locked.RosterID,
+				"lock":      locked.Lock,
+			},
+		}
+	case errors.As(err, &roleErr):
+		return &api.Error{
+			Type:   api.RoleProblemType,
+			Title:  "Role requirement not satisfied",
+			Status: http.StatusConflict,
+			Detail: roleErr.Error(),
+			Extensions: map[string]interface{}{
+				"roster_id":   roleErr.RosterID,
+				"requirement": roleErr.Requirement,
+				"active":      roleErr.Active,
+			},
+		}
+	case errors.As(err, &conflict):
+		return &api.Error{
+			Type:   api.ConflictProblemType,
+			Title:  "Roster conflict",
+			Status: http.StatusConflict,
+			Detail: conflict.Reason,
+			Extensions: map[string]interface{}{
+				"roster_id": conflict.RosterID,
+				"players":   conflict.Players,
+			},
+		}
+	}
+	problem := &api.Error{
+		Type:   api.BlankProblemType,
+		Title:  http.StatusText(code),
+		Status: code,
+	}
+	// sentinel errors do not provide more information than the title
+	if legacyErrors[code] != err {
+		problem.Detail = err.Error()
+	}
+	return problem
+}
+
+// invalidRequest returns problem details for a request body that could not be
+// decoded. Fields of the wrong type or unknown fields are reported in the
+// invalid-params extension member.
+func invalidRequest(err error) *api.Error {
+	problem := &api.Error{
+		Type:   api.InvalidProblemType,
+		Title:  "Invalid request",
+		Status: http.StatusBadRequest,
+		Detail: "malformed request body",
+	}
+	var params []api.InvalidParam
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		problem.Detail = "missing request body"
+	case errors.As(err, &typeErr):
+		params = append(params, api.InvalidParam{
+			Name:   typeErr.Field,
+			Reason: "must be of type " + typeErr.Type.String(),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// the json package does not provide a typed error for unknown fields
+		params = append(params, api.InvalidParam{
+			Name:   strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`),
+			Reason: "unknown field",
+		})
+	}
+	if len(params) > 0 {
+		problem.Detail = "request body contains invalid fields"
+		problem.Extensions = map[string]interface{}{"invalid-params": params}
+	}
+	return problem
+}
+
+// invalidParam returns problem details for a single invalid request parameter.
+func invalidParam(name, reason string) *api.Error {
+	return &api.Error{
+		Type:   api.InvalidProblemType,
+		Title:  "Invalid request",
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("%s %s", name, reason),
+		Extensions: map[string]interface{}{
+			"invalid-params": []api.InvalidParam{{Name: name, Reason: reason}},
+		},
+	}
+}
+
+// writeError writes an error to the http response. Errors are written as
+// problem details according to RFC 7807 unless the client of API version 1
+// prefers the legacy JSON representation via the Accept header.
+func writeError(w http.ResponseWriter, r *http.Request, err error, code int) {
+	problem := newProblem(err, code)
+	// prepare log
+	logger := loggerFromRequest(r).With().
+		Err(err).
+		Int("status", problem.Status).
+		Logger()
+	// hide error from client if it's internal
+	if problem.Status >= http.StatusInternalServerError {
+		logger.Error().Msg("unexpected http error")
+		problem = &api.Error{
+			Type:   api.BlankProblemType,
+			Title:  http.StatusText(problem.Status),
+			Status: problem.Status,
+		}
+	} else {
+		logger.Debug().Msg("http error")
+	}
+	if id, ok := hlog.IDFromRequest(r); ok {
+		problem.Instance = id.String()
+	}
+
+	if versionFromRequest(r) == v1 && negotiate(r, api.ProblemMediaType, api.LegacyMediaType) == api.LegacyMediaType {
+		legacy, ok := legacyErrors[problem.Status]
+		if !ok {
+			legacy = errors.New(strings.ToLower(strings.Replace(problem.Title, " ", "_", -1)))
+		}
+		encodeJSON(w, r, &api.Error{Err: legacy.Error()}, problem.Status)
+		return
+	}
+	writeJSON(w, r, api.ProblemMediaType, problem, problem.Status)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/fgrimme/patrongg/store"
+)
+
+var problemTests = []struct {
+	d string // description of test case
+	e error  // error to write
+	c int    // status code passed to writeError
+	a string // Accept header of the request
+	s int    // expected HTTP status code
+	t string // expected content type
+	b string // expected payload
+}{
+	{
+		d: "expect internal errors to be masked",
+		e: errors.New("connection refused"),
+		c: http.StatusInternalServerError,
+		s: http.StatusInternalServerError,
+		t: api.ProblemMediaType,
+		b: `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+	},
+	{
+		d: "expect legacy representation if JSON is preferred",
+		e: errors.New("connection refused"),
+		c: http.StatusInternalServerError,
+		a: "application/json",
+		s: http.StatusInternalServerError,
+		t: api.LegacyMediaType,
+		b: `{"error":"internal_error"}`,
+	},
+	{
+		d: "expect problem details if problem+json is preferred",
+		e: errNotFound,
+		c: http.StatusNotFound,
+		a: "application/json;q=0.5, application/problem+json",
+		s: http.StatusNotFound,
+		t: api.ProblemMediaType,
+		b: `{"type":"about:blank","title":"Not Found","status":404}`,
+	},
+	{
+		d: "expect conflicting roster state in extension members",
+		e: &store.ConflictError{
+			RosterID: 1,
+			Reason:   "player 2 must be active",
+			Players:  []store.Player{{PlayerID: 2, RosterID: 1, Status: "benched"}},
+		},
+		c: http.StatusInternalServerError,
+		s: http.StatusConflict,
+		t: api.ProblemMediaType,
+		b: `{"type":"/problems/roster-conflict","title":"Roster conflict","status":409,"detail":"player 2 must be active","players":[{"player_id":2,"roster_id":1,"first_name":"","last_name":"","alias":"","status":"benched"}],"roster_id":1}`,
+	},
+	{
+		d: "expect legacy conflict error",
+		e: &store.ConflictError{RosterID: 1, Reason: "player 2 must be active"},
+		c: http.StatusInternalServerError,
+		a: "application/json",
+		s: http.StatusConflict,
+		t: api.LegacyMediaType,
+		b: `{"error":"conflict"}`,
+	},
+	{
+		d: "expect unknown fields in invalid params",
+		e: invalidRequest(decodeStrict(`{"foo":1}`, &store.Player{})),
+		c: http.StatusBadRequest,
+		s: http.StatusBadRequest,
+		t: api.ProblemMediaType,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"request body contains invalid fields","invalid-params":[{"name":"foo","reason":"unknown field"}]}`,
+	},
+	{
+		d: "expect fields of wrong type in invalid params",
+		e: invalidRequest(decodeStrict(`{"player_id":"1"}`, &store.Player{})),
+		c: http.StatusBadRequest,
+		s: http.StatusBadRequest,
+		t: api.ProblemMediaType,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"detail":"request body contains invalid fields","invalid-params":[{"name":"player_id","reason":"must be of type uint64"}]}`,
+	},
+}
+
+// decodeStrict decodes body into v like the services do and returns the error.
+func decodeStrict(body string, v interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func TestWriteError(t *testing.T) {
+	for _, tc := range problemTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.a != "" {
+				r.Header.Set("Accept", tt.a)
+			}
+			writeError(w, r, tt.e, tt.c)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.t, w.Header().Get("Content-Type"); want != got {
+				t.Errorf("want content type %s got %s", want, got)
+			}
+			if want, got := tt.b, strings.TrimSpace(w.Body.String()); want != got {
+				t.Errorf("want response\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
+
+var negotiateTests = []struct {
+	d string   // description of test case
+	a string   // Accept header of the request
+	o []string // offered media types
+	m string   // expected media type
+}{
+	{
+		d: "expect first offer without Accept header",
+		o: []string{"application/problem+json", "application/json"},
+		m: "application/problem+json",
+	},
+	{
+		d: "expect server preference for wildcards",
+		a: "*/*",
+		o: []string{"application/problem+json", "application/json"},
+		m: "application/problem+json",
+	},
+	{
+		d: "expect exact match to take precedence over wildcards",
+		a: "application/*;q=0.2, application/json",
+		o: []string{"application/problem+json", "application/json"},
+		m: "application/json",
+	},
+	{
+		d: "expect no match for unacceptable offers",
+		a: "text/html",
+		o: []string{"application/json"},
+		m: "",
+	},
+	{
+		d: "expect offers with q=0 to be excluded",
+		a: "application/json;q=0, */*",
+		o: []string{"application/json", "text/csv"},
+		m: "text/csv",
+	},
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range negotiateTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.a != "" {
+				r.Header.Set("Accept", tt.a)
+			}
+			if want, got := tt.m, negotiate(r, tt.o...); want != got {
+				t.Errorf("want %q got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// Limits of the custom fields of a profile.
+const (
+	maxCustomFields = 32
+	maxCustomSize   = 4096 // bytes of the JSON encoded fields
+)
+
+// countryCode matches ISO 3166-1 alpha-2 country codes.
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// profileStore handles operations on the profiles of players.
+type profileStore interface {
+	Get(ctx context.Context, playerID uint64) (*store.Profile, error)
+	Update(ctx context.Context, playerID uint64, update func(store.Profile) (store.Profile, error)) (*store.Profile, error)
+}
+
+// profileService provides API methods to read and patch the profiles of
+// players.
+type profileService struct {
+	profileStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the profile endpoints. Profiles are changed by
+// JSON merge patches (RFC 7386).
+func (ps *profileService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ps.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	playerID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPatch {
+		p, err := ps.Get(ctx, playerID)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, p, http.StatusOK)
+		return
+	}
+
+	// we expect a merge patch of the profile or we consider the request as
+	// invalid, members of custom fields, accounts and socials named like ids
+	// are kept as given in requests and responses, see opaqueMembers
+	var patch map[string]interface{}
+	if err := decodeVerbatim(r, &patch); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	p, err := ps.Update(ctx, playerID, func(current store.Profile) (store.Profile, error) {
+		return patchProfile(current, patch)
+	})
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, p, http.StatusOK)
+}
+
+// patchProfile applies the merge patch to the profile. Returns the patched and
+// normalized profile or a problem if it is invalid.
+func patchProfile(profile store.Profile, patch map[string]interface{}) (store.Profile, error) {
+	b, err := json.Marshal(profile)
+	if err != nil {
+		return store.Profile{}, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(b, &target); err != nil {
+		return store.Profile{}, err
+	}
+	if b, err = json.Marshal(mergePatch(target, patch)); err != nil {
+		return store.Profile{}, err
+	}
+
+	var patched store.Profile
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields() // catch unwanted fields
+	if err := decoder.Decode(&patched); err != nil {
+		return store.Profile{}, invalidRequest(err)
+	}
+	if err := normalizeProfile(&patched); err != nil {
+		return store.Profile{}, err
+	}
+	return patched, nil
+}
+
+// mergePatch applies the patch to the target as specified by RFC 7386. Null
+// members of the patch remove members of the target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// normalizeProfile normalizes the profile in place. Returns a problem if a
+// field of the profile is invalid.
+func normalizeProfile(p *store.Profile) error {
+	p.Nationality = strings.ToUpper(strings.TrimSpace(p.Nationality))
+	if p.Nationality != "" && !countryCode.MatchString(p.Nationality) {
+		return invalidParam("nationality", "must be an ISO 3166-1 alpha-2 country code")
+	}
+	if p.DateOfBirth != "" {
+		d, err := time.Parse("2006-01-02", p.DateOfBirth)
+		if err != nil {
+			return invalidParam("date_of_birth", "must be a date formatted as YYYY-MM-DD")
+		}
+		if d.After(time.Now()) {
+			return invalidParam("date_of_birth", "must not be in the future")
+		}
+	}
+	var err error
+	if p.Accounts, err = normalizeHandles("accounts", p.Accounts); err != nil {
+		return err
+	}
+	if p.Socials, err = normalizeHandles("socials", p.Socials); err != nil {
+		return err
+	}
+	if len(p.Custom) > maxCustomFields {
+		return invalidParam("custom", fmt.Sprintf("must not have more than %d fields", maxCustomFields))
+	}
+	for k := range p.Custom {
+		if n := utf8.RuneCountInString(k); n == 0 || n > 64 {
+			return invalidParam("custom", "must have names of 1 to 64 characters")
+		}
+	}
+	if b, err := json.Marshal(p.Custom); err != nil || len(b) > maxCustomSize {
+		return invalidParam("custom", fmt.Sprintf("must not be larger than %d bytes", maxCustomSize))
+	}
+	if len(p.Custom) == 0 {
+		p.Custom = nil
+	}
+	return nil
+}
+
+// normalizeHandles returns the handles by platform or network with lower case
+// keys. Returns a problem for the named field if a key or handle is empty or
+// too long.
+func normalizeHandles(name string, handles map[string]string) (map[string]string, error) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(handles))
+	for k, v := range handles {
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		switch {
+		case k == "" || utf8.RuneCountInString(k) > 32:
+			return nil, invalidParam(name, "must have names of 1 to 32 characters")
+		case v == "" || utf8.RuneCountInString(v) > 64:
+			return nil, invalidParam(name, fmt.Sprintf("must have values of 1 to 64 characters, %s has not", k))
+		}
+		if _, ok := normalized[k]; ok {
+			return nil, invalidParam(name, fmt.Sprintf("must not contain %s twice", k))
+		}
+		normalized[k] = v
+	}
+	return normalized, nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// player 1 is from sweden and has a steam account and a custom team, player 2
+// has no profile.
+type mockProfileStore struct{}
+
+func (s *mockProfileStore) Get(ctx context.Context, playerID uint64) (*store.Profile, error) {
+	switch playerID {
+	case 1:
+		return &store.Profile{
+			Nationality: "SE",
+			Accounts:    map[string]string{"steam": "42"},
+			Custom:      map[string]interface{}{"team": "a"},
+		}, nil
+	case 2:
+		return &store.Profile{}, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *mockProfileStore) Update(ctx context.Context, playerID uint64, update func(store.Profile) (store.Profile, error)) (*store.Profile, error) {
+	current, err := s.Get(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := update(*current)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func TestProfiles(t *testing.T) {
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		s int    // expected http status code
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect profile",
+			m: http.MethodGet,
+			u: "/players/1/profile",
+			s: http.StatusOK,
+			b: `{"nationality":"SE","accounts":{"steam":"42"},"custom":{"team":"a"}}`,
+		},
+		{
+			d: "expect empty profile of player without profile",
+			m: http.MethodGet,
+			u: "/v2/players/2/profile",
+			s: http.StatusOK,
+			b: `{}`,
+		},
+		{
+			d: "expect profile of missing player to result in 404",
+			m: http.MethodGet,
+			u: "/players/3/profile",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect profile to be merged with the patch",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"nationality":"de","accounts":{"Riot":"foo#42"},"custom":{"team":null,"rank":3}}`,
+			s: http.StatusOK,
+			b: `{"nationality":"DE","accounts":{"riot":"foo#42","steam":"42"},"custom":{"rank":3}}`,
+		},
+		{
+			d: "expect custom fields named like ids to be kept by v2",
+			m: http.MethodPatch,
+			u: "/v2/players/2/profile",
+			p: `{"custom":{"faceit_id":"1234"}}`,
+			s: http.StatusOK,
+			b: `"custom":{"faceit_id":"1234"}`,
+		},
+		{
+			d: "expect numeric custom fields named like ids to be kept by v2",
+			m: http.MethodPatch,
+			u: "/v2/players/2/profile",
+			p: `{"accounts":{"steam_id":"0042"},"custom":{"faceit_id":1234}}`,
+			s: http.StatusOK,
+			b: `{"accounts":{"steam_id":"0042"},"custom":{"faceit_id":1234}}`,
+		},
+		{
+			d: "expect fields to be removed by null",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"nationality":null,"accounts":null,"custom":null}`,
+			s: http.StatusOK,
+			b: `{}`,
+		},
+		{
+			d: "expect invalid nationality to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"nationality":"swe"}`,
+			s: http.StatusBadRequest,
+			b: `"name":"nationality"`,
+		},
+		{
+			d: "expect invalid date of birth to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"date_of_birth":"24.12.2001"}`,
+			s: http.StatusBadRequest,
+			b: `"name":"date_of_birth"`,
+		},
+		{
+			d: "expect date of birth in the future to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"date_of_birth":"2999-01-01"}`,
+			s: http.StatusBadRequest,
+			b: `"reason":"must not be in the future"`,
+		},
+		{
+			d: "expect empty account to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"accounts":{"epic":""}}`,
+			s: http.StatusBadRequest,
+			b: `"name":"accounts"`,
+		},
+		{
+			d: "expect unknown field to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1/profile",
+			p: `{"height":180}`,
+			s: http.StatusBadRequest,
+		},
+		{
+			d: "expect patch of missing player to result in 404",
+			m: http.MethodPatch,
+			u: "/players/3/profile",
+			p: `{"nationality":"DE"}`,
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect profile patched with the player to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1",
+			p: `{"profile":{"nationality":"DE"}}`,
+			s: http.StatusBadRequest,
+			b: `"name":"profile"`,
+		},
+		{
+			d: "expect players to be listed by profile",
+			m: http.MethodGet,
+			u: "/players?nationality=se&platform=steam&custom.team=a",
+			s: http.StatusOK,
+			b: `"status":"benched"`,
+		},
+		{
+			d: "expect invalid status filter to result in 400",
+			m: http.MethodGet,
+			u: "/players?status=injured",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+		{
+			d: "expect invalid roster filter to result in 400",
+			m: http.MethodGet,
+			u: "/v2/players?roster_id=foo",
+			s: http.StatusBadRequest,
+			b: `"name":"roster_id"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p)))
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
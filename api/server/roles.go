@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// maxRoleLength is the maximum number of characters of a role.
+const maxRoleLength = 32
+
+// roleStore handles operations on the roles of players and the role
+// requirements of rosters.
+type roleStore interface {
+	Requirements(ctx context.Context, rosterID uint64) ([]store.RoleRequirement, error)
+	SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error)
+	SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error)
+}
+
+// roleService provides API methods to assign roles to players and to require
+// roles of the active players of rosters.
+type roleService struct {
+	roleStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the role endpoints. The id of the URL path is
+// the id of a roster for requirements and of a player for roles.
+func (rs *roleService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), rs.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	_, route := path.Split(r.URL.Path)
+	switch {
+	case route == "roles":
+		// we expect a request body that contains the roles of the player or
+		// we consider the request as invalid
+		var roles []string
+		if err := decode(r, &roles); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		rs.setRoles(ctx, w, r, id, roles)
+
+	case r.Method == http.MethodPut:
+		// we expect a request body that contains the requirements of the
+		// roster or we consider the request as invalid
+		var requirements []store.RoleRequirement
+		if err := decode(r, &requirements); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		rs.setRequirements(ctx, w, r, id, requirements)
+
+	default:
+		requirements, err := rs.Requirements(ctx, id)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, requirements, http.StatusOK)
+	}
+}
+
+// setRoles replaces the roles of the player. Responds with the player or an
+// error if the active players of its roster would no longer cover the roles
+// required by the roster.
+func (rs *roleService) setRoles(ctx context.Context, w http.ResponseWriter, r *http.Request, playerID uint64, roles []string) {
+	if err := normalizeRoles("roles", roles); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	p, err := rs.SetRoles(ctx, playerID, roles)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, p, http.StatusOK)
+}
+
+// setRequirements replaces the role requirements of the roster. Responds with
+// the requirements or an error if the active players of the roster do not
+// satisfy them.
+func (rs *roleService) setRequirements(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64, requirements []store.RoleRequirement) {
+	if err := normalizeRequirements(requirements, store.MaxActiveSize); err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	reqs, err := rs.SetRequirements(ctx, rosterID, requirements)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, reqs, http.StatusOK)
+}
+
+// normalizeRoles normalizes the roles in place. Returns a problem for the
+// named field if a role is invalid or contained twice.
+func normalizeRoles(name string, roles []string) error {
+	seen := make(map[string]bool, len(roles))
+	for i, role := range roles {
+		role, err := normalizeRole(name, role)
+		if err != nil {
+			return err
+		}
+		if seen[role] {
+			return invalidParam(name, fmt.Sprintf("must not contain %s twice", role))
+		}
+		seen[role] = true
+		roles[i] = role
+	}
+	return nil
+}
+
+// normalizeRequirements normalizes the roles of the requirements in place.
+// Returns a problem if a role is invalid or required twice or if a count is
+// not between 1 and max.
+func normalizeRequirements(requirements []store.RoleRequirement, max int) error {
+	seen := make(map[string]bool, len(requirements))
+	for i, req := range requirements {
+		role, err := normalizeRole("role", req.Role)
+		if err != nil {
+			return err
+		}
+		switch {
+		case seen[role]:
+			return invalidParam("role", fmt.Sprintf("must not require %s twice", role))
+		case req.Count < 1 || req.Count > max:
+			return invalidParam("count", fmt.Sprintf("must be between 1 and %d", max))
+		}
+		seen[role] = true
+		requirements[i].Role = role
+	}
+	return nil
+}
+
+// normalizeRole returns the role in lower case or a problem for the named
+// field if the role is empty or too long.
+func normalizeRole(name, role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	switch {
+	case role == "":
+		return "", invalidParam(name, "must not be empty")
+	case utf8.RuneCountInString(role) > maxRoleLength:
+		return "", invalidParam(name, fmt.Sprintf("must not be longer than %d characters", maxRoleLength))
+	}
+	return role, nil
+}
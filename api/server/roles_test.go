@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// roster 1 requires one active igl, player 1 is its only active igl.
+type mockRoleStore struct{}
+
+func (rs *mockRoleStore) Requirements(ctx context.Context, rosterID uint64) ([]store.RoleRequirement, error) {
+	if rosterID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return []store.RoleRequirement{{Role: "igl", Count: 1}}, nil
+}
+
+func (rs *mockRoleStore) SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error) {
+	if rosterID != 1 {
+		return nil, store.ErrNotFound
+	}
+	for _, r := range requirements {
+		if r.Role == "igl" && r.Count > 1 {
+			return nil, &store.RoleError{RosterID: 1, Requirement: r, Active: 1}
+		}
+	}
+	return requirements, nil
+}
+
+func (rs *mockRoleStore) SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error) {
+	if playerID != 1 {
+		return nil, store.ErrNotFound
+	}
+	for _, r := range roles {
+		if r == "igl" {
+			return &store.Player{PlayerID: 1, RosterID: 1, Status: store.Active, Roles: roles}, nil
+		}
+	}
+	return nil, &store.RoleError{RosterID: 1, Requirement: store.RoleRequirement{Role: "igl", Count: 1}}
+}
+
+func TestRoles(t *testing.T) {
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		s int    // expected http status code
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect requirements of roster",
+			m: http.MethodGet,
+			u: "/roster/1/requirements",
+			s: http.StatusOK,
+			b: `[{"role":"igl","count":1}]`,
+		},
+		{
+			d: "expect requirements of missing roster to result in 404",
+			m: http.MethodGet,
+			u: "/v2/rosters/2/requirements",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect requirements to be replaced in lower case",
+			m: http.MethodPut,
+			u: "/v2/rosters/1/requirements",
+			p: `[{"role":"IGL","count":1},{"role":"awper","count":1}]`,
+			s: http.StatusOK,
+			b: `{"role":"awper","count":1}`,
+		},
+		{
+			d: "expect requirement of more than ten players to result in 400",
+			m: http.MethodPut,
+			u: "/roster/1/requirements",
+			p: `[{"role":"entry","count":11}]`,
+			s: http.StatusBadRequest,
+			b: `"name":"count"`,
+		},
+		{
+			d: "expect duplicate requirement to result in 400",
+			m: http.MethodPut,
+			u: "/roster/1/requirements",
+			p: `[{"role":"igl","count":1},{"role":"Igl","count":1}]`,
+			s: http.StatusBadRequest,
+			b: `"name":"role"`,
+		},
+		{
+			d: "expect unsatisfied requirements to result in 409",
+			m: http.MethodPut,
+			u: "/roster/1/requirements",
+			p: `[{"role":"igl","count":2}]`,
+			s: http.StatusConflict,
+			b: `"type":"/problems/role-requirement"`,
+		},
+		{
+			d: "expect roles of player to be replaced",
+			m: http.MethodPut,
+			u: "/players/1/roles",
+			p: `["igl","support"]`,
+			s: http.StatusOK,
+			b: `"roles":["igl","support"]`,
+		},
+		{
+			d: "expect empty role to result in 400",
+			m: http.MethodPut,
+			u: "/players/1/roles",
+			p: `["igl",""]`,
+			s: http.StatusBadRequest,
+			b: `"name":"roles"`,
+		},
+		{
+			d: "expect removing the only active igl to result in 409",
+			m: http.MethodPut,
+			u: "/v2/players/1/roles",
+			p: `["support"]`,
+			s: http.StatusConflict,
+			b: `"requirement":{"role":"igl","count":1}`,
+		},
+		{
+			d: "expect patch of roles to result in 400",
+			m: http.MethodPatch,
+			u: "/players/1",
+			p: `{"roles":["igl"]}`,
+			s: http.StatusBadRequest,
+			b: `"name":"roles"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p)))
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
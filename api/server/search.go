@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/search"
+)
+
+// searchStore searches players by their names and aliases.
+type searchStore interface {
+	Search(ctx context.Context, q store.SearchQuery) ([]store.SearchResult, error)
+}
+
+// searchService provides an API method to search players.
+type searchService struct {
+	searchStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the search endpoint. The text is given by the
+// query parameter q, results are filtered by roster_id and status like player
+// listings and capped by limit.
+func (ss *searchService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ss.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	q := r.URL.Query()
+	text := search.Normalize(q.Get("q"))
+	if n := utf8.RuneCountInString(text); n == 0 || n > 64 {
+		writeError(w, r, invalidParam("q", "must have 1 to 64 characters"), http.StatusBadRequest)
+		return
+	}
+	rosterIDs, status, err := rosterFilter(q)
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	limit := search.DefaultLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > search.MaxLimit {
+			writeError(w, r, invalidParam("limit", fmt.Sprintf("must be between 1 and %d", search.MaxLimit)), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := ss.Search(ctx, store.SearchQuery{
+		Text:      text,
+		RosterIDs: rosterIDs,
+		Status:    status,
+		Limit:     limit,
+	})
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, results, http.StatusOK)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/store/search"
+	"github.com/fgrimme/patrongg/testdata"
+	"github.com/rs/zerolog"
+)
+
+// testSearchStore searches the players of the test roster.
+var testSearchStore = search.NewMemoryStore(append(
+	testdata.Rosters[382574876546039808].R.Players.Active,
+	testdata.Rosters[382574876546039808].R.Players.Benched...)...)
+
+func TestSearch(t *testing.T) {
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		u string // request url
+		s int    // expected http status code
+		b string // expected payload or substring of the payload
+	}{
+		{
+			d: "expect players matching part of an alias",
+			u: "/players/search?q=slay",
+			s: http.StatusOK,
+			b: `[{"player_id":182919996442279937,"roster_id":382574876546039808,"first_name":"Dominic","last_name":"Luklowski","alias":"DataSlayer9","status":"active","rank":0.5666666666666667,"highlights":{"alias":"Data\u003cem\u003eSlay\u003c/em\u003eer9"}}]`,
+		},
+		{
+			d: "expect misspelled names to match",
+			u: "/v2/players/search?q=Domenic",
+			s: http.StatusOK,
+			b: `"player_id":"182919996442279937"`,
+		},
+		{
+			d: "expect filtered results",
+			u: "/players/search?q=slay&status=benched",
+			s: http.StatusOK,
+			b: `[]`,
+		},
+		{
+			d: "expect missing text to result in 400",
+			u: "/players/search?q=+",
+			s: http.StatusBadRequest,
+			b: `"name":"q"`,
+		},
+		{
+			d: "expect invalid limit to result in 400",
+			u: "/players/search?q=slay&limit=101",
+			s: http.StatusBadRequest,
+			b: `"name":"limit"`,
+		},
+		{
+			d: "expect invalid status to result in 400",
+			u: "/players/search?q=slay&status=injured",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.u, nil))
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
 	"github.com/rs/zerolog"
 )
 
@@ -14,17 +16,26 @@ type HTTPServer struct {
 	logger zerolog.Logger
 }
 
-// New returns an HTTPServer instance with a handler attached.
-func New(httpAddr string, timeout time.Duration, rs rosterStore, ps playerStore, logger zerolog.Logger) (*HTTPServer, error) {
-	handler, err := newHandler(rs, ps, timeout, logger)
+// New returns an HTTPServer instance with a handler attached. Requests time out
+// after timeout, exports of rosters after exportTimeout. Clients may cache
+// roster responses for maxAge. API version 1 is announced to be removed at
+// sunset, if not zero. Requests are not rate limited if limiter is nil. All
+// clients are anonymous if no API keys are given.
+func New(httpAddr string, timeout, exportTimeout, maxAge time.Duration, sunset time.Time, stores Stores, limiter *middleware.RateLimiter, keys auth.Keys, logger zerolog.Logger) (*HTTPServer, error) {
+	handler, err := newHandler(stores, timeout, exportTimeout, maxAge, sunset, limiter, keys, logger)
 	if err != nil {
 		return nil, err
 	}
+	// exports are streamed until they time out
+	writeTimeout := 5 * time.Second
+	if exportTimeout > writeTimeout {
+		writeTimeout = exportTimeout
+	}
 	server := &http.Server{
 		Addr:         httpAddr,
 		Handler:      handler,
 		ReadTimeout:  5 * time.Second, // deadline for reading request body
-		WriteTimeout: 5 * time.Second, // deadline for ServeHTTP
+		WriteTimeout: writeTimeout,    // deadline for ServeHTTP
 	}
 	return &HTTPServer{
 		server: server,
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// titleStore handles operations on the game title catalog.
+type titleStore interface {
+	Create(ctx context.Context, title store.Title) (*store.Title, error)
+	Get(ctx context.Context, titleID uint64) (*store.Title, error)
+	List(ctx context.Context) ([]store.Title, error)
+}
+
+// titleService provides API methods to operate on the game title catalog. The
+// rules of a title are the defaults of its rosters.
+type titleService struct {
+	titleStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the title endpoints. Only admins may add
+// titles to the catalog.
+func (ts *titleService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ts.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	v, ok := mux.Vars(r)["id"]
+	if !ok {
+		if r.Method == http.MethodGet {
+			titles, err := ts.List(ctx)
+			if err != nil {
+				writeError(w, r, err, http.StatusInternalServerError)
+				return
+			}
+			encode(w, r, titles, http.StatusOK)
+			return
+		}
+		// we expect a request body that represents a title or we consider
+		// the request as invalid
+		var title store.Title
+		if err := decode(r, &title); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		ts.create(ctx, w, r, title)
+		return
+	}
+
+	// query param validation is currently performed by mux only
+	titleID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	title, err := ts.Get(ctx, titleID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, title, http.StatusOK)
+}
+
+// create adds a title to the catalog. Responds with 201, the location and the
+// representation of the title or an error.
+func (ts *titleService) create(ctx context.Context, w http.ResponseWriter, r *http.Request, title store.Title) {
+	if err := requireAdmin(r, "only admins may create game titles"); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	var err error
+	switch n := utf8.RuneCountInString(title.Name); {
+	case n == 0 || n > 64:
+		err = invalidParam("name", "must have 1 to 64 characters")
+	case title.ActiveSize < 1 || title.ActiveSize > store.MaxActiveSize:
+		err = invalidParam("active_size", fmt.Sprintf("must be between 1 and %d", store.MaxActiveSize))
+	case title.MaxBenched != nil && *title.MaxBenched < 0:
+		err = invalidParam("max_benched", "must not be negative")
+	default:
+		// role requirements can not exceed the active players
+		err = normalizeRequirements(title.Requirements, title.ActiveSize)
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	created, err := ts.Create(ctx, title)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/titles/%d", versionPrefix(r), created.TitleID))
+	encode(w, r, created, http.StatusCreated)
+}
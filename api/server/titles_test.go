@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// title 1 has 3 active players, a bench of 1 and requires a striker.
+type mockTitleStore struct{}
+
+func (s *mockTitleStore) Create(ctx context.Context, title store.Title) (*store.Title, error) {
+	if title.Name == "exists" {
+		return nil, fmt.Errorf("title %s: %w", title.Name, store.ErrExists)
+	}
+	title.TitleID = 2
+	return &title, nil
+}
+
+func (s *mockTitleStore) Get(ctx context.Context, titleID uint64) (*store.Title, error) {
+	if titleID != 1 {
+		return nil, store.ErrNotFound
+	}
+	bench := 1
+	return &store.Title{
+		TitleID:      1,
+		Name:         "rocket league",
+		Rules:        store.Rules{ActiveSize: 3, MaxBenched: &bench},
+		Requirements: []store.RoleRequirement{{Role: "striker", Count: 1}},
+	}, nil
+}
+
+func (s *mockTitleStore) List(ctx context.Context) ([]store.Title, error) {
+	t, err := s.Get(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	return []store.Title{*t}, nil
+}
+
+func TestTitles(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"user":  {Subject: "bob"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect catalog of titles",
+			m: http.MethodGet,
+			u: "/titles",
+			s: http.StatusOK,
+			b: `[{"title_id":1,"name":"rocket league","active_size":3,"max_benched":1,"requirements":[{"role":"striker","count":1}]}]`,
+		},
+		{
+			d: "expect title",
+			m: http.MethodGet,
+			u: "/v2/titles/1",
+			s: http.StatusOK,
+			b: `"title_id":"1"`,
+		},
+		{
+			d: "expect missing title to result in 404",
+			m: http.MethodGet,
+			u: "/titles/2",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect admin to create title",
+			m: http.MethodPost,
+			u: "/v2/titles",
+			p: `{"name":"valorant","active_size":5,"requirements":[{"role":" Duelist ","count":2}]}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/titles/2",
+			b: `"requirements":[{"role":"duelist","count":2}]`,
+		},
+		{
+			d: "expect title created by user to result in 403",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"valorant","active_size":5}`,
+			k: "user",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect anonymous title to result in 401",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"valorant","active_size":5}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect title without active players to result in 400",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"valorant"}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"active_size"`,
+		},
+		{
+			d: "expect negative bench to result in 400",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"valorant","active_size":5,"max_benched":-1}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"max_benched"`,
+		},
+		{
+			d: "expect requirement exceeding active players to result in 400",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"rocket league","active_size":3,"requirements":[{"role":"striker","count":4}]}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"reason":"must be between 1 and 3"`,
+		},
+		{
+			d: "expect existing title to result in 409",
+			m: http.MethodPost,
+			u: "/titles",
+			p: `{"name":"exists","active_size":5}`,
+			k: "admin",
+			s: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
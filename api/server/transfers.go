@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/gorilla/mux"
+)
+
+// transferTTL is the time a transfer can be accepted if the request does not
+// specify when it expires.
+const transferTTL = 7 * 24 * time.Hour
+
+// transferStore handles operations on transfer requests.
+type transferStore interface {
+	Propose(ctx context.Context, transfer store.Transfer) (*store.Transfer, error)
+	Get(ctx context.Context, transferID uint64) (*store.Transfer, error)
+	List(ctx context.Context, rosterID uint64, status string) ([]store.Transfer, error)
+	ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error)
+	Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error)
+	Decide(ctx context.Context, transferID uint64, status, subject string) (*store.Transfer, error)
+}
+
+// managerStore handles operations on the managers of rosters.
+type managerStore interface {
+	List(ctx context.Context, rosterID uint64) ([]string, error)
+	Add(ctx context.Context, rosterID uint64, subject string) error
+	Remove(ctx context.Context, rosterID uint64, subject string) error
+	Manages(ctx context.Context, subject string, rosterID uint64) (bool, error)
+}
+
+// transferService provides API methods to transfer players between rosters.
+// Transfers are proposed by the managers of the source roster and accepted or
+// rejected by the managers of the destination roster.
+type transferService struct {
+	transferStore
+	players  playerStore
+	managers managerStore
+	timeout  time.Duration
+}
+
+// ServeHTTP serves requests to the transfer endpoints.
+func (ts *transferService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ts.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	vars := mux.Vars(r)
+	if v, ok := vars["roster_id"]; ok {
+		rosterID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			// note, this is non-reachable code whith the current mux routing setup
+			writeError(w, r, errBadRequest, http.StatusBadRequest)
+			return
+		}
+		ts.list(ctx, w, r, rosterID, r.URL.Query().Get("status"))
+		return
+	}
+	v, ok := vars["id"]
+	if !ok {
+		// we expect a request body that represents a transfer or we consider
+		// the request as invalid
+		var transfer store.Transfer
+		if err := decode(r, &transfer); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		ts.propose(ctx, w, r, transfer)
+		return
+	}
+	transferID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		ts.get(ctx, w, r, transferID)
+	case http.MethodPatch:
+		// we expect a merge patch of the status or we consider the request
+		// as invalid
+		var transfer store.Transfer
+		if err := decode(r, &transfer); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		ts.decide(ctx, w, r, transferID, transfer.Status)
+	default:
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errNotFound, http.StatusNotFound)
+	}
+}
+
+// propose proposes the transfer of a player from its roster to another
+// roster. Only managers of the roster of the player may propose transfers.
+// Responds with 201, the location and the representation of the transfer or
+// an error.
+func (ts *transferService) propose(ctx context.Context, w http.ResponseWriter, r *http.Request, transfer store.Transfer) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case transfer.PlayerID == 0:
+		err = invalidParam("player_id", "must not be empty")
+	case transfer.ToRosterID == 0:
+		err = invalidParam("to_roster_id", "must not be empty")
+	case transfer.ExpiresAt.IsZero():
+		transfer.ExpiresAt = time.Now().Add(transferTTL)
+	case !transfer.ExpiresAt.After(time.Now()):
+		err = invalidParam("expires_at", "must be in the future")
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	player, err := ts.players.Get(ctx, transfer.PlayerID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if player.RosterID == transfer.ToRosterID {
+		writeError(w, r, invalidParam("to_roster_id", "must not be the roster of the player"), http.StatusBadRequest)
+		return
+	}
+	if err := ts.authorize(ctx, p, player.RosterID); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	transfer.FromRosterID = player.RosterID
+	transfer.RequestedBy = p.Subject
+
+	t, err := ts.Propose(ctx, transfer)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/transfers/%d", versionPrefix(r), t.TransferID))
+	encode(w, r, t, http.StatusCreated)
+}
+
+// get responds with the transfer and its history.
+func (ts *transferService) get(ctx context.Context, w http.ResponseWriter, r *http.Request, transferID uint64) {
+	t, err := ts.Get(ctx, transferID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, t, http.StatusOK)
+}
+
+// list responds with the transfers from and to the roster.
+func (ts *transferService) list(ctx context.Context, w http.ResponseWriter, r *http.Request, rosterID uint64, status string) {
+	transfers, err := ts.List(ctx, rosterID, status)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, transfers, http.StatusOK)
+}
+
+// decide accepts, rejects or cancels the transfer. Transfers are accepted or
+// rejected by managers of the destination roster and cancelled by managers of
+// the source roster or the subject that proposed them. Responds with the
+// updated transfer or an error.
+func (ts *transferService) decide(ctx context.Context, w http.ResponseWriter, r *http.Request, transferID uint64, status string) {
+	p, err := principal(r)
+	if err != nil {
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	t, err := ts.Get(ctx, transferID)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	switch status {
+	case store.TransferAccepted, store.TransferRejected:
+		err = ts.authorize(ctx, p, t.ToRosterID)
+	case store.TransferCancelled:
+		if p.Subject != t.RequestedBy {
+			err = ts.authorize(ctx, p, t.FromRosterID)
+		}
+	default:
+		writeError(w, r, invalidParam("status", fmt.Sprintf("must be one of %s, %s or %s", store.TransferAccepted, store.TransferRejected, store.TransferCancelled)), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	if status == store.TransferAccepted {
+		t, err = ts.Accept(ctx, transferID, p.Subject)
+	} else {
+		t, err = ts.Decide(ctx, transferID, status, p.Subject)
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	encode(w, r, t, http.StatusOK)
+}
+
+// authorize returns a 403 problem if the principal is neither an admin nor a
+// manager of the roster.
+func (ts *transferService) authorize(ctx context.Context, p auth.Principal, rosterID uint64) error {
+	return authorize(ctx, ts.managers, p, rosterID)
+}
+
+// authorize returns a 403 problem if the principal is neither an admin nor a
+// manager of the roster according to the managers store.
+func authorize(ctx context.Context, managers managerStore, p auth.Principal, rosterID uint64) error {
+	if p.Admin {
+		return nil
+	}
+	ok, err := managers.Manages(ctx, p.Subject, rosterID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return forbidden(fmt.Sprintf("%s does not manage roster %d", p.Subject, rosterID))
+	}
+	return nil
+}
+
+// managerService provides API methods to administrate the managers of
+// rosters.
+type managerService struct {
+	managerStore
+	timeout time.Duration
+}
+
+// ServeHTTP serves requests to the manager endpoints. Only admins may change
+// the managers of a roster.
+func (ms *managerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ms.timeout)
+	defer cancel()
+	// we attach the logger from the request to the context so we do need
+	// to pass it as an parameter
+	ctx = loggerFromRequest(r).WithContext(ctx)
+
+	// query param validation is currently performed by mux only
+	vars := mux.Vars(r)
+	rosterID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		// note, this is non-reachable code whith the current mux routing setup
+		writeError(w, r, errBadRequest, http.StatusBadRequest)
+		return
+	}
+	if _, route := path.Split(r.URL.Path); route == "managers" {
+		managers, err := ms.List(ctx, rosterID)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		encode(w, r, managers, http.StatusOK)
+		return
+	}
+
+	if err := requireAdmin(r, "only admins may change the managers of rosters"); err != nil {
+		writeError(w, r, err, http.StatusForbidden)
+		return
+	}
+	subject := vars["subject"]
+	if r.Method == http.MethodDelete {
+		err = ms.Remove(ctx, rosterID, subject)
+	} else {
+		err = ms.Add(ctx, rosterID, subject)
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
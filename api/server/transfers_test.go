@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog"
+)
+
+// transfer 1 of player 1 from roster 2 to roster 3 was proposed by bob,
+// transfer 2 is rejected already.
+type mockTransferStore struct{}
+
+func (ts *mockTransferStore) Propose(ctx context.Context, transfer store.Transfer) (*store.Transfer, error) {
+	transfer.TransferID = 3
+	transfer.Status = store.TransferProposed
+	return &transfer, nil
+}
+
+func (ts *mockTransferStore) Get(ctx context.Context, transferID uint64) (*store.Transfer, error) {
+	switch transferID {
+	case 1:
+		return &store.Transfer{TransferID: 1, PlayerID: 1, FromRosterID: 2, ToRosterID: 3, Status: store.TransferProposed, RequestedBy: "bob"}, nil
+	case 2:
+		return &store.Transfer{TransferID: 2, PlayerID: 1, FromRosterID: 2, ToRosterID: 3, Status: store.TransferRejected, RequestedBy: "bob"}, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (ts *mockTransferStore) List(ctx context.Context, rosterID uint64, status string) ([]store.Transfer, error) {
+	t, _ := ts.Get(ctx, 1)
+	return []store.Transfer{*t}, nil
+}
+
+func (ts *mockTransferStore) Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error) {
+	return ts.Decide(ctx, transferID, store.TransferAccepted, subject)
+}
+
+func (ts *mockTransferStore) Decide(ctx context.Context, transferID uint64, status, subject string) (*store.Transfer, error) {
+	t, err := ts.Get(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != store.TransferProposed {
+		return nil, &store.ConflictError{RosterID: t.FromRosterID, Reason: fmt.Sprintf("transfer %d is %s", transferID, t.Status)}
+	}
+	t.Status = status
+	t.History = []store.TransferEvent{{Status: status, Subject: subject}}
+	return t, nil
+}
+
+// bob manages roster 2, carol manages roster 3, dave manages roster 4.
+type mockManagerStore struct{}
+
+var mockManagers = map[uint64]string{2: "bob", 3: "carol", 4: "dave"}
+
+func (ms *mockManagerStore) List(ctx context.Context, rosterID uint64) ([]string, error) {
+	if m, ok := mockManagers[rosterID]; ok {
+		return []string{m}, nil
+	}
+	return []string{}, nil
+}
+
+func (ms *mockManagerStore) Add(ctx context.Context, rosterID uint64, subject string) error {
+	return nil
+}
+
+func (ms *mockManagerStore) Remove(ctx context.Context, rosterID uint64, subject string) error {
+	if mockManagers[rosterID] != subject {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (ms *mockManagerStore) Manages(ctx context.Context, subject string, rosterID uint64) (bool, error) {
+	return mockManagers[rosterID] == subject, nil
+}
+
+func TestTransfers(t *testing.T) {
+	keys := auth.Keys{
+		"admin": {Subject: "alice", Admin: true},
+		"bob":   {Subject: "bob"},
+		"carol": {Subject: "carol"},
+	}
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, keys, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		m string // request method
+		u string // request url path
+		p string // request payload
+		k string // API key
+		s int    // expected http status code
+		l string // expected Location header
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect anonymous proposal to result in 401",
+			m: http.MethodPost,
+			u: "/transfers",
+			p: `{"player_id":1,"to_roster_id":3}`,
+			s: http.StatusUnauthorized,
+		},
+		{
+			d: "expect proposal of destination manager to result in 403",
+			m: http.MethodPost,
+			u: "/transfers",
+			p: `{"player_id":1,"to_roster_id":3}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect 201 when source manager proposes transfer",
+			m: http.MethodPost,
+			u: "/transfers",
+			p: `{"player_id":1,"to_roster_id":3}`,
+			k: "bob",
+			s: http.StatusCreated,
+			l: "/transfers/3",
+			b: `"requested_by":"bob"`,
+		},
+		{
+			d: "expect versioned location when proposing transfer",
+			m: http.MethodPost,
+			u: "/v2/transfers",
+			p: `{"player_id":"1","to_roster_id":"3"}`,
+			k: "admin",
+			s: http.StatusCreated,
+			l: "/v2/transfers/3",
+			b: `"from_roster_id":"2"`,
+		},
+		{
+			d: "expect transfer to the roster of the player to result in 400",
+			m: http.MethodPost,
+			u: "/transfers",
+			p: `{"player_id":1,"to_roster_id":2}`,
+			k: "bob",
+			s: http.StatusBadRequest,
+			b: `"name":"to_roster_id"`,
+		},
+		{
+			d: "expect transfer of missing player to result in 404",
+			m: http.MethodPost,
+			u: "/transfers",
+			p: `{"player_id":2,"to_roster_id":3}`,
+			k: "bob",
+			s: http.StatusNotFound,
+		},
+		{
+			d: "expect transfer with history",
+			m: http.MethodGet,
+			u: "/transfers/1",
+			s: http.StatusOK,
+			b: `"status":"proposed"`,
+		},
+		{
+			d: "expect transfers of roster",
+			m: http.MethodGet,
+			u: "/roster/3/transfers?status=proposed",
+			s: http.StatusOK,
+			b: `"transfer_id":1`,
+		},
+		{
+			d: "expect destination manager to accept transfer",
+			m: http.MethodPatch,
+			u: "/transfers/1",
+			p: `{"status":"accepted"}`,
+			k: "carol",
+			s: http.StatusOK,
+			b: `"subject":"carol"`,
+		},
+		{
+			d: "expect acceptance of source manager to result in 403",
+			m: http.MethodPatch,
+			u: "/transfers/1",
+			p: `{"status":"accepted"}`,
+			k: "bob",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect requester to cancel transfer",
+			m: http.MethodPatch,
+			u: "/transfers/1",
+			p: `{"status":"cancelled"}`,
+			k: "bob",
+			s: http.StatusOK,
+			b: `"status":"cancelled"`,
+		},
+		{
+			d: "expect cancellation of destination manager to result in 403",
+			m: http.MethodPatch,
+			u: "/transfers/1",
+			p: `{"status":"cancelled"}`,
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect decision of rejected transfer to result in 409",
+			m: http.MethodPatch,
+			u: "/transfers/2",
+			p: `{"status":"accepted"}`,
+			k: "carol",
+			s: http.StatusConflict,
+		},
+		{
+			d: "expect invalid status to result in 400",
+			m: http.MethodPatch,
+			u: "/transfers/1",
+			p: `{"status":"expired"}`,
+			k: "admin",
+			s: http.StatusBadRequest,
+			b: `"name":"status"`,
+		},
+		{
+			d: "expect managers of roster",
+			m: http.MethodGet,
+			u: "/v2/rosters/2/managers",
+			s: http.StatusOK,
+			b: `["bob"]`,
+		},
+		{
+			d: "expect 204 when admin adds manager",
+			m: http.MethodPut,
+			u: "/roster/3/managers/dave",
+			k: "admin",
+			s: http.StatusNoContent,
+		},
+		{
+			d: "expect manager added by user to result in 403",
+			m: http.MethodPut,
+			u: "/roster/3/managers/dave",
+			k: "carol",
+			s: http.StatusForbidden,
+		},
+		{
+			d: "expect removing missing manager to result in 404",
+			m: http.MethodDelete,
+			u: "/roster/3/managers/dave",
+			k: "admin",
+			s: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			r := httptest.NewRequest(tt.m, tt.u, strings.NewReader(tt.p))
+			if tt.k != "" {
+				r.Header.Set(middleware.APIKeyHeader, tt.k)
+			}
+			if tt.m == http.MethodPatch {
+				r.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d: %s", want, got, w.Body.String())
+			}
+			if want, got := tt.l, w.Header().Get("Location"); want != got {
+				t.Errorf("want location %q got %q", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fgrimme/patrongg/middleware"
+)
+
+// API versions. Version 1 preserves the original routes and representations
+// and is served unversioned too. Version 2 uses plural resource names, string
+// ids and problem details only.
+const (
+	v1 = 1
+	v2 = 2
+)
+
+type versionKey struct{}
+
+// withVersion produces middleware attaching the API version to the request
+// context. Responses of version 1 announce its deprecation and, if sunset is
+// not zero, the time it will be removed.
+func withVersion(version int, sunset time.Time) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if version == v1 {
+				w.Header().Set("Deprecation", "true")
+				if !sunset.IsZero() {
+					w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+				w.Header().Set("Link", `</v2>; rel="successor-version"`)
+			}
+			ctx := context.WithValue(r.Context(), versionKey{}, version)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// versionFromRequest returns the API version of the request. Requests to
+// endpoints that are not versioned default to version 1.
+func versionFromRequest(r *http.Request) int {
+	if version, ok := r.Context().Value(versionKey{}).(int); ok {
+		return version
+	}
+	return v1
+}
+
+// versionPrefix returns the version prefix of the request path, which is
+// empty for unversioned routes.
+func versionPrefix(r *http.Request) string {
+	switch {
+	case versionFromRequest(r) == v2:
+		return "/v2"
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		return "/v1"
+	}
+	return ""
+}
+
+// rosterPath returns the path of the roster with the given id in the API
+// version of the request.
+func rosterPath(r *http.Request, rosterID uint64) string {
+	if versionFromRequest(r) == v2 {
+		return fmt.Sprintf("/v2/rosters/%d", rosterID)
+	}
+	return fmt.Sprintf("%s/roster/%d", versionPrefix(r), rosterID)
+}
+
+// playerPath returns the path of the player with the given id in the API
+// version of the request.
+func playerPath(r *http.Request, playerID uint64) string {
+	return fmt.Sprintf("%s/players/%d", versionPrefix(r), playerID)
+}
+
+// matchPath returns the path of the match with the given id in the API
+// version of the request.
+func matchPath(r *http.Request, matchID uint64) string {
+	return fmt.Sprintf("%s/matches/%d", versionPrefix(r), matchID)
+}
+
+// unversioned returns the path without the prefix of version 1, so that the
+// versioned and unversioned routes of version 1 are treated alike.
+func unversioned(path string) string {
+	if strings.HasPrefix(path, "/v1/") {
+		return path[len("/v1"):]
+	}
+	return path
+}
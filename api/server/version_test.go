@@ -0,0 +1,127 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/api"
+	"github.com/rs/zerolog"
+)
+
+func TestVersions(t *testing.T) {
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, sunset, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		d string // description of test case
+		p string // url path
+		a string // Accept header
+		s int    // expected status code
+		t string // expected content type
+		x string // expected Sunset header, set on deprecated versions only
+		b string // expected substring of the payload
+	}{
+		{
+			d: "expect unversioned routes to be served by v1",
+			p: "/roster/4",
+			s: http.StatusOK,
+			t: "application/json",
+			x: "Wed, 30 Jun 2027 00:00:00 GMT",
+			b: `"roster_id":382574876546039808`,
+		},
+		{
+			d: "expect v1 to be deprecated",
+			p: "/v1/roster/4/active",
+			s: http.StatusOK,
+			t: "application/json",
+			x: "Wed, 30 Jun 2027 00:00:00 GMT",
+			b: `"player_id":`,
+		},
+		{
+			d: "expect v1 legacy errors",
+			p: "/v1/roster/7",
+			a: api.LegacyMediaType,
+			s: http.StatusNotFound,
+			t: "application/json",
+			x: "Wed, 30 Jun 2027 00:00:00 GMT",
+			b: `{"error":"not_found"}`,
+		},
+		{
+			d: "expect v2 to use string ids",
+			p: "/v2/rosters/4",
+			s: http.StatusOK,
+			t: "application/json",
+			b: `"roster_id":"382574876546039808"`,
+		},
+		{
+			d: "expect v2 players of a roster",
+			p: "/v2/rosters/5/players/benched",
+			s: http.StatusOK,
+			t: "application/json",
+			b: `"status":"benched"`,
+		},
+		{
+			d: "expect v2 to respond with problem details only",
+			p: "/v2/rosters/7",
+			a: api.LegacyMediaType,
+			s: http.StatusNotFound,
+			t: api.ProblemMediaType,
+			b: `"status":404`,
+		},
+		{
+			d: "expect v1 routes not to be served by v2",
+			p: "/v2/roster/4",
+			s: http.StatusNotFound,
+			t: "text/plain; charset=utf-8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.p, nil)
+			if tt.a != "" {
+				r.Header.Set("Accept", tt.a)
+			}
+			h.ServeHTTP(w, r)
+			if want, got := tt.s, w.Code; want != got {
+				t.Errorf("want status code %d got %d", want, got)
+			}
+			if want, got := tt.t, w.Header().Get("Content-Type"); want != got {
+				t.Errorf("want content type %q got %q", want, got)
+			}
+			if want, got := tt.x, w.Header().Get("Sunset"); want != got {
+				t.Errorf("want Sunset %q got %q", want, got)
+			}
+			if want, got := tt.x != "", w.Header().Get("Deprecation") == "true"; want != got {
+				t.Errorf("want deprecated %t got %t", want, got)
+			}
+			if !strings.Contains(w.Body.String(), tt.b) {
+				t.Errorf("want payload containing\n%s\ngot\n%s", tt.b, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRosterPath(t *testing.T) {
+	h, err := newHandler(testStores(), 200*time.Millisecond, time.Second, 0, time.Time{}, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	for path, want := range map[string]string{
+		"/rosters":    "/roster/1",
+		"/v1/rosters": "/v1/roster/1",
+		"/v2/rosters": "/v2/rosters/1",
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"foo","players":{"active":`+activePlayers+`}}`)))
+		if got := w.Header().Get("Location"); want != got {
+			t.Errorf("%s: want location %q got %q", path, want, got)
+		}
+	}
+}
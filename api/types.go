@@ -1,22 +1,183 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"sort"
 )
 
+// Media types of error responses. Problem details are described in RFC 7807,
+// the legacy representation only holds an error string.
+const (
+	ProblemMediaType = "application/problem+json"
+	LegacyMediaType  = "application/json"
+)
+
+// Problem types used in the type member of problem details. Errors that do not
+// have a specific problem type use BlankProblemType and the HTTP status text as
+// their title.
+const (
+	BlankProblemType         = "about:blank"
+	InvalidProblemType       = "/problems/invalid-request"
+	InvalidImportProblemType = "/problems/invalid-import"
+	ConflictProblemType      = "/problems/roster-conflict"
+	LockedProblemType        = "/problems/roster-locked"
+	RoleProblemType          = "/problems/role-requirement"
+)
+
+// Error represents an error response of the API. It is serialized as problem
+// details according to RFC 7807. Extension members are serialized as top-level
+// members next to the standard ones. Err holds the error of the legacy
+// representation; it is not set in problem details.
 type Error struct {
-	Err      string         `json:"error"`
-	Response *http.Response `json:"-"` // Will not be marshalled
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Err        string                 `json:"error,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+	Response   *http.Response         `json:"-"` // Will not be marshalled
+}
+
+// InvalidParam describes a request field that failed validation. A list of
+// invalid params is provided in the invalid-params extension member.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
 
 func (e Error) Error() string {
+	msg := e.Err
+	if msg == "" {
+		msg = e.Title
+		if e.Detail != "" {
+			msg = fmt.Sprintf("%s: %s", e.Title, e.Detail)
+		}
+	}
 	if e.Response == nil {
-		return e.Err
+		return msg
 	}
 	return fmt.Sprintf("%v %v: %d %v",
 		e.Response.Request.Method,
 		e.Response.Request.URL,
 		e.Response.StatusCode,
-		e.Err)
+		msg)
+}
+
+// errorMembers is used to (un)marshal the standard members of an Error without
+// recursing into the custom marshalling functions.
+type errorMembers Error
+
+var standardMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+	"error":    true,
+}
+
+// MarshalJSON encodes the standard members followed by the extension members
+// in lexical order.
+func (e Error) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(errorMembers(e))
+	if err != nil || len(e.Extensions) == 0 {
+		return b, err
+	}
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		if !standardMembers[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(b[:len(b)-1]) // strip closing brace
+	for _, name := range names {
+		v, err := json.Marshal(e.Extensions[name])
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the standard members and collects all unknown members
+// as extensions. Extension values are kept as json.RawMessage, use Extension
+// to decode them.
+func (e *Error) UnmarshalJSON(b []byte) error {
+	var m errorMembers
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+	for name, v := range members {
+		if standardMembers[name] {
+			continue
+		}
+		if m.Extensions == nil {
+			m.Extensions = make(map[string]interface{})
+		}
+		m.Extensions[name] = v
+	}
+	m.Response = e.Response
+	*e = Error(m)
+	return nil
+}
+
+// Extension decodes the extension member with the given name into v. Returns
+// false if the member does not exist.
+func (e *Error) Extension(name string, v interface{}) (bool, error) {
+	ext, ok := e.Extensions[name]
+	if !ok {
+		return false, nil
+	}
+	raw, ok := ext.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(ext); err != nil {
+			return true, err
+		}
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// CheckResponse returns an *Error if the response has a status code outside of
+// the 2xx range. Problem details as well as the legacy representation are
+// parsed. The response body is consumed in case of an error.
+func CheckResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	e := &Error{Response: resp}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		e.Err = err.Error()
+		return e
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if (mediaType != ProblemMediaType && mediaType != LegacyMediaType) || json.Unmarshal(body, e) != nil {
+		// not an API error, e.g. a 404 of the router
+		e.Status = resp.StatusCode
+		e.Title = http.StatusText(resp.StatusCode)
+		e.Detail = string(bytes.TrimSpace(body))
+	}
+	return e
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var checkResponseTests = []struct {
+	d string // description of test case
+	s int    // status code of the response
+	t string // content type of the response
+	b string // payload of the response
+	e *Error // expected error without response
+	x []InvalidParam
+}{
+	{
+		d: "expect no error for 2xx responses",
+		s: http.StatusOK,
+		t: "application/json",
+		b: `{}`,
+	},
+	{
+		d: "expect problem details with extensions to be parsed",
+		s: http.StatusBadRequest,
+		t: ProblemMediaType,
+		b: `{"type":"/problems/invalid-request","title":"Invalid request","status":400,"instance":"bq7k","invalid-params":[{"name":"foo","reason":"unknown field"}]}`,
+		e: &Error{
+			Type:     InvalidProblemType,
+			Title:    "Invalid request",
+			Status:   http.StatusBadRequest,
+			Instance: "bq7k",
+		},
+		x: []InvalidParam{{Name: "foo", Reason: "unknown field"}},
+	},
+	{
+		d: "expect legacy errors to be parsed",
+		s: http.StatusInternalServerError,
+		t: "application/json",
+		b: `{"error":"internal_error"}`,
+		e: &Error{Err: "internal_error"},
+	},
+	{
+		d: "expect plain text errors to be converted",
+		s: http.StatusNotFound,
+		t: "text/plain; charset=utf-8",
+		b: "404 page not found\n",
+		e: &Error{
+			Title:  "Not Found",
+			Status: http.StatusNotFound,
+			Detail: "404 page not found",
+		},
+	},
+}
+
+func TestCheckResponse(t *testing.T) {
+	for _, tc := range checkResponseTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/roster/1", nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp := &http.Response{
+				StatusCode: tt.s,
+				Header:     http.Header{"Content-Type": []string{tt.t}},
+				Body:       ioutil.NopCloser(strings.NewReader(tt.b)),
+				Request:    req,
+			}
+			err = CheckResponse(resp)
+			if tt.e == nil {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			got, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("want *Error got %T", err)
+			}
+			var params []InvalidParam
+			if _, err := got.Extension("invalid-params", &params); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(tt.x, params) {
+				t.Errorf("want invalid params %+v got %+v", tt.x, params)
+			}
+			got.Response = nil
+			got.Extensions = nil
+			if !reflect.DeepEqual(tt.e, got) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.e, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package auth identifies the clients of the API by their API keys. Clients
+// without API key are anonymous.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fgrimme/patrongg/middleware"
+)
+
+// AdminRole is the role of principals permitted to administrate rosters.
+const AdminRole = "admin"
+
+// Principal is an authenticated client.
+type Principal struct {
+	Subject string
+	Admin   bool
+}
+
+// Keys maps API keys to the principals they identify.
+type Keys map[string]Principal
+
+// ParseKey parses an API key of the form <key>=<subject>[:admin], e.g.
+// "s3cr3t=alice:admin".
+func ParseKey(s string) (string, Principal, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", Principal{}, fmt.Errorf("invalid API key: missing key")
+	}
+	key, subject := s[:i], s[i+1:]
+	var p Principal
+	if j := strings.Index(subject, ":"); j >= 0 {
+		if subject[j+1:] != AdminRole {
+			return "", Principal{}, fmt.Errorf("invalid API key of %s: unknown role %q", subject[:j], subject[j+1:])
+		}
+		subject, p.Admin = subject[:j], true
+	}
+	if subject == "" {
+		return "", Principal{}, fmt.Errorf("invalid API key: missing subject")
+	}
+	p.Subject = subject
+	return key, p, nil
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the context. Returns false for
+// anonymous clients.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware produces middleware attaching the principal identified by the
+// API key of a request to its context. The API key is attached as validated
+// client key for rate limiting, see middleware.ClientKey. Requests with an
+// unknown API key are passed to denied.
+func (k Keys) Middleware(denied http.Handler) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(middleware.APIKeyHeader)
+			if key == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			p, ok := k[key]
+			if !ok {
+				denied.ServeHTTP(w, r)
+				return
+			}
+			ctx := middleware.WithClient(WithPrincipal(r.Context(), p), key)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fgrimme/patrongg/middleware"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		s string    // key to parse
+		k string    // expected key
+		p Principal // expected principal
+		e bool      // expect error
+	}{
+		{s: "s3cr3t=alice", k: "s3cr3t", p: Principal{Subject: "alice"}},
+		{s: "s3cr3t=alice:admin", k: "s3cr3t", p: Principal{Subject: "alice", Admin: true}},
+		{s: "a=b=c", k: "a", p: Principal{Subject: "b=c"}},
+		{s: "s3cr3t", e: true},
+		{s: "=alice", e: true},
+		{s: "s3cr3t=", e: true},
+		{s: "s3cr3t=alice:root", e: true},
+	}
+	for _, tt := range tests {
+		k, p, err := ParseKey(tt.s)
+		if tt.e {
+			if err == nil {
+				t.Errorf("%s: want error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.s, err)
+			continue
+		}
+		if k != tt.k || p != tt.p {
+			t.Errorf("%s: want %s %+v got %s %+v", tt.s, tt.k, tt.p, k, p)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := Keys{"s3cr3t": {Subject: "alice", Admin: true}}
+	denied := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	h := keys.Middleware(denied)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := FromContext(r.Context()); ok {
+			w.Header().Set("Subject", p.Subject)
+		}
+	}))
+
+	tests := []struct {
+		k string // API key
+		s int    // expected status code
+		p string // expected subject
+	}{
+		{s: http.StatusOK},
+		{k: "s3cr3t", s: http.StatusOK, p: "alice"},
+		{k: "unknown", s: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.k != "" {
+			r.Header.Set(middleware.APIKeyHeader, tt.k)
+		}
+		h.ServeHTTP(w, r)
+		if want, got := tt.s, w.Code; want != got {
+			t.Errorf("%q: want status code %d got %d", tt.k, want, got)
+		}
+		if want, got := tt.p, w.Header().Get("Subject"); want != got {
+			t.Errorf("%q: want subject %q got %q", tt.k, want, got)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// Export formats.
+const (
+	FormatCSV      = "csv"
+	FormatNDJSON   = "ndjson"
+	FormatMarkdown = "markdown"
+)
+
+// Columns are the columns of roster exports in the order they are written.
+var Columns = []string{
+	"roster_id",
+	"roster_name",
+	"player_id",
+	"first_name",
+	"last_name",
+	"alias",
+	"status",
+}
+
+// Writer writes the rows of a roster export.
+type Writer interface {
+	// Write writes a single row.
+	Write(row store.RosterRow) error
+	// Flush writes any buffered data to the underlying io.Writer.
+	Flush() error
+}
+
+// NewWriter returns a Writer for the given export format. Formats with a
+// header write it immediately.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatMarkdown:
+		return newMarkdownWriter(w)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// ContentType returns the media type of the given export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return CSV + "; charset=utf-8"
+	case FormatNDJSON:
+		return NDJSON
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// FileExtension returns the file extension of the given export format.
+func FileExtension(format string) string {
+	if format == FormatMarkdown {
+		return "md"
+	}
+	return format
+}
+
+// fields returns the values of a row in column order.
+func fields(row store.RosterRow) []string {
+	return []string{
+		strconv.FormatUint(row.Player.RosterID, 10),
+		row.RosterName,
+		strconv.FormatUint(row.Player.PlayerID, 10),
+		row.Player.FirstName,
+		row.Player.LastName,
+		row.Player.Alias,
+		row.Player.Status,
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(Columns)
+}
+
+// Write writes a CSV record. Values that spreadsheet applications would
+// interpret as formulas are prefixed with a single quote.
+func (cw *csvWriter) Write(row store.RosterRow) error {
+	record := fields(row)
+	for i, v := range record {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			record[i] = "'" + v
+		}
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonRow is the JSON representation of an exported row. The field order
+// matches Columns.
+type ndjsonRow struct {
+	RosterID   uint64 `json:"roster_id"`
+	RosterName string `json:"roster_name"`
+	PlayerID   uint64 `json:"player_id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Alias      string `json:"alias"`
+	Status     string `json:"status"`
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(row store.RosterRow) error {
+	return nw.encoder.Encode(ndjsonRow{
+		RosterID:   row.Player.RosterID,
+		RosterName: row.RosterName,
+		PlayerID:   row.Player.PlayerID,
+		FirstName:  row.Player.FirstName,
+		LastName:   row.Player.LastName,
+		Alias:      row.Player.Alias,
+		Status:     row.Player.Status,
+	})
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nil
+}
+
+// markdownEscaper escapes characters that would break a markdown table cell.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
+
+type markdownWriter struct {
+	w io.Writer
+}
+
+func newMarkdownWriter(w io.Writer) (*markdownWriter, error) {
+	mw := &markdownWriter{w: w}
+	if err := mw.writeLine(Columns); err != nil {
+		return nil, err
+	}
+	separator := make([]string, len(Columns))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	return mw, mw.writeLine(separator)
+}
+
+func (mw *markdownWriter) Write(row store.RosterRow) error {
+	cells := fields(row)
+	for i, v := range cells {
+		cells[i] = markdownEscaper.Replace(v)
+	}
+	return mw.writeLine(cells)
+}
+
+func (mw *markdownWriter) writeLine(cells []string) error {
+	_, err := fmt.Fprintf(mw.w, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
+
+func (mw *markdownWriter) Flush() error {
+	return nil
+}
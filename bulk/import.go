@@ -0,0 +1,242 @@
+// Package bulk reads and writes players in bulk formats.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// Media types of the supported bulk formats.
+const (
+	CSV    = "text/csv"
+	NDJSON = "application/x-ndjson"
+)
+
+// maxLength is the maximum length of the player's name fields in the datastore.
+const maxLength = 32
+
+// ErrUnsupportedFormat is returned for media types that are not supported.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// FormatError is returned if an import can not be decoded at all, e.g. because
+// of a missing CSV header.
+type FormatError struct {
+	Err error
+}
+
+func (e *FormatError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// Importer imports players in a single transaction.
+type Importer interface {
+	Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error)
+}
+
+// ImportPlayers decodes the players of an import in the given format and
+// imports them. If rows can not be decoded, the remaining rows are only
+// validated so that all errors are reported at once. The errors of the result
+// are ordered by row.
+func ImportPlayers(ctx context.Context, importer Importer, r io.Reader, mediaType string, dryRun bool) (*store.ImportResult, error) {
+	rows, rowErrs, err := DecodePlayers(r, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	result, err := importer.Import(ctx, rows, dryRun || len(rowErrs) > 0)
+	if err != nil {
+		return nil, err
+	}
+	result.DryRun = dryRun
+	result.Errors = append(append(make([]store.RowError, 0), rowErrs...), result.Errors...)
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Row < result.Errors[j].Row
+	})
+	return result, nil
+}
+
+// DecodePlayers decodes the players of an import in the given format. Rows that
+// can not be decoded or hold invalid fields are reported as row errors. A
+// *FormatError is returned if the import can not be read at all.
+func DecodePlayers(r io.Reader, mediaType string) ([]store.PlayerRow, []store.RowError, error) {
+	var rows []store.PlayerRow
+	var rowErrs []store.RowError
+	var err error
+	switch mediaType {
+	case CSV:
+		rows, rowErrs, err = decodeCSV(r)
+	case NDJSON:
+		rows, rowErrs, err = decodeNDJSON(r)
+	default:
+		return nil, nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, nil, &FormatError{Err: err}
+	}
+	return rows, rowErrs, nil
+}
+
+// decodeCSV decodes players from CSV. The first record must be a header naming
+// the columns; roster_id, first_name, last_name and alias are required,
+// status is optional.
+func decodeCSV(r io.Reader) ([]store.PlayerRow, []store.RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // we report the field count per row
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("missing CSV header")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "player_id": // ignored, ids are generated by the datastore
+		case "roster_id", "first_name", "last_name", "alias", "status":
+		default:
+			return nil, nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"roster_id", "first_name", "last_name", "alias"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing CSV column %q", name)
+		}
+	}
+
+	var rows []store.PlayerRow
+	var rowErrs []store.RowError
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, store.RowError{Row: row, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(record) != len(header) {
+			rowErrs = append(rowErrs, store.RowError{
+				Row:    row,
+				Reason: fmt.Sprintf("want %d fields got %d", len(header), len(record)),
+			})
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rosterID, err := strconv.ParseUint(field("roster_id"), 10, 64)
+		if err != nil {
+			rowErrs = append(rowErrs, store.RowError{Row: row, Field: "roster_id", Reason: "must be a positive integer"})
+			continue
+		}
+		p := store.Player{
+			RosterID:  rosterID,
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+			Alias:     field("alias"),
+			Status:    field("status"),
+		}
+		if errs := validate(row, &p); len(errs) > 0 {
+			rowErrs = append(rowErrs, errs...)
+			continue
+		}
+		rows = append(rows, store.PlayerRow{Row: row, Player: p})
+	}
+	return rows, rowErrs, nil
+}
+
+// decodeNDJSON decodes players from newline delimited JSON. Each non-empty line
+// must hold a JSON representation of a player.
+func decodeNDJSON(r io.Reader) ([]store.PlayerRow, []store.RowError, error) {
+	scanner := bufio.NewScanner(r)
+	var rows []store.PlayerRow
+	var rowErrs []store.RowError
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			row-- // blank lines are not counted as rows
+			continue
+		}
+		var p store.Player
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields() // catch unwanted fields
+		if err := decoder.Decode(&p); err != nil {
+			rowErrs = append(rowErrs, store.RowError{Row: row, Reason: err.Error()})
+			continue
+		}
+		p.PlayerID = 0 // ids are generated by the datastore
+		if errs := validate(row, &p); len(errs) > 0 {
+			rowErrs = append(rowErrs, errs...)
+			continue
+		}
+		rows = append(rows, store.PlayerRow{Row: row, Player: p})
+	}
+	return rows, rowErrs, scanner.Err()
+}
+
+// validate checks the fields of a player that is about to be imported. Players
+// without a status are benched.
+func validate(row int, p *store.Player) []store.RowError {
+	var errs []store.RowError
+	if p.RosterID == 0 {
+		errs = append(errs, store.RowError{Row: row, Field: "roster_id", Reason: "is required"})
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"first_name", p.FirstName},
+		{"last_name", p.LastName},
+		{"alias", p.Alias},
+	} {
+		switch {
+		case f.value == "":
+			errs = append(errs, store.RowError{Row: row, Field: f.name, Reason: "is required"})
+		case utf8.RuneCountInString(f.value) > maxLength:
+			errs = append(errs, store.RowError{
+				Row:    row,
+				Field:  f.name,
+				Reason: fmt.Sprintf("must not be longer than %d characters", maxLength),
+			})
+		}
+	}
+	switch p.Status {
+	case "":
+		p.Status = store.Benched
+	case store.Active, store.Benched:
+	default:
+		errs = append(errs, store.RowError{
+			Row:    row,
+			Field:  "status",
+			Reason: fmt.Sprintf("must be %s or %s", store.Active, store.Benched),
+		})
+	}
+	return errs
+}
@@ -0,0 +1,101 @@
+package bulk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+var decodeTests = []struct {
+	d string            // description of test case
+	t string            // media type of the import
+	p string            // import payload
+	r []store.PlayerRow // expected players
+	e []store.RowError  // expected row errors
+	f bool              // expect failure
+}{
+	{
+		d: "expect CSV players to be benched by default",
+		t: CSV,
+		p: "alias,roster_id,first_name,last_name\nfoobar,1,foo,bar\n",
+		r: []store.PlayerRow{
+			{Row: 1, Player: store.Player{RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}},
+		},
+	},
+	{
+		d: "expect CSV row errors to be reported",
+		t: CSV,
+		p: "roster_id,first_name,last_name,alias,status\n1,foo,bar,foobar,active\n1,foo,bar\nx,foo,bar,foobar,\n1,,bar,foobar,retired\n",
+		r: []store.PlayerRow{
+			{Row: 1, Player: store.Player{RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "active"}},
+		},
+		e: []store.RowError{
+			{Row: 2, Reason: "want 5 fields got 3"},
+			{Row: 3, Field: "roster_id", Reason: "must be a positive integer"},
+			{Row: 4, Field: "first_name", Reason: "is required"},
+			{Row: 4, Field: "status", Reason: "must be active or benched"},
+		},
+	},
+	{
+		d: "expect unknown CSV columns to fail",
+		t: CSV,
+		p: "roster_id,first_name,last_name,alias,team\n",
+		f: true,
+	},
+	{
+		d: "expect missing CSV columns to fail",
+		t: CSV,
+		p: "roster_id,first_name,last_name\n",
+		f: true,
+	},
+	{
+		d: "expect NDJSON players without ids",
+		t: NDJSON,
+		p: `{"player_id":7,"roster_id":1,"first_name":"foo","last_name":"bar","alias":"foobar"}` + "\n\n" +
+			`{"roster_id":1,"first_name":"boo","last_name":"baz","alias":"boobaz","status":"active"}`,
+		r: []store.PlayerRow{
+			{Row: 1, Player: store.Player{RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}},
+			{Row: 2, Player: store.Player{RosterID: 1, FirstName: "boo", LastName: "baz", Alias: "boobaz", Status: "active"}},
+		},
+	},
+	{
+		d: "expect NDJSON row errors to be reported",
+		t: NDJSON,
+		p: `{"roster_id":1,"team":"foo"}` + "\n" + `{"roster_id":1,"first_name":"` + strings.Repeat("x", 33) + `","last_name":"bar","alias":"foobar"}`,
+		e: []store.RowError{
+			{Row: 1, Reason: `json: unknown field "team"`},
+			{Row: 2, Field: "first_name", Reason: "must not be longer than 32 characters"},
+		},
+	},
+	{
+		d: "expect unsupported format to fail",
+		t: "application/json",
+		f: true,
+	},
+}
+
+func TestDecodePlayers(t *testing.T) {
+	for _, tc := range decodeTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			rows, rowErrs, err := DecodePlayers(strings.NewReader(tt.p), tt.t)
+			if tt.f {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(tt.r, rows) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.r, rows)
+			}
+			if !reflect.DeepEqual(tt.e, rowErrs) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.e, rowErrs)
+			}
+		})
+	}
+}
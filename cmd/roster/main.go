@@ -2,16 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/fgrimme/patrongg/api/rpc"
 	"github.com/fgrimme/patrongg/api/server"
+	"github.com/fgrimme/patrongg/auth"
+	"github.com/fgrimme/patrongg/bulk"
 	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/events"
+	"github.com/fgrimme/patrongg/middleware"
+	"github.com/fgrimme/patrongg/snowflake"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/availability"
+	"github.com/fgrimme/patrongg/store/cache"
+	"github.com/fgrimme/patrongg/store/compliance"
+	"github.com/fgrimme/patrongg/store/contract"
+	"github.com/fgrimme/patrongg/store/lock"
+	"github.com/fgrimme/patrongg/store/manager"
+	"github.com/fgrimme/patrongg/store/match"
+	"github.com/fgrimme/patrongg/store/org"
 	"github.com/fgrimme/patrongg/store/player"
+	"github.com/fgrimme/patrongg/store/profile"
+	"github.com/fgrimme/patrongg/store/ratelimit"
+	"github.com/fgrimme/patrongg/store/role"
 	"github.com/fgrimme/patrongg/store/roster"
+	"github.com/fgrimme/patrongg/store/title"
+	"github.com/fgrimme/patrongg/store/transfer"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
@@ -21,16 +46,34 @@ var (
 	version = "unkown" // version is build into the binary, see Makefile
 
 	// provide the configuration via env parameters or arguments
-	httpAddr      = kingpin.Flag("http-addr", "address of HTTP server").Envar("HTTP_ADDR").Required().String()
-	serviceName   = kingpin.Flag("service", "service name").Envar("SERVICE").Default("roster-service").String()
-	playerDBDSN   = kingpin.Flag("player-db-dsn", "player db DSN").Envar("PLAYER_DB_DSN").Required().String()
-	timeout       = kingpin.Flag("timeout", "timeout to handle incoming requests").Envar("REQ_TIMEOUT").Default("900ms").Duration()
-	shutdownDelay = kingpin.Flag("shutdown-delay", "shutdown delay in ms").Envar("SHUTDOWN_DELAY").Default("5000ms").Duration()
+	serviceName = kingpin.Flag("service", "service name").Envar("SERVICE").Default("roster-service").String()
+	playerDBDSN = kingpin.Flag("player-db-dsn", "player db DSN").Envar("PLAYER_DB_DSN").Required().String()
+	timeout     = kingpin.Flag("timeout", "timeout to handle incoming requests").Envar("REQ_TIMEOUT").Default("900ms").Duration()
+	workerID    = kingpin.Flag("worker-id", "id of the instance used to generate unique ids, must be distinct per instance").Envar("WORKER_ID").Default("0").Int()
+
+	// the service is run by default
+	serveCmd      = kingpin.Command("serve", "run the roster service").Default()
+	httpAddr      = serveCmd.Flag("http-addr", "address of HTTP server").Envar("HTTP_ADDR").Required().String()
+	grpcAddr      = serveCmd.Flag("grpc-addr", "address of gRPC server").Envar("GRPC_ADDR").Default(":9090").String()
+	rateLimits    = serveCmd.Flag("rate-limit", "rate limit per client of a route, e.g. 'PATCH /players/change=10/m:5', route * applies to all other routes").Envar("RATE_LIMITS").Default("*=50/s:100").Strings()
+	rateLimitDB   = serveCmd.Flag("rate-limit-store", "store of the rate limiter, use postgres to share limits between instances").Envar("RATE_LIMIT_STORE").Default("memory").Enum("memory", "postgres")
+	exportTimeout = serveCmd.Flag("export-timeout", "timeout to stream roster exports").Envar("EXPORT_TIMEOUT").Default("5m").Duration()
+	cacheTTL      = serveCmd.Flag("cache-ttl", "time to live of cached rosters").Envar("CACHE_TTL").Default("30s").Duration()
+	cacheSize     = serveCmd.Flag("cache-size", "max number of cached rosters, 0 disables the cache").Envar("CACHE_SIZE").Default("1000").Int()
+	httpMaxAge    = serveCmd.Flag("http-cache-max-age", "max age of roster responses cached by clients").Envar("HTTP_CACHE_MAX_AGE").Default("0s").Duration()
+	apiKeys       = serveCmd.Flag("api-key", "API key of a client, e.g. 's3cr3t=alice:admin', clients without key are anonymous").Envar("API_KEYS").Strings()
+	v1Sunset      = serveCmd.Flag("v1-sunset", "date API version 1 is removed, e.g. 2027-06-30").Envar("V1_SUNSET").String()
+	shutdownDelay = serveCmd.Flag("shutdown-delay", "shutdown delay in ms").Envar("SHUTDOWN_DELAY").Default("5000ms").Duration()
+
+	importCmd    = kingpin.Command("import", "import players from a CSV or NDJSON file")
+	importFile   = importCmd.Arg("file", "file to import, - reads from stdin").Required().String()
+	importFormat = importCmd.Flag("format", "format of the file, derived from the file extension by default").Enum("csv", "ndjson")
+	importDryRun = importCmd.Flag("dry-run", "validate the import without adding players").Bool()
 )
 
 func main() {
 	kingpin.Version(version)
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 
 	// we use the default log level debug and write to stderr.
 	// note, we log in (inefficient) human friendly format to console here since it
@@ -58,9 +101,76 @@ func main() {
 		}
 	}()
 
+	// ids of new rosters and players are generated by the service
+	ids, err := snowflake.New(*workerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
+		os.Exit(1)
+	}
+
+	if cmd == importCmd.FullCommand() {
+		if err := importPlayers(player.New(ds, ids), *importFile, *importFormat, *importDryRun); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// we use dependency injection throughout the whole application to either create
 	// working instances or fail early on instantiation
-	httpSrv, err := server.New(*httpAddr, *timeout, roster.New(ds), player.New(ds), logger)
+	// rosters are cached, writes to the players store of this instance
+	// invalidate them immediately, writes of other instances once they are
+	// notified by the datastore
+	rosterCache := cache.New(roster.New(ds, ids), *cacheTTL, *cacheSize)
+	rosterStore, playerStore := rosterCache, rosterCache.Players(player.New(ds, ids))
+	limits, err := parseLimits(*rateLimits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
+		os.Exit(1)
+	}
+	var limitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if *rateLimitDB == "postgres" {
+		limitStore = ratelimit.New(ds)
+	}
+	limiter := middleware.NewRateLimiter(limitStore, limits)
+	var sunset time.Time
+	if *v1Sunset != "" {
+		if sunset, err = time.Parse("2006-01-02", *v1Sunset); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid v1 sunset: %v\n", *serviceName, err)
+			os.Exit(1)
+		}
+	}
+	keys, err := parseKeys(*apiKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
+		os.Exit(1)
+	}
+	transferStore := rosterCache.Transfers(transfer.New(ds, ids, player.New(ds, ids)))
+	contractStore := rosterCache.Contracts(contract.New(ds, ids))
+	stores := server.Stores{
+		Rosters:      rosterStore,
+		Players:      playerStore,
+		Locks:        lock.New(ds, ids),
+		Matches:      match.New(ds, ids),
+		Transfers:    transferStore,
+		Managers:     manager.New(ds),
+		Roles:        rosterCache.Roles(role.New(ds)),
+		Orgs:         org.New(ds, ids),
+		Titles:       title.New(ds, ids),
+		Profiles:     profile.New(ds),
+		Search:       player.New(ds, ids),
+		Contracts:    contractStore,
+		Compliance:   rosterCache.Compliance(compliance.New(ds)),
+		Availability: availability.New(ds),
+	}
+	httpSrv, err := server.New(*httpAddr, *timeout, *exportTimeout, *httpMaxAge, sunset, stores, limiter, keys, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
+		os.Exit(1)
+	}
+	// roster changes are notified by the datastore
+	broker := events.NewBroker()
+	grpcSrv, err := rpc.New(*grpcAddr, *timeout, rosterStore, playerStore, broker, logger)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %v\n", *serviceName, err)
 		os.Exit(1)
@@ -77,7 +187,18 @@ func main() {
 		cancel()
 	}()
 
+	go func() {
+		if err := events.Listen(ctx, *playerDBDSN, rosterCache.Publisher(broker), logger); err != nil {
+			logger.Error().Err(err).Msg("failed to listen for roster changes")
+		}
+	}()
+	if s, ok := limitStore.(*ratelimit.RateLimitStore); ok {
+		go pruneRateLimits(ctx, s, logger)
+	}
+	go expireTransfers(ctx, transferStore, logger)
+	go expireContracts(ctx, contractStore, logger)
 	go httpSrv.Run()
+	go grpcSrv.Run()
 
 	<-ctx.Done()
 
@@ -88,10 +209,159 @@ func main() {
 	defer cancel()
 
 	// when shutting down, we first gracefully shutting down the main http
-	// server, waiting for it to finish processing all the running requests.
-	httpSrv.Shutdown(ctx)
+	// and grpc servers, waiting for them to finish processing all the running
+	// requests.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		httpSrv.Shutdown(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		grpcSrv.Shutdown(ctx)
+	}()
+	wg.Wait()
 	// here we would also shut down the metrics server so we would set the
 	// shutdown timeout to something higher than the prometheus scrape interval.
 	// we would use a counter to check if a scrape happened to shutdown
 	// asap though
 }
+
+// parseLimits parses the rate limits of the routes.
+func parseLimits(specs []string) (map[string]middleware.Limit, error) {
+	limits := make(map[string]middleware.Limit, len(specs))
+	for _, spec := range specs {
+		route, limit, err := middleware.ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := limits[route]; ok {
+			return nil, fmt.Errorf("duplicate rate limit for route %s", route)
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// parseKeys parses the API keys of the clients.
+func parseKeys(specs []string) (auth.Keys, error) {
+	keys := make(auth.Keys, len(specs))
+	for _, spec := range specs {
+		key, p, err := auth.ParseKey(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("duplicate API key of %s", p.Subject)
+		}
+		keys[key] = p
+	}
+	return keys, nil
+}
+
+// pruneRateLimits deletes the rate limits of clients that have not sent a
+// request for a day, until ctx is done.
+func pruneRateLimits(ctx context.Context, s *ratelimit.RateLimitStore, logger zerolog.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Prune(ctx, 24*time.Hour)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to prune rate limits")
+				continue
+			}
+			logger.Debug().Int64("pruned", n).Msg("pruned rate limits")
+		}
+	}
+}
+
+// expireTransfers marks proposed transfers that were not decided in time as
+// expired.
+func expireTransfers(ctx context.Context, s *cache.TransferStore, logger zerolog.Logger) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Expire(ctx)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to expire transfers")
+				continue
+			}
+			logger.Debug().Int64("expired", n).Msg("expired transfers")
+		}
+	}
+}
+
+// expireContracts releases the players of contracts that ended, until ctx is
+// done. Contracts that leave a roster without a valid lineup are retried.
+func expireContracts(ctx context.Context, s *cache.ContractStore, logger zerolog.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			contracts, err := s.Expire(ctx)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to expire contracts")
+			}
+			for _, c := range contracts {
+				if c.Status == store.ContractOverdue {
+					logger.Warn().Uint64("contract_id", c.ContractID).Uint64("roster_id", c.RosterID).Msg("no replacement for player of expired contract")
+				}
+			}
+			logger.Debug().Int("expired", len(contracts)).Msg("expired contracts")
+		}
+	}
+}
+
+// importPlayers imports the players of the given file in a single transaction
+// and writes the result to stdout. Fails if any of the rows was rejected.
+func importPlayers(ps *player.PlayerStore, file, format string, dryRun bool) error {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+	var mediaType string
+	switch format {
+	case "csv":
+		mediaType = bulk.CSV
+	case "ndjson", "jsonl":
+		mediaType = bulk.NDJSON
+	default:
+		return fmt.Errorf("unknown format of %s, use --format", file)
+	}
+
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	result, err := bulk.ImportPlayers(context.Background(), ps, in, mediaType, dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d errors in import", len(result.Errors))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package events distributes notifications about roster changes to
+// subscribers within the service.
+package events
+
+import "sync"
+
+// Event notifies about a change of a roster. An event with roster id 0
+// notifies about a change of all rosters, e.g. after notifications might have
+// been missed.
+type Event struct {
+	RosterID uint64
+}
+
+// Publisher publishes roster changes.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Broker distributes events to subscribers of a roster. Publishing never
+// blocks; if a subscriber has not consumed its pending event, the pending and
+// the new event are coalesced.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[uint64]map[chan Event]struct{}
+	all  map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[uint64]map[chan Event]struct{}),
+		all:  make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events of the roster with the
+// given id, or of all rosters if rosterID is 0. The returned function cancels
+// the subscription and must be called to release its resources.
+func (b *Broker) Subscribe(rosterID uint64) (<-chan Event, func()) {
+	ch := make(chan Event, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rosterID == 0 {
+		b.all[ch] = struct{}{}
+	} else {
+		if b.subs[rosterID] == nil {
+			b.subs[rosterID] = make(map[chan Event]struct{})
+		}
+		b.subs[rosterID][ch] = struct{}{}
+	}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.all, ch)
+			delete(b.subs[rosterID], ch)
+			if len(b.subs[rosterID]) == 0 {
+				delete(b.subs, rosterID)
+			}
+		})
+	}
+}
+
+// Publish sends the event to all subscribers of its roster and to the
+// subscribers of all rosters.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.all {
+		send(ch, e)
+	}
+	if e.RosterID == 0 {
+		for _, subs := range b.subs {
+			for ch := range subs {
+				send(ch, e)
+			}
+		}
+		return
+	}
+	for ch := range b.subs[e.RosterID] {
+		send(ch, e)
+	}
+}
+
+// send delivers e to ch. If an event is pending, it is replaced by an event
+// covering both the pending and the new event.
+func send(ch chan Event, e Event) {
+	select {
+	case ch <- e:
+		return
+	default:
+	}
+	var pending Event
+	select {
+	case pending = <-ch:
+	default:
+	}
+	if pending.RosterID != e.RosterID {
+		e = Event{}
+	}
+	select {
+	case ch <- e:
+	default:
+	}
+}
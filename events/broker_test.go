@@ -0,0 +1,49 @@
+package events
+
+import "testing"
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	foo, cancelFoo := b.Subscribe(1)
+	defer cancelFoo()
+	all, cancelAll := b.Subscribe(0)
+	defer cancelAll()
+
+	// events of other rosters are not received
+	b.Publish(Event{RosterID: 2})
+	select {
+	case e := <-foo:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+	if want, got := (Event{RosterID: 2}), <-all; want != got {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	// pending events of the same roster are coalesced
+	b.Publish(Event{RosterID: 1})
+	b.Publish(Event{RosterID: 1})
+	if want, got := (Event{RosterID: 1}), <-foo; want != got {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	select {
+	case e := <-foo:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	// pending events of different rosters are coalesced to a change of all rosters
+	b.Publish(Event{RosterID: 2})
+	if want, got := (Event{}), <-all; want != got {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	// canceled subscriptions do not receive events
+	cancelFoo()
+	b.Publish(Event{})
+	select {
+	case e := <-foo:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+)
+
+// Channel is the notification channel the datastore notifies roster changes
+// on, see initdb/03_notify.sql. The payload of a notification is the id of the
+// changed roster.
+const Channel = "roster_changes"
+
+// Listen publishes the roster changes notified by the datastore until the
+// context is canceled. Since notifications are sent on commit by the datastore,
+// changes made by other instances of the service are published as well.
+// Reconnects are published as a change of all rosters since notifications may
+// have been missed.
+func Listen(ctx context.Context, dsn string, b Publisher, logger zerolog.Logger) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn().Err(err).Msg("roster change listener")
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				b.Publish(Event{})
+				continue
+			}
+			rosterID, err := strconv.ParseUint(n.Extra, 10, 64)
+			if err != nil {
+				logger.Warn().Err(err).Str("payload", n.Extra).Msg("invalid roster change notification")
+				continue
+			}
+			b.Publish(Event{RosterID: rosterID})
+		case <-time.After(90 * time.Second):
+			// detect broken connections if there is no traffic
+			go func() {
+				if err := listener.Ping(); err != nil {
+					logger.Warn().Err(err).Msg("roster change listener ping")
+				}
+			}()
+		}
+	}
+}
@@ -1,10 +1,10 @@
 CREATE TABLE rosters (
-    id   BIGSERIAL PRIMARY KEY,
+    id   BIGINT PRIMARY KEY, -- generated by the service
     name varchar(32) UNIQUE NOT NULL
 );
 
 CREATE TABLE players (
-    id         BIGSERIAL PRIMARY KEY,
+    id         BIGINT PRIMARY KEY, -- generated by the service
     roster_id  BIGINT REFERENCES rosters(id) NOT NULL,
     first_name varchar(32) NOT NULL,
     last_name  varchar(32) NOT NULL,
@@ -0,0 +1,19 @@
+-- Notifies listeners about changes of rosters. Notifications are delivered on
+-- commit, identical notifications of a transaction are sent only once.
+CREATE OR REPLACE FUNCTION notify_roster_changes() RETURNS TRIGGER AS $nt$
+BEGIN
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        PERFORM pg_notify('roster_changes', NEW.roster_id::text);
+    END IF;
+
+    IF TG_OP = 'DELETE' OR (TG_OP = 'UPDATE' AND OLD.roster_id <> NEW.roster_id) THEN
+        PERFORM pg_notify('roster_changes', OLD.roster_id::text);
+    END IF;
+
+    RETURN NULL;
+END;
+$nt$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER notify_roster_changes
+AFTER INSERT OR UPDATE OR DELETE ON players
+FOR EACH ROW EXECUTE PROCEDURE notify_roster_changes();
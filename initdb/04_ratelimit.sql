@@ -0,0 +1,8 @@
+-- Token buckets of the rate limiter, keyed by client and route.
+CREATE TABLE rate_limits (
+    key        text PRIMARY KEY,
+    tokens     double precision NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX rate_limits_updated_at ON rate_limits(updated_at);
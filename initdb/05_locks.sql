@@ -0,0 +1,25 @@
+-- Lock windows of rosters. Players of a locked roster must not be swapped or
+-- transferred. Locks without end last until the roster is unlocked.
+CREATE TABLE roster_locks (
+    id        BIGINT PRIMARY KEY,
+    roster_id BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    starts_at timestamptz NOT NULL DEFAULT now(),
+    ends_at   timestamptz,
+    reason    varchar(256) NOT NULL DEFAULT '',
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX roster_locks_roster_id_idx ON roster_locks (roster_id, starts_at);
+
+-- Audit of changes of locked rosters permitted by admins. Overrides are kept
+-- when their lock is deleted.
+CREATE TABLE lock_overrides (
+    id         BIGINT PRIMARY KEY,
+    lock_id    BIGINT NOT NULL,
+    roster_id  BIGINT NOT NULL,
+    subject    text NOT NULL,
+    reason     text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX lock_overrides_roster_id_idx ON lock_overrides (roster_id, created_at);
@@ -0,0 +1,49 @@
+-- Matches of a roster against an opponent roster.
+CREATE TABLE matches (
+    id              BIGINT PRIMARY KEY,
+    roster_id       BIGINT REFERENCES rosters(id) NOT NULL,
+    opponent_id     BIGINT REFERENCES rosters(id) NOT NULL,
+    scheduled_at    timestamptz NOT NULL,
+    lineup_deadline timestamptz NOT NULL,
+    status          varchar(32) NOT NULL DEFAULT 'scheduled',
+    CHECK (roster_id <> opponent_id),
+    CHECK (lineup_deadline <= scheduled_at)
+);
+
+CREATE INDEX matches_roster_id_idx ON matches (roster_id, scheduled_at);
+CREATE INDEX matches_opponent_id_idx ON matches (opponent_id, scheduled_at);
+
+-- Lineups are snapshots of the active players of the rosters of a match. The
+-- players are copied so that the lineup is kept when players change or leave.
+CREATE TABLE lineups (
+    match_id     BIGINT REFERENCES matches(id) NOT NULL,
+    roster_id    BIGINT REFERENCES rosters(id) NOT NULL,
+    submitted_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (match_id, roster_id)
+);
+
+CREATE TABLE lineup_players (
+    match_id   BIGINT NOT NULL,
+    roster_id  BIGINT NOT NULL,
+    player_id  BIGINT NOT NULL,
+    first_name varchar(32) NOT NULL,
+    last_name  varchar(32) NOT NULL,
+    alias      varchar(32) NOT NULL,
+    PRIMARY KEY (match_id, roster_id, player_id),
+    FOREIGN KEY (match_id, roster_id) REFERENCES lineups (match_id, roster_id)
+);
+
+-- Submitted lineups are final, they serve as proof of who played.
+CREATE OR REPLACE FUNCTION reject_lineup_changes() RETURNS TRIGGER AS $li$
+BEGIN
+    RAISE EXCEPTION 'lineup of roster id=% for match id=% is final',OLD.roster_id,OLD.match_id;
+END;
+$li$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER lineups_final
+BEFORE UPDATE OR DELETE ON lineups
+FOR EACH ROW EXECUTE PROCEDURE reject_lineup_changes();
+
+CREATE TRIGGER lineup_players_final
+BEFORE UPDATE OR DELETE ON lineup_players
+FOR EACH ROW EXECUTE PROCEDURE reject_lineup_changes();
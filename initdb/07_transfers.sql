@@ -0,0 +1,52 @@
+-- Managers of rosters, identified by the subject of their API key.
+CREATE TABLE roster_managers (
+    roster_id BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    subject   text NOT NULL,
+    PRIMARY KEY (roster_id, subject)
+);
+
+CREATE INDEX roster_managers_subject_idx ON roster_managers (subject);
+
+-- Requests to transfer players between rosters. A player has at most one
+-- proposed transfer.
+CREATE TABLE transfer_requests (
+    id             BIGINT PRIMARY KEY,
+    player_id      BIGINT REFERENCES players(id) ON DELETE CASCADE NOT NULL,
+    from_roster_id BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    to_roster_id   BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    status         varchar(32) NOT NULL DEFAULT 'proposed',
+    requested_by   text NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    expires_at     timestamptz NOT NULL,
+    CHECK (from_roster_id <> to_roster_id),
+    CHECK (expires_at > created_at)
+);
+
+CREATE UNIQUE INDEX transfer_requests_proposed_idx ON transfer_requests (player_id) WHERE status = 'proposed';
+CREATE INDEX transfer_requests_from_roster_id_idx ON transfer_requests (from_roster_id, created_at);
+CREATE INDEX transfer_requests_to_roster_id_idx ON transfer_requests (to_roster_id, created_at);
+CREATE INDEX transfer_requests_expires_at_idx ON transfer_requests (expires_at) WHERE status = 'proposed';
+
+-- History of the status changes of transfers.
+CREATE TABLE transfer_events (
+    transfer_id BIGINT REFERENCES transfer_requests(id) ON DELETE CASCADE NOT NULL,
+    status      varchar(32) NOT NULL,
+    subject     text NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX transfer_events_transfer_id_idx ON transfer_events (transfer_id, created_at);
+
+-- Notifies the listeners of both rosters about changes of transfers, see
+-- 03_notify.sql.
+CREATE OR REPLACE FUNCTION notify_transfer_changes() RETURNS TRIGGER AS $nt$
+BEGIN
+    PERFORM pg_notify('roster_changes', NEW.from_roster_id::text);
+    PERFORM pg_notify('roster_changes', NEW.to_roster_id::text);
+    RETURN NULL;
+END;
+$nt$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER notify_transfer_changes
+AFTER INSERT OR UPDATE ON transfer_requests
+FOR EACH ROW EXECUTE PROCEDURE notify_transfer_changes();
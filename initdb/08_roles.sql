@@ -0,0 +1,45 @@
+-- Roles of players, e.g. igl, support, entry or awper. A player can have
+-- several roles.
+CREATE TABLE player_roles (
+    player_id BIGINT REFERENCES players(id) ON DELETE CASCADE NOT NULL,
+    role      varchar(32) NOT NULL,
+    PRIMARY KEY (player_id, role)
+);
+
+-- Role requirements of rosters. The active players of a roster must cover
+-- each required role with at least count players. A player with several roles
+-- counts for each of them.
+CREATE TABLE roster_roles (
+    roster_id BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    role      varchar(32) NOT NULL,
+    count     INT NOT NULL CHECK (count BETWEEN 1 AND 5),
+    PRIMARY KEY (roster_id, role)
+);
+
+-- Notifies the listeners of the roster about changes of roles, see
+-- 03_notify.sql.
+CREATE OR REPLACE FUNCTION notify_role_changes() RETURNS TRIGGER AS $nt$
+DECLARE
+    changed BIGINT;
+BEGIN
+    IF TG_TABLE_NAME = 'roster_roles' THEN
+        changed := COALESCE(NEW.roster_id, OLD.roster_id);
+    ELSE
+        SELECT roster_id INTO changed FROM players WHERE id = COALESCE(NEW.player_id, OLD.player_id);
+    END IF;
+
+    IF changed IS NOT NULL THEN
+        PERFORM pg_notify('roster_changes', changed::text);
+    END IF;
+
+    RETURN NULL;
+END;
+$nt$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER notify_player_role_changes
+AFTER INSERT OR DELETE ON player_roles
+FOR EACH ROW EXECUTE PROCEDURE notify_role_changes();
+
+CREATE TRIGGER notify_roster_role_changes
+AFTER INSERT OR DELETE ON roster_roles
+FOR EACH ROW EXECUTE PROCEDURE notify_role_changes();
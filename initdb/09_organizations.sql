@@ -0,0 +1,25 @@
+-- Organizations own rosters across games and divisions.
+CREATE TABLE organizations (
+    id   BIGINT PRIMARY KEY, -- generated by the service
+    name varchar(64) UNIQUE NOT NULL
+);
+
+-- Rosters without organization are standalone. The organization of a roster
+-- does not change.
+ALTER TABLE rosters ADD COLUMN org_id BIGINT REFERENCES organizations(id);
+
+-- Roster names are unique within their organization and among standalone
+-- rosters.
+ALTER TABLE rosters DROP CONSTRAINT rosters_name_key;
+CREATE UNIQUE INDEX rosters_org_id_name_idx ON rosters (org_id, name) WHERE org_id IS NOT NULL;
+CREATE UNIQUE INDEX rosters_name_idx ON rosters (name) WHERE org_id IS NULL;
+
+-- Managers of organizations manage all rosters of the organization, see
+-- 07_transfers.sql.
+CREATE TABLE org_managers (
+    org_id  BIGINT REFERENCES organizations(id) ON DELETE CASCADE NOT NULL,
+    subject text NOT NULL,
+    PRIMARY KEY (org_id, subject)
+);
+
+CREATE INDEX org_managers_subject_idx ON org_managers (subject);
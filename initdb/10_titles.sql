@@ -0,0 +1,87 @@
+-- Game titles define the default rules of their rosters: the number of
+-- active players, the maximum number of benched players and role
+-- requirements. Titles without max_benched do not limit the bench.
+CREATE TABLE titles (
+    id          BIGINT PRIMARY KEY, -- generated by the service
+    name        varchar(64) UNIQUE NOT NULL,
+    active_size INT NOT NULL CHECK (active_size BETWEEN 1 AND 10),
+    max_benched INT CHECK (max_benched >= 0)
+);
+
+-- Default role requirements of the rosters of a title, see 08_roles.sql.
+CREATE TABLE title_roles (
+    title_id BIGINT REFERENCES titles(id) ON DELETE CASCADE NOT NULL,
+    role     varchar(32) NOT NULL,
+    count    INT NOT NULL CHECK (count BETWEEN 1 AND 10),
+    PRIMARY KEY (title_id, role)
+);
+
+-- Rosters without title have 5 active players and an unlimited bench. The
+-- title of a roster does not change.
+ALTER TABLE rosters ADD COLUMN title_id BIGINT REFERENCES titles(id);
+
+-- Role requirements may be as large as the active players of a title.
+ALTER TABLE roster_roles DROP CONSTRAINT roster_roles_count_check;
+ALTER TABLE roster_roles ADD CHECK (count BETWEEN 1 AND 10);
+
+-- The role requirements in effect for each roster. Requirements of the
+-- roster replace the defaults of its title.
+CREATE VIEW roster_requirements AS
+    SELECT roster_id, role, count
+    FROM roster_roles
+    UNION ALL
+    SELECT rosters.id, title_roles.role, title_roles.count
+    FROM rosters
+    INNER JOIN title_roles ON title_roles.title_id = rosters.title_id
+    WHERE NOT EXISTS (SELECT 1 FROM roster_roles WHERE roster_roles.roster_id = rosters.id);
+
+-- Checks the players of a roster against the rules of its title, see
+-- 02_trigger.sql.
+CREATE OR REPLACE FUNCTION check_roster_rules(roster BIGINT, op text) RETURNS void AS $cr$
+DECLARE
+    size    integer;
+    bench   integer;
+    active  integer;
+    benched integer;
+BEGIN
+    SELECT INTO size, bench COALESCE(titles.active_size, 5), titles.max_benched
+    FROM rosters
+    LEFT JOIN titles ON titles.id = rosters.title_id
+    WHERE rosters.id = roster;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    SELECT INTO active, benched
+        count(id) FILTER (WHERE status = 'active'),
+        count(id) FILTER (WHERE status = 'benched')
+    FROM players WHERE roster_id = roster;
+    IF active <> size THEN
+        RAISE EXCEPTION 'During % of players: roster id=% must have exactly % active players, not %',op,roster,size,active;
+    END IF;
+    IF benched > bench THEN
+        RAISE EXCEPTION 'During % of players: roster id=% must have at most % benched players, not %',op,roster,bench,benched;
+    END IF;
+END;
+$cr$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION active_players_per_roster() RETURNS TRIGGER AS $pl$
+BEGIN
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        PERFORM check_roster_rules(NEW.roster_id, TG_OP);
+    END IF;
+
+    IF TG_OP = 'UPDATE' OR TG_OP = 'DELETE' THEN
+        PERFORM check_roster_rules(OLD.roster_id, TG_OP);
+    END IF;
+
+    RETURN NULL;
+END;
+$pl$ LANGUAGE 'plpgsql';
+
+CREATE OR REPLACE FUNCTION roster_constrain_active_players() RETURNS trigger AS $ro$
+BEGIN
+    PERFORM check_roster_rules(NEW.id, 'INSERT');
+    RETURN NULL;
+END;
+$ro$ LANGUAGE 'plpgsql';
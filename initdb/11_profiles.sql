@@ -0,0 +1,16 @@
+-- Profiles of players. Accounts are in-game account ids by platform, socials
+-- are handles by social network and custom holds arbitrary fields of the
+-- organizers. Players without profile row have an empty profile.
+CREATE TABLE player_profiles (
+    player_id     BIGINT PRIMARY KEY REFERENCES players(id) ON DELETE CASCADE,
+    nationality   char(2) CHECK (nationality ~ '^[A-Z]{2}$'), -- ISO 3166-1 alpha-2
+    date_of_birth date,
+    accounts      jsonb NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(accounts) = 'object'),
+    socials       jsonb NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(socials) = 'object'),
+    custom        jsonb NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(custom) = 'object')
+);
+
+-- Player listings filter by nationality, platform and custom fields.
+CREATE INDEX player_profiles_nationality_idx ON player_profiles (nationality);
+CREATE INDEX player_profiles_accounts_idx ON player_profiles USING GIN (accounts);
+CREATE INDEX player_profiles_custom_idx ON player_profiles USING GIN (custom);
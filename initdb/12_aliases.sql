@@ -0,0 +1,47 @@
+-- Aliases are unique regardless of case among the players of the rosters of a
+-- game title. Rosters without title count as one title.
+CREATE INDEX players_alias_idx ON players (lower(alias));
+
+CREATE OR REPLACE FUNCTION check_alias_unique() RETURNS TRIGGER AS $ca$
+DECLARE
+    title BIGINT;
+    taken BIGINT;
+BEGIN
+    IF TG_OP = 'UPDATE' AND lower(NEW.alias) = lower(OLD.alias) AND NEW.roster_id = OLD.roster_id THEN
+        RETURN NEW;
+    END IF;
+
+    -- concurrent writes of the same alias are checked one after another
+    PERFORM pg_advisory_xact_lock(hashtext('alias:' || lower(NEW.alias)));
+
+    SELECT COALESCE(title_id, 0) INTO title FROM rosters WHERE id = NEW.roster_id;
+    SELECT players.id INTO taken
+    FROM players
+    JOIN rosters ON rosters.id = players.roster_id
+    WHERE lower(players.alias) = lower(NEW.alias)
+      AND COALESCE(rosters.title_id, 0) = title
+      AND players.id <> NEW.id
+    LIMIT 1;
+
+    IF taken IS NOT NULL THEN
+        RAISE EXCEPTION 'alias % is taken by player %', NEW.alias, taken
+            USING ERRCODE = 'unique_violation';
+    END IF;
+    RETURN NEW;
+END;
+$ca$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER check_alias_unique
+BEFORE INSERT OR UPDATE OF alias, roster_id ON players
+FOR EACH ROW EXECUTE PROCEDURE check_alias_unique();
+
+-- Former aliases of players, written by the service whenever a player changes
+-- its alias. Former aliases are not reserved, they only serve lookups.
+CREATE TABLE player_aliases (
+    player_id  BIGINT REFERENCES players(id) ON DELETE CASCADE NOT NULL,
+    alias      varchar(32) NOT NULL,
+    renamed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX player_aliases_alias_idx ON player_aliases (lower(alias));
+CREATE INDEX player_aliases_player_idx ON player_aliases (player_id);
@@ -0,0 +1,7 @@
+-- Players are searched by trigram similarity and substrings of their names and
+-- aliases, see store/search.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX players_first_name_trgm_idx ON players USING GIN (lower(first_name) gin_trgm_ops);
+CREATE INDEX players_last_name_trgm_idx ON players USING GIN (lower(last_name) gin_trgm_ops);
+CREATE INDEX players_alias_trgm_idx ON players USING GIN (lower(alias) gin_trgm_ops);
@@ -0,0 +1,36 @@
+-- Contracts bind players to their roster from starts_on to ends_on, both
+-- inclusive. A player has at most one current contract, which is signed or
+-- overdue. Ended contracts are released by the service, which releases their
+-- player from the roster. Active players are only released if a benched
+-- player of the roster takes their place, otherwise the contract is overdue
+-- and the roster non-compliant until a replacement is found.
+CREATE TABLE contracts (
+    id          BIGINT PRIMARY KEY, -- generated by the service
+    player_id   BIGINT REFERENCES players(id) ON DELETE CASCADE NOT NULL,
+    roster_id   BIGINT REFERENCES rosters(id) ON DELETE CASCADE NOT NULL,
+    starts_on   date NOT NULL,
+    ends_on     date NOT NULL,
+    terms       jsonb NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(terms) = 'object'),
+    status      varchar(32) NOT NULL DEFAULT 'signed',
+    replaced_by BIGINT REFERENCES players(id) ON DELETE SET NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    released_at timestamptz,
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE UNIQUE INDEX contracts_current_idx ON contracts (player_id) WHERE status IN ('signed', 'overdue');
+CREATE INDEX contracts_roster_id_idx ON contracts (roster_id, ends_on) WHERE status IN ('signed', 'overdue');
+CREATE INDEX contracts_ends_on_idx ON contracts (ends_on) WHERE status = 'signed';
+
+-- Notifies the listeners of the roster about released and overdue contracts,
+-- see 03_notify.sql.
+CREATE OR REPLACE FUNCTION notify_contract_changes() RETURNS TRIGGER AS $nt$
+BEGIN
+    PERFORM pg_notify('roster_changes', NEW.roster_id::text);
+    RETURN NULL;
+END;
+$nt$ LANGUAGE 'plpgsql';
+
+CREATE TRIGGER notify_contract_changes
+AFTER UPDATE OF status ON contracts
+FOR EACH ROW EXECUTE PROCEDURE notify_contract_changes();
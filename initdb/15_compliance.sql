@@ -0,0 +1,72 @@
+-- Rosters are strict or lenient about their rules. Writes leaving a strict
+-- roster in violation of its rules are rejected, violations of lenient rosters
+-- are reported by roster_violations instead, e.g. when a player departs in
+-- the middle of a season.
+ALTER TABLE rosters ADD COLUMN compliance varchar(16) NOT NULL DEFAULT 'strict'
+    CHECK (compliance IN ('strict', 'lenient'));
+
+-- Checks the players of a strict roster against the rules of its title, see
+-- 10_titles.sql. The roster is share locked until commit, so that it can not
+-- become strict before the checked players are committed.
+CREATE OR REPLACE FUNCTION check_roster_rules(roster BIGINT, op text) RETURNS void AS $cr$
+DECLARE
+    size    integer;
+    bench   integer;
+    mode    varchar;
+    active  integer;
+    benched integer;
+BEGIN
+    SELECT INTO size, bench, mode COALESCE(titles.active_size, 5), titles.max_benched, rosters.compliance
+    FROM rosters
+    LEFT JOIN titles ON titles.id = rosters.title_id
+    WHERE rosters.id = roster
+    FOR SHARE OF rosters;
+    IF NOT FOUND OR mode = 'lenient' THEN
+        RETURN;
+    END IF;
+
+    SELECT INTO active, benched
+        count(id) FILTER (WHERE status = 'active'),
+        count(id) FILTER (WHERE status = 'benched')
+    FROM players WHERE roster_id = roster;
+    IF active <> size THEN
+        RAISE EXCEPTION 'During % of players: roster id=% must have exactly % active players, not %',op,roster,size,active;
+    END IF;
+    IF benched > bench THEN
+        RAISE EXCEPTION 'During % of players: roster id=% must have at most % benched players, not %',op,roster,bench,benched;
+    END IF;
+END;
+$cr$ LANGUAGE 'plpgsql';
+
+-- The violations of the rules of each roster: the number of active and
+-- benched players, the role requirements and overdue contracts.
+CREATE VIEW roster_violations AS
+    SELECT rosters.id AS roster_id, v.rule, ''::text AS role, 0::BIGINT AS contract_id, 0::BIGINT AS player_id, v.detail
+    FROM rosters
+    LEFT JOIN titles ON titles.id = rosters.title_id
+    CROSS JOIN LATERAL (
+        SELECT
+            count(id) FILTER (WHERE status = 'active') AS active,
+            count(id) FILTER (WHERE status = 'benched') AS benched
+        FROM players
+        WHERE players.roster_id = rosters.id) AS n
+    CROSS JOIN LATERAL (VALUES
+        ('active_size'::text, n.active <> COALESCE(titles.active_size, 5),
+            format('must have exactly %s active players, not %s', COALESCE(titles.active_size, 5), n.active)),
+        ('max_benched'::text, n.benched > titles.max_benched,
+            format('must have at most %s benched players, not %s', titles.max_benched, n.benched))
+    ) AS v(rule, violated, detail)
+    WHERE v.violated
+    UNION ALL
+    SELECT r.roster_id, 'role', r.role, 0, 0,
+        format('requires %s active %s players, not %s', r.count, r.role, count(pr.player_id))
+    FROM roster_requirements AS r
+    LEFT JOIN players AS p ON p.roster_id = r.roster_id AND p.status = 'active'
+    LEFT JOIN player_roles AS pr ON pr.player_id = p.id AND pr.role = r.role
+    GROUP BY r.roster_id, r.role, r.count
+    HAVING count(pr.player_id) < r.count
+    UNION ALL
+    SELECT roster_id, 'contract', '', id, player_id,
+        format('contract %s of player %s ended on %s', id, player_id, ends_on)
+    FROM contracts
+    WHERE status = 'overdue';
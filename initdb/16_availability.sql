@@ -0,0 +1,16 @@
+-- Availability of players. Players without availability are available,
+-- injured players are expected back on until, if known, and unavailable
+-- players on until.
+CREATE TABLE player_availability (
+    player_id  BIGINT PRIMARY KEY REFERENCES players(id) ON DELETE CASCADE,
+    status     varchar(32) NOT NULL CHECK (status IN ('injured', 'unavailable')),
+    until      date CHECK (status = 'injured' OR until IS NOT NULL),
+    note       varchar(256) NOT NULL DEFAULT '',
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- The players that are not available today.
+CREATE VIEW unavailable_players AS
+    SELECT player_id, status, until, note
+    FROM player_availability
+    WHERE until IS NULL OR until > current_date;
@@ -31,6 +31,11 @@ func NewRecoverHandler() Middleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					// aborted responses are left to the http server,
+					// which closes the connection
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
 					hlog.FromRequest(r).Error().Interface("err", err).Msg("PANIC")
 					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// APIKeyHeader is the request header holding the API key of a client.
+const APIKeyHeader = "X-Api-Key"
+
+// DefaultRoute is the route of the limit applying to all routes without a
+// limit of their own.
+const DefaultRoute = "*"
+
+// Limit is the rate of a token bucket. Buckets hold up to Burst tokens and are
+// refilled at Rate tokens per second. Each request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit of the form <route>=<n>/<unit>[:<burst>], e.g.
+// "PATCH /players/change=10/m:5". Units are s, m and h. The burst defaults to
+// the number of requests per unit.
+func ParseLimit(s string) (string, Limit, error) {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return "", Limit{}, fmt.Errorf("invalid rate limit %q: missing route", s)
+	}
+	route, spec := strings.TrimSpace(s[:i]), s[i+1:]
+
+	var burst string
+	if j := strings.Index(spec, ":"); j >= 0 {
+		spec, burst = spec[:j], spec[j+1:]
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) != 2 {
+		return "", Limit{}, fmt.Errorf("invalid rate limit %q: rate must be <n>/<unit>", s)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return "", Limit{}, fmt.Errorf("invalid rate limit %q: number of requests must be positive", s)
+	}
+	units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	unit, ok := units[parts[1]]
+	if !ok {
+		return "", Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", s)
+	}
+
+	l := Limit{Rate: float64(n) / unit.Seconds(), Burst: n}
+	if burst != "" {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+			return "", Limit{}, fmt.Errorf("invalid rate limit %q: burst must be positive", s)
+		}
+	}
+	return route, l, nil
+}
+
+// RateLimitStore holds the token buckets of clients. Implementations must take
+// tokens atomically so that a store can be shared by several instances.
+type RateLimitStore interface {
+	// Take takes a token from the bucket with the given key after refilling
+	// it at rate tokens per second up to burst tokens. Returns the tokens
+	// left in the bucket and whether a token was taken. If the bucket is
+	// empty, no token is taken.
+	Take(ctx context.Context, key string, rate float64, burst int) (float64, bool, error)
+}
+
+// RateLimiter limits the requests of clients per route with token buckets.
+type RateLimiter struct {
+	store  RateLimitStore
+	limits map[string]Limit
+}
+
+// NewRateLimiter returns a rate limiter with the given limits indexed by route.
+// The limit of DefaultRoute applies to routes without a limit of their own;
+// other routes are not limited.
+func NewRateLimiter(store RateLimitStore, limits map[string]Limit) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		limits: limits,
+	}
+}
+
+// Middleware returns middleware limiting the requests of clients per route.
+// route returns the route of a request, denied writes the response to
+// requests exceeding the limit. Responses carry the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers; denied responses carry the
+// Retry-After header as well. Requests are not limited if the store fails.
+func (l *RateLimiter) Middleware(route func(*http.Request) string, denied http.Handler) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := route(r)
+			limit, ok := l.limits[name]
+			if !ok {
+				if limit, ok = l.limits[DefaultRoute]; !ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+				name = DefaultRoute
+			}
+
+			key := ClientKey(r) + " " + name
+			tokens, ok, err := l.store.Take(r.Context(), key, limit.Rate, limit.Burst)
+			if err != nil {
+				hlog.FromRequest(r).Error().Err(err).Msg("failed to take rate limit token")
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+			w.Header().Set("RateLimit-Reset", seconds((float64(limit.Burst)-tokens)/limit.Rate))
+			if !ok {
+				w.Header().Set("Retry-After", seconds((1-tokens)/limit.Rate))
+				denied.ServeHTTP(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats a duration in seconds rounded up.
+func seconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(s)))
+}
+
+type clientKey struct{}
+
+// WithClient returns a context carrying the validated API key of the client
+// of a request.
+func WithClient(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKey{}, key)
+}
+
+// ClientKey identifies the client of a request by its validated API key, see
+// WithClient, or, for requests without validated API key, by its remote IP.
+// Unvalidated API keys are ignored so that clients cannot get fresh buckets by
+// making up keys. API keys are hashed to not keep them in rate limit stores.
+func ClientKey(r *http.Request) string {
+	if key, _ := r.Context().Value(clientKey{}).(string); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// bucket is a token bucket of the memory store.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   int
+}
+
+// MemoryRateLimitStore keeps token buckets in memory. It is limited to a
+// single instance of the service.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+// refill returns the tokens of the bucket at the given time.
+func refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(b.burst), b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+}
+
+// sweepInterval is the interval in which full buckets are removed from the
+// memory store.
+const sweepInterval = time.Minute
+
+// Take takes a token from the bucket with the given key.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+	b.tokens, b.updated = refill(b, now), now
+	ok = b.tokens >= 1
+	if ok {
+		b.tokens--
+	}
+
+	// full buckets are equal to new ones and do not need to be kept
+	if now.Sub(s.swept) > sweepInterval {
+		for k, b := range s.buckets {
+			if refill(b, now) >= float64(b.burst) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+	return b.tokens, ok, nil
+}
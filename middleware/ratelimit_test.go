@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var parseTests = map[string]struct {
+	r string // expected route
+	l Limit  // expected limit
+	e bool   // expect error
+}{
+	"PATCH /players/change=10/m:5": {r: "PATCH /players/change", l: Limit{Rate: 10.0 / 60, Burst: 5}},
+	"*=50/s":                       {r: "*", l: Limit{Rate: 50, Burst: 50}},
+	"GET /roster/{id}=3600/h":      {r: "GET /roster/{id}", l: Limit{Rate: 1, Burst: 3600}},
+	"*":                            {e: true},
+	"*=50":                         {e: true},
+	"*=0/s":                        {e: true},
+	"*=5/d":                        {e: true},
+	"*=5/s:0":                      {e: true},
+}
+
+func TestParseLimit(t *testing.T) {
+	for s, tt := range parseTests {
+		route, limit, err := ParseLimit(s)
+		if tt.e {
+			if err == nil {
+				t.Errorf("%s: expected error", s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", s, err)
+			continue
+		}
+		if tt.r != route || tt.l != limit {
+			t.Errorf("%s: want %s %+v got %s %+v", s, tt.r, tt.l, route, limit)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+
+	take := func(want float64, wantOK bool) {
+		t.Helper()
+		got, ok, err := s.Take(context.Background(), "foo", 1, 2)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want != got || wantOK != ok {
+			t.Errorf("want %v %v got %v %v", want, wantOK, got, ok)
+		}
+	}
+	take(1, true)
+	take(0, true)
+	take(0, false)
+	// buckets are refilled at the given rate
+	now = now.Add(1500 * time.Millisecond)
+	take(0.5, true)
+	// and never exceed the burst
+	now = now.Add(time.Hour)
+	take(1, true)
+
+	// full buckets are swept
+	now = now.Add(time.Hour)
+	if _, _, err := s.Take(context.Background(), "bar", 1, 2); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := s.buckets["foo"]; ok {
+		t.Errorf("expected full bucket to be swept")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(NewMemoryRateLimitStore(), map[string]Limit{
+		"/limited": {Rate: 0.5, Burst: 1},
+	})
+	route := func(r *http.Request) string { return r.URL.Path }
+	denied := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := Use(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), l.Middleware(route, denied))
+
+	tests := []struct {
+		p string            // url path
+		k string            // api key
+		v bool              // whether the api key is validated
+		s int               // expected status code
+		h map[string]string // expected headers
+	}{
+		{p: "/limited", s: http.StatusOK, h: map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "2"}},
+		{p: "/limited", s: http.StatusTooManyRequests, h: map[string]string{"RateLimit-Remaining": "0", "Retry-After": "2"}},
+		// clients are identified by validated api keys
+		{p: "/limited", k: "foo", v: true, s: http.StatusOK},
+		// and by remote ip otherwise
+		{p: "/limited", k: "bar", s: http.StatusTooManyRequests},
+		// routes without limit are not limited
+		{p: "/unlimited", s: http.StatusOK, h: map[string]string{"RateLimit-Limit": ""}},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.p, nil)
+		if tt.k != "" {
+			r.Header.Set(APIKeyHeader, tt.k)
+		}
+		if tt.v {
+			r = r.WithContext(WithClient(r.Context(), tt.k))
+		}
+		h.ServeHTTP(w, r)
+		if want, got := tt.s, w.Code; want != got {
+			t.Errorf("%d: want status code %d got %d", i, want, got)
+		}
+		for k, want := range tt.h {
+			if got := w.Header().Get(k); want != got {
+				t.Errorf("%d: want header %s %q got %q", i, k, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	tests := map[uint64]ID{
+		382574876546039808: {
+			CreatedAt: time.Date(2017, 11, 21, 16, 55, 58, 466000000, time.UTC),
+			WorkerID:  64,
+		},
+		622318474387128331: {
+			CreatedAt: time.Date(2019, 9, 14, 6, 31, 26, 412000000, time.UTC),
+			WorkerID:  32,
+			Sequence:  11,
+		},
+	}
+	for id, want := range tests {
+		want.ID = id
+		if got := Decode(id); want != got {
+			t.Errorf("want %+v got %+v", want, got)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	if _, err := New(MaxWorkerID + 1); err == nil {
+		t.Fatalf("expected error for invalid worker id")
+	}
+	g, err := New(7)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	want := ID{CreatedAt: now, WorkerID: 7}
+	id := g.Next()
+	want.ID = id
+	if got := Decode(id); want != got {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	// ids of the same millisecond are sequenced
+	if got := Decode(g.Next()); got.Sequence != 1 || got.CreatedAt != now {
+		t.Errorf("want sequence 1 got %+v", got)
+	}
+	// ids keep increasing if the clock goes backwards
+	now = now.Add(-time.Second)
+	last := g.Next()
+	if last <= id {
+		t.Errorf("want id greater than %d got %d", id, last)
+	}
+	// ids move on to the next millisecond once the sequence is exhausted
+	for i := 0; i < maxSequence-2; i++ {
+		g.Next()
+	}
+	now = want.CreatedAt.Add(time.Millisecond)
+	if got := Decode(g.Next()); got.Sequence != 0 || got.CreatedAt != now {
+		t.Errorf("want first id of next millisecond got %+v", got)
+	}
+}
@@ -0,0 +1,160 @@
+package availability
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/role"
+	"github.com/lib/pq"
+)
+
+const foreignKeyViolation = "23503"
+
+// columns are the columns of the availability of a player p, see the
+// unavailable_players view.
+const columns = `
+    COALESCE(u.status, 'available'),
+    COALESCE(to_char(u.until, 'YYYY-MM-DD'), ''),
+    COALESCE(u.note, '')`
+
+// AvailabilityStore handles operations on the player_availability table of
+// the encapsulated datastore and suggests swaps of unavailable players.
+type AvailabilityStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *AvailabilityStore {
+	return &AvailabilityStore{
+		db: db,
+	}
+}
+
+// Get returns the availability of the player or store.ErrNotFound. Players
+// are available unless they are injured or unavailable today.
+func (as *AvailabilityStore) Get(ctx context.Context, playerID uint64) (*store.Availability, error) {
+	query := `
+  SELECT p.id,` + columns + `
+  FROM players AS p
+  LEFT JOIN unavailable_players AS u ON u.player_id = p.id
+  WHERE p.id = $1`
+
+	db := as.db.GetDB()
+	ctx, cancel := as.db.RequestContext(ctx)
+	defer cancel()
+
+	var a store.Availability
+	err := db.QueryRowContext(ctx, query, playerID).Scan(&a.PlayerID, &a.Status, &a.Until, &a.Note)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Set sets the availability of the player and returns it. Available players
+// have no availability stored. Returns store.ErrNotFound if the player does
+// not exist.
+func (as *AvailabilityStore) Set(ctx context.Context, a store.Availability) (*store.Availability, error) {
+	db := as.db.GetDB()
+	rctx, cancel := as.db.RequestContext(ctx)
+	defer cancel()
+
+	var err error
+	if a.Status == store.Available {
+		_, err = db.ExecContext(rctx, `
+  DELETE FROM player_availability
+  WHERE player_id = $1`, a.PlayerID)
+	} else {
+		_, err = db.ExecContext(rctx, `
+  INSERT INTO player_availability(player_id,status,until,note)
+  VALUES($1,$2,CAST(NULLIF($3, '') AS date),$4)
+  ON CONFLICT (player_id) DO UPDATE
+  SET status = EXCLUDED.status, until = EXCLUDED.until, note = EXCLUDED.note, updated_at = now()`,
+			a.PlayerID, a.Status, a.Until, a.Note)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return as.Get(ctx, a.PlayerID)
+}
+
+// Suggestions returns the swaps of the active players of the roster that are
+// not available with available benched players, see suggest. Returns
+// store.ErrNotFound if the roster does not exist.
+func (as *AvailabilityStore) Suggestions(ctx context.Context, rosterID uint64) ([]store.Suggestion, error) {
+	query := `
+  SELECT
+    r.id,
+    COALESCE(p.id, 0),
+    COALESCE(p.first_name, ''),
+    COALESCE(p.last_name, ''),
+    COALESCE(p.alias, ''),
+    COALESCE(p.status, ''),
+    ARRAY(SELECT role FROM player_roles WHERE player_id = p.id ORDER BY role),` + columns + `
+  FROM rosters AS r
+  LEFT JOIN players AS p ON p.roster_id = r.id AND p.status IN ('active', 'benched')
+  LEFT JOIN unavailable_players AS u ON u.player_id = p.id
+  WHERE r.id = $1
+  ORDER BY p.id`
+
+	db := as.db.GetDB()
+	ctx, cancel := as.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found bool
+	var members []member
+	for rows.Next() {
+		found = true
+		var m member
+		var roles pq.StringArray
+		if err := rows.Scan(
+			&m.RosterID,
+			&m.PlayerID,
+			&m.FirstName,
+			&m.LastName,
+			&m.Alias,
+			&m.Status,
+			&roles,
+			&m.availability.Status,
+			&m.availability.Until,
+			&m.availability.Note,
+		); err != nil {
+			return nil, err
+		}
+		if m.PlayerID == 0 {
+			continue
+		}
+		if len(roles) > 0 {
+			m.Roles = roles
+		}
+		m.availability.PlayerID = m.PlayerID
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+
+	requirements, err := role.Requirements(ctx, db, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	return suggest(members, requirements), nil
+}
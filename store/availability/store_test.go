@@ -0,0 +1,205 @@
+package availability
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+var availabilityColumns = []string{"id", "status", "until", "note"}
+
+func TestSet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	get := `SELECT (.+) FROM players AS p LEFT JOIN unavailable_players AS u ON u.player_id = p.id WHERE p.id = \$1`
+	mock.ExpectExec(`INSERT INTO player_availability(.+) ON CONFLICT \(player_id\) DO UPDATE`).
+		WithArgs(1, store.Injured, "2020-02-01", "wrist").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(get).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(availabilityColumns).AddRow(1, store.Injured, "2020-02-01", "wrist"))
+	// available players have no availability
+	mock.ExpectExec(`DELETE FROM player_availability WHERE player_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(get).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(availabilityColumns).AddRow(1, store.Available, "", ""))
+	mock.ExpectExec(`INSERT INTO player_availability`).
+		WithArgs(2, store.Unavailable, "2020-02-01", "").
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+
+	as := New(database.New(db, "mock-db", 0))
+	ctx := context.Background()
+	tests := []struct {
+		d string              // description of test case
+		a store.Availability  // availability to set
+		w *store.Availability // expected availability
+		e error               // expected error
+	}{
+		{
+			d: "expect injured player",
+			a: store.Availability{PlayerID: 1, Status: store.Injured, Until: "2020-02-01", Note: "wrist"},
+			w: &store.Availability{PlayerID: 1, Status: store.Injured, Until: "2020-02-01", Note: "wrist"},
+		},
+		{
+			d: "expect available player",
+			a: store.Availability{PlayerID: 1, Status: store.Available},
+			w: &store.Availability{PlayerID: 1, Status: store.Available},
+		},
+		{
+			d: "expect missing player to result in not found",
+			a: store.Availability{PlayerID: 2, Status: store.Unavailable, Until: "2020-02-01"},
+			e: store.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			got, err := as.Set(ctx, tt.a)
+			if !errors.Is(err, tt.e) {
+				t.Fatalf("want error %v got %v", tt.e, err)
+			}
+			if !reflect.DeepEqual(tt.w, got) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.w, got)
+			}
+		})
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// injured active player 2 is replaced by benched player 5, who is an awper
+// like player 2, or by benched player 4, but not by unavailable benched player
+// 6
+func TestSuggestions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"roster_id", "id", "first_name", "last_name", "alias", "status", "roles", "availability", "until", "note"}).
+		AddRow(1, 1, "a", "a", "a", store.Active, "{igl}", store.Available, "", "").
+		AddRow(1, 2, "b", "b", "b", store.Active, "{awper}", store.Injured, "", "wrist").
+		AddRow(1, 4, "d", "d", "d", store.Benched, "{}", store.Available, "", "").
+		AddRow(1, 5, "e", "e", "e", store.Benched, "{awper}", store.Available, "", "").
+		AddRow(1, 6, "f", "f", "f", store.Benched, "{awper}", store.Unavailable, "2999-01-01", "")
+	mock.ExpectQuery(`SELECT (.+) FROM rosters AS r LEFT JOIN players AS p (.+) WHERE r.id = \$1 ORDER BY p.id`).
+		WithArgs(1).
+		WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT role, count FROM roster_requirements WHERE roster_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "count"}).AddRow("igl", 1))
+	// roster 2 does not exist
+	mock.ExpectQuery(`SELECT (.+) FROM rosters AS r`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "id", "first_name", "last_name", "alias", "status", "roles", "availability", "until", "note"}))
+
+	as := New(database.New(db, "mock-db", 0))
+	got, err := as.Suggestions(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out := store.Player{PlayerID: 2, RosterID: 1, FirstName: "b", LastName: "b", Alias: "b", Status: store.Active, Roles: []string{"awper"}}
+	injured := store.Availability{PlayerID: 2, Status: store.Injured, Note: "wrist"}
+	want := []store.Suggestion{
+		{
+			Change:       store.PlayerChange{Active: out, Benched: store.Player{PlayerID: 5, RosterID: 1, FirstName: "e", LastName: "e", Alias: "e", Status: store.Benched, Roles: []string{"awper"}}},
+			Availability: injured,
+			Roles:        []string{"awper"},
+		},
+		{
+			Change:       store.PlayerChange{Active: out, Benched: store.Player{PlayerID: 4, RosterID: 1, FirstName: "d", LastName: "d", Alias: "d", Status: store.Benched}},
+			Availability: injured,
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	if _, err := as.Suggestions(context.Background(), 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	player := func(id uint64, status, availability string, roles ...string) member {
+		return member{
+			Player:       store.Player{PlayerID: id, Status: status, Roles: roles},
+			availability: store.Availability{PlayerID: id, Status: availability},
+		}
+	}
+	tests := []struct {
+		d string                  // description of test case
+		m []member                // members of the roster
+		r []store.RoleRequirement // role requirements of the roster
+		w []uint64                // expected pairs of active and benched player ids
+	}{
+		{
+			d: "expect no suggestions if all active players are available",
+			m: []member{player(1, store.Active, store.Available), player(2, store.Benched, store.Available)},
+		},
+		{
+			d: "expect swaps with available benched players only",
+			m: []member{
+				player(1, store.Active, store.Injured),
+				player(2, store.Benched, store.Unavailable),
+				player(3, store.Benched, store.Available),
+			},
+			w: []uint64{1, 3},
+		},
+		{
+			d: "expect swaps to keep required roles covered",
+			m: []member{
+				player(1, store.Active, store.Injured, "igl"),
+				player(2, store.Benched, store.Available),
+				player(3, store.Benched, store.Available, "igl"),
+			},
+			r: []store.RoleRequirement{{Role: "igl", Count: 1}},
+			w: []uint64{1, 3},
+		},
+		{
+			d: "expect swaps of roster violating its requirements not to be worse",
+			m: []member{
+				player(1, store.Active, store.Injured),
+				player(2, store.Benched, store.Available),
+			},
+			r: []store.RoleRequirement{{Role: "igl", Count: 1}},
+			w: []uint64{1, 2},
+		},
+		{
+			d: "expect swaps of each unavailable active player",
+			m: []member{
+				player(1, store.Active, store.Injured),
+				player(2, store.Active, store.Unavailable, "entry"),
+				player(3, store.Benched, store.Available),
+				player(4, store.Benched, store.Available, "entry"),
+			},
+			w: []uint64{1, 3, 1, 4, 2, 4, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			var got []uint64
+			for _, s := range suggest(tt.m, tt.r) {
+				got = append(got, s.Change.Active.PlayerID, s.Change.Benched.PlayerID)
+			}
+			if !reflect.DeepEqual(tt.w, got) {
+				t.Errorf("want %v got %v", tt.w, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package availability
+
+import (
+	"sort"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// member is an active or benched player of a roster along with its
+// availability.
+type member struct {
+	store.Player
+	availability store.Availability
+}
+
+// suggest returns the swaps of each active member that is not available with
+// the available benched members, ordered like the active members. Swaps are
+// valid on their own: they keep each role requirement covered as far as it is
+// covered by the current active members. The swaps of an active member are
+// ordered by the number of its roles the benched member takes over.
+func suggest(members []member, requirements []store.RoleRequirement) []store.Suggestion {
+	var active, benched []member
+	for _, m := range members {
+		switch m.Status {
+		case store.Active:
+			active = append(active, m)
+		case store.Benched:
+			benched = append(benched, m)
+		}
+	}
+	covered := make(map[string]int) // active members by role
+	for _, m := range active {
+		for _, r := range m.Roles {
+			covered[r]++
+		}
+	}
+
+	suggestions := make([]store.Suggestion, 0)
+	for _, out := range active {
+		if out.availability.Status == store.Available {
+			continue
+		}
+		var swaps []store.Suggestion
+		for _, in := range benched {
+			if in.availability.Status != store.Available || !keeps(requirements, covered, out.Player, in.Player) {
+				continue
+			}
+			swaps = append(swaps, store.Suggestion{
+				Change:       store.PlayerChange{Active: out.Player, Benched: in.Player},
+				Availability: out.availability,
+				Roles:        shared(out.Roles, in.Roles),
+			})
+		}
+		sort.SliceStable(swaps, func(i, j int) bool {
+			return len(swaps[i].Roles) > len(swaps[j].Roles)
+		})
+		suggestions = append(suggestions, swaps...)
+	}
+	return suggestions
+}
+
+// keeps reports whether activating in for out keeps each requirement covered
+// by at least as many active players as required or currently covering it.
+func keeps(requirements []store.RoleRequirement, covered map[string]int, out, in store.Player) bool {
+	for _, r := range requirements {
+		n := covered[r.Role] - count(out.Roles, r.Role) + count(in.Roles, r.Role)
+		if n < r.Count && n < covered[r.Role] {
+			return false
+		}
+	}
+	return true
+}
+
+// count returns 1 if role is one of roles, otherwise 0.
+func count(roles []string, role string) int {
+	for _, r := range roles {
+		if r == role {
+			return 1
+		}
+	}
+	return 0
+}
+
+// shared returns the roles of a that are roles of b too.
+func shared(a, b []string) []string {
+	var roles []string
+	for _, r := range a {
+		if count(b, r) == 1 {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
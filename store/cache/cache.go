@@ -0,0 +1,186 @@
+// Package cache provides a read-through cache of rosters. Rosters are
+// invalidated by writes to the players store of the same instance and by
+// change events of the datastore, which cover writes of other instances.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fgrimme/patrongg/events"
+	"github.com/fgrimme/patrongg/store"
+)
+
+// rosterStore handles operations on rosters.
+type rosterStore interface {
+	Create(ctx context.Context, roster store.Roster) (*store.Roster, error)
+	Get(ctx context.Context, rosterID uint64) (*store.Roster, error)
+	List(ctx context.Context, name string) ([]store.Roster, error)
+	Export(ctx context.Context, rosterID uint64, fn func(store.RosterRow) error) error
+}
+
+// entry is a cached roster.
+type entry struct {
+	roster  *store.Roster
+	expires time.Time
+}
+
+// call is a pending lookup of a roster. Concurrent lookups of the same roster
+// wait for the pending one.
+type call struct {
+	done   chan struct{}
+	roster *store.Roster
+	err    error
+}
+
+// RosterCache caches the rosters returned by Get for the given TTL and evicts
+// the least recently used rosters if the cache exceeds its size. Concurrent
+// misses of the same roster result in a single lookup. Cached rosters are
+// shared and must not be modified. All other methods are passed through to
+// the wrapped store.
+type RosterCache struct {
+	rosterStore
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[uint64]*list.Element // values are roster ids in lru order
+	lru     *list.List
+	cached  map[uint64]entry
+	calls   map[uint64]*call
+	version uint64 // incremented on invalidation
+}
+
+func New(rs rosterStore, ttl time.Duration, size int) *RosterCache {
+	return &RosterCache{
+		rosterStore: rs,
+		ttl:         ttl,
+		size:        size,
+		now:         time.Now,
+		entries:     make(map[uint64]*list.Element),
+		lru:         list.New(),
+		cached:      make(map[uint64]entry),
+		calls:       make(map[uint64]*call),
+	}
+}
+
+// Get returns the cached roster with the given id or looks it up in the
+// wrapped store. Errors are not cached. Caches with a size of 0 are disabled.
+func (c *RosterCache) Get(ctx context.Context, rosterID uint64) (*store.Roster, error) {
+	if c.size <= 0 {
+		return c.rosterStore.Get(ctx, rosterID)
+	}
+	c.mu.Lock()
+	if e, ok := c.cached[rosterID]; ok && c.now().Before(e.expires) {
+		c.lru.MoveToFront(c.entries[rosterID])
+		c.mu.Unlock()
+		return e.roster, nil
+	}
+	if cl, ok := c.calls[rosterID]; ok {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+			return cl.roster, cl.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	cl := &call{done: make(chan struct{})}
+	c.calls[rosterID] = cl
+	version := c.version
+	c.mu.Unlock()
+
+	// the lookup is not canceled if the first caller gives up, since other
+	// callers may still wait for it
+	lookupCtx, cancel := detach(ctx)
+	cl.roster, cl.err = c.rosterStore.Get(lookupCtx, rosterID)
+	cancel()
+
+	c.mu.Lock()
+	delete(c.calls, rosterID)
+	// rosters looked up before an invalidation might be stale
+	if cl.err == nil && version == c.version {
+		c.add(rosterID, cl.roster)
+	}
+	c.mu.Unlock()
+	close(cl.done)
+	return cl.roster, cl.err
+}
+
+// detached is a context carrying the values but not the cancelation of its
+// parent.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// detach returns a context with the values and the deadline of ctx that is
+// not canceled with ctx.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached{ctx}, deadline)
+	}
+	return context.WithCancel(detached{ctx})
+}
+
+// add caches the roster and evicts the least recently used roster if the
+// cache is full. Must be called with the lock held.
+func (c *RosterCache) add(rosterID uint64, roster *store.Roster) {
+	if el, ok := c.entries[rosterID]; ok {
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[rosterID] = c.lru.PushFront(rosterID)
+	}
+	c.cached[rosterID] = entry{roster: roster, expires: c.now().Add(c.ttl)}
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back().Value.(uint64))
+	}
+}
+
+// remove removes the roster from the cache. Must be called with the lock held.
+func (c *RosterCache) remove(rosterID uint64) {
+	if el, ok := c.entries[rosterID]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, rosterID)
+		delete(c.cached, rosterID)
+	}
+}
+
+// Invalidate removes the roster with the given id from the cache or all
+// rosters if rosterID is 0. Pending lookups are not cached.
+func (c *RosterCache) Invalidate(rosterID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	if rosterID == 0 {
+		c.entries = make(map[uint64]*list.Element)
+		c.lru.Init()
+		c.cached = make(map[uint64]entry)
+		return
+	}
+	c.remove(rosterID)
+}
+
+// invalidator invalidates changed rosters before publishing their changes.
+type invalidator struct {
+	cache *RosterCache
+	next  events.Publisher
+}
+
+func (i *invalidator) Publish(e events.Event) {
+	i.cache.Invalidate(e.RosterID)
+	i.next.Publish(e)
+}
+
+// Publisher returns a publisher invalidating changed rosters before
+// publishing their changes to next, so that subscribers of next never read
+// stale rosters from the cache.
+func (c *RosterCache) Publisher(next events.Publisher) events.Publisher {
+	return &invalidator{cache: c, next: next}
+}
@@ -0,0 +1,405 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fgrimme/patrongg/events"
+	"github.com/fgrimme/patrongg/store"
+)
+
+// counts the lookups per roster, lookups block until release is closed.
+type mockRosterStore struct {
+	mu      sync.Mutex
+	lookups map[uint64]int
+	release chan struct{}
+}
+
+func (rs *mockRosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster, error) {
+	rs.mu.Lock()
+	rs.lookups[rosterID]++
+	rs.mu.Unlock()
+	if rs.release != nil {
+		<-rs.release
+	}
+	if rosterID == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &store.Roster{
+		RosterID: rosterID,
+		Players: store.Players{
+			Active: []store.Player{{PlayerID: rosterID * 10, RosterID: rosterID}},
+		},
+	}, nil
+}
+
+func (rs *mockRosterStore) Create(ctx context.Context, roster store.Roster) (*store.Roster, error) {
+	return &roster, nil
+}
+
+func (rs *mockRosterStore) List(ctx context.Context, name string) ([]store.Roster, error) {
+	return nil, nil
+}
+
+func (rs *mockRosterStore) Export(ctx context.Context, rosterID uint64, fn func(store.RosterRow) error) error {
+	return nil
+}
+
+func (rs *mockRosterStore) count(rosterID uint64) int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.lookups[rosterID]
+}
+
+func newCache(size int) (*RosterCache, *mockRosterStore, *time.Time) {
+	rs := &mockRosterStore{lookups: make(map[uint64]int)}
+	now := time.Now()
+	c := New(rs, time.Minute, size)
+	c.now = func() time.Time { return now }
+	return c, rs, &now
+}
+
+func get(t *testing.T, c *RosterCache, rosterID uint64) {
+	t.Helper()
+	if _, err := c.Get(context.Background(), rosterID); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	c, rs, now := newCache(2)
+
+	get(t, c, 1)
+	get(t, c, 1)
+	if want, got := 1, rs.count(1); want != got {
+		t.Errorf("want %d lookups got %d", want, got)
+	}
+
+	// expired rosters are looked up again
+	*now = now.Add(time.Minute)
+	get(t, c, 1)
+	if want, got := 2, rs.count(1); want != got {
+		t.Errorf("want %d lookups after expiry got %d", want, got)
+	}
+
+	// the least recently used roster is evicted
+	get(t, c, 2)
+	get(t, c, 1)
+	get(t, c, 3)
+	get(t, c, 1)
+	get(t, c, 2)
+	if want, got := 2, rs.count(2); want != got {
+		t.Errorf("want %d lookups after eviction got %d", want, got)
+	}
+	if want, got := 2, rs.count(1); want != got {
+		t.Errorf("want %d lookups of recently used roster got %d", want, got)
+	}
+
+	// errors are not cached
+	c.Get(context.Background(), 0)
+	c.Get(context.Background(), 0)
+	if want, got := 2, rs.count(0); want != got {
+		t.Errorf("want %d lookups of missing roster got %d", want, got)
+	}
+}
+
+func TestSingleFlight(t *testing.T) {
+	c, rs, _ := newCache(2)
+	rs.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, c, 1)
+		}()
+	}
+	// wait for the first lookup before releasing it
+	for rs.count(1) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(rs.release)
+	wg.Wait()
+	if want, got := 1, rs.count(1); want != got {
+		t.Errorf("want %d lookups got %d", want, got)
+	}
+}
+
+// lookups pending while a roster is invalidated might be stale
+func TestInvalidatePending(t *testing.T) {
+	c, rs, _ := newCache(2)
+	rs.release = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		get(t, c, 1)
+		close(done)
+	}()
+	for rs.count(1) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Invalidate(1)
+	close(rs.release)
+	<-done
+
+	get(t, c, 1)
+	if want, got := 2, rs.count(1); want != got {
+		t.Errorf("want %d lookups got %d", want, got)
+	}
+}
+
+// mocks the players store, the updated player is moved to roster 2. Other
+// players are members of the roster with a tenth of their id.
+type mockPlayerStore struct{}
+
+func (ps *mockPlayerStore) Get(ctx context.Context, playerID uint64) (*store.Player, error) {
+	return &store.Player{PlayerID: playerID, RosterID: playerID / 10}, nil
+}
+
+func (ps *mockPlayerStore) Delete(ctx context.Context, playerID uint64) (*store.Player, error) {
+	return &store.Player{PlayerID: playerID, RosterID: playerID / 10}, nil
+}
+
+func (ps *mockPlayerStore) Insert(ctx context.Context, player store.Player) (*store.Player, error) {
+	return &player, nil
+}
+
+func (ps *mockPlayerStore) Update(ctx context.Context, player store.Player) (*store.Player, error) {
+	player.RosterID = 2
+	return &player, nil
+}
+
+func (ps *mockPlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
+	players.Active.RosterID = players.Active.PlayerID / 10
+	players.Benched.RosterID = players.Benched.PlayerID / 10
+	return &players, nil
+}
+
+func (ps *mockPlayerStore) Trade(ctx context.Context, trade store.Trade) (*store.Trade, error) {
+	return &trade, nil
+}
+
+func (ps *mockPlayerStore) Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error) {
+	result := &store.ImportResult{DryRun: dryRun}
+	for _, row := range rows {
+		result.Players = append(result.Players, row.Player)
+	}
+	return result, nil
+}
+
+func (ps *mockPlayerStore) Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error) {
+	return nil, nil
+}
+
+func (ps *mockPlayerStore) FindByAlias(ctx context.Context, alias string) ([]store.AliasMatch, error) {
+	return nil, nil
+}
+
+func (ps *mockPlayerStore) Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error) {
+	return nil, nil
+}
+
+var invalidateTests = []struct {
+	d string                // description of test case
+	w func(ps *PlayerStore) // write to the players store
+	l map[uint64]int        // expected lookups per roster after the write
+}{
+	{
+		d: "expect insert to invalidate the roster of the player",
+		w: func(ps *PlayerStore) {
+			ps.Insert(context.Background(), store.Player{RosterID: 1})
+		},
+		l: map[uint64]int{1: 2, 2: 1, 3: 1},
+	},
+	{
+		d: "expect update to invalidate the old and the new roster of the player",
+		w: func(ps *PlayerStore) {
+			ps.Update(context.Background(), store.Player{PlayerID: 30})
+		},
+		l: map[uint64]int{1: 1, 2: 2, 3: 2},
+	},
+	{
+		d: "expect change to invalidate the roster of the players",
+		w: func(ps *PlayerStore) {
+			ps.ChangePlayers(context.Background(), store.PlayerChange{
+				Active:  store.Player{PlayerID: 30},
+				Benched: store.Player{PlayerID: 31},
+			})
+		},
+		l: map[uint64]int{1: 1, 2: 1, 3: 2},
+	},
+	{
+		d: "expect trade to invalidate both rosters",
+		w: func(ps *PlayerStore) {
+			ps.Trade(context.Background(), store.Trade{RosterID: 1, PartnerID: 3})
+		},
+		l: map[uint64]int{1: 2, 2: 1, 3: 2},
+	},
+	{
+		d: "expect delete to invalidate the roster of the player",
+		w: func(ps *PlayerStore) {
+			ps.Delete(context.Background(), 20)
+		},
+		l: map[uint64]int{1: 1, 2: 2, 3: 1},
+	},
+	{
+		d: "expect dry runs not to invalidate rosters",
+		w: func(ps *PlayerStore) {
+			ps.Import(context.Background(), []store.PlayerRow{{Player: store.Player{RosterID: 1}}}, true)
+		},
+		l: map[uint64]int{1: 1, 2: 1, 3: 1},
+	},
+	{
+		d: "expect import to invalidate the rosters of the players",
+		w: func(ps *PlayerStore) {
+			ps.Import(context.Background(), []store.PlayerRow{{Player: store.Player{RosterID: 1}}}, false)
+		},
+		l: map[uint64]int{1: 2, 2: 1, 3: 1},
+	},
+}
+
+func TestInvalidate(t *testing.T) {
+	for _, tc := range invalidateTests {
+		tt := tc
+		t.Run(tt.d, func(t *testing.T) {
+			c, rs, _ := newCache(3)
+			for id := uint64(1); id <= 3; id++ {
+				get(t, c, id)
+			}
+			tt.w(c.Players(&mockPlayerStore{}))
+			for id := uint64(1); id <= 3; id++ {
+				get(t, c, id)
+				if want, got := tt.l[id], rs.count(id); want != got {
+					t.Errorf("roster %d: want %d lookups got %d", id, want, got)
+				}
+			}
+		})
+	}
+}
+
+// mocks the transfers store, transfers move players from roster 1 to roster 2.
+type mockTransferStore struct {
+	transferStore
+}
+
+func (ts *mockTransferStore) Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error) {
+	return &store.Transfer{TransferID: transferID, FromRosterID: 1, ToRosterID: 2, Status: store.TransferAccepted}, nil
+}
+
+func TestInvalidateTransfers(t *testing.T) {
+	c, rs, _ := newCache(3)
+	for id := uint64(1); id <= 3; id++ {
+		get(t, c, id)
+	}
+	c.Transfers(&mockTransferStore{}).Accept(context.Background(), 1, "alice")
+	for id, want := range map[uint64]int{1: 2, 2: 2, 3: 1} {
+		get(t, c, id)
+		if got := rs.count(id); want != got {
+			t.Errorf("roster %d: want %d lookups got %d", id, want, got)
+		}
+	}
+}
+
+// mocks the roles store, players are members of the roster with a tenth of
+// their id.
+type mockRoleStore struct {
+	roleStore
+}
+
+func (rs *mockRoleStore) SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error) {
+	return requirements, nil
+}
+
+func (rs *mockRoleStore) SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error) {
+	return &store.Player{PlayerID: playerID, RosterID: playerID / 10, Roles: roles}, nil
+}
+
+func TestInvalidateRoles(t *testing.T) {
+	c, rs, _ := newCache(3)
+	for id := uint64(1); id <= 3; id++ {
+		get(t, c, id)
+	}
+	roles := c.Roles(&mockRoleStore{})
+	roles.SetRequirements(context.Background(), 1, []store.RoleRequirement{{Role: "igl", Count: 1}})
+	roles.SetRoles(context.Background(), 30, []string{"igl"})
+	for id, want := range map[uint64]int{1: 2, 2: 1, 3: 2} {
+		get(t, c, id)
+		if got := rs.count(id); want != got {
+			t.Errorf("roster %d: want %d lookups got %d", id, want, got)
+		}
+	}
+}
+
+// mocks the contracts store, the contracts of rosters 1 and 3 expire.
+type mockContractStore struct {
+	contractStore
+}
+
+func (cs *mockContractStore) Expire(ctx context.Context) ([]store.Contract, error) {
+	return []store.Contract{
+		{ContractID: 1, RosterID: 1, Status: store.ContractReleased},
+		{ContractID: 2, RosterID: 3, Status: store.ContractOverdue},
+	}, nil
+}
+
+func TestInvalidateContracts(t *testing.T) {
+	c, rs, _ := newCache(3)
+	for id := uint64(1); id <= 3; id++ {
+		get(t, c, id)
+	}
+	c.Contracts(&mockContractStore{}).Expire(context.Background())
+	for id, want := range map[uint64]int{1: 2, 2: 1, 3: 2} {
+		get(t, c, id)
+		if got := rs.count(id); want != got {
+			t.Errorf("roster %d: want %d lookups got %d", id, want, got)
+		}
+	}
+}
+
+// mocks the compliance store, the mode of roster 2 can not be changed.
+type mockComplianceStore struct {
+	complianceStore
+}
+
+func (cs *mockComplianceStore) SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error) {
+	if rosterID == 2 {
+		return nil, &store.ConflictError{RosterID: rosterID, Reason: "roster violates its rules"}
+	}
+	return &store.Compliance{RosterID: rosterID, Mode: mode}, nil
+}
+
+func TestInvalidateCompliance(t *testing.T) {
+	c, rs, _ := newCache(3)
+	for id := uint64(1); id <= 3; id++ {
+		get(t, c, id)
+	}
+	compliance := c.Compliance(&mockComplianceStore{})
+	compliance.SetMode(context.Background(), 1, store.Lenient)
+	compliance.SetMode(context.Background(), 2, store.Strict)
+	for id, want := range map[uint64]int{1: 2, 2: 1, 3: 1} {
+		get(t, c, id)
+		if got := rs.count(id); want != got {
+			t.Errorf("roster %d: want %d lookups got %d", id, want, got)
+		}
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	c, rs, _ := newCache(2)
+	b := events.NewBroker()
+	changes, cancel := b.Subscribe(1)
+	defer cancel()
+
+	get(t, c, 1)
+	c.Publisher(b).Publish(events.Event{RosterID: 1})
+	<-changes
+	// subscribers read the changed roster
+	get(t, c, 1)
+	if want, got := 2, rs.count(1); want != got {
+		t.Errorf("want %d lookups got %d", want, got)
+	}
+}
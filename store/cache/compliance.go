@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// complianceStore provides methods to operate on the compliance store.
+type complianceStore interface {
+	Get(ctx context.Context, rosterID uint64) (*store.Compliance, error)
+	List(ctx context.Context, mode string) ([]store.Compliance, error)
+	SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error)
+}
+
+// ComplianceStore invalidates the cached rosters changed by writes to the
+// wrapped compliance store.
+type ComplianceStore struct {
+	complianceStore
+	cache *RosterCache
+}
+
+// Compliance wraps the compliance store so that changes of the compliance
+// mode invalidate the roster.
+func (c *RosterCache) Compliance(cs complianceStore) *ComplianceStore {
+	return &ComplianceStore{
+		complianceStore: cs,
+		cache:           c,
+	}
+}
+
+func (cs *ComplianceStore) SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error) {
+	c, err := cs.complianceStore.SetMode(ctx, rosterID, mode)
+	if err == nil {
+		cs.cache.Invalidate(rosterID)
+	}
+	return c, err
+}
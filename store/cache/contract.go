@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// contractStore provides methods to operate on the contracts store.
+type contractStore interface {
+	Sign(ctx context.Context, contract store.Contract) (*store.Contract, error)
+	Get(ctx context.Context, contractID uint64) (*store.Contract, error)
+	List(ctx context.Context, rosterID uint64) ([]store.Contract, error)
+	Expiring(ctx context.Context, rosterID uint64, days int) ([]store.Contract, error)
+	Expire(ctx context.Context) ([]store.Contract, error)
+}
+
+// ContractStore invalidates the cached rosters changed by expired contracts
+// of the wrapped contracts store.
+type ContractStore struct {
+	contractStore
+	cache *RosterCache
+}
+
+// Contracts wraps the contracts store so that expired contracts invalidate
+// the rosters that released or benched players.
+func (c *RosterCache) Contracts(cs contractStore) *ContractStore {
+	return &ContractStore{
+		contractStore: cs,
+		cache:         c,
+	}
+}
+
+func (cs *ContractStore) Expire(ctx context.Context) ([]store.Contract, error) {
+	contracts, err := cs.contractStore.Expire(ctx)
+	for _, c := range contracts {
+		cs.cache.Invalidate(c.RosterID)
+	}
+	return contracts, err
+}
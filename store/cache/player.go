@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// playerStore provides methods to operate on the players store.
+type playerStore interface {
+	Get(ctx context.Context, playerID uint64) (*store.Player, error)
+	Insert(ctx context.Context, player store.Player) (*store.Player, error)
+	Update(ctx context.Context, player store.Player) (*store.Player, error)
+	Delete(ctx context.Context, playerID uint64) (*store.Player, error)
+	ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error)
+	Trade(ctx context.Context, trade store.Trade) (*store.Trade, error)
+	Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error)
+	Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error)
+	FindByAlias(ctx context.Context, alias string) ([]store.AliasMatch, error)
+	Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error)
+}
+
+// PlayerStore invalidates the cached rosters changed by writes to the wrapped
+// players store.
+type PlayerStore struct {
+	playerStore
+	cache *RosterCache
+}
+
+// Players wraps the players store so that writes invalidate the rosters they
+// change.
+func (c *RosterCache) Players(ps playerStore) *PlayerStore {
+	return &PlayerStore{
+		playerStore: ps,
+		cache:       c,
+	}
+}
+
+func (ps *PlayerStore) Insert(ctx context.Context, player store.Player) (*store.Player, error) {
+	p, err := ps.playerStore.Insert(ctx, player)
+	if err == nil {
+		ps.cache.Invalidate(p.RosterID)
+	}
+	return p, err
+}
+
+// Update invalidates the roster the player is moved to and the cached roster
+// the player is moved from.
+func (ps *PlayerStore) Update(ctx context.Context, player store.Player) (*store.Player, error) {
+	p, err := ps.playerStore.Update(ctx, player)
+	if err == nil {
+		ps.cache.invalidatePlayer(p.PlayerID)
+		ps.cache.Invalidate(p.RosterID)
+	}
+	return p, err
+}
+
+func (ps *PlayerStore) Delete(ctx context.Context, playerID uint64) (*store.Player, error) {
+	p, err := ps.playerStore.Delete(ctx, playerID)
+	if err == nil {
+		ps.cache.Invalidate(p.RosterID)
+	}
+	return p, err
+}
+
+// ChangePlayers invalidates the roster of the swapped players. The roster ids
+// of the request are optional, so the ones of the result are used.
+func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
+	p, err := ps.playerStore.ChangePlayers(ctx, players)
+	if err == nil {
+		ps.cache.Invalidate(p.Active.RosterID)
+	}
+	return p, err
+}
+
+// Trade invalidates both rosters of the trade.
+func (ps *PlayerStore) Trade(ctx context.Context, trade store.Trade) (*store.Trade, error) {
+	t, err := ps.playerStore.Trade(ctx, trade)
+	if err == nil {
+		ps.cache.Invalidate(t.RosterID)
+		ps.cache.Invalidate(t.PartnerID)
+	}
+	return t, err
+}
+
+func (ps *PlayerStore) Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error) {
+	result, err := ps.playerStore.Import(ctx, rows, dryRun)
+	if err == nil && !result.DryRun && len(result.Errors) == 0 {
+		for _, p := range result.Players {
+			ps.cache.Invalidate(p.RosterID)
+		}
+	}
+	return result, err
+}
+
+// invalidatePlayer invalidates the cached roster of the player.
+func (c *RosterCache) invalidatePlayer(playerID uint64) {
+	c.mu.Lock()
+	var rosterID uint64
+	for id, e := range c.cached {
+		if hasPlayer(e.roster.Players.Active, playerID) || hasPlayer(e.roster.Players.Benched, playerID) {
+			rosterID = id
+			break
+		}
+	}
+	c.mu.Unlock()
+	if rosterID != 0 {
+		c.Invalidate(rosterID)
+	}
+}
+
+func hasPlayer(players []store.Player, playerID uint64) bool {
+	for _, p := range players {
+		if p.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
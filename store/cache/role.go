@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// roleStore provides methods to operate on the roles store.
+type roleStore interface {
+	Requirements(ctx context.Context, rosterID uint64) ([]store.RoleRequirement, error)
+	SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error)
+	SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error)
+}
+
+// RoleStore invalidates the cached rosters changed by writes to the wrapped
+// roles store.
+type RoleStore struct {
+	roleStore
+	cache *RosterCache
+}
+
+// Roles wraps the roles store so that changes of requirements and roles
+// invalidate the rosters they change.
+func (c *RosterCache) Roles(rs roleStore) *RoleStore {
+	return &RoleStore{
+		roleStore: rs,
+		cache:     c,
+	}
+}
+
+func (rs *RoleStore) SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error) {
+	r, err := rs.roleStore.SetRequirements(ctx, rosterID, requirements)
+	if err == nil {
+		rs.cache.Invalidate(rosterID)
+	}
+	return r, err
+}
+
+func (rs *RoleStore) SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error) {
+	p, err := rs.roleStore.SetRoles(ctx, playerID, roles)
+	if err == nil {
+		rs.cache.Invalidate(p.RosterID)
+	}
+	return p, err
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// transferStore provides methods to operate on the transfers store.
+type transferStore interface {
+	Propose(ctx context.Context, transfer store.Transfer) (*store.Transfer, error)
+	Get(ctx context.Context, transferID uint64) (*store.Transfer, error)
+	List(ctx context.Context, rosterID uint64, status string) ([]store.Transfer, error)
+	ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error)
+	Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error)
+	Decide(ctx context.Context, transferID uint64, status, subject string) (*store.Transfer, error)
+	Expire(ctx context.Context) (int64, error)
+}
+
+// TransferStore invalidates the cached rosters changed by accepted transfers
+// of the wrapped transfers store.
+type TransferStore struct {
+	transferStore
+	cache *RosterCache
+}
+
+// Transfers wraps the transfers store so that accepted transfers invalidate
+// the rosters the player is moved between.
+func (c *RosterCache) Transfers(ts transferStore) *TransferStore {
+	return &TransferStore{
+		transferStore: ts,
+		cache:         c,
+	}
+}
+
+func (ts *TransferStore) Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error) {
+	t, err := ts.transferStore.Accept(ctx, transferID, subject)
+	if err == nil {
+		ts.cache.Invalidate(t.FromRosterID)
+		ts.cache.Invalidate(t.ToRosterID)
+	}
+	return t, err
+}
@@ -0,0 +1,170 @@
+package compliance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog/log"
+)
+
+// columns are the columns of compliance reports, one row per violation or a
+// single row without rule for compliant rosters.
+const columns = `
+    rosters.id,
+    rosters.compliance,
+    COALESCE(v.rule, ''),
+    COALESCE(v.detail, ''),
+    COALESCE(v.role, ''),
+    COALESCE(v.contract_id, 0),
+    COALESCE(v.player_id, 0)`
+
+// ComplianceStore evaluates the rosters of the encapsulated datastore against
+// their rules, see the roster_violations view, and handles their compliance
+// modes.
+type ComplianceStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *ComplianceStore {
+	return &ComplianceStore{
+		db: db,
+	}
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Get returns the compliance report of the roster or store.ErrNotFound.
+func (cs *ComplianceStore) Get(ctx context.Context, rosterID uint64) (*store.Compliance, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	return get(ctx, db, rosterID)
+}
+
+func get(ctx context.Context, q querier, rosterID uint64) (*store.Compliance, error) {
+	reports, err := scanReports(q.QueryContext(ctx, `
+  SELECT `+columns+`
+  FROM rosters
+  LEFT JOIN roster_violations AS v ON v.roster_id = rosters.id
+  WHERE rosters.id = $1
+  ORDER BY v.rule, v.role, v.contract_id`, rosterID))
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &reports[0], nil
+}
+
+// List returns the reports of the non-compliant rosters ordered by roster id.
+// Rosters are filtered by their compliance mode unless mode is empty.
+func (cs *ComplianceStore) List(ctx context.Context, mode string) ([]store.Compliance, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	return scanReports(db.QueryContext(ctx, `
+  SELECT `+columns+`
+  FROM rosters
+  INNER JOIN roster_violations AS v ON v.roster_id = rosters.id
+  WHERE $1 = '' OR rosters.compliance = $1
+  ORDER BY rosters.id, v.rule, v.role, v.contract_id`, mode))
+}
+
+// SetMode sets the compliance mode of the roster and returns its report.
+// Rosters violating their rules can not become strict, a *store.ConflictError
+// names the first violation. Returns store.ErrNotFound if the roster does not
+// exist.
+func (cs *ComplianceStore) SetMode(ctx context.Context, rosterID uint64, mode string) (*store.Compliance, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	report, err := setMode(ctx, tx, rosterID, mode)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("roster_id", rosterID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func setMode(ctx context.Context, tx *sql.Tx, rosterID uint64, mode string) (*store.Compliance, error) {
+	// the roster is locked until commit, so that its players can not be
+	// changed by lenient writes while it becomes strict: check_roster_rules
+	// share locks the roster, so writes checked before wait for this update
+	// and writes checked after see the roster as strict
+	res, err := tx.ExecContext(ctx, `UPDATE rosters SET compliance = $2 WHERE id = $1`, rosterID, mode)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = store.ErrNotFound
+		}
+		return nil, err
+	}
+	report, err := get(ctx, tx, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	if mode == store.Strict && !report.Compliant {
+		return nil, &store.ConflictError{
+			RosterID: rosterID,
+			Reason:   fmt.Sprintf("strict rosters must comply with their rules, roster %s", report.Violations[0].Detail),
+		}
+	}
+	return report, nil
+}
+
+// scanReports scans the rows of compliance reports ordered by roster id.
+func scanReports(rows *sql.Rows, err error) ([]store.Compliance, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]store.Compliance, 0)
+	for rows.Next() {
+		var rosterID uint64
+		var mode string
+		var v store.Violation
+		if err := rows.Scan(&rosterID, &mode, &v.Rule, &v.Detail, &v.Role, &v.ContractID, &v.PlayerID); err != nil {
+			return nil, err
+		}
+		if n := len(reports); n == 0 || reports[n-1].RosterID != rosterID {
+			reports = append(reports, store.Compliance{
+				RosterID:   rosterID,
+				Mode:       mode,
+				Compliant:  true,
+				Violations: make([]store.Violation, 0),
+			})
+		}
+		if v.Rule == "" {
+			continue
+		}
+		r := &reports[len(reports)-1]
+		r.Compliant = false
+		r.Violations = append(r.Violations, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,165 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+)
+
+var reportColumns = []string{"id", "compliance", "rule", "detail", "role", "contract_id", "player_id"}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	query := `SELECT (.+) FROM rosters LEFT JOIN roster_violations AS v ON v.roster_id = rosters.id WHERE rosters.id = \$1`
+	// roster 1 lacks an active player and an igl, roster 2 is compliant
+	mock.ExpectQuery(query).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(reportColumns).
+			AddRow(1, store.Lenient, store.RuleActiveSize, "must have exactly 5 active players, not 4", "", 0, 0).
+			AddRow(1, store.Lenient, store.RuleRole, "requires 1 active igl players, not 0", "igl", 0, 0))
+	mock.ExpectQuery(query).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(reportColumns).AddRow(2, store.Strict, "", "", "", 0, 0))
+	mock.ExpectQuery(query).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(reportColumns))
+
+	cs := New(database.New(db, "mock-db", 0))
+	ctx := context.Background()
+	tests := []struct {
+		d string            // description of test case
+		i uint64            // roster id
+		w *store.Compliance // expected report
+		e error             // expected error
+	}{
+		{
+			d: "expect violations of roster",
+			i: 1,
+			w: &store.Compliance{RosterID: 1, Mode: store.Lenient, Violations: []store.Violation{
+				{Rule: store.RuleActiveSize, Detail: "must have exactly 5 active players, not 4"},
+				{Rule: store.RuleRole, Detail: "requires 1 active igl players, not 0", Role: "igl"},
+			}},
+		},
+		{
+			d: "expect compliant roster",
+			i: 2,
+			w: &store.Compliance{RosterID: 2, Mode: store.Strict, Compliant: true, Violations: []store.Violation{}},
+		},
+		{
+			d: "expect missing roster to result in not found",
+			i: 3,
+			e: store.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			got, err := cs.Get(ctx, tt.i)
+			if !errors.Is(err, tt.e) {
+				t.Fatalf("want error %v got %v", tt.e, err)
+			}
+			if !reflect.DeepEqual(tt.w, got) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.w, got)
+			}
+		})
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// lists the reports of non-compliant rosters grouped by roster
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM rosters INNER JOIN roster_violations AS v (.+) WHERE \$1 = '' OR rosters.compliance = \$1 ORDER BY rosters.id`).
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows(reportColumns).
+			AddRow(1, store.Lenient, store.RuleActiveSize, "must have exactly 5 active players, not 4", "", 0, 0).
+			AddRow(1, store.Lenient, store.RuleMaxBenched, "must have at most 1 benched players, not 2", "", 0, 0).
+			AddRow(3, store.Strict, store.RuleContract, "contract 7 of player 8 ended on 2019-12-31", "", 7, 8))
+
+	cs := New(database.New(db, "mock-db", 0))
+	got, err := cs.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Compliance{
+		{RosterID: 1, Mode: store.Lenient, Violations: []store.Violation{
+			{Rule: store.RuleActiveSize, Detail: "must have exactly 5 active players, not 4"},
+			{Rule: store.RuleMaxBenched, Detail: "must have at most 1 benched players, not 2"},
+		}},
+		{RosterID: 3, Mode: store.Strict, Violations: []store.Violation{
+			{Rule: store.RuleContract, Detail: "contract 7 of player 8 ended on 2019-12-31", ContractID: 7, PlayerID: 8},
+		}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// rosters violating their rules may become lenient but not strict
+func TestSetMode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	update := `UPDATE rosters SET compliance = \$2 WHERE id = \$1`
+	query := `SELECT (.+) FROM rosters LEFT JOIN roster_violations AS v`
+	violations := func() *sqlmock.Rows {
+		return sqlmock.NewRows(reportColumns).
+			AddRow(1, store.Lenient, store.RuleActiveSize, "must have exactly 5 active players, not 4", "", 0, 0)
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(1, store.Lenient).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(violations())
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(1, store.Strict).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(violations())
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(2, store.Strict).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	cs := New(database.New(db, "mock-db", 0))
+	ctx := context.Background()
+	got, err := cs.SetMode(ctx, 1, store.Lenient)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := 1; len(got.Violations) != want {
+		t.Errorf("want %d violations got %d", want, len(got.Violations))
+	}
+	var conflict *store.ConflictError
+	if _, err := cs.SetMode(ctx, 1, store.Strict); !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error got %v", err)
+	}
+	if want, got := "strict rosters must comply with their rules, roster must have exactly 5 active players, not 4", conflict.Reason; want != got {
+		t.Errorf("want reason %q got %q", want, got)
+	}
+	if _, err := cs.SetMode(ctx, 2, store.Strict); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,451 @@
+package contract
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/role"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation = "23505"
+)
+
+const columns = `id, player_id, roster_id, to_char(starts_on, 'YYYY-MM-DD'), to_char(ends_on, 'YYYY-MM-DD'), terms, status, COALESCE(replaced_by, 0), released_at`
+
+// ContractStore handles operations on the contracts table of the encapsulated
+// datastore. Expired contracts release their players from the roster.
+type ContractStore struct {
+	db  *database.DB
+	ids store.IDGenerator
+}
+
+func New(db *database.DB, ids store.IDGenerator) *ContractStore {
+	return &ContractStore{
+		db:  db,
+		ids: ids,
+	}
+}
+
+// Sign creates a signed contract of the player with its roster. The contract
+// id is generated. Returns the created contract, store.ErrNotFound if the
+// player does not exist, a *store.ConflictError if the player is not a member
+// of the roster or an error wrapping store.ErrExists if the player has a
+// current contract.
+func (cs *ContractStore) Sign(ctx context.Context, c store.Contract) (*store.Contract, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := sign(ctx, tx, cs.ids.Next(), c)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("player_id", c.PlayerID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+func sign(ctx context.Context, tx *sql.Tx, contractID uint64, c store.Contract) (*store.Contract, error) {
+	// the player is locked, so that it can not be moved to another roster
+	// while signing
+	var rosterID uint64
+	var status string
+	err := tx.QueryRowContext(ctx, `
+  SELECT roster_id, status
+  FROM players
+  WHERE id = $1
+  FOR UPDATE`, c.PlayerID).
+		Scan(&rosterID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rosterID != c.RosterID || status == store.Released {
+		return nil, &store.ConflictError{RosterID: c.RosterID, Reason: fmt.Sprintf("player %d is not a member of the roster", c.PlayerID)}
+	}
+
+	terms, err := json.Marshal(c.Terms)
+	if err != nil {
+		return nil, err
+	}
+	if c.Terms == nil {
+		terms = []byte("{}")
+	}
+	signed, err := scanContract(tx.QueryRowContext(ctx, `
+  INSERT INTO contracts(id,player_id,roster_id,starts_on,ends_on,terms)
+  VALUES($1,$2,$3,$4,$5,$6)
+  RETURNING `+columns,
+		contractID,
+		c.PlayerID,
+		c.RosterID,
+		c.StartsOn,
+		c.EndsOn,
+		string(terms)))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, fmt.Errorf("contract of player %d: %w", c.PlayerID, store.ErrExists)
+	}
+	return signed, err
+}
+
+// Get returns the contract with the given id or store.ErrNotFound.
+func (cs *ContractStore) Get(ctx context.Context, contractID uint64) (*store.Contract, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	return scanContract(db.QueryRowContext(ctx, `
+  SELECT `+columns+`
+  FROM contracts
+  WHERE id = $1`, contractID))
+}
+
+// List returns the current contracts of the roster, which are signed or
+// overdue, ordered by their end.
+func (cs *ContractStore) List(ctx context.Context, rosterID uint64) ([]store.Contract, error) {
+	return cs.list(ctx, `
+  SELECT `+columns+`
+  FROM contracts
+  WHERE roster_id = $1
+  AND status IN ('signed', 'overdue')
+  ORDER BY ends_on, id`, rosterID)
+}
+
+// Expiring returns the signed contracts of the roster ending within the given
+// number of days from today and the overdue ones, ordered by their end.
+func (cs *ContractStore) Expiring(ctx context.Context, rosterID uint64, days int) ([]store.Contract, error) {
+	return cs.list(ctx, `
+  SELECT `+columns+`
+  FROM contracts
+  WHERE roster_id = $1
+  AND (status = 'overdue' OR (status = 'signed' AND ends_on < current_date + $2::int))
+  ORDER BY ends_on, id`, rosterID, days)
+}
+
+func (cs *ContractStore) list(ctx context.Context, query string, args ...interface{}) ([]store.Contract, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contracts := make([]store.Contract, 0)
+	for rows.Next() {
+		c, err := scanContract(rows)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return contracts, nil
+}
+
+// Expire releases the players of the contracts that ended before today and
+// of the overdue contracts. Each contract is released in its own transaction.
+// Active players are replaced by a benched player of the roster that keeps
+// the role requirements satisfied; if there is none, the player of a lenient
+// roster is released anyway and the contract of a strict roster is overdue
+// and retried by the next call. Contracts of locked rosters are skipped.
+// Contracts that fail to expire are logged and do not stop the others from
+// expiring. Returns the released and newly overdue contracts along with the
+// errors of the failed contracts, if any.
+func (cs *ContractStore) Expire(ctx context.Context) ([]store.Contract, error) {
+	db := cs.db.GetDB()
+	rctx, cancel := cs.db.RequestContext(ctx)
+	rows, err := db.QueryContext(rctx, `
+  SELECT id
+  FROM contracts
+  WHERE status = 'overdue' OR (status = 'signed' AND ends_on < current_date)
+  ORDER BY ends_on, id`)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			cancel()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	cancel()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	expired := make([]store.Contract, 0)
+	var failed expireErrors
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			failed = append(failed, ctx.Err())
+			break
+		}
+		c, err := cs.expire(ctx, id)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("contract_id", id).Msg("failed to expire contract")
+			failed = append(failed, fmt.Errorf("contract %d: %w", id, err))
+			continue
+		}
+		if c != nil {
+			expired = append(expired, *c)
+		}
+	}
+	if len(failed) > 0 {
+		return expired, failed
+	}
+	return expired, nil
+}
+
+// expireErrors are the errors of the contracts that failed to expire.
+type expireErrors []error
+
+func (e expireErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to expire %d contracts: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// expire releases the contract with the given id. Returns the changed
+// contract or nil if it was left unchanged.
+func (cs *ContractStore) expire(ctx context.Context, contractID uint64) (*store.Contract, error) {
+	db := cs.db.GetDB()
+	ctx, cancel := cs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	c, err := expire(ctx, tx, contractID)
+	if err != nil || c == nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("contract_id", contractID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func expire(ctx context.Context, tx *sql.Tx, contractID uint64) (*store.Contract, error) {
+	// contracts expired by another instance are skipped
+	c, err := scanContract(tx.QueryRowContext(ctx, `
+  SELECT `+columns+`
+  FROM contracts
+  WHERE id = $1
+  AND (status = 'overdue' OR (status = 'signed' AND ends_on < current_date))
+  FOR UPDATE SKIP LOCKED`, contractID))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rosterID uint64
+	var status string
+	err = tx.QueryRowContext(ctx, `
+  SELECT roster_id, status
+  FROM players
+  WHERE id = $1
+  FOR UPDATE`, c.PlayerID).
+		Scan(&rosterID, &status)
+	if err != nil {
+		return nil, err
+	}
+	// players that left the roster are not released again
+	if rosterID != c.RosterID || status == store.Released {
+		return release(ctx, tx, c, 0)
+	}
+
+	var mode string
+	var locked bool
+	err = tx.QueryRowContext(ctx, `
+  SELECT rosters.compliance, EXISTS(
+    SELECT 1
+    FROM roster_locks
+    WHERE roster_id = rosters.id
+    AND starts_at <= now()
+    AND (ends_at IS NULL OR ends_at > now()))
+  FROM rosters
+  WHERE rosters.id = $1`, c.RosterID).
+		Scan(&mode, &locked)
+	if err != nil || locked {
+		return nil, err
+	}
+
+	if status == store.Active {
+		replacementID, err := replace(ctx, tx, c)
+		if err != nil {
+			return nil, err
+		}
+		if replacementID != 0 {
+			return release(ctx, tx, c, replacementID)
+		}
+	}
+	// lenient rosters let active players depart without replacement
+	if status == store.Benched || mode == store.Lenient {
+		if _, err := tx.ExecContext(ctx, `UPDATE players SET status = $2 WHERE id = $1`, c.PlayerID, store.Released); err != nil {
+			return nil, err
+		}
+		return release(ctx, tx, c, 0)
+	}
+	if c.Status == store.ContractOverdue {
+		return nil, nil
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE contracts SET status = $2 WHERE id = $1`, c.ContractID, store.ContractOverdue)
+	if err != nil {
+		return nil, err
+	}
+	c.Status = store.ContractOverdue
+	return c, nil
+}
+
+// replace releases the active player of the contract in favor of the first
+// benched player of the roster that keeps the role requirements of the roster
+// satisfied. Benched players with ended contracts and unavailable ones are
+// passed over. Returns the id of the activated player or 0 if no benched
+// player can replace the player, in which case the player stays active.
+func replace(ctx context.Context, tx *sql.Tx, c *store.Contract) (uint64, error) {
+	rows, err := tx.QueryContext(ctx, `
+  SELECT p.id
+  FROM players AS p
+  WHERE p.roster_id = $1
+  AND p.status = 'benched'
+  AND NOT EXISTS (
+    SELECT 1
+    FROM contracts
+    WHERE player_id = p.id
+    AND (status = 'overdue' OR (status = 'signed' AND ends_on < current_date)))
+  AND NOT EXISTS (SELECT 1 FROM unavailable_players WHERE player_id = p.id)
+  ORDER BY p.id
+  FOR UPDATE OF p`, c.RosterID)
+	if err != nil {
+		return 0, err
+	}
+	var candidates []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		candidates = append(candidates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range candidates {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT replacement`); err != nil {
+			return 0, err
+		}
+		_, err := tx.ExecContext(ctx, `
+  UPDATE players
+  SET status = CASE WHEN id = $1 THEN $3 ELSE $4 END
+  WHERE id IN ($1, $2)`, c.PlayerID, id, store.Released, store.Active)
+		if err != nil {
+			return 0, err
+		}
+		err = role.Check(ctx, tx, c.RosterID)
+		var roleErr *store.RoleError
+		if errors.As(err, &roleErr) {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT replacement`); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	return 0, nil
+}
+
+// release marks the contract as released, replaced by the given player if not
+// 0.
+func release(ctx context.Context, tx *sql.Tx, c *store.Contract, replacementID uint64) (*store.Contract, error) {
+	return scanContract(tx.QueryRowContext(ctx, `
+  UPDATE contracts
+  SET status = $2, replaced_by = NULLIF($3, CAST(0 AS BIGINT)), released_at = now()
+  WHERE id = $1
+  RETURNING `+columns, c.ContractID, store.ContractReleased, replacementID))
+}
+
+// scanner scans a single row.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanContract scans the columns of a contract. Returns store.ErrNotFound if
+// there is no row.
+func scanContract(s scanner) (*store.Contract, error) {
+	var c store.Contract
+	var terms []byte
+	var releasedAt pq.NullTime
+	err := s.Scan(
+		&c.ContractID,
+		&c.PlayerID,
+		&c.RosterID,
+		&c.StartsOn,
+		&c.EndsOn,
+		&terms,
+		&c.Status,
+		&c.ReplacementID,
+		&releasedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(terms, &c.Terms); err != nil {
+		return nil, err
+	}
+	if len(c.Terms) == 0 {
+		c.Terms = nil
+	}
+	if releasedAt.Valid {
+		c.ReleasedAt = &releasedAt.Time
+	}
+	return &c, nil
+}
@@ -0,0 +1,229 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+var contractColumns = []string{"id", "player_id", "roster_id", "starts_on", "ends_on", "terms", "status", "replaced_by", "released_at"}
+
+var rosterColumns = []string{"compliance", "exists"}
+
+var roleColumns = []string{"roster_id", "role", "count", "active"}
+
+func TestSign(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id, status FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(3, store.Active))
+	mock.ExpectQuery(`INSERT INTO contracts(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(1, 2, 3, "2020-01-01", "2020-12-31", `{"salary":1000}`).
+		WillReturnRows(sqlmock.NewRows(contractColumns).
+			AddRow(1, 2, 3, "2020-01-01", "2020-12-31", `{"salary":1000}`, store.ContractSigned, 0, nil))
+	mock.ExpectCommit()
+	// player 4 is a member of another roster
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id, status FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(5, store.Benched))
+	mock.ExpectRollback()
+	// player 6 has a current contract
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id, status FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(6).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(3, store.Benched))
+	mock.ExpectQuery(`INSERT INTO contracts(.*)VALUES(.*)RETURNING (.+)`).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+
+	cs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	ctx := context.Background()
+	got, err := cs.Sign(ctx, store.Contract{PlayerID: 2, RosterID: 3, StartsOn: "2020-01-01", EndsOn: "2020-12-31", Terms: map[string]interface{}{"salary": 1000}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Contract{ContractID: 1, PlayerID: 2, RosterID: 3, StartsOn: "2020-01-01", EndsOn: "2020-12-31", Terms: map[string]interface{}{"salary": float64(1000)}, Status: store.ContractSigned}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	var conflict *store.ConflictError
+	if _, err := cs.Sign(ctx, store.Contract{PlayerID: 4, RosterID: 3, StartsOn: "2020-01-01", EndsOn: "2020-12-31"}); !errors.As(err, &conflict) {
+		t.Errorf("want conflict error got %v", err)
+	}
+	if _, err := cs.Sign(ctx, store.Contract{PlayerID: 6, RosterID: 3, StartsOn: "2020-01-01", EndsOn: "2020-12-31"}); !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// contract 1 of benched player 11 is released, contract 2 of active player 12
+// is released in favor of benched player 22 as benched player 21 lacks a
+// required role, contract 3 of active player 13 becomes overdue, contract 4
+// is skipped as its roster is locked and active player 15 of contract 5
+// departs from a lenient roster without replacement.
+func TestExpire(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	lockContract := `SELECT (.+) FROM contracts WHERE id = \$1 (.+) FOR UPDATE SKIP LOCKED`
+	lockPlayer := `SELECT roster_id, status FROM players WHERE id = \$1 FOR UPDATE`
+	rosterLocked := `SELECT rosters.compliance, EXISTS\((.+) FROM roster_locks (.+) FROM rosters WHERE rosters.id = \$1`
+	released := sqlmock.NewRows(contractColumns)
+
+	mock.ExpectQuery(`SELECT id FROM contracts WHERE status = 'overdue' OR (.+) ORDER BY ends_on, id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(6).AddRow(2).AddRow(3).AddRow(4).AddRow(5))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(1, 11, 1, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(1, store.Benched))
+	mock.ExpectQuery(rosterLocked).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(rosterColumns).AddRow(store.Strict, false))
+	mock.ExpectExec(`UPDATE players SET status = \$2 WHERE id = \$1`).WithArgs(11, store.Released).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE contracts SET (.+) WHERE id = \$1 RETURNING (.+)`).WithArgs(1, store.ContractReleased, 0).
+		WillReturnRows(released.AddRow(1, 11, 1, "2019-01-01", "2019-12-31", "{}", store.ContractReleased, 0, nil))
+	mock.ExpectCommit()
+
+	// the player of contract 6 is missing, which does not stop the other
+	// contracts from expiring
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(6).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(6, 16, 1, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(16).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(2, 12, 2, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(2, store.Active))
+	mock.ExpectQuery(rosterLocked).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(rosterColumns).AddRow(store.Strict, false))
+	mock.ExpectQuery(`SELECT p.id FROM players AS p WHERE p.roster_id = \$1 AND p.status = 'benched' (.+) FOR UPDATE OF p`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21).AddRow(22))
+	mock.ExpectExec(`SAVEPOINT replacement`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE players SET status = CASE (.+) WHERE id IN \(\$1, \$2\)`).WithArgs(12, 21, store.Released, store.Active).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r`).WithArgs(pq.Array([]int64{2})).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(2, "igl", 1, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT replacement`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT replacement`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE players SET status = CASE (.+) WHERE id IN \(\$1, \$2\)`).WithArgs(12, 22, store.Released, store.Active).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r`).WithArgs(pq.Array([]int64{2})).
+		WillReturnRows(sqlmock.NewRows(roleColumns))
+	mock.ExpectQuery(`UPDATE contracts SET (.+) WHERE id = \$1 RETURNING (.+)`).WithArgs(2, store.ContractReleased, 22).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(2, 12, 2, "2019-01-01", "2019-12-31", "{}", store.ContractReleased, 22, nil))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(3, 13, 3, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(13).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(3, store.Active))
+	mock.ExpectQuery(rosterLocked).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(rosterColumns).AddRow(store.Strict, false))
+	mock.ExpectQuery(`SELECT p.id FROM players AS p`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE contracts SET status = \$2 WHERE id = \$1`).WithArgs(3, store.ContractOverdue).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(4, 14, 4, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(14).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(4, store.Active))
+	mock.ExpectQuery(rosterLocked).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(rosterColumns).AddRow(store.Strict, true))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockContract).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(5, 15, 5, "2019-01-01", "2019-12-31", "{}", store.ContractSigned, 0, nil))
+	mock.ExpectQuery(lockPlayer).WithArgs(15).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "status"}).AddRow(5, store.Active))
+	mock.ExpectQuery(rosterLocked).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(rosterColumns).AddRow(store.Lenient, false))
+	mock.ExpectQuery(`SELECT p.id FROM players AS p`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE players SET status = \$2 WHERE id = \$1`).WithArgs(15, store.Released).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE contracts SET (.+) WHERE id = \$1 RETURNING (.+)`).WithArgs(5, store.ContractReleased, 0).
+		WillReturnRows(sqlmock.NewRows(contractColumns).AddRow(5, 15, 5, "2019-01-01", "2019-12-31", "{}", store.ContractReleased, 0, nil))
+	mock.ExpectCommit()
+
+	cs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := cs.Expire(context.Background())
+	if want := "failed to expire 1 contracts: contract 6: sql: no rows in result set"; err == nil || err.Error() != want {
+		t.Errorf("want error %q got %v", want, err)
+	}
+	want := []store.Contract{
+		{ContractID: 1, PlayerID: 11, RosterID: 1, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractReleased},
+		{ContractID: 2, PlayerID: 12, RosterID: 2, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractReleased, ReplacementID: 22},
+		{ContractID: 3, PlayerID: 13, RosterID: 3, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractOverdue},
+		{ContractID: 5, PlayerID: 15, RosterID: 5, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractReleased},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// lists signed contracts ending soon along with overdue ones
+func TestExpiring(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM contracts WHERE roster_id = \$1 AND \(status = 'overdue' OR \(status = 'signed' AND ends_on < current_date \+ \$2::int\)\) ORDER BY ends_on, id`).
+		WithArgs(1, 30).
+		WillReturnRows(sqlmock.NewRows(contractColumns).
+			AddRow(3, 13, 1, "2019-01-01", "2019-12-31", "{}", store.ContractOverdue, 0, nil).
+			AddRow(2, 12, 1, "2020-01-01", "2020-01-15", `{"buyout":500}`, store.ContractSigned, 0, nil))
+
+	cs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := cs.Expiring(context.Background(), 1, 30)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Contract{
+		{ContractID: 3, PlayerID: 13, RosterID: 1, StartsOn: "2019-01-01", EndsOn: "2019-12-31", Status: store.ContractOverdue},
+		{ContractID: 2, PlayerID: 12, RosterID: 1, StartsOn: "2020-01-01", EndsOn: "2020-01-15", Terms: map[string]interface{}{"buyout": float64(500)}, Status: store.ContractSigned},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
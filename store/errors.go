@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a requested resource does not exist in the
+// datastore.
+var ErrNotFound = errors.New("not found")
+
+// ErrExists is returned when a resource can not be created because a resource
+// with the same unique attributes exists in the datastore.
+var ErrExists = errors.New("already exists")
+
+// ConflictError is returned when a change can not be applied because it
+// conflicts with the current state of a roster. Players holds the current
+// state of the players involved in the change, if known.
+type ConflictError struct {
+	RosterID uint64
+	Reason   string
+	Players  []Player
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("roster %d: %s", e.RosterID, e.Reason)
+}
+
+// LockedError is returned when the players of a roster can not be changed
+// because the roster is locked.
+type LockedError struct {
+	RosterID uint64
+	Lock     Lock
+}
+
+func (e *LockedError) Error() string {
+	if e.Lock.EndsAt == nil {
+		return fmt.Sprintf("roster %d is locked until it is unlocked", e.RosterID)
+	}
+	return fmt.Sprintf("roster %d is locked until %s", e.RosterID, e.Lock.EndsAt.UTC().Format(time.RFC3339))
+}
+
+// RoleError is returned when the active players of a roster would not satisfy
+// one of its role requirements. Active is the number of active players that
+// would have the required role.
+type RoleError struct {
+	RosterID    uint64
+	Requirement RoleRequirement
+	Active      int
+}
+
+func (e *RoleError) Error() string {
+	return fmt.Sprintf("roster %d requires %d active %s players, not %d", e.RosterID, e.Requirement.Count, e.Requirement.Role, e.Active)
+}
@@ -0,0 +1,210 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+const foreignKeyViolation = "23503"
+
+// LockStore handles operations on the roster_locks and lock_overrides tables
+// of the encapsulated datastore.
+type LockStore struct {
+	db  *database.DB
+	ids store.IDGenerator
+}
+
+func New(db *database.DB, ids store.IDGenerator) *LockStore {
+	return &LockStore{
+		db:  db,
+		ids: ids,
+	}
+}
+
+// Create inserts a new lock of a roster. Locks without start start now, locks
+// without end last until the roster is unlocked. Returns the created lock or
+// store.ErrNotFound if the roster does not exist.
+func (ls *LockStore) Create(ctx context.Context, lock store.Lock) (*store.Lock, error) {
+	query := `
+  INSERT INTO roster_locks(id,roster_id,starts_at,ends_at,reason)
+  VALUES($1,$2,COALESCE($3,now()),$4,$5)
+  RETURNING id, roster_id, starts_at, ends_at, reason`
+
+	db := ls.db.GetDB()
+	ctx, cancel := ls.db.RequestContext(ctx)
+	defer cancel()
+
+	var startsAt *time.Time
+	if !lock.StartsAt.IsZero() {
+		startsAt = &lock.StartsAt
+	}
+	l, err := scanLock(db.QueryRowContext(ctx, query,
+		ls.ids.Next(),
+		lock.RosterID,
+		startsAt,
+		lock.EndsAt,
+		lock.Reason))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+// List returns the current and upcoming locks of the roster ordered by start.
+func (ls *LockStore) List(ctx context.Context, rosterID uint64) ([]store.Lock, error) {
+	query := `
+  SELECT id, roster_id, starts_at, ends_at, reason
+  FROM roster_locks
+  WHERE roster_id = $1
+  AND (ends_at IS NULL OR ends_at > now())
+  ORDER BY starts_at, id`
+
+	db := ls.db.GetDB()
+	ctx, cancel := ls.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := make([]store.Lock, 0)
+	for rows.Next() {
+		l, err := scanLock(rows)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, *l)
+	}
+	return locks, rows.Err()
+}
+
+// Delete deletes the lock with the given id of the roster or returns
+// store.ErrNotFound.
+func (ls *LockStore) Delete(ctx context.Context, rosterID, lockID uint64) error {
+	query := `
+  DELETE FROM roster_locks
+  WHERE id = $1
+  AND roster_id = $2`
+
+	db := ls.db.GetDB()
+	ctx, cancel := ls.db.RequestContext(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, lockID, rosterID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Unlock ends the current locks of the roster now. Upcoming locks are kept.
+// Returns the ended locks.
+func (ls *LockStore) Unlock(ctx context.Context, rosterID uint64) ([]store.Lock, error) {
+	query := `
+  UPDATE roster_locks
+  SET ends_at = now()
+  WHERE roster_id = $1
+  AND starts_at < now()
+  AND (ends_at IS NULL OR ends_at > now())
+  RETURNING id, roster_id, starts_at, ends_at, reason`
+
+	db := ls.db.GetDB()
+	ctx, cancel := ls.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := make([]store.Lock, 0)
+	for rows.Next() {
+		l, err := scanLock(rows)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, *l)
+	}
+	return locks, rows.Err()
+}
+
+// Overrides returns the recorded overrides of locks of the roster, latest
+// first.
+func (ls *LockStore) Overrides(ctx context.Context, rosterID uint64) ([]store.Override, error) {
+	query := `
+  SELECT id, lock_id, roster_id, subject, reason, created_at
+  FROM lock_overrides
+  WHERE roster_id = $1
+  ORDER BY created_at DESC, id DESC`
+
+	db := ls.db.GetDB()
+	ctx, cancel := ls.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make([]store.Override, 0)
+	for rows.Next() {
+		var o store.Override
+		if err := rows.Scan(
+			&o.OverrideID,
+			&o.LockID,
+			&o.RosterID,
+			&o.Subject,
+			&o.Reason,
+			&o.CreatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLock(s scanner) (*store.Lock, error) {
+	var l store.Lock
+	var endsAt pq.NullTime
+	if err := s.Scan(
+		&l.LockID,
+		&l.RosterID,
+		&l.StartsAt,
+		&endsAt,
+		&l.Reason); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	if endsAt.Valid {
+		l.EndsAt = &endsAt.Time
+	}
+	return &l, nil
+}
@@ -0,0 +1,182 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+var lockColumns = []string{"id", "roster_id", "starts_at", "ends_at", "reason"}
+
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(time.Hour)
+
+	// manual locks start now and have no end
+	mock.ExpectQuery(`INSERT INTO roster_locks(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(1, 2, nil, nil, "match").
+		WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(1, 2, now, nil, "match"))
+	mock.ExpectQuery(`INSERT INTO roster_locks(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(2, 2, now, end, "").
+		WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(2, 2, now, end, ""))
+	mock.ExpectQuery(`INSERT INTO roster_locks(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(3, 3, nil, nil, "").
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+
+	ls := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ls.Create(context.Background(), store.Lock{RosterID: 2, Reason: "match"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Lock{LockID: 1, RosterID: 2, StartsAt: now, Reason: "match"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	got, err = ls.Create(context.Background(), store.Lock{RosterID: 2, StartsAt: now, EndsAt: &end})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = &store.Lock{LockID: 2, RosterID: 2, StartsAt: now, EndsAt: &end}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	if _, err := ls.Create(context.Background(), store.Lock{RosterID: 3}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(time.Hour)
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = \$1 AND \(ends_at IS NULL OR ends_at > now\(\)\)`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(lockColumns).
+			AddRow(1, 2, now, end, "match").
+			AddRow(2, 2, end, nil, ""))
+
+	ls := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ls.List(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Lock{
+		{LockID: 1, RosterID: 2, StartsAt: now, EndsAt: &end, Reason: "match"},
+		{LockID: 2, RosterID: 2, StartsAt: end},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`DELETE FROM roster_locks WHERE id = \$1 AND roster_id = \$2`).
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM roster_locks WHERE id = \$1 AND roster_id = \$2`).
+		WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ls := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	if err := ls.Delete(context.Background(), 2, 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ls.Delete(context.Background(), 3, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(time.Minute)
+	mock.ExpectQuery(`UPDATE roster_locks SET ends_at = now\(\) WHERE roster_id = \$1 (.+) RETURNING (.+)`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(1, 2, now, end, "match"))
+
+	ls := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ls.Unlock(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Lock{{LockID: 1, RosterID: 2, StartsAt: now, EndsAt: &end, Reason: "match"}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM lock_overrides WHERE roster_id = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lock_id", "roster_id", "subject", "reason", "created_at"}).
+			AddRow(3, 1, 2, "admin", "injury", now))
+
+	ls := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ls.Overrides(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Override{{OverrideID: 3, LockID: 1, RosterID: 2, Subject: "admin", Reason: "injury", CreatedAt: now}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
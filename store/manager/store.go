@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+const foreignKeyViolation = "23503"
+
+// ManagerStore handles operations on the roster_managers table of the
+// encapsulated datastore. Managers are identified by the subject of their API
+// key.
+type ManagerStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *ManagerStore {
+	return &ManagerStore{
+		db: db,
+	}
+}
+
+// List returns the managers of the roster ordered by subject.
+func (ms *ManagerStore) List(ctx context.Context, rosterID uint64) ([]string, error) {
+	query := `
+  SELECT subject
+  FROM roster_managers
+  WHERE roster_id = $1
+  ORDER BY subject`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]string, 0)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, s)
+	}
+	return subjects, rows.Err()
+}
+
+// Add makes the subject a manager of the roster. Adding a manager twice has
+// no effect. Returns store.ErrNotFound if the roster does not exist.
+func (ms *ManagerStore) Add(ctx context.Context, rosterID uint64, subject string) error {
+	query := `
+  INSERT INTO roster_managers(roster_id,subject)
+  VALUES($1,$2)
+  ON CONFLICT DO NOTHING`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, query, rosterID, subject); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return store.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove removes the subject from the managers of the roster or returns
+// store.ErrNotFound.
+func (ms *ManagerStore) Remove(ctx context.Context, rosterID uint64, subject string) error {
+	query := `
+  DELETE FROM roster_managers
+  WHERE roster_id = $1
+  AND subject = $2`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, rosterID, subject)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Manages reports whether the subject manages the roster, either directly or
+// as a manager of the organization of the roster.
+func (ms *ManagerStore) Manages(ctx context.Context, subject string, rosterID uint64) (bool, error) {
+	query := `
+  SELECT EXISTS(
+    SELECT 1
+    FROM roster_managers
+    WHERE roster_id = $1
+    AND subject = $2
+  ) OR EXISTS(
+    SELECT 1
+    FROM org_managers AS m
+    INNER JOIN rosters ON rosters.org_id = m.org_id
+    WHERE rosters.id = $1
+    AND m.subject = $2)`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	var ok bool
+	err := db.QueryRowContext(ctx, query, rosterID, subject).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+func TestManagers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO roster_managers(.*)VALUES(.*)ON CONFLICT DO NOTHING`).
+		WithArgs(1, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO roster_managers(.*)VALUES(.*)ON CONFLICT DO NOTHING`).
+		WithArgs(2, "alice").
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+	mock.ExpectQuery(`SELECT subject FROM roster_managers WHERE roster_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"subject"}).AddRow("alice").AddRow("bob"))
+	mock.ExpectQuery(`SELECT EXISTS\((.+)\)`).
+		WithArgs(1, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`DELETE FROM roster_managers WHERE roster_id = \$1 AND subject = \$2`).
+		WithArgs(1, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM roster_managers WHERE roster_id = \$1 AND subject = \$2`).
+		WithArgs(1, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	ms := New(database.New(db, "mock-db", 0))
+	if err := ms.Add(ctx, 1, "alice"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ms.Add(ctx, 2, "alice"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	got, err := ms.List(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+	ok, err := ms.Manages(ctx, "alice", 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !ok {
+		t.Error("want alice to manage roster 1")
+	}
+	if err := ms.Remove(ctx, 1, "alice"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ms.Remove(ctx, 1, "alice"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
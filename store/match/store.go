@@ -0,0 +1,430 @@
+package match
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// MatchStore handles operations on the matches and lineups tables of the
+// encapsulated datastore.
+type MatchStore struct {
+	db  *database.DB
+	ids store.IDGenerator
+}
+
+func New(db *database.DB, ids store.IDGenerator) *MatchStore {
+	return &MatchStore{
+		db:  db,
+		ids: ids,
+	}
+}
+
+// Create schedules a new match. Matches without lineup deadline must submit
+// lineups until the scheduled time. Returns the created match or
+// store.ErrNotFound if one of the rosters does not exist.
+func (ms *MatchStore) Create(ctx context.Context, match store.Match) (*store.Match, error) {
+	query := `
+  INSERT INTO matches(id,roster_id,opponent_id,scheduled_at,lineup_deadline,status)
+  VALUES($1,$2,$3,$4,$5,$6)
+  RETURNING id, roster_id, opponent_id, scheduled_at, lineup_deadline, status`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	deadline := match.LineupDeadline
+	if deadline.IsZero() {
+		deadline = match.ScheduledAt
+	}
+	m, err := scanMatch(db.QueryRowContext(ctx, query,
+		ms.ids.Next(),
+		match.RosterID,
+		match.OpponentID,
+		match.ScheduledAt,
+		deadline,
+		store.MatchScheduled))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// Get returns the match with the given id or store.ErrNotFound.
+func (ms *MatchStore) Get(ctx context.Context, matchID uint64) (*store.Match, error) {
+	query := `
+  SELECT id, roster_id, opponent_id, scheduled_at, lineup_deadline, status
+  FROM matches
+  WHERE id = $1`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	return scanMatch(db.QueryRowContext(ctx, query, matchID))
+}
+
+// List returns the matches the roster plays in, ordered by scheduled time.
+func (ms *MatchStore) List(ctx context.Context, rosterID uint64) ([]store.Match, error) {
+	query := `
+  SELECT id, roster_id, opponent_id, scheduled_at, lineup_deadline, status
+  FROM matches
+  WHERE roster_id = $1 OR opponent_id = $1
+  ORDER BY scheduled_at, id`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]store.Match, 0)
+	for rows.Next() {
+		m, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *m)
+	}
+	return matches, rows.Err()
+}
+
+// ListRosters returns the matches any of the rosters plays in, ordered by
+// scheduled time.
+func (ms *MatchStore) ListRosters(ctx context.Context, rosterIDs []uint64) ([]store.Match, error) {
+	query := `
+  SELECT id, roster_id, opponent_id, scheduled_at, lineup_deadline, status
+  FROM matches
+  WHERE roster_id = ANY($1) OR opponent_id = ANY($1)
+  ORDER BY scheduled_at, id`
+
+	ids := make([]int64, len(rosterIDs))
+	for i, id := range rosterIDs {
+		ids[i] = int64(id)
+	}
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]store.Match, 0)
+	for rows.Next() {
+		m, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *m)
+	}
+	return matches, rows.Err()
+}
+
+// SetStatus changes the status of the match if its current status is one of
+// from. Returns the updated match, store.ErrNotFound or a
+// *store.ConflictError if the match has a different status.
+func (ms *MatchStore) SetStatus(ctx context.Context, matchID uint64, status string, from ...string) (*store.Match, error) {
+	query := `
+  UPDATE matches
+  SET status = $2
+  WHERE id = $1
+  AND status = ANY($3)
+  RETURNING id, roster_id, opponent_id, scheduled_at, lineup_deadline, status`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	m, err := scanMatch(db.QueryRowContext(ctx, query, matchID, status, pq.Array(from)))
+	if !errors.Is(err, store.ErrNotFound) {
+		return m, err
+	}
+	// the match does not exist or has a different status
+	m, err = ms.Get(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &store.ConflictError{
+		RosterID: m.RosterID,
+		Reason:   fmt.Sprintf("match %d is %s and can not be %s", matchID, m.Status, status),
+	}
+}
+
+// SubmitLineup stores the active players of the roster of the lineup as the
+// final lineup of the roster for the match, the given players are ignored.
+// Returns the submitted lineup, store.ErrNotFound if the match does not exist,
+// an error wrapping store.ErrExists if the roster has submitted its lineup
+// already or a *store.ConflictError if the roster does not play the match or
+// the submission is closed.
+func (ms *MatchStore) SubmitLineup(ctx context.Context, lineup store.Lineup) (*store.Lineup, error) {
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rollback := func() {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed rollback transaction")
+		}
+	}
+
+	// the deadline is compared to the time of the datastore so that all
+	// instances of the service agree on it
+	var rosterID, opponentID uint64
+	var status string
+	var closed bool
+	err = tx.QueryRowContext(ctx, `
+  SELECT roster_id, opponent_id, status, lineup_deadline < now()
+  FROM matches
+  WHERE id = $1
+  FOR SHARE`, lineup.MatchID).
+		Scan(&rosterID, &opponentID, &status, &closed)
+	if err != nil {
+		rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	var reason string
+	switch {
+	case lineup.RosterID != rosterID && lineup.RosterID != opponentID:
+		reason = fmt.Sprintf("roster does not play match %d", lineup.MatchID)
+	case status != store.MatchScheduled:
+		reason = fmt.Sprintf("match %d is %s", lineup.MatchID, status)
+	case closed:
+		reason = fmt.Sprintf("lineup deadline of match %d has passed", lineup.MatchID)
+	}
+	if reason != "" {
+		rollback()
+		return nil, &store.ConflictError{RosterID: lineup.RosterID, Reason: reason}
+	}
+
+	submitted := store.Lineup{
+		MatchID:  lineup.MatchID,
+		RosterID: lineup.RosterID,
+		Players:  make([]store.Player, 0, store.ActiveSize),
+	}
+	err = tx.QueryRowContext(ctx, `
+  INSERT INTO lineups(match_id,roster_id)
+  VALUES($1,$2)
+  RETURNING submitted_at`, lineup.MatchID, lineup.RosterID).
+		Scan(&submitted.SubmittedAt)
+	if err != nil {
+		rollback()
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("lineup of roster %d for match %d: %w", lineup.RosterID, lineup.MatchID, store.ErrExists)
+		}
+		return nil, err
+	}
+
+	// the active players are taken within the transaction, so that the
+	// lineup is a consistent snapshot of the roster at the time of submission
+	rows, err := tx.QueryContext(ctx, `
+  INSERT INTO lineup_players(match_id,roster_id,player_id,first_name,last_name,alias)
+  SELECT $1, roster_id, id, first_name, last_name, alias
+  FROM players
+  WHERE roster_id = $2
+  AND status = 'active'
+  ORDER BY id
+  RETURNING player_id, first_name, last_name, alias`, lineup.MatchID, lineup.RosterID)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	for rows.Next() {
+		p := store.Player{RosterID: lineup.RosterID, Status: store.Active}
+		if err := rows.Scan(&p.PlayerID, &p.FirstName, &p.LastName, &p.Alias); err != nil {
+			rows.Close()
+			rollback()
+			return nil, err
+		}
+		submitted.Players = append(submitted.Players, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &submitted, nil
+}
+
+// Lineups returns the submitted lineups of the match ordered by submission.
+// Returns store.ErrNotFound if the match does not exist.
+func (ms *MatchStore) Lineups(ctx context.Context, matchID uint64) ([]store.Lineup, error) {
+	query := `
+  SELECT
+    m.id,
+    l.roster_id,
+    l.submitted_at,
+    p.player_id,
+    p.first_name,
+    p.last_name,
+    p.alias
+  FROM matches AS m
+  LEFT JOIN lineups AS l ON l.match_id = m.id
+  LEFT JOIN lineup_players AS p ON p.match_id = l.match_id AND p.roster_id = l.roster_id
+  WHERE m.id = $1
+  ORDER BY l.submitted_at, l.roster_id, p.player_id`
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// matches without lineups are returned once without lineup columns
+	lineups, found, err := scanLineups(rows)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+	return lineups, nil
+}
+
+// ListLineups returns the submitted lineups of the matches ordered by match
+// and submission.
+func (ms *MatchStore) ListLineups(ctx context.Context, matchIDs []uint64) ([]store.Lineup, error) {
+	query := `
+  SELECT
+    l.match_id,
+    l.roster_id,
+    l.submitted_at,
+    p.player_id,
+    p.first_name,
+    p.last_name,
+    p.alias
+  FROM lineups AS l
+  LEFT JOIN lineup_players AS p ON p.match_id = l.match_id AND p.roster_id = l.roster_id
+  WHERE l.match_id = ANY($1)
+  ORDER BY l.match_id, l.submitted_at, l.roster_id, p.player_id`
+
+	ids := make([]int64, len(matchIDs))
+	for i, id := range matchIDs {
+		ids[i] = int64(id)
+	}
+
+	db := ms.db.GetDB()
+	ctx, cancel := ms.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineups, _, err := scanLineups(rows)
+	return lineups, err
+}
+
+// scanLineups scans the rows of lineups and their players ordered by match,
+// lineup and player. Rows without lineup columns are skipped, found reports
+// whether there were any rows.
+func scanLineups(rows *sql.Rows) (lineups []store.Lineup, found bool, err error) {
+	lineups = make([]store.Lineup, 0)
+	for rows.Next() {
+		found = true
+		var id uint64
+		var rosterID, playerID sql.NullInt64
+		var submittedAt pq.NullTime
+		var firstName, lastName, alias sql.NullString
+		if err := rows.Scan(
+			&id,
+			&rosterID,
+			&submittedAt,
+			&playerID,
+			&firstName,
+			&lastName,
+			&alias); err != nil {
+			return nil, false, err
+		}
+		if !rosterID.Valid {
+			continue
+		}
+		if n := len(lineups); n == 0 || lineups[n-1].MatchID != id || lineups[n-1].RosterID != uint64(rosterID.Int64) {
+			lineups = append(lineups, store.Lineup{
+				MatchID:     id,
+				RosterID:    uint64(rosterID.Int64),
+				Players:     make([]store.Player, 0, store.ActiveSize),
+				SubmittedAt: submittedAt.Time,
+			})
+		}
+		if playerID.Valid {
+			l := &lineups[len(lineups)-1]
+			l.Players = append(l.Players, store.Player{
+				PlayerID:  uint64(playerID.Int64),
+				RosterID:  l.RosterID,
+				FirstName: firstName.String,
+				LastName:  lastName.String,
+				Alias:     alias.String,
+				Status:    store.Active,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	return lineups, found, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMatch(s scanner) (*store.Match, error) {
+	var m store.Match
+	if err := s.Scan(
+		&m.MatchID,
+		&m.RosterID,
+		&m.OpponentID,
+		&m.ScheduledAt,
+		&m.LineupDeadline,
+		&m.Status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,323 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+var matchColumns = []string{"id", "roster_id", "opponent_id", "scheduled_at", "lineup_deadline", "status"}
+
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	// the lineup deadline defaults to the scheduled time
+	mock.ExpectQuery(`INSERT INTO matches(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(1, 2, 3, at, at, store.MatchScheduled).
+		WillReturnRows(sqlmock.NewRows(matchColumns).AddRow(1, 2, 3, at, at, store.MatchScheduled))
+	mock.ExpectQuery(`INSERT INTO matches(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(2, 2, 4, at, at, store.MatchScheduled).
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+
+	ms := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ms.Create(context.Background(), store.Match{RosterID: 2, OpponentID: 3, ScheduledAt: at})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Match{MatchID: 1, RosterID: 2, OpponentID: 3, ScheduledAt: at, LineupDeadline: at, Status: store.MatchScheduled}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	if _, err := ms.Create(context.Background(), store.Match{RosterID: 2, OpponentID: 4, ScheduledAt: at}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`UPDATE matches SET status = \$2 WHERE id = \$1 AND status = ANY\(\$3\) RETURNING (.+)`).
+		WithArgs(1, store.MatchLive, pq.Array([]string{store.MatchScheduled})).
+		WillReturnRows(sqlmock.NewRows(matchColumns).AddRow(1, 2, 3, at, at, store.MatchLive))
+	// the match is completed already
+	mock.ExpectQuery(`UPDATE matches SET status = \$2 WHERE id = \$1 AND status = ANY\(\$3\) RETURNING (.+)`).
+		WithArgs(1, store.MatchLive, pq.Array([]string{store.MatchScheduled})).
+		WillReturnRows(sqlmock.NewRows(matchColumns))
+	mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(matchColumns).AddRow(1, 2, 3, at, at, store.MatchCompleted))
+
+	ms := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ms.SetStatus(context.Background(), 1, store.MatchLive, store.MatchScheduled)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := store.MatchLive; got.Status != want {
+		t.Errorf("want status %s got %s", want, got.Status)
+	}
+
+	_, err = ms.SetStatus(context.Background(), 1, store.MatchLive, store.MatchScheduled)
+	var conflict *store.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error got %v", err)
+	}
+	if want := "match 1 is completed and can not be live"; conflict.Reason != want {
+		t.Errorf("want reason %q got %q", want, conflict.Reason)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSubmitLineup(t *testing.T) {
+	at := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+	players := []store.Player{
+		{PlayerID: 1, RosterID: 2, FirstName: "a", LastName: "b", Alias: "ab", Status: store.Active},
+		{PlayerID: 3, RosterID: 2, FirstName: "c", LastName: "d", Alias: "cd", Status: store.Active},
+	}
+	tests := []struct {
+		d string                // description of test case
+		m func(sqlmock.Sqlmock) // mock expectations
+		l store.Lineup          // lineup to submit
+		r *store.Lineup         // expected result
+		e error                 // expected error
+		c string                // expected conflict reason
+	}{
+		{
+			d: "expect lineup to be submitted",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT roster_id, opponent_id, status, lineup_deadline < now\(\) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}).AddRow(4, 2, store.MatchScheduled, false))
+				mock.ExpectQuery(`INSERT INTO lineups(.*)VALUES(.*)RETURNING submitted_at`).
+					WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"submitted_at"}).AddRow(at))
+				mock.ExpectQuery(`INSERT INTO lineup_players(.*) SELECT (.+) FROM players WHERE roster_id = \$2 AND status = 'active' ORDER BY id RETURNING (.+)`).
+					WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"player_id", "first_name", "last_name", "alias"}).
+						AddRow(1, "a", "b", "ab").
+						AddRow(3, "c", "d", "cd"))
+				mock.ExpectCommit()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			r: &store.Lineup{MatchID: 1, RosterID: 2, Players: players, SubmittedAt: at},
+		},
+		{
+			d: "expect missing match to result in not found",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}))
+				mock.ExpectRollback()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			e: store.ErrNotFound,
+		},
+		{
+			d: "expect lineup of other roster to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}).AddRow(4, 5, store.MatchScheduled, false))
+				mock.ExpectRollback()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			c: "roster does not play match 1",
+		},
+		{
+			d: "expect lineup after deadline to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}).AddRow(2, 4, store.MatchScheduled, true))
+				mock.ExpectRollback()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			c: "lineup deadline of match 1 has passed",
+		},
+		{
+			d: "expect lineup of cancelled match to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}).AddRow(2, 4, store.MatchCancelled, false))
+				mock.ExpectRollback()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			c: "match 1 is cancelled",
+		},
+		{
+			d: "expect resubmission to result in exists",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT (.+) FROM matches WHERE id = \$1 FOR SHARE`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id", "opponent_id", "status", "closed"}).AddRow(2, 4, store.MatchScheduled, false))
+				mock.ExpectQuery(`INSERT INTO lineups(.*)VALUES(.*)RETURNING submitted_at`).
+					WithArgs(1, 2).
+					WillReturnError(&pq.Error{Code: uniqueViolation})
+				mock.ExpectRollback()
+			},
+			l: store.Lineup{MatchID: 1, RosterID: 2},
+			e: store.ErrExists,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer db.Close()
+			tt.m(mock)
+
+			ms := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+			got, err := ms.SubmitLineup(context.Background(), tt.l)
+			switch {
+			case tt.c != "":
+				var conflict *store.ConflictError
+				if !errors.As(err, &conflict) || conflict.Reason != tt.c {
+					t.Errorf("want conflict %q got %v", tt.c, err)
+				}
+			case !errors.Is(err, tt.e):
+				t.Errorf("want error %v got %v", tt.e, err)
+			}
+			if !reflect.DeepEqual(tt.r, got) {
+				t.Errorf("want\n%+v\ngot\n%+v", tt.r, got)
+			}
+			// we make sure that all expectations were met
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestLineups(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2030, 1, 1, 17, 0, 0, 0, time.UTC)
+	columns := []string{"id", "roster_id", "submitted_at", "player_id", "first_name", "last_name", "alias"}
+	mock.ExpectQuery(`SELECT (.+) FROM matches AS m LEFT JOIN lineups (.+) WHERE m.id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 2, at, 1, "a", "b", "ab").
+			AddRow(1, 2, at, 3, "c", "d", "cd").
+			AddRow(1, 4, at.Add(time.Minute), 5, "e", "f", "ef"))
+	// matches without lineups
+	mock.ExpectQuery(`SELECT (.+) FROM matches AS m LEFT JOIN lineups (.+) WHERE m.id = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, nil, nil, nil, nil, nil, nil))
+	mock.ExpectQuery(`SELECT (.+) FROM matches AS m LEFT JOIN lineups (.+) WHERE m.id = \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	ms := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ms.Lineups(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Lineup{
+		{MatchID: 1, RosterID: 2, SubmittedAt: at, Players: []store.Player{
+			{PlayerID: 1, RosterID: 2, FirstName: "a", LastName: "b", Alias: "ab", Status: store.Active},
+			{PlayerID: 3, RosterID: 2, FirstName: "c", LastName: "d", Alias: "cd", Status: store.Active},
+		}},
+		{MatchID: 1, RosterID: 4, SubmittedAt: at.Add(time.Minute), Players: []store.Player{
+			{PlayerID: 5, RosterID: 4, FirstName: "e", LastName: "f", Alias: "ef", Status: store.Active},
+		}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	got, err = ms.Lineups(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no lineups got %+v", got)
+	}
+
+	if _, err := ms.Lineups(context.Background(), 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// groups the lineups by match, rosters submit lineups for many matches
+func TestListLineups(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2030, 1, 1, 17, 0, 0, 0, time.UTC)
+	columns := []string{"match_id", "roster_id", "submitted_at", "player_id", "first_name", "last_name", "alias"}
+	mock.ExpectQuery(`SELECT (.+) FROM lineups AS l LEFT JOIN lineup_players (.+) WHERE l.match_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{1, 2, 3})).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 2, at, 1, "a", "b", "ab").
+			AddRow(2, 2, at, 1, "a", "b", "ab").
+			AddRow(2, 4, at.Add(time.Minute), 5, "e", "f", "ef"))
+
+	ms := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ms.ListLineups(context.Background(), []uint64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Lineup{
+		{MatchID: 1, RosterID: 2, SubmittedAt: at, Players: []store.Player{
+			{PlayerID: 1, RosterID: 2, FirstName: "a", LastName: "b", Alias: "ab", Status: store.Active},
+		}},
+		{MatchID: 2, RosterID: 2, SubmittedAt: at, Players: []store.Player{
+			{PlayerID: 1, RosterID: 2, FirstName: "a", LastName: "b", Alias: "ab", Status: store.Active},
+		}},
+		{MatchID: 2, RosterID: 4, SubmittedAt: at.Add(time.Minute), Players: []store.Player{
+			{PlayerID: 5, RosterID: 4, FirstName: "e", LastName: "f", Alias: "ef", Status: store.Active},
+		}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
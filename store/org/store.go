@@ -0,0 +1,238 @@
+package org
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// OrgStore handles operations on the organizations and org_managers tables of
+// the encapsulated datastore.
+type OrgStore struct {
+	db  *database.DB
+	ids store.IDGenerator
+}
+
+func New(db *database.DB, ids store.IDGenerator) *OrgStore {
+	return &OrgStore{
+		db:  db,
+		ids: ids,
+	}
+}
+
+// Create inserts a new organization. The id of the given organization is
+// ignored, a new one is generated. Returns the created organization or an
+// error wrapping store.ErrExists if an organization with the same name exists.
+func (s *OrgStore) Create(ctx context.Context, org store.Organization) (*store.Organization, error) {
+	query := `
+  INSERT INTO organizations(id,name)
+  VALUES($1,$2)
+  RETURNING id, name`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	var o store.Organization
+	err := db.QueryRowContext(ctx, query, s.ids.Next(), org.Name).Scan(&o.OrgID, &o.Name)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("organization %s: %w", org.Name, store.ErrExists)
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Get returns the organization with the given id or store.ErrNotFound.
+func (s *OrgStore) Get(ctx context.Context, orgID uint64) (*store.Organization, error) {
+	query := `
+  SELECT id, name
+  FROM organizations
+  WHERE id = $1`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	var o store.Organization
+	err := db.QueryRowContext(ctx, query, orgID).Scan(&o.OrgID, &o.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// List returns all organizations ordered by name.
+func (s *OrgStore) List(ctx context.Context) ([]store.Organization, error) {
+	query := `
+  SELECT id, name
+  FROM organizations
+  ORDER BY name`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := make([]store.Organization, 0)
+	for rows.Next() {
+		var o store.Organization
+		if err := rows.Scan(&o.OrgID, &o.Name); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+	return orgs, rows.Err()
+}
+
+// Rosters returns the rosters of the organization ordered by name, without
+// their players. Returns store.ErrNotFound if the organization does not exist.
+func (s *OrgStore) Rosters(ctx context.Context, orgID uint64) ([]store.Roster, error) {
+	if _, err := s.Get(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	query := `
+  SELECT id, name
+  FROM rosters
+  WHERE org_id = $1
+  ORDER BY name`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rosters := make([]store.Roster, 0)
+	for rows.Next() {
+		r := store.Roster{OrgID: orgID}
+		if err := rows.Scan(&r.RosterID, &r.Name); err != nil {
+			return nil, err
+		}
+		rosters = append(rosters, r)
+	}
+	return rosters, rows.Err()
+}
+
+// Managers returns the managers of the organization ordered by subject.
+func (s *OrgStore) Managers(ctx context.Context, orgID uint64) ([]string, error) {
+	query := `
+  SELECT subject
+  FROM org_managers
+  WHERE org_id = $1
+  ORDER BY subject`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]string, 0)
+	for rows.Next() {
+		var subject string
+		if err := rows.Scan(&subject); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
+
+// AddManager makes the subject a manager of the organization and thus of all
+// of its rosters. Adding a manager twice has no effect. Returns
+// store.ErrNotFound if the organization does not exist.
+func (s *OrgStore) AddManager(ctx context.Context, orgID uint64, subject string) error {
+	query := `
+  INSERT INTO org_managers(org_id,subject)
+  VALUES($1,$2)
+  ON CONFLICT DO NOTHING`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, query, orgID, subject); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return store.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveManager removes the subject from the managers of the organization or
+// returns store.ErrNotFound.
+func (s *OrgStore) RemoveManager(ctx context.Context, orgID uint64, subject string) error {
+	query := `
+  DELETE FROM org_managers
+  WHERE org_id = $1
+  AND subject = $2`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, orgID, subject)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Manages reports whether the subject manages the organization.
+func (s *OrgStore) Manages(ctx context.Context, subject string, orgID uint64) (bool, error) {
+	query := `
+  SELECT EXISTS(
+    SELECT 1
+    FROM org_managers
+    WHERE org_id = $1
+    AND subject = $2)`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	var ok bool
+	err := db.QueryRowContext(ctx, query, orgID, subject).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,120 @@
+package org
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO organizations(.*)VALUES(.*)RETURNING id, name`).
+		WithArgs(1, "foo").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery(`INSERT INTO organizations(.*)VALUES(.*)RETURNING id, name`).
+		WithArgs(2, "foo").
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+
+	ctx := context.Background()
+	s := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := s.Create(ctx, store.Organization{OrgID: 7, Name: "foo"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (&store.Organization{OrgID: 1, Name: "foo"}); !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	if _, err := s.Create(ctx, store.Organization{Name: "foo"}); !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRosters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT id, name FROM organizations WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery(`SELECT id, name FROM rosters WHERE org_id = \$1 ORDER BY name`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "cs").AddRow(2, "dota"))
+	mock.ExpectQuery(`SELECT id, name FROM organizations WHERE id = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	ctx := context.Background()
+	s := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := s.Rosters(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Roster{{RosterID: 3, OrgID: 1, Name: "cs"}, {RosterID: 2, OrgID: 1, Name: "dota"}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	if _, err := s.Rosters(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestManagers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO org_managers(.*)VALUES(.*)ON CONFLICT DO NOTHING`).
+		WithArgs(2, "alice").
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+	mock.ExpectQuery(`SELECT EXISTS\((.+)\)`).
+		WithArgs(1, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`DELETE FROM org_managers WHERE org_id = \$1 AND subject = \$2`).
+		WithArgs(1, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	s := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	if err := s.AddManager(ctx, 2, "alice"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	ok, err := s.Manages(ctx, "alice", 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !ok {
+		t.Error("want alice to manage organization 1")
+	}
+	if err := s.RemoveManager(ctx, 1, "bob"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
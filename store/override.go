@@ -0,0 +1,23 @@
+package store
+
+import "context"
+
+// LockOverride permits an admin to change the players of locked rosters.
+// Each override of a lock is recorded along with the subject and the reason.
+type LockOverride struct {
+	Subject string
+	Reason  string
+}
+
+type lockOverrideKey struct{}
+
+// WithLockOverride returns a context permitting changes of locked rosters.
+func WithLockOverride(ctx context.Context, o LockOverride) context.Context {
+	return context.WithValue(ctx, lockOverrideKey{}, o)
+}
+
+// LockOverrideFromContext returns the lock override of the context, if any.
+func LockOverrideFromContext(ctx context.Context) (LockOverride, bool) {
+	o, ok := ctx.Value(lockOverrideKey{}).(LockOverride)
+	return o, ok
+}
@@ -0,0 +1,75 @@
+package player
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+// checkLocks returns a *store.LockedError if one of the rosters is currently
+// locked. Locks are overridden if the context carries a lock override, which
+// is recorded once per lock and transaction: now() is the start of the
+// transaction, so overrides recorded before within the transaction share it.
+func checkLocks(ctx context.Context, tx *sql.Tx, ids store.IDGenerator, rosterIDs ...uint64) error {
+	query := `
+  SELECT id, roster_id, starts_at, ends_at, reason
+  FROM roster_locks
+  WHERE roster_id = ANY($1)
+  AND starts_at <= now()
+  AND (ends_at IS NULL OR ends_at > now())
+  ORDER BY starts_at, id`
+
+	params := make([]int64, len(rosterIDs))
+	for i, id := range rosterIDs {
+		params[i] = int64(id)
+	}
+	rows, err := tx.QueryContext(ctx, query, pq.Array(params))
+	if err != nil {
+		return err
+	}
+	var locks []store.Lock
+	for rows.Next() {
+		var l store.Lock
+		var endsAt pq.NullTime
+		if err := rows.Scan(&l.LockID, &l.RosterID, &l.StartsAt, &endsAt, &l.Reason); err != nil {
+			rows.Close()
+			return err
+		}
+		if endsAt.Valid {
+			l.EndsAt = &endsAt.Time
+		}
+		locks = append(locks, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(locks) == 0 {
+		return nil
+	}
+
+	override, ok := store.LockOverrideFromContext(ctx)
+	if !ok {
+		return &store.LockedError{RosterID: locks[0].RosterID, Lock: locks[0]}
+	}
+	for _, l := range locks {
+		_, err := tx.ExecContext(ctx, `
+  INSERT INTO lock_overrides(id,lock_id,roster_id,subject,reason)
+  SELECT $1,$2,$3,$4,$5
+  WHERE NOT EXISTS (
+    SELECT 1 FROM lock_overrides
+    WHERE roster_id = $3 AND created_at = now() AND lock_id = $2 AND subject = $4
+  )`,
+			ids.Next(),
+			l.LockID,
+			l.RosterID,
+			override.Subject,
+			override.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,31 +2,51 @@ package player
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/fgrimme/patrongg/database"
 	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/profile"
+	"github.com/fgrimme/patrongg/store/role"
+	"github.com/fgrimme/patrongg/store/search"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505" // raised by the alias trigger
+	raiseException      = "P0001" // raised by the roster constraint triggers
+)
+
 // PlayerStore handles operations on the the
 // players table of the encapsulated datastore.
 type PlayerStore struct {
-	db *database.DB
+	db  *database.DB
+	ids store.IDGenerator
 }
 
-func New(db *database.DB) *PlayerStore {
+func New(db *database.DB, ids store.IDGenerator) *PlayerStore {
 	return &PlayerStore{
-		db: db,
+		db:  db,
+		ids: ids,
 	}
 }
 
-// Insert inserts a new player to the datastore. The player id is not inserted
-// and must be created by the datastore. Returns the newly created player with
-// the generated id.
+// Insert inserts a new player to the datastore. The player id of the given
+// player is ignored, a new one is generated. Returns the newly created player
+// with the generated id or an error wrapping store.ErrExists if the alias is
+// taken by a player of the same game title.
 func (ps *PlayerStore) Insert(ctx context.Context, player store.Player) (*store.Player, error) {
 	query := `
-  INSERT INTO players(roster_id,first_name,last_name,alias,status)
-  VALUES($1,$2,$3,$4,$5)
+  INSERT INTO players(id,roster_id,first_name,last_name,alias,status)
+  VALUES($1,$2,$3,$4,$5,$6)
   RETURNING *
   `
 	db := ps.db.GetDB()
@@ -35,6 +55,7 @@ func (ps *PlayerStore) Insert(ctx context.Context, player store.Player) (*store.
 
 	var p store.Player
 	err := db.QueryRowContext(ctx, query,
+		ps.ids.Next(),
 		player.RosterID,
 		player.FirstName,
 		player.LastName,
@@ -48,16 +69,115 @@ func (ps *PlayerStore) Insert(ctx context.Context, player store.Player) (*store.
 			&p.Alias,
 			&p.Status)
 	if err != nil {
+		return nil, storeError(err, player.RosterID)
+	}
+	return &p, nil
+}
+
+// Get returns the player with the given id or store.ErrNotFound.
+func (ps *PlayerStore) Get(ctx context.Context, playerID uint64) (*store.Player, error) {
+	query := `
+  SELECT
+    id, roster_id, first_name, last_name, alias, status,
+    ARRAY(SELECT role FROM player_roles WHERE player_id = players.id ORDER BY role),
+    ` + profile.Columns + `
+  FROM players
+  LEFT JOIN player_profiles AS pp ON pp.player_id = players.id
+  WHERE id = $1`
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	var p store.Player
+	var roles pq.StringArray
+	var sc profile.Scanner
+	err := db.QueryRowContext(ctx, query, playerID).
+		Scan(append([]interface{}{
+			&p.PlayerID,
+			&p.RosterID,
+			&p.FirstName,
+			&p.LastName,
+			&p.Alias,
+			&p.Status,
+			&roles}, sc.Dest()...)...)
+	if err != nil {
+		return nil, storeError(err, 0)
+	}
+	if len(roles) > 0 {
+		p.Roles = roles
+	}
+	if p.Profile, err = sc.Profile(); err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
+// Delete deletes the player with the given id. Returns the deleted player,
+// store.ErrNotFound if the player does not exist or a *store.ConflictError if
+// the strict roster of the player would be left without store.ActiveSize
+// active players.
+func (ps *PlayerStore) Delete(ctx context.Context, playerID uint64) (*store.Player, error) {
+	query := `
+  DELETE FROM players
+  WHERE id = $1
+  RETURNING *`
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	var p store.Player
+	err := db.QueryRowContext(ctx, query, playerID).
+		Scan(
+			&p.PlayerID,
+			&p.RosterID,
+			&p.FirstName,
+			&p.LastName,
+			&p.Alias,
+			&p.Status)
+	if err != nil {
+		return nil, storeError(err, 0)
+	}
+	return &p, nil
+}
+
 // Update updates all non-empty/not-null fields of the given player except for
 // the player_id. Fails if foreign-key constraint roster_id is violated e.g. a
 // roster with the given id does not exists.
-// Returns the updated/patched player.
+// Returns the updated/patched player, store.ErrNotFound if the player does not
+// exist, a *store.ConflictError if the change violates a roster constraint,
+// an error wrapping store.ErrExists if the alias is taken by a player of the
+// same game title or a *store.LockedError if the current or the new roster of
+// the player is locked. A changed alias is kept in the alias history of the
+// player.
 func (ps *PlayerStore) Update(ctx context.Context, player store.Player) (*store.Player, error) {
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ps.UpdateTx(ctx, tx, player)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Interface("player", player).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		return nil, storeError(err, p.RosterID)
+	}
+	return p, nil
+}
+
+// UpdateTx updates the player like Update within the given transaction, so
+// that the update can be combined with changes of other tables. The active
+// player constraints are checked when the transaction is committed.
+func (ps *PlayerStore) UpdateTx(ctx context.Context, tx *sql.Tx, player store.Player) (*store.Player, error) {
 	query := `
   UPDATE players
   SET
@@ -69,12 +189,41 @@ func (ps *PlayerStore) Update(ctx context.Context, player store.Player) (*store.
   WHERE id = $1
   RETURNING *`
 
-	db := ps.db.GetDB()
-	ctx, cancel := ps.db.RequestContext(ctx)
-	defer cancel()
+	// the player is locked until commit, so it can not be moved to a roster
+	// that is not checked for locks
+	var rosterID uint64
+	err := tx.QueryRowContext(ctx, `
+  SELECT roster_id
+  FROM players
+  WHERE id = $1
+  FOR UPDATE`, player.PlayerID).
+		Scan(&rosterID)
+	if err != nil {
+		return nil, storeError(err, player.RosterID)
+	}
+	rosterIDs := []uint64{rosterID}
+	if player.RosterID != 0 && player.RosterID != rosterID {
+		rosterIDs = append(rosterIDs, player.RosterID)
+	}
+	if err := checkLocks(ctx, tx, ps.ids, rosterIDs...); err != nil {
+		return nil, err
+	}
+
+	// the former alias is kept in the history of the player, so that it can
+	// still be looked up
+	if player.Alias != "" {
+		_, err := tx.ExecContext(ctx, `
+  INSERT INTO player_aliases(player_id,alias)
+  SELECT id, alias
+  FROM players
+  WHERE id = $1 AND alias <> $2`, player.PlayerID, player.Alias)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var p store.Player
-	err := db.QueryRowContext(ctx, query,
+	err = tx.QueryRowContext(ctx, query,
 		player.PlayerID,
 		player.RosterID,
 		player.FirstName,
@@ -89,7 +238,7 @@ func (ps *PlayerStore) Update(ctx context.Context, player store.Player) (*store.
 			&p.Alias,
 			&p.Status)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, player.RosterID)
 	}
 	return &p, nil
 }
@@ -101,8 +250,11 @@ func (ps *PlayerStore) Update(ctx context.Context, player store.Player) (*store.
 // the active roster, an active player gets moved to the bench. The function
 // succeeds only if the given player to activate is currently benched, the given
 // player to be benched is currently active and both players are members of the
-// same roster.
-// In case of failure, the transaction is rolled back.
+// same roster. If the roster id of the active player is given, both players
+// must be members of that roster.
+// In case of failure, the transaction is rolled back and a *store.ConflictError
+// holding the current state of both players is returned if the preconditions
+// were not met or a *store.LockedError if the roster is locked.
 // Returns the updated/patched players.
 func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerChange) (*store.PlayerChange, error) {
 	query := `
@@ -115,6 +267,7 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
     FROM players
     WHERE id = $4
   )
+  AND (CAST($5 AS BIGINT) = 0 OR roster_id = $5)
   RETURNING *`
 
 	db := ps.db.GetDB()
@@ -147,7 +300,8 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
 		"active", // new status
 		players.Benched.PlayerID,
 		"benched", // needs to be benched currently
-		players.Active.PlayerID).
+		players.Active.PlayerID,
+		players.Active.RosterID).
 		Scan(
 			&playerID,
 			&rosterID,
@@ -159,7 +313,7 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
 		if err := tx.Rollback(); err != nil {
 			log.Ctx(ctx).Error().Err(err).Interface("players", players).Msg("failed rollback transaction")
 		}
-		return nil, err
+		return nil, ps.conflict(ctx, players, err)
 	}
 
 	// newly actived player
@@ -178,7 +332,8 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
 		"benched", // new status
 		players.Active.PlayerID,
 		"active", // needs to be active currently
-		players.Benched.PlayerID).
+		players.Benched.PlayerID,
+		players.Active.RosterID).
 		Scan(
 			&playerID,
 			&rosterID,
@@ -190,7 +345,7 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
 		if err := tx.Rollback(); err != nil {
 			log.Ctx(ctx).Error().Err(err).Interface("players", players).Msg("failed rollback transaction")
 		}
-		return nil, err
+		return nil, ps.conflict(ctx, players, err)
 	}
 
 	// newly benched player
@@ -203,8 +358,517 @@ func (ps *PlayerStore) ChangePlayers(ctx context.Context, players store.PlayerCh
 		Status:    status,
 	}
 
+	if err := checkLocks(ctx, tx, ps.ids, rosterID); err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Interface("players", players).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	// the active players must still cover the roles required by the roster
+	if err := role.Check(ctx, tx, rosterID); err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Interface("players", players).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, storeError(err, rosterID)
+	}
 	return &store.PlayerChange{
 		Active:  active,
 		Benched: benched,
-	}, tx.Commit()
+	}, nil
 }
+
+// conflict translates an error of a failed player change. If one of the
+// preconditions was not met, a *store.ConflictError holding the current state
+// of both players is returned.
+func (ps *PlayerStore) conflict(ctx context.Context, players store.PlayerChange, err error) error {
+	if !errors.Is(err, sql.ErrNoRows) {
+		return storeError(err, 0)
+	}
+	query := `
+  SELECT id, roster_id, first_name, last_name, alias, status
+  FROM players
+  WHERE id IN ($1, $2)
+  ORDER BY id`
+
+	rows, err := ps.db.GetDB().QueryContext(ctx, query, players.Active.PlayerID, players.Benched.PlayerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c := &store.ConflictError{
+		Reason: fmt.Sprintf("player %d must be active and player %d must be benched in the same roster",
+			players.Active.PlayerID, players.Benched.PlayerID),
+		Players: make([]store.Player, 0, 2),
+	}
+	for rows.Next() {
+		var p store.Player
+		if err := rows.Scan(
+			&p.PlayerID,
+			&p.RosterID,
+			&p.FirstName,
+			&p.LastName,
+			&p.Alias,
+			&p.Status); err != nil {
+			return err
+		}
+		c.RosterID = p.RosterID
+		c.Players = append(c.Players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(c.Players) < 2 {
+		return store.ErrNotFound
+	}
+	return c
+}
+
+// storeError translates errors of the datastore into errors of the store
+// package. Unknown errors are returned unchanged.
+func storeError(err error, rosterID uint64) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case foreignKeyViolation:
+		return &store.ConflictError{RosterID: rosterID, Reason: "roster does not exist"}
+	case raiseException:
+		return &store.ConflictError{RosterID: rosterID, Reason: pqErr.Message}
+	case uniqueViolation:
+		return fmt.Errorf("%s: %w", pqErr.Message, store.ErrExists)
+	}
+	return err
+}
+
+// Import inserts the given players in a single transaction. Before inserting,
+// it is verified that all referenced rosters exist and still follow the rules
+// of their game title after the import. Violations are reported as row errors.
+// Players are only inserted if no row was rejected and dryRun is false;
+// otherwise the transaction is rolled back.
+// Returns the players with generated ids or, in case of a dry run or rejected
+// rows, the validated players without ids.
+func (ps *PlayerStore) Import(ctx context.Context, rows []store.PlayerRow, dryRun bool) (*store.ImportResult, error) {
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rollback := func() {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed rollback transaction")
+		}
+	}
+
+	rowErrs, err := validateImport(ctx, tx, rows)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	result := &store.ImportResult{
+		DryRun:  dryRun,
+		Players: make([]store.Player, 0, len(rows)),
+		Errors:  rowErrs,
+	}
+	if dryRun || len(rowErrs) > 0 {
+		rollback()
+		for _, row := range rows {
+			result.Players = append(result.Players, row.Player)
+		}
+		return result, nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+  INSERT INTO players(id,roster_id,first_name,last_name,alias,status)
+  VALUES($1,$2,$3,$4,$5,$6)
+  RETURNING *`)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		var p store.Player
+		err := stmt.QueryRowContext(ctx,
+			ps.ids.Next(),
+			row.Player.RosterID,
+			row.Player.FirstName,
+			row.Player.LastName,
+			row.Player.Alias,
+			row.Player.Status).
+			Scan(
+				&p.PlayerID,
+				&p.RosterID,
+				&p.FirstName,
+				&p.LastName,
+				&p.Alias,
+				&p.Status)
+		if err != nil {
+			rollback()
+			return nil, storeError(err, row.Player.RosterID)
+		}
+		result.Players = append(result.Players, p)
+	}
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		return nil, storeError(err, 0)
+	}
+	return result, nil
+}
+
+// validateImport reports rows referencing rosters that do not exist and rows
+// of strict rosters that would violate the rules of their game title after
+// the import.
+func validateImport(ctx context.Context, tx *sql.Tx, rows []store.PlayerRow) ([]store.RowError, error) {
+	type count struct{ active, benched int }
+	imported := make(map[uint64]count) // imported players by roster id
+	var rosterIDs []int64
+	for _, row := range rows {
+		n, ok := imported[row.Player.RosterID]
+		if !ok {
+			rosterIDs = append(rosterIDs, int64(row.Player.RosterID))
+		}
+		if row.Player.Status == store.Active {
+			n.active++
+		} else {
+			n.benched++
+		}
+		imported[row.Player.RosterID] = n
+	}
+
+	query := `
+  SELECT
+    rosters.id,
+    rosters.compliance,
+    titles.active_size,
+    titles.max_benched,
+    COUNT(players.id) FILTER (WHERE players.status = 'active'),
+    COUNT(players.id) FILTER (WHERE players.status = 'benched')
+  FROM rosters
+  LEFT JOIN titles ON titles.id = rosters.title_id
+  LEFT JOIN players ON players.roster_id = rosters.id
+  WHERE rosters.id = ANY($1)
+  GROUP BY rosters.id, rosters.compliance, titles.active_size, titles.max_benched`
+
+	res, err := tx.QueryContext(ctx, query, pq.Array(rosterIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	current := make(map[uint64]count)     // current players by roster id
+	rules := make(map[uint64]store.Rules) // rules by roster id
+	lenient := make(map[uint64]bool)      // lenient rosters by roster id
+	for res.Next() {
+		var rosterID uint64
+		var mode string
+		var activeSize, maxBenched sql.NullInt64
+		var n count
+		if err := res.Scan(&rosterID, &mode, &activeSize, &maxBenched, &n.active, &n.benched); err != nil {
+			return nil, err
+		}
+		r := store.DefaultRules
+		if activeSize.Valid {
+			r = store.Rules{ActiveSize: int(activeSize.Int64)}
+		}
+		if maxBenched.Valid {
+			m := int(maxBenched.Int64)
+			r.MaxBenched = &m
+		}
+		current[rosterID] = n
+		rules[rosterID] = r
+		lenient[rosterID] = mode == store.Lenient
+	}
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	var rowErrs []store.RowError
+	for _, row := range rows {
+		n, ok := current[row.Player.RosterID]
+		if !ok {
+			rowErrs = append(rowErrs, store.RowError{
+				Row:    row.Row,
+				Field:  "roster_id",
+				Reason: "roster does not exist",
+			})
+			continue
+		}
+		if lenient[row.Player.RosterID] {
+			continue
+		}
+		i := imported[row.Player.RosterID]
+		reason := rules[row.Player.RosterID].Violation(n.active+i.active, n.benched+i.benched)
+		if reason != "" {
+			rowErrs = append(rowErrs, store.RowError{
+				Row:    row.Row,
+				Field:  "status",
+				Reason: fmt.Sprintf("roster %d %s", row.Player.RosterID, reason),
+			})
+		}
+	}
+	return rowErrs, nil
+}
+
+// Find returns the players matching the filter ordered by roster and player id
+// along with their profiles.
+func (ps *PlayerStore) Find(ctx context.Context, filter store.PlayerFilter) ([]store.Player, error) {
+	query := `
+  SELECT p.id, p.roster_id, p.first_name, p.last_name, p.alias, p.status, ` + profile.Columns + `
+  FROM players AS p
+  LEFT JOIN player_profiles AS pp ON pp.player_id = p.id
+  WHERE (CARDINALITY($1::BIGINT[]) = 0 OR p.roster_id = ANY($1))
+    AND ($2 = '' OR p.status = $2)
+    AND ($3 = '' OR p.alias ILIKE '%' || $3 || '%')
+    AND ($4 = '' OR pp.nationality = $4)
+    AND ($5 = '' OR pp.accounts ? $5)
+    AND NOT EXISTS (
+      SELECT 1
+      FROM jsonb_each_text($6::jsonb) AS f
+      WHERE (pp.custom ->> f.key) IS DISTINCT FROM f.value)
+  ORDER BY p.roster_id, p.id`
+
+	rosterIDs := make([]int64, len(filter.RosterIDs))
+	for i, id := range filter.RosterIDs {
+		rosterIDs[i] = int64(id)
+	}
+	custom, err := json.Marshal(filter.Custom)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Custom == nil {
+		custom = []byte("{}")
+	}
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query,
+		pq.Array(rosterIDs),
+		filter.Status,
+		filter.Alias,
+		filter.Nationality,
+		filter.Platform,
+		string(custom))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := make([]store.Player, 0)
+	for rows.Next() {
+		var p store.Player
+		var sc profile.Scanner
+		if err := rows.Scan(append([]interface{}{
+			&p.PlayerID,
+			&p.RosterID,
+			&p.FirstName,
+			&p.LastName,
+			&p.Alias,
+			&p.Status,
+		}, sc.Dest()...)...); err != nil {
+			return nil, err
+		}
+		if p.Profile, err = sc.Profile(); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+// FindByAlias returns the players whose current or former alias matches the
+// given alias regardless of case. Players with the current alias come first,
+// followed by former holders of the alias, most recent first.
+func (ps *PlayerStore) FindByAlias(ctx context.Context, alias string) ([]store.AliasMatch, error) {
+	query := `
+  SELECT
+    p.id, p.roster_id, p.first_name, p.last_name, p.alias, p.status,
+    COALESCE(r.title_id, 0), m.renamed_at
+  FROM (
+    SELECT DISTINCT ON (player_id) player_id, renamed_at
+    FROM (
+      SELECT id AS player_id, NULL::timestamptz AS renamed_at
+      FROM players
+      WHERE lower(alias) = lower($1)
+      UNION ALL
+      SELECT player_id, renamed_at
+      FROM player_aliases
+      WHERE lower(alias) = lower($1)
+    ) AS a
+    ORDER BY player_id, renamed_at DESC NULLS FIRST
+  ) AS m
+  JOIN players AS p ON p.id = m.player_id
+  JOIN rosters AS r ON r.id = p.roster_id
+  ORDER BY m.renamed_at DESC NULLS FIRST, p.id`
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, alias)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]store.AliasMatch, 0)
+	for rows.Next() {
+		var m store.AliasMatch
+		var renamedAt sql.NullTime
+		if err := rows.Scan(
+			&m.PlayerID,
+			&m.RosterID,
+			&m.FirstName,
+			&m.LastName,
+			&m.Alias,
+			&m.Status,
+			&m.TitleID,
+			&renamedAt); err != nil {
+			return nil, err
+		}
+		m.Current = !renamedAt.Valid
+		if renamedAt.Valid {
+			m.RenamedAt = &renamedAt.Time
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// Aliases returns the former aliases of the players ordered by player id, most
+// recent first.
+func (ps *PlayerStore) Aliases(ctx context.Context, playerIDs []uint64) ([]store.AliasChange, error) {
+	query := `
+  SELECT player_id, alias, renamed_at
+  FROM player_aliases
+  WHERE player_id = ANY($1)
+  ORDER BY player_id, renamed_at DESC`
+
+	ids := make([]int64, len(playerIDs))
+	for i, id := range playerIDs {
+		ids[i] = int64(id)
+	}
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make([]store.AliasChange, 0)
+	for rows.Next() {
+		var a store.AliasChange
+		if err := rows.Scan(&a.PlayerID, &a.Alias, &a.RenamedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// Search returns the players whose first name, last name or alias contain the
+// text of the query or are similar to it, best matches first. Matching,
+// ranking and highlighting follow package search, the similarity is computed
+// by pg_trgm.
+func (ps *PlayerStore) Search(ctx context.Context, q store.SearchQuery) ([]store.SearchResult, error) {
+	query := `
+  SELECT
+    id, roster_id, first_name, last_name, alias, status,
+    first_name_match, last_name_match, alias_match,
+    GREATEST(
+      (similarity(lower(first_name), $1) + (strpos(lower(first_name), $1) > 0)::int) / 2,
+      (similarity(lower(last_name), $1) + (strpos(lower(last_name), $1) > 0)::int) / 2,
+      (similarity(lower(alias), $1) + (strpos(lower(alias), $1) > 0)::int) / 2) AS rank
+  FROM (
+    SELECT
+      p.*,
+      lower(p.first_name) % $1 OR lower(p.first_name) LIKE $2 AS first_name_match,
+      lower(p.last_name) % $1 OR lower(p.last_name) LIKE $2 AS last_name_match,
+      lower(p.alias) % $1 OR lower(p.alias) LIKE $2 AS alias_match
+    FROM players AS p
+    WHERE (CARDINALITY($3::BIGINT[]) = 0 OR p.roster_id = ANY($3))
+      AND ($4 = '' OR p.status = $4)
+  ) AS p
+  WHERE first_name_match OR last_name_match OR alias_match
+  ORDER BY rank DESC, id
+  LIMIT $5`
+
+	text := search.Normalize(q.Text)
+	rosterIDs := make([]int64, len(q.RosterIDs))
+	for i, id := range q.RosterIDs {
+		rosterIDs[i] = int64(id)
+	}
+
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query,
+		text,
+		"%"+likeEscaper.Replace(text)+"%",
+		pq.Array(rosterIDs),
+		q.Status,
+		search.Limit(q))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]store.SearchResult, 0)
+	for rows.Next() {
+		var r store.SearchResult
+		var matches [3]bool
+		if err := rows.Scan(
+			&r.PlayerID,
+			&r.RosterID,
+			&r.FirstName,
+			&r.LastName,
+			&r.Alias,
+			&r.Status,
+			&matches[0],
+			&matches[1],
+			&matches[2],
+			&r.Rank); err != nil {
+			return nil, err
+		}
+		fields := search.Fields(r.Player)
+		r.Highlights = make(map[string]string)
+		for i, name := range []string{"first_name", "last_name", "alias"} {
+			if matches[i] {
+				r.Highlights[name] = search.Highlight(fields[name], text)
+			}
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
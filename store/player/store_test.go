@@ -2,12 +2,17 @@ package player
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/fgrimme/patrongg/database"
 	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
 )
 
 func TestInsert(t *testing.T) {
@@ -21,8 +26,11 @@ func TestInsert(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "active"}).
 		AddRow(182919996442279937, 382574876546039808, "Dominic", "Luklowski", "DataSlayer9", "active")
 
+	// the player id is generated by the store
 	query := `INSERT INTO players(.*)VALUES(.*) RETURNING *`
-	mock.ExpectQuery(query).WillReturnRows(rows)
+	mock.ExpectQuery(query).
+		WithArgs(1, 382574876546039808, "Dominic", "Luklowski", "DataSlayer9", "active").
+		WillReturnRows(rows)
 
 	want := &store.Player{
 		PlayerID:  182919996442279937,
@@ -32,7 +40,7 @@ func TestInsert(t *testing.T) {
 		Alias:     "DataSlayer9",
 		Status:    "active",
 	}
-	ps := New(database.New(db, "mock-db", 0))
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
 	got, err := ps.Insert(context.Background(), *want)
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
@@ -46,6 +54,29 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+// fails if the alias is taken by a player of the same game title
+func TestInsertAliasTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO players(.*)VALUES(.*) RETURNING *`).
+		WithArgs(1, 2, "foo", "bar", "FooBar", "benched").
+		WillReturnError(&pq.Error{Code: uniqueViolation, Message: "alias FooBar is taken by player 3"})
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = ps.Insert(context.Background(), store.Player{RosterID: 2, FirstName: "foo", LastName: "bar", Alias: "FooBar", Status: "benched"})
+	if !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 // changes the roster id and status
 func TestUpdate(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -78,6 +109,18 @@ func TestUpdate(t *testing.T) {
 		Status:    "active",
 	}
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(p.PlayerID).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(1))
+	// the current and the new roster of the player are checked for locks
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{1, 382574876546039808})).
+		WillReturnRows(sqlmock.NewRows(lockColumns))
+	// the former alias is kept in the history
+	mock.ExpectExec(`INSERT INTO player_aliases\(player_id,alias\) SELECT id, alias FROM players WHERE id = \$1 AND alias <> \$2`).
+		WithArgs(p.PlayerID, p.Alias).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectQuery(query).WithArgs(
 		p.PlayerID,
 		p.RosterID,
@@ -86,8 +129,9 @@ func TestUpdate(t *testing.T) {
 		p.Alias,
 		p.Status,
 	).WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	ps := New(database.New(db, "mock-db", 0))
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
 	got, err := ps.Update(context.Background(), p)
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
@@ -104,6 +148,180 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+var lockColumns = []string{"id", "roster_id", "starts_at", "ends_at", "reason"}
+
+var roleColumns = []string{"roster_id", "role", "count", "active"}
+
+// profileColumns are the columns of a profile scanned along with a player.
+var profileColumns = []string{"nationality", "date_of_birth", "accounts", "socials", "custom"}
+
+// fails if the roster of the player is locked
+func TestUpdateLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{2, 3})).
+		WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(4, 2, start, nil, "match"))
+	mock.ExpectRollback()
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = ps.Update(context.Background(), store.Player{PlayerID: 1, RosterID: 3, Status: store.Benched})
+	var locked *store.LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("want locked error got %v", err)
+	}
+	want := store.Lock{LockID: 4, RosterID: 2, StartsAt: start, Reason: "match"}
+	if !reflect.DeepEqual(want, locked.Lock) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, locked.Lock)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// records the override of the lock of the roster
+func TestUpdateOverride(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{2})).
+		WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(4, 2, start, nil, "match"))
+	mock.ExpectExec(`INSERT INTO lock_overrides(.*) SELECT (.+) WHERE NOT EXISTS \((.+)created_at = now\(\)(.+)\)`).
+		WithArgs(1, 4, 2, "admin", "injury").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO player_aliases(.*)`).
+		WithArgs(1, "foo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`UPDATE players SET (.+) WHERE id = \$1 RETURNING \*`).
+		WithArgs(1, 0, "", "", "foo", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "status"}).
+			AddRow(1, 2, "foo", "bar", "foo", "active"))
+	mock.ExpectCommit()
+
+	ctx := store.WithLockOverride(context.Background(), store.LockOverride{Subject: "admin", Reason: "injury"})
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	if _, err := ps.Update(ctx, store.Player{PlayerID: 1, Alias: "foo"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// moves players between rosters in a single transaction
+func TestTrade(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	playerColumns := []string{"id", "roster_id", "first_name", "last_name", "alias", "status"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, roster_id FROM players WHERE id = ANY\(\$1\) ORDER BY id FOR UPDATE`).
+		WithArgs(pq.Array([]int64{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id"}).AddRow(1, 10).AddRow(2, 20))
+	mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(10))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{10, 20})).
+		WillReturnRows(sqlmock.NewRows(lockColumns))
+	mock.ExpectQuery(`UPDATE players SET (.+) WHERE id = \$1 RETURNING \*`).
+		WithArgs(1, 20, "", "", "", store.Active).
+		WillReturnRows(sqlmock.NewRows(playerColumns).AddRow(1, 20, "foo", "bar", "foobar", store.Active))
+	mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(20))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{20, 10})).
+		WillReturnRows(sqlmock.NewRows(lockColumns))
+	mock.ExpectQuery(`UPDATE players SET (.+) WHERE id = \$1 RETURNING \*`).
+		WithArgs(2, 10, "", "", "", store.Active).
+		WillReturnRows(sqlmock.NewRows(playerColumns).AddRow(2, 10, "baz", "qux", "bazqux", store.Active))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WithArgs(pq.Array([]int64{10, 20})).
+		WillReturnRows(sqlmock.NewRows(roleColumns))
+	mock.ExpectCommit()
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Trade(context.Background(), store.Trade{
+		RosterID:       10,
+		PartnerID:      20,
+		Players:        []store.Player{{PlayerID: 1, Status: store.Active}},
+		PartnerPlayers: []store.Player{{PlayerID: 2, Status: store.Active}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Trade{
+		RosterID:       10,
+		PartnerID:      20,
+		Players:        []store.Player{{PlayerID: 1, RosterID: 20, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: store.Active}},
+		PartnerPlayers: []store.Player{{PlayerID: 2, RosterID: 10, FirstName: "baz", LastName: "qux", Alias: "bazqux", Status: store.Active}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if a player is not a member of the roster it is traded from
+func TestTradeConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, roster_id FROM players WHERE id = ANY\(\$1\) ORDER BY id FOR UPDATE`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id"}).AddRow(1, 30))
+	mock.ExpectRollback()
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = ps.Trade(context.Background(), store.Trade{
+		RosterID:  10,
+		PartnerID: 20,
+		Players:   []store.Player{{PlayerID: 1, Status: store.Benched}},
+	})
+	var conflict *store.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error got %v", err)
+	}
+	if conflict.RosterID != 10 {
+		t.Errorf("want conflict of roster 10 got %d", conflict.RosterID)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 // swaps the status
 func TestChangePlayer(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -128,6 +346,7 @@ func TestChangePlayer(t *testing.T) {
     FROM players
     WHERE id = \$4
   \)
+  AND \(CAST\(\$5 AS BIGINT\) = 0 OR roster_id = \$5\)
   RETURNING *`
 
 	mock.ExpectBegin()
@@ -139,6 +358,7 @@ func TestChangePlayer(t *testing.T) {
 		2,
 		"benched",
 		1,
+		1,
 	).WillReturnRows(rowsActive)
 
 	// expected query for activating benched player
@@ -147,8 +367,15 @@ func TestChangePlayer(t *testing.T) {
 		1,
 		"active",
 		2,
+		1,
 	).WillReturnRows(rowsBenched)
 
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows(lockColumns))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows(roleColumns))
 	mock.ExpectCommit()
 
 	// input
@@ -191,7 +418,7 @@ func TestChangePlayer(t *testing.T) {
 		},
 	}
 
-	ps := New(database.New(db, "mock-db", 0))
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
 	got, err := ps.ChangePlayers(context.Background(), players)
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
@@ -204,3 +431,419 @@ func TestChangePlayer(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+// fails if the active players would not cover a role required by the roster
+func TestChangePlayerRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	playerColumns := []string{"id", "roster_id", "first_name", "last_name", "alias", "status"}
+	mock.ExpectBegin()
+	mock.ExpectPrepare(`UPDATE players SET status = \$1`)
+	mock.ExpectQuery(`UPDATE players SET status = \$1`).
+		WithArgs(store.Active, 2, store.Benched, 1, 0).
+		WillReturnRows(sqlmock.NewRows(playerColumns).AddRow(2, 1, "boo", "baz", "boobaz", store.Active))
+	mock.ExpectQuery(`UPDATE players SET status = \$1`).
+		WithArgs(store.Benched, 1, store.Active, 2, 0).
+		WillReturnRows(sqlmock.NewRows(playerColumns).AddRow(1, 1, "foo", "bar", "foobar", store.Benched))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_locks WHERE roster_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows(lockColumns))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(1, "igl", 1, 0))
+	mock.ExpectRollback()
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = ps.ChangePlayers(context.Background(), store.PlayerChange{
+		Active:  store.Player{PlayerID: 1},
+		Benched: store.Player{PlayerID: 2},
+	})
+	var roleErr *store.RoleError
+	if !errors.As(err, &roleErr) {
+		t.Fatalf("want role error got %v", err)
+	}
+	want := store.RoleError{RosterID: 1, Requirement: store.RoleRequirement{Role: "igl", Count: 1}}
+	if !reflect.DeepEqual(want, *roleErr) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, *roleErr)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	columns := append([]string{"id", "roster_id", "first_name", "last_name", "alias", "status", "roles"}, profileColumns...)
+	mock.ExpectQuery(`SELECT (.+) FROM players LEFT JOIN player_profiles AS pp (.+) WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 2, "foo", "bar", "foobar", "benched", "{awper,entry}", "DE", "1999-02-01", `{"steam":"765611"}`, "{}", `{"rank":3}`))
+	mock.ExpectQuery(`SELECT (.+) FROM players LEFT JOIN player_profiles AS pp (.+) WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnError(sql.ErrNoRows)
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Player{
+		PlayerID: 1, RosterID: 2, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched", Roles: []string{"awper", "entry"},
+		Profile: &store.Profile{
+			Nationality: "DE",
+			DateOfBirth: "1999-02-01",
+			Accounts:    map[string]string{"steam": "765611"},
+			Custom:      map[string]interface{}{"rank": float64(3)},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	if _, err := ps.Get(context.Background(), 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if the roster would be left without enough active players
+func TestDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`DELETE FROM players WHERE id = \$1 RETURNING \*`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "status"}).
+			AddRow(1, 2, "foo", "bar", "foobar", "benched"))
+	mock.ExpectQuery(`DELETE FROM players WHERE id = \$1 RETURNING \*`).
+		WithArgs(3).
+		WillReturnError(&pq.Error{Code: raiseException, Message: "must have exactly 5 active players"})
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Delete(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Player{PlayerID: 1, RosterID: 2, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	var conflict *store.ConflictError
+	if _, err := ps.Delete(context.Background(), 3); !errors.As(err, &conflict) {
+		t.Errorf("want conflict error got %v", err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if the player to be benched is not active
+func TestChangePlayerConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// current state of both players, both are benched
+	rows := sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "active"}).
+		AddRow(1, 1, "foo", "bar", "foobar", "benched").
+		AddRow(2, 1, "boo", "baz", "boobaz", "benched")
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(`UPDATE players SET status = \$1`)
+	mock.ExpectQuery(`UPDATE players SET status = \$1`).WithArgs(
+		"active",
+		2,
+		"benched",
+		1,
+		0,
+	).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	mock.ExpectQuery(`SELECT (.+) FROM players WHERE id IN \(\$1, \$2\)`).
+		WithArgs(1, 2).
+		WillReturnRows(rows)
+
+	players := store.PlayerChange{
+		Active:  store.Player{PlayerID: 1},
+		Benched: store.Player{PlayerID: 2},
+	}
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = ps.ChangePlayers(context.Background(), players)
+	var conflict *store.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error got %v", err)
+	}
+	if want, got := uint64(1), conflict.RosterID; want != got {
+		t.Errorf("want roster id %d got %d", want, got)
+	}
+	if want, got := 2, len(conflict.Players); want != got {
+		t.Errorf("want %d players got %d", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// inserts benched players in a transaction
+func TestImport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// before we actually execute our api function, we need to expect required DB actions
+	counts := sqlmock.NewRows([]string{"id", "compliance", "active_size", "max_benched", "active", "benched"}).
+		AddRow(382574876546039808, store.Strict, nil, nil, 5, 1)
+	rows := sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "active"}).
+		AddRow(1, 382574876546039808, "foo", "bar", "foobar", "benched")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM rosters LEFT JOIN titles (.+) WHERE rosters.id = ANY\(\$1\)`).
+		WillReturnRows(counts)
+	mock.ExpectPrepare(`INSERT INTO players(.*)VALUES(.*) RETURNING *`)
+	mock.ExpectQuery(`INSERT INTO players(.*)VALUES(.*) RETURNING *`).
+		WithArgs(1, 382574876546039808, "foo", "bar", "foobar", "benched").
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	want := &store.ImportResult{
+		Players: []store.Player{{
+			PlayerID:  1,
+			RosterID:  382574876546039808,
+			FirstName: "foo",
+			LastName:  "bar",
+			Alias:     "foobar",
+			Status:    "benched",
+		}},
+	}
+	in := []store.PlayerRow{{Row: 1, Player: want.Players[0]}}
+	in[0].Player.PlayerID = 0
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Import(context.Background(), in, false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v\n", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// rejects players exceeding the rules of strict rosters and unknown rosters
+func TestImportRejected(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// roster 2 does not exist, roster 3 has a title with a bench of 1, roster 4
+	// is lenient about the same title
+	counts := sqlmock.NewRows([]string{"id", "compliance", "active_size", "max_benched", "active", "benched"}).
+		AddRow(1, store.Strict, nil, nil, 5, 0).
+		AddRow(3, store.Strict, 3, 1, 3, 1).
+		AddRow(4, store.Lenient, 3, 1, 3, 1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM rosters LEFT JOIN titles`).
+		WillReturnRows(counts)
+	mock.ExpectRollback()
+
+	in := []store.PlayerRow{
+		{Row: 1, Player: store.Player{RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "active"}},
+		{Row: 2, Player: store.Player{RosterID: 2, FirstName: "boo", LastName: "baz", Alias: "boobaz", Status: "benched"}},
+		{Row: 3, Player: store.Player{RosterID: 3, FirstName: "moo", LastName: "maz", Alias: "moomaz", Status: "benched"}},
+		{Row: 4, Player: store.Player{RosterID: 4, FirstName: "zoo", LastName: "zaz", Alias: "zoozaz", Status: "benched"}},
+	}
+	want := []store.RowError{
+		{Row: 1, Field: "status", Reason: "roster 1 must have exactly 5 active players, not 6"},
+		{Row: 2, Field: "roster_id", Reason: "roster does not exist"},
+		{Row: 3, Field: "status", Reason: "roster 3 must have at most 1 benched players, not 2"},
+	}
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Import(context.Background(), in, false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got.Errors) {
+		t.Errorf("want\n%+v\ngot\n%+v\n", want, got.Errors)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// players without profile have no profile row
+	rows := sqlmock.NewRows(append([]string{"id", "roster_id", "first_name", "last_name", "alias", "status"}, profileColumns...)).
+		AddRow(1, 1, "foo", "bar", "foobar", "benched", "DE", nil, `{"steam":"765611"}`, "{}", `{"team":"a"}`).
+		AddRow(2, 2, "boo", "baz", "boobaz", "benched", nil, nil, nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp (.+) ORDER BY p.roster_id, p.id`).
+		WithArgs(pq.Array([]int64{1, 2}), "benched", "", "DE", "steam", `{"team":"a"}`).
+		WillReturnRows(rows)
+
+	want := []store.Player{
+		{PlayerID: 1, RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched", Profile: &store.Profile{
+			Nationality: "DE",
+			Accounts:    map[string]string{"steam": "765611"},
+			Custom:      map[string]interface{}{"team": "a"},
+		}},
+		{PlayerID: 2, RosterID: 2, FirstName: "boo", LastName: "baz", Alias: "boobaz", Status: "benched"},
+	}
+	filter := store.PlayerFilter{
+		RosterIDs:   []uint64{1, 2},
+		Status:      "benched",
+		Nationality: "DE",
+		Platform:    "steam",
+		Custom:      map[string]string{"team": "a"},
+	}
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Find(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v\n", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// finds the current holder of the alias before its former holders
+func TestFindByAlias(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	renamed := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM players WHERE lower\(alias\) = lower\(\$1\) UNION ALL SELECT (.+) FROM player_aliases WHERE lower\(alias\) = lower\(\$1\)`).
+		WithArgs("foobar").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "status", "title_id", "renamed_at"}).
+			AddRow(1, 2, "foo", "bar", "FooBar", "active", 0, nil).
+			AddRow(3, 4, "baz", "qux", "bazqux", "benched", 5, renamed))
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.FindByAlias(context.Background(), "foobar")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.AliasMatch{
+		{
+			Player:  store.Player{PlayerID: 1, RosterID: 2, FirstName: "foo", LastName: "bar", Alias: "FooBar", Status: "active"},
+			Current: true,
+		},
+		{
+			Player:    store.Player{PlayerID: 3, RosterID: 4, FirstName: "baz", LastName: "qux", Alias: "bazqux", Status: "benched"},
+			TitleID:   5,
+			RenamedAt: &renamed,
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	renamed := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT player_id, alias, renamed_at FROM player_aliases WHERE player_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"player_id", "alias", "renamed_at"}).
+			AddRow(1, "foo", renamed.Add(time.Hour)).
+			AddRow(1, "bar", renamed))
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Aliases(context.Background(), []uint64{1, 2})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.AliasChange{
+		{PlayerID: 1, Alias: "foo", RenamedAt: renamed.Add(time.Hour)},
+		{PlayerID: 1, Alias: "bar", RenamedAt: renamed},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// highlights the matching fields of the ranked players
+func TestSearch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p WHERE (.+) ORDER BY rank DESC, id LIMIT \$5`).
+		WithArgs("data_sl", `%data\_sl%`, pq.Array([]int64{1}), "active", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "status", "first_name_match", "last_name_match", "alias_match", "rank"}).
+			AddRow(1, 1, "Dominic", "Luklowski", "Data_Slayer9", "active", false, false, true, 0.75))
+
+	ps := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ps.Search(context.Background(), store.SearchQuery{Text: " Data_Sl", RosterIDs: []uint64{1}, Status: "active"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.SearchResult{{
+		Player:     store.Player{PlayerID: 1, RosterID: 1, FirstName: "Dominic", LastName: "Luklowski", Alias: "Data_Slayer9", Status: "active"},
+		Rank:       0.75,
+		Highlights: map[string]string{"alias": "<em>Data_Sl</em>ayer9"},
+	}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,123 @@
+package player
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/role"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Trade moves the players of the trade between the two rosters in a single
+// transaction, so that the active player constraints of both rosters are only
+// checked after all players were moved.
+// Returns the moved players, store.ErrNotFound if a player does not exist, a
+// *store.ConflictError if a player is not a member of the roster it is traded
+// from or the trade leaves a strict roster without store.ActiveSize active
+// players or a *store.LockedError if one of the rosters is locked.
+func (ps *PlayerStore) Trade(ctx context.Context, trade store.Trade) (*store.Trade, error) {
+	db := ps.db.GetDB()
+	ctx, cancel := ps.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ps.trade(ctx, tx, trade)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Interface("trade", trade).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		return nil, storeError(err, trade.RosterID)
+	}
+	return t, nil
+}
+
+// trade moves the players of the trade within the given transaction.
+func (ps *PlayerStore) trade(ctx context.Context, tx *sql.Tx, trade store.Trade) (*store.Trade, error) {
+	// players are locked in the order of their ids, so that concurrent trades
+	// of the same players can not deadlock
+	query := `
+  SELECT id, roster_id
+  FROM players
+  WHERE id = ANY($1)
+  ORDER BY id
+  FOR UPDATE`
+
+	// roster ids the players are traded from indexed by the player id
+	n := len(trade.Players) + len(trade.PartnerPlayers)
+	from := make(map[uint64]uint64, n)
+	params := make([]int64, 0, n)
+	for _, p := range trade.Players {
+		from[p.PlayerID] = trade.RosterID
+		params = append(params, int64(p.PlayerID))
+	}
+	for _, p := range trade.PartnerPlayers {
+		from[p.PlayerID] = trade.PartnerID
+		params = append(params, int64(p.PlayerID))
+	}
+	rows, err := tx.QueryContext(ctx, query, pq.Array(params))
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[uint64]uint64, len(from))
+	for rows.Next() {
+		var playerID, rosterID uint64
+		if err := rows.Scan(&playerID, &rosterID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found[playerID] = rosterID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range params {
+		playerID := uint64(id)
+		rosterID, ok := found[playerID]
+		if !ok {
+			return nil, fmt.Errorf("player %d: %w", playerID, store.ErrNotFound)
+		}
+		if rosterID != from[playerID] {
+			return nil, &store.ConflictError{
+				RosterID: from[playerID],
+				Reason:   fmt.Sprintf("player %d is not a member of roster %d", playerID, from[playerID]),
+			}
+		}
+	}
+
+	t := store.Trade{
+		RosterID:       trade.RosterID,
+		PartnerID:      trade.PartnerID,
+		Players:        make([]store.Player, len(trade.Players)),
+		PartnerPlayers: make([]store.Player, len(trade.PartnerPlayers)),
+	}
+	for i, p := range trade.Players {
+		moved, err := ps.UpdateTx(ctx, tx, store.Player{PlayerID: p.PlayerID, RosterID: trade.PartnerID, Status: p.Status})
+		if err != nil {
+			return nil, err
+		}
+		t.Players[i] = *moved
+	}
+	for i, p := range trade.PartnerPlayers {
+		moved, err := ps.UpdateTx(ctx, tx, store.Player{PlayerID: p.PlayerID, RosterID: trade.RosterID, Status: p.Status})
+		if err != nil {
+			return nil, err
+		}
+		t.PartnerPlayers[i] = *moved
+	}
+	// the active players must still cover the roles required by the rosters
+	if err := role.Check(ctx, tx, trade.RosterID, trade.PartnerID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
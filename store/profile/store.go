@@ -0,0 +1,204 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Columns selects the profile columns of player_profiles joined as pp, to be
+// scanned by a Scanner.
+const Columns = `pp.nationality, to_char(pp.date_of_birth, 'YYYY-MM-DD'), pp.accounts, pp.socials, pp.custom`
+
+// ProfileStore handles operations on the player_profiles table of the
+// encapsulated datastore.
+type ProfileStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *ProfileStore {
+	return &ProfileStore{
+		db: db,
+	}
+}
+
+// Get returns the profile of the player or store.ErrNotFound if the player
+// does not exist. Players without profile have an empty profile.
+func (s *ProfileStore) Get(ctx context.Context, playerID uint64) (*store.Profile, error) {
+	query := `
+  SELECT ` + Columns + `
+  FROM players AS p
+  LEFT JOIN player_profiles AS pp ON pp.player_id = p.id
+  WHERE p.id = $1`
+
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	var sc Scanner
+	err := db.QueryRowContext(ctx, query, playerID).Scan(sc.Dest()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sc.profile()
+}
+
+// Update replaces the profile of the player with the profile returned by
+// update, which is called with the current profile. Concurrent updates of the
+// profile are applied one after another. Returns the updated profile,
+// store.ErrNotFound if the player does not exist or the error of update.
+func (s *ProfileStore) Update(ctx context.Context, playerID uint64, update func(store.Profile) (store.Profile, error)) (*store.Profile, error) {
+	db := s.db.GetDB()
+	ctx, cancel := s.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	p, err := updateProfile(ctx, tx, playerID, update)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("player_id", playerID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func updateProfile(ctx context.Context, tx *sql.Tx, playerID uint64, update func(store.Profile) (store.Profile, error)) (*store.Profile, error) {
+	// the player is locked, so that concurrent updates of its profile are
+	// based on each other
+	var sc Scanner
+	err := tx.QueryRowContext(ctx, `
+  SELECT `+Columns+`
+  FROM players AS p
+  LEFT JOIN player_profiles AS pp ON pp.player_id = p.id
+  WHERE p.id = $1
+  FOR UPDATE OF p`, playerID).Scan(sc.Dest()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	current, err := sc.profile()
+	if err != nil {
+		return nil, err
+	}
+	p, err := update(*current)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := object(p.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	socials, err := object(p.Socials)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := object(p.Custom)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+  INSERT INTO player_profiles(player_id,nationality,date_of_birth,accounts,socials,custom)
+  VALUES($1,NULLIF($2,''),CAST(NULLIF($3,'') AS date),$4,$5,$6)
+  ON CONFLICT (player_id) DO UPDATE SET
+    nationality = EXCLUDED.nationality,
+    date_of_birth = EXCLUDED.date_of_birth,
+    accounts = EXCLUDED.accounts,
+    socials = EXCLUDED.socials,
+    custom = EXCLUDED.custom`, playerID, p.Nationality, p.DateOfBirth, accounts, socials, custom)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// object encodes the map as JSON object. Nil maps are encoded as empty
+// objects.
+func object(m interface{}) (string, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	if string(b) == "null" {
+		return "{}", nil
+	}
+	return string(b), nil
+}
+
+// Scanner scans the profile columns of a player selected by Columns.
+type Scanner struct {
+	nationality sql.NullString
+	dateOfBirth sql.NullString
+	accounts    []byte
+	socials     []byte
+	custom      []byte
+}
+
+// Dest returns the scan destinations of the profile columns.
+func (sc *Scanner) Dest() []interface{} {
+	return []interface{}{&sc.nationality, &sc.dateOfBirth, &sc.accounts, &sc.socials, &sc.custom}
+}
+
+// Profile returns the scanned profile or nil if it is empty.
+func (sc *Scanner) Profile() (*store.Profile, error) {
+	p, err := sc.profile()
+	if err != nil {
+		return nil, err
+	}
+	if p.Nationality == "" && p.DateOfBirth == "" && p.Accounts == nil && p.Socials == nil && p.Custom == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// profile returns the scanned profile, which is empty for players without
+// profile.
+func (sc *Scanner) profile() (*store.Profile, error) {
+	p := store.Profile{
+		Nationality: sc.nationality.String,
+		DateOfBirth: sc.dateOfBirth.String,
+	}
+	for _, f := range []struct {
+		b []byte
+		v interface{}
+	}{
+		{sc.accounts, &p.Accounts},
+		{sc.socials, &p.Socials},
+		{sc.custom, &p.Custom},
+	} {
+		if f.b == nil {
+			continue
+		}
+		if err := json.Unmarshal(f.b, f.v); err != nil {
+			return nil, err
+		}
+	}
+	// empty objects are omitted like missing ones
+	if len(p.Accounts) == 0 {
+		p.Accounts = nil
+	}
+	if len(p.Socials) == 0 {
+		p.Socials = nil
+	}
+	if len(p.Custom) == 0 {
+		p.Custom = nil
+	}
+	return &p, nil
+}
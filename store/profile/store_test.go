@@ -0,0 +1,127 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+)
+
+var columns = []string{"nationality", "date_of_birth", "accounts", "socials", "custom"}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp (.+) WHERE p.id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("SE", "2001-12-24", "{}", `{"twitter":"@foo"}`, "{}"))
+	// player 2 has no profile
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, nil, nil, nil, nil))
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	ctx := context.Background()
+	s := New(database.New(db, "mock-db", 0))
+	got, err := s.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Profile{Nationality: "SE", DateOfBirth: "2001-12-24", Socials: map[string]string{"twitter": "@foo"}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	got, err = s.Get(ctx, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (&store.Profile{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	if _, err := s.Get(ctx, 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// updates are based on the locked profile and stored with empty objects for
+// missing maps
+func TestUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp (.+) FOR UPDATE OF p`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("SE", nil, "{}", "{}", `{"rank":1}`))
+	mock.ExpectExec(`INSERT INTO player_profiles(.*)VALUES(.*)ON CONFLICT \(player_id\) DO UPDATE`).
+		WithArgs(1, "SE", "", `{"steam":"42"}`, "{}", `{"rank":2}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	s := New(database.New(db, "mock-db", 0))
+	got, err := s.Update(context.Background(), 1, func(p store.Profile) (store.Profile, error) {
+		if p.Custom["rank"] != float64(1) {
+			t.Errorf("want current rank 1 got %v", p.Custom["rank"])
+		}
+		p.Accounts = map[string]string{"steam": "42"}
+		p.Custom = map[string]interface{}{"rank": 2}
+		return p, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Profile{Nationality: "SE", Accounts: map[string]string{"steam": "42"}, Custom: map[string]interface{}{"rank": 2}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// the profile is left unchanged if the update fails
+func TestUpdateFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM players AS p LEFT JOIN player_profiles AS pp (.+) FOR UPDATE OF p`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, nil, nil, nil, nil))
+	mock.ExpectRollback()
+
+	invalid := errors.New("invalid")
+	s := New(database.New(db, "mock-db", 0))
+	_, err = s.Update(context.Background(), 1, func(p store.Profile) (store.Profile, error) {
+		return p, invalid
+	})
+	if !errors.Is(err, invalid) {
+		t.Errorf("want error %v got %v", invalid, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
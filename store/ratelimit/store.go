@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fgrimme/patrongg/database"
+)
+
+// RateLimitStore keeps the token buckets of the rate limiter in the
+// rate_limits table of the encapsulated datastore, so that limits apply
+// across all instances of the service.
+type RateLimitStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *RateLimitStore {
+	return &RateLimitStore{
+		db: db,
+	}
+}
+
+// Take takes a token from the bucket with the given key after refilling it at
+// rate tokens per second up to burst tokens. Returns the tokens left in the
+// bucket and whether a token was taken. The bucket is refilled and taken from
+// in a single statement, concurrent requests are serialized by the row lock.
+func (rs *RateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	query := `
+  INSERT INTO rate_limits AS rl (key, tokens, updated_at)
+  VALUES ($1, $3::FLOAT8 - 1, now())
+  ON CONFLICT (key) DO UPDATE SET
+    tokens     = LEAST($3::FLOAT8, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $2::FLOAT8) - 1,
+    updated_at = now()
+  WHERE LEAST($3::FLOAT8, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $2::FLOAT8) >= 1
+  RETURNING tokens`
+
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	var tokens float64
+	err := db.QueryRowContext(ctx, query, key, rate, burst).Scan(&tokens)
+	if err == nil {
+		return tokens, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	// the bucket is empty, we read the refilled tokens without changing it
+	query = `
+  SELECT LEAST($3::FLOAT8, tokens + EXTRACT(EPOCH FROM now() - updated_at) * $2::FLOAT8)
+  FROM rate_limits
+  WHERE key = $1`
+	if err := db.QueryRowContext(ctx, query, key, rate, burst).Scan(&tokens); err != nil {
+		return 0, false, err
+	}
+	return tokens, false, nil
+}
+
+// Prune deletes the buckets that have not been used for the given duration.
+// Deleted buckets are recreated with full tokens, so the duration must exceed
+// the time it takes to refill a bucket.
+func (rs *RateLimitStore) Prune(ctx context.Context, unused time.Duration) (int64, error) {
+	query := `
+  DELETE FROM rate_limits
+  WHERE updated_at < now() - $1 * INTERVAL '1 second'`
+
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, unused.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+)
+
+func TestTake(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// before we actually execute our api function, we need to expect required DB actions
+	mock.ExpectQuery(`INSERT INTO rate_limits AS rl (.+) ON CONFLICT \(key\) DO UPDATE (.+) RETURNING tokens`).
+		WithArgs("foo", 0.5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(1.0))
+
+	rs := New(database.New(db, "mock-db", 0))
+	tokens, ok, err := rs.Take(context.Background(), "foo", 0.5, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !ok || tokens != 1 {
+		t.Errorf("want 1 token taken got %v %v", tokens, ok)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// an empty bucket is not updated
+func TestTakeEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO rate_limits AS rl (.+) RETURNING tokens`).
+		WithArgs("foo", 0.5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}))
+	mock.ExpectQuery(`SELECT LEAST(.+) FROM rate_limits WHERE key = \$1`).
+		WithArgs("foo", 0.5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(0.25))
+
+	rs := New(database.New(db, "mock-db", 0))
+	tokens, ok, err := rs.Take(context.Background(), "foo", 0.5, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ok || tokens != 0.25 {
+		t.Errorf("want no token taken got %v %v", tokens, ok)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
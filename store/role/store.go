@@ -0,0 +1,228 @@
+package role
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// RoleStore handles operations on the player_roles and roster_roles tables of
+// the encapsulated datastore.
+type RoleStore struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *RoleStore {
+	return &RoleStore{
+		db: db,
+	}
+}
+
+// Requirements returns the role requirements of the roster ordered by role.
+func (rs *RoleStore) Requirements(ctx context.Context, rosterID uint64) ([]store.RoleRequirement, error) {
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM rosters WHERE id = $1)`, rosterID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, store.ErrNotFound
+	}
+	return Requirements(ctx, db, rosterID)
+}
+
+// SetRequirements replaces the role requirements of the roster. Without
+// requirements of its own, the defaults of the game title of the roster
+// apply. Returns the requirements in effect, store.ErrNotFound if the roster
+// does not exist or a *store.RoleError if the active players of the roster do
+// not satisfy them.
+func (rs *RoleStore) SetRequirements(ctx context.Context, rosterID uint64, requirements []store.RoleRequirement) ([]store.RoleRequirement, error) {
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := setRequirements(ctx, tx, rosterID, requirements); err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("roster_id", rosterID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return Requirements(ctx, db, rosterID)
+}
+
+func setRequirements(ctx context.Context, tx *sql.Tx, rosterID uint64, requirements []store.RoleRequirement) error {
+	// the roster is locked, so that concurrent changes of its requirements
+	// are checked one after another
+	var id uint64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM rosters WHERE id = $1 FOR UPDATE`, rosterID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM roster_roles WHERE roster_id = $1`, rosterID); err != nil {
+		return err
+	}
+	for _, r := range requirements {
+		_, err := tx.ExecContext(ctx, `
+  INSERT INTO roster_roles(roster_id,role,count)
+  VALUES($1,$2,$3)`, rosterID, r.Role, r.Count)
+		if err != nil {
+			return err
+		}
+	}
+	return Check(ctx, tx, rosterID)
+}
+
+// SetRoles replaces the roles of the player. Returns the player with its
+// roles, store.ErrNotFound if the player does not exist or a *store.RoleError
+// if the active players of its roster would no longer satisfy the role
+// requirements of the roster.
+func (rs *RoleStore) SetRoles(ctx context.Context, playerID uint64, roles []string) (*store.Player, error) {
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	p, err := setRoles(ctx, tx, playerID, roles)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("player_id", playerID).Msg("failed rollback transaction")
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func setRoles(ctx context.Context, tx *sql.Tx, playerID uint64, roles []string) (*store.Player, error) {
+	var p store.Player
+	err := tx.QueryRowContext(ctx, `
+  SELECT id, roster_id, first_name, last_name, alias, status
+  FROM players
+  WHERE id = $1
+  FOR UPDATE`, playerID).
+		Scan(
+			&p.PlayerID,
+			&p.RosterID,
+			&p.FirstName,
+			&p.LastName,
+			&p.Alias,
+			&p.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM player_roles WHERE player_id = $1`, playerID); err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		_, err := tx.ExecContext(ctx, `
+  INSERT INTO player_roles(player_id,role)
+  VALUES($1,$2)
+  ON CONFLICT DO NOTHING`, playerID, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.Status == store.Active {
+		if err := Check(ctx, tx, p.RosterID); err != nil {
+			return nil, err
+		}
+	}
+	if len(roles) > 0 {
+		p.Roles = append([]string(nil), roles...)
+		sort.Strings(p.Roles)
+	}
+	return &p, nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Requirements returns the role requirements in effect for the roster ordered
+// by role. These are the requirements of the roster or, if it has none, of
+// its game title.
+func Requirements(ctx context.Context, q querier, rosterID uint64) ([]store.RoleRequirement, error) {
+	query := `
+  SELECT role, count
+  FROM roster_requirements
+  WHERE roster_id = $1
+  ORDER BY role`
+
+	rows, err := q.QueryContext(ctx, query, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requirements := make([]store.RoleRequirement, 0)
+	for rows.Next() {
+		var r store.RoleRequirement
+		if err := rows.Scan(&r.Role, &r.Count); err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, rows.Err()
+}
+
+// Check returns a *store.RoleError for the first role requirement of the
+// given strict rosters that is not satisfied by their active players. It is
+// called within the transaction that changes the active players of the
+// rosters.
+func Check(ctx context.Context, tx *sql.Tx, rosterIDs ...uint64) error {
+	query := `
+  SELECT r.roster_id, r.role, r.count, COUNT(pr.player_id)
+  FROM roster_requirements AS r
+  INNER JOIN rosters ON rosters.id = r.roster_id AND rosters.compliance = 'strict'
+  LEFT JOIN players AS p ON p.roster_id = r.roster_id AND p.status = 'active'
+  LEFT JOIN player_roles AS pr ON pr.player_id = p.id AND pr.role = r.role
+  WHERE r.roster_id = ANY($1)
+  GROUP BY r.roster_id, r.role, r.count
+  HAVING COUNT(pr.player_id) < r.count
+  ORDER BY r.roster_id, r.role
+  LIMIT 1`
+
+	params := make([]int64, len(rosterIDs))
+	for i, id := range rosterIDs {
+		params[i] = int64(id)
+	}
+	var e store.RoleError
+	err := tx.QueryRowContext(ctx, query, pq.Array(params)).
+		Scan(&e.RosterID, &e.Requirement.Role, &e.Requirement.Count, &e.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &e
+}
@@ -0,0 +1,105 @@
+package role
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+)
+
+var roleColumns = []string{"roster_id", "role", "count", "active"}
+
+func TestSetRequirements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM rosters WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM roster_roles WHERE roster_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO roster_roles(.*)VALUES(.*)`).
+		WithArgs(1, "igl", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WithArgs(pq.Array([]int64{1})).
+		WillReturnRows(sqlmock.NewRows(roleColumns))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT role, count FROM roster_requirements WHERE roster_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "count"}).AddRow("igl", 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM rosters WHERE id = \$1 FOR UPDATE`).
+		WithArgs(2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+	rs := New(database.New(db, "mock-db", 0))
+	want := []store.RoleRequirement{{Role: "igl", Count: 1}}
+	got, err := rs.SetRequirements(ctx, 1, want)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+	if _, err := rs.SetRequirements(ctx, 2, want); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if the active player was the only one with a required role
+func TestSetRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM players WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "roster_id", "first_name", "last_name", "alias", "status"}).
+			AddRow(1, 2, "foo", "bar", "foobar", store.Active))
+	mock.ExpectExec(`DELETE FROM player_roles WHERE player_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO player_roles(.*)VALUES(.*)ON CONFLICT DO NOTHING`).
+		WithArgs(1, "support").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WithArgs(pq.Array([]int64{2})).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(2, "igl", 1, 0))
+	mock.ExpectRollback()
+
+	rs := New(database.New(db, "mock-db", 0))
+	_, err = rs.SetRoles(context.Background(), 1, []string{"support"})
+	var roleErr *store.RoleError
+	if !errors.As(err, &roleErr) {
+		t.Fatalf("want role error got %v", err)
+	}
+	want := store.RoleError{RosterID: 2, Requirement: store.RoleRequirement{Role: "igl", Count: 1}}
+	if !reflect.DeepEqual(want, *roleErr) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, *roleErr)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
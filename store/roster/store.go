@@ -2,37 +2,211 @@ package roster
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/fgrimme/patrongg/database"
 	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/role"
+	"github.com/fgrimme/patrongg/store/title"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	raiseException      = "P0001" // raised by the roster constraint triggers
 )
 
 // RosterStore handles operations on the the
 // rosters table of the encapsulated datastore.
 type RosterStore struct {
-	db *database.DB
+	db  *database.DB
+	ids store.IDGenerator
 }
 
-func New(db *database.DB) *RosterStore {
+func New(db *database.DB, ids store.IDGenerator) *RosterStore {
 	return &RosterStore{
-		db: db,
+		db:  db,
+		ids: ids,
 	}
 }
 
+// Create inserts a new roster along with its active and benched players in a
+// single transaction. The ids of the given roster and players are ignored, new
+// ones are generated. Returns the created roster, an error wrapping
+// store.ErrExists if a roster with the same name exists in the organization of
+// the roster, an error wrapping store.ErrNotFound if the organization or game
+// title does not exist, a *store.ConflictError if the players violate the
+// rules of the title or a *store.RoleError if the active players do not
+// satisfy the role requirements of the title. Rosters without title must have
+// store.ActiveSize active players.
+func (rs *RosterStore) Create(ctx context.Context, roster store.Roster) (*store.Roster, error) {
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rollback := func() {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed rollback transaction")
+		}
+	}
+
+	// the rules are checked by the deferred triggers too, but we can report
+	// violations before inserting any player
+	rules, err := title.Rules(ctx, tx, roster.TitleID)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	created := store.Roster{
+		OrgID:   roster.OrgID,
+		TitleID: roster.TitleID,
+		Players: store.Players{
+			Active:  make([]store.Player, 0, len(roster.Players.Active)),
+			Benched: make([]store.Player, 0, len(roster.Players.Benched)),
+		},
+	}
+	err = tx.QueryRowContext(ctx, `
+  INSERT INTO rosters(id,name,org_id,title_id)
+  VALUES($1,$2,NULLIF($3, CAST(0 AS BIGINT)),NULLIF($4, CAST(0 AS BIGINT)))
+  RETURNING id, name`, rs.ids.Next(), roster.Name, roster.OrgID, roster.TitleID).
+		Scan(&created.RosterID, &created.Name)
+	if err != nil {
+		rollback()
+		return nil, rosterError(err, roster)
+	}
+	if reason := rules.Violation(len(roster.Players.Active), len(roster.Players.Benched)); reason != "" {
+		rollback()
+		return nil, &store.ConflictError{RosterID: created.RosterID, Reason: reason}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+  INSERT INTO players(id,roster_id,first_name,last_name,alias,status)
+  VALUES($1,$2,$3,$4,$5,$6)
+  RETURNING *`)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+
+	insert := func(player store.Player, status string) (store.Player, error) {
+		var p store.Player
+		err := stmt.QueryRowContext(ctx,
+			rs.ids.Next(),
+			created.RosterID,
+			player.FirstName,
+			player.LastName,
+			player.Alias,
+			status).
+			Scan(
+				&p.PlayerID,
+				&p.RosterID,
+				&p.FirstName,
+				&p.LastName,
+				&p.Alias,
+				&p.Status)
+		if err != nil {
+			return p, err
+		}
+		for _, r := range player.Roles {
+			_, err := tx.ExecContext(ctx, `
+  INSERT INTO player_roles(player_id,role)
+  VALUES($1,$2)
+  ON CONFLICT DO NOTHING`, p.PlayerID, r)
+			if err != nil {
+				return p, err
+			}
+		}
+		if len(player.Roles) > 0 {
+			p.Roles = append([]string(nil), player.Roles...)
+			sort.Strings(p.Roles)
+		}
+		return p, nil
+	}
+	for _, player := range roster.Players.Active {
+		p, err := insert(player, store.Active)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		created.Players.Active = append(created.Players.Active, p)
+	}
+	for _, player := range roster.Players.Benched {
+		p, err := insert(player, store.Benched)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		created.Players.Benched = append(created.Players.Benched, p)
+	}
+
+	// new rosters have no role requirements of their own, only those of
+	// their title
+	if roster.TitleID != 0 {
+		if err := role.Check(ctx, tx, created.RosterID); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		return nil, rosterError(err, created)
+	}
+	return &created, nil
+}
+
+// rosterError translates errors of the datastore into errors of the store
+// package. Unknown errors are returned unchanged.
+func rosterError(err error, roster store.Roster) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case uniqueViolation:
+		return fmt.Errorf("roster %s: %w", roster.Name, store.ErrExists)
+	case foreignKeyViolation:
+		return fmt.Errorf("organization %d: %w", roster.OrgID, store.ErrNotFound)
+	case raiseException:
+		return &store.ConflictError{RosterID: roster.RosterID, Reason: pqErr.Message}
+	}
+	return err
+}
+
 // Get returns a representation of the entire roster for the given id or an error.
+// Returns store.ErrNotFound if the roster does not exist. Rosters without
+// players, which lenient rosters may end up with, are returned as well.
 func (rs *RosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster, error) {
 	query := `
   SELECT
     rosters.id,
     rosters.name,
-    p.id,
-    p.first_name,
-    p.last_name,
-    p.alias,
-    p.status
-  FROM players as p
-  INNER JOIN rosters ON p.roster_id = rosters.id
-  WHERE p.roster_id = $1`
+    COALESCE(rosters.org_id, 0),
+    COALESCE(rosters.title_id, 0),
+    titles.active_size,
+    titles.max_benched,
+    COALESCE(p.id, 0),
+    COALESCE(p.first_name, ''),
+    COALESCE(p.last_name, ''),
+    COALESCE(p.alias, ''),
+    COALESCE(p.status, ''),
+    ARRAY(SELECT role FROM player_roles WHERE player_id = p.id ORDER BY role)
+  FROM rosters
+  LEFT JOIN players AS p ON p.roster_id = rosters.id
+  LEFT JOIN titles ON titles.id = rosters.title_id
+  WHERE rosters.id = $1`
 
 	db := rs.db.GetDB()
 	ctx, cancel := rs.db.RequestContext(ctx)
@@ -50,23 +224,39 @@ func (rs *RosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster,
 	}
 	var id uint64
 	var rosterName string
+	var orgID uint64
+	var titleID uint64
+	var activeSize sql.NullInt64
+	var maxBenched sql.NullInt64
 	var playerID uint64
 	var firstName string
 	var lastName string
 	var alias string
 	var status string
+	var found bool
 	for rows.Next() {
+		found = true
+		var roles pq.StringArray
 		if err := rows.Scan(
 			&id,
 			&rosterName,
+			&orgID,
+			&titleID,
+			&activeSize,
+			&maxBenched,
 			&playerID,
 			&firstName,
 			&lastName,
 			&alias,
 			&status,
+			&roles,
 		); err != nil {
 			return nil, err
 		}
+		// rosters without players are returned once without player columns
+		if playerID == 0 {
+			continue
+		}
 		p := store.Player{
 			PlayerID:  playerID,
 			RosterID:  id,
@@ -75,15 +265,168 @@ func (rs *RosterStore) Get(ctx context.Context, rosterID uint64) (*store.Roster,
 			Alias:     alias,
 			Status:    status,
 		}
-		if status == "active" {
+		if len(roles) > 0 {
+			p.Roles = roles
+		}
+		// released players are no longer members of the roster
+		switch status {
+		case store.Active:
 			players.Active = append(players.Active, p)
-		} else {
+		case store.Benched:
 			players.Benched = append(players.Benched, p)
 		}
 	}
-	return &store.Roster{
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+	// the violations are evaluated once per roster rather than once per
+	// player, the roster_violations view is expensive
+	var nonCompliant bool
+	err = db.QueryRowContext(ctx, `
+  SELECT EXISTS(
+    SELECT 1
+    FROM roster_violations
+    WHERE roster_id = $1)`, rosterID).
+		Scan(&nonCompliant)
+	if err != nil {
+		return nil, err
+	}
+	requirements, err := role.Requirements(ctx, db, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	rules := store.DefaultRules
+	if activeSize.Valid {
+		rules = store.Rules{ActiveSize: int(activeSize.Int64)}
+	}
+	if maxBenched.Valid {
+		n := int(maxBenched.Int64)
+		rules.MaxBenched = &n
+	}
+	roster := &store.Roster{
 		RosterID: id,
+		OrgID:    orgID,
+		TitleID:  titleID,
 		Name:     rosterName,
 		Players:  players,
-	}, rows.Err()
+		Rules:    &rules,
+	}
+	// lenient rosters and rosters with overdue contracts may violate their
+	// rules
+	roster.NonCompliant = nonCompliant
+	if len(requirements) > 0 {
+		roster.Requirements = requirements
+	}
+	return roster, nil
+}
+
+// Export streams the players of the roster with the given id to fn, one row at
+// a time ordered by status and player id. If rosterID is 0, the players of all
+// rosters are exported ordered by roster id first. Streaming stops at the first
+// error returned by fn. Rosters without players export no rows, but
+// store.ErrNotFound is returned if a single roster is requested that does not
+// exist. Exports are not bounded by the request timeout of the database but by
+// ctx only, since they stream all players.
+func (rs *RosterStore) Export(ctx context.Context, rosterID uint64, fn func(store.RosterRow) error) error {
+	query := `
+  SELECT
+    rosters.id,
+    rosters.name,
+    p.id,
+    p.first_name,
+    p.last_name,
+    p.alias,
+    p.status
+  FROM players as p
+  INNER JOIN rosters ON p.roster_id = rosters.id
+  WHERE p.status <> 'released'`
+	var args []interface{}
+	if rosterID != 0 {
+		query += `
+  AND p.roster_id = $1`
+		args = append(args, rosterID)
+	}
+	query += `
+  ORDER BY rosters.id, p.status, p.id`
+
+	db := rs.db.GetDB()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var found bool
+	for rows.Next() {
+		found = true
+		var row store.RosterRow
+		if err := rows.Scan(
+			&row.Player.RosterID,
+			&row.RosterName,
+			&row.Player.PlayerID,
+			&row.Player.FirstName,
+			&row.Player.LastName,
+			&row.Player.Alias,
+			&row.Player.Status,
+		); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if found || rosterID == 0 {
+		return nil
+	}
+	// rosters without players to export exist nevertheless, e.g. lenient
+	// rosters whose players were released
+	var exists bool
+	err = db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM rosters WHERE id = $1)`, rosterID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// List returns the rosters ordered by id. If name is not empty, only rosters
+// whose name contains it case-insensitively are returned. The players of the
+// rosters are not loaded.
+func (rs *RosterStore) List(ctx context.Context, name string) ([]store.Roster, error) {
+	query := `
+  SELECT id, name, COALESCE(org_id, 0), COALESCE(title_id, 0)
+  FROM rosters
+  WHERE $1 = '' OR name ILIKE '%' || $1 || '%'
+  ORDER BY id`
+
+	db := rs.db.GetDB()
+	ctx, cancel := rs.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rosters := make([]store.Roster, 0)
+	for rows.Next() {
+		var r store.Roster
+		if err := rows.Scan(&r.RosterID, &r.Name, &r.OrgID, &r.TitleID); err != nil {
+			return nil, err
+		}
+		rosters = append(rosters, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rosters, nil
 }
@@ -2,12 +2,16 @@ package roster
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
 	"github.com/fgrimme/patrongg/testdata"
+	"github.com/lib/pq"
 )
 
 func TestGet(t *testing.T) {
@@ -17,21 +21,243 @@ func TestGet(t *testing.T) {
 	}
 	defer db.Close()
 
+	// before we actually execute our api function, we need to expect required DB actions
+	columns := []string{"roster_id", "roster_name", "org_id", "title_id", "active_size", "max_benched", "id", "first_name", "last_name", "alias", "active", "roles"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 182919996442279937, "Dominic", "Luklowski", "DataSlayer9", "active", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 337332768876789763, "Jane", "Beddingfield", "__Jain", "active", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 444322878230495243, "Phillip", "Aaronivic", "phikic", "active", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 602403447886839809, "Ji", "Bhok", "TARG3T", "active", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 622318474387128331, "Damian", "Grey", "Klikx", "active", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 184315303323238400, "Oliver", "Fieldbutter", "Smaayo", "benched", "{}").
+		AddRow(382574876546039808, "foo", 0, 0, nil, nil, 184315303323238401, "Released", "Player", "gone", "released", "{}")
+
+	query := `SELECT (.+) FROM rosters LEFT JOIN players AS p ON p.roster_id = rosters.id LEFT JOIN titles (.+) WHERE rosters.id = \$1`
+	violations := `SELECT EXISTS\( SELECT 1 FROM roster_violations WHERE roster_id = \$1\)`
+	mock.ExpectQuery(query).WithArgs(382574876546039808).WillReturnRows(rows)
+	mock.ExpectQuery(violations).WithArgs(382574876546039808).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT role, count FROM roster_requirements WHERE roster_id = \$1`).
+		WithArgs(382574876546039808).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "count"}))
+	// a lenient roster that lost all of its players
+	mock.ExpectQuery(query).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "empty", 0, 0, nil, nil, 0, "", "", "", "", "{}"))
+	mock.ExpectQuery(violations).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT role, count FROM roster_requirements WHERE roster_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "count"}))
+	mock.ExpectQuery(query).WithArgs(2).WillReturnRows(sqlmock.NewRows(columns))
+
+	// rosters without title follow the default rules, released players are
+	// omitted
+	want := testdata.Rosters[382574876546039808].R
+	want.Rules = &store.DefaultRules
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := rs.Get(context.Background(), want.RosterID)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	empty := &store.Roster{
+		RosterID:     1,
+		Name:         "empty",
+		Players:      store.Players{Active: []store.Player{}, Benched: []store.Player{}},
+		Rules:        &store.DefaultRules,
+		NonCompliant: true,
+	}
+	if got, err := rs.Get(context.Background(), 1); err != nil || !reflect.DeepEqual(empty, got) {
+		t.Errorf("want\n%+v\ngot\n%+v %v", empty, got, err)
+	}
+	if _, err := rs.Get(context.Background(), 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
 	// before we actually execute our api function, we need to expect required DB actions
 	rows := sqlmock.NewRows([]string{"roster_id", "roster_name", "id", "first_name", "last_name", "alias", "active"}).
 		AddRow(382574876546039808, "foo", 182919996442279937, "Dominic", "Luklowski", "DataSlayer9", "active").
-		AddRow(382574876546039808, "foo", 337332768876789763, "Jane", "Beddingfield", "__Jain", "active").
-		AddRow(382574876546039808, "foo", 444322878230495243, "Phillip", "Aaronivic", "phikic", "active").
-		AddRow(382574876546039808, "foo", 602403447886839809, "Ji", "Bhok", "TARG3T", "active").
-		AddRow(382574876546039808, "foo", 622318474387128331, "Damian", "Grey", "Klikx", "active").
 		AddRow(382574876546039808, "foo", 184315303323238400, "Oliver", "Fieldbutter", "Smaayo", "benched")
 
-	query := `SELECT (.+) FROM players as p INNER JOIN rosters ON p.roster_id = rosters.id WHERE p.roster_id = \$1`
-	mock.ExpectQuery(query).WillReturnRows(rows)
+	query := `SELECT (.+) FROM players as p INNER JOIN rosters ON p.roster_id = rosters.id WHERE p.status <> 'released' AND p.roster_id = \$1 ORDER BY rosters.id, p.status, p.id`
+	mock.ExpectQuery(query).WithArgs(382574876546039808).WillReturnRows(rows)
 
-	want := testdata.Rosters[382574876546039808].R
-	rs := New(database.New(db, "mock-db", 0))
-	got, err := rs.Get(context.Background(), want.RosterID)
+	roster := testdata.Rosters[382574876546039808].R
+	want := []store.RosterRow{
+		{RosterName: roster.Name, Player: roster.Players.Active[0]},
+		{RosterName: roster.Name, Player: roster.Players.Benched[0]},
+	}
+	var got []store.RosterRow
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	err = rs.Export(context.Background(), roster.RosterID, func(row store.RosterRow) error {
+		got = append(got, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// exports rosters without players, of which roster 2 does not exist
+func TestExportEmpty(t *testing.T) {
+	tests := []struct {
+		d  string // description of test case
+		id uint64 // roster id
+		ok bool   // roster exists
+		e  error  // expected error
+	}{
+		{
+			d:  "expect empty export of roster without players",
+			id: 1,
+			ok: true,
+		},
+		{
+			d:  "expect missing roster to result in not found",
+			id: 2,
+			e:  store.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"roster_id", "roster_name", "id", "first_name", "last_name", "alias", "active"})
+			mock.ExpectQuery(`SELECT (.+) FROM players as p INNER JOIN rosters (.+) AND p.roster_id = \$1`).
+				WithArgs(tt.id).WillReturnRows(rows)
+			mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM rosters WHERE id = \$1\)`).
+				WithArgs(tt.id).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.ok))
+
+			rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+			var n int
+			err = rs.Export(context.Background(), tt.id, func(row store.RosterRow) error {
+				n++
+				return nil
+			})
+			if !errors.Is(err, tt.e) {
+				t.Errorf("want error %v got %v", tt.e, err)
+			}
+			if n != 0 {
+				t.Errorf("want no rows got %d", n)
+			}
+			// we make sure that all expectations were met
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "org_id", "title_id"}).
+		AddRow(1, "foo", 0, 0).
+		AddRow(382574876546039808, "foobar", 7, 3)
+
+	query := `SELECT id, name, COALESCE\(org_id, 0\), COALESCE\(title_id, 0\) FROM rosters WHERE (.+) ORDER BY id`
+	mock.ExpectQuery(query).WithArgs("foo").WillReturnRows(rows)
+
+	want := []store.Roster{
+		{RosterID: 1, Name: "foo"},
+		{RosterID: 382574876546039808, OrgID: 7, TitleID: 3, Name: "foobar"},
+	}
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := rs.List(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// creates a roster of a title with one active and one benched player
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	// before we actually execute our api function, we need to expect required DB actions
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT active_size, max_benched FROM titles WHERE id = \$1 FOR SHARE`).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"active_size", "max_benched"}).AddRow(1, 1))
+	mock.ExpectQuery(`INSERT INTO rosters\(id,name,org_id,title_id\) VALUES(.+) RETURNING id, name`).
+		WithArgs(1, "foo", 4, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectPrepare(`INSERT INTO players(.*)VALUES(.*) RETURNING *`)
+	columns := []string{"id", "roster_id", "first_name", "last_name", "alias", "status"}
+	mock.ExpectQuery(`INSERT INTO players(.*)VALUES(.*) RETURNING *`).
+		WithArgs(2, 1, "foo", "bar", "foobar", "active").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 1, "foo", "bar", "foobar", "active"))
+	mock.ExpectExec(`INSERT INTO player_roles(.*)VALUES(.*)`).
+		WithArgs(2, "igl").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO players(.*)VALUES(.*) RETURNING *`).
+		WithArgs(3, 1, "boo", "baz", "boobaz", "benched").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "boo", "baz", "boobaz", "benched"))
+	mock.ExpectQuery(`SELECT (.+) FROM roster_requirements AS r (.+) HAVING COUNT\(pr.player_id\) < r.count`).
+		WillReturnRows(sqlmock.NewRows([]string{"roster_id", "role", "count", "active"}))
+	mock.ExpectCommit()
+
+	// ids and statuses of the given players are ignored
+	in := store.Roster{
+		RosterID: 7,
+		OrgID:    4,
+		TitleID:  9,
+		Name:     "foo",
+		Players: store.Players{
+			Active:  []store.Player{{PlayerID: 7, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "benched", Roles: []string{"igl"}}},
+			Benched: []store.Player{{FirstName: "boo", LastName: "baz", Alias: "boobaz"}},
+		},
+	}
+	want := &store.Roster{
+		RosterID: 1,
+		OrgID:    4,
+		TitleID:  9,
+		Name:     "foo",
+		Players: store.Players{
+			Active:  []store.Player{{PlayerID: 2, RosterID: 1, FirstName: "foo", LastName: "bar", Alias: "foobar", Status: "active", Roles: []string{"igl"}}},
+			Benched: []store.Player{{PlayerID: 3, RosterID: 1, FirstName: "boo", LastName: "baz", Alias: "boobaz", Status: "benched"}},
+		},
+	}
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := rs.Create(context.Background(), in)
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
 	}
@@ -43,3 +269,108 @@ func TestGet(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+// rosters without title must have store.ActiveSize active players
+func TestCreateViolatesRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rosters`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectRollback()
+
+	in := store.Roster{
+		Name: "foo",
+		Players: store.Players{
+			Active: []store.Player{{FirstName: "foo", LastName: "bar", Alias: "foobar"}},
+		},
+	}
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = rs.Create(context.Background(), in)
+	var conflict *store.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error got %v", err)
+	}
+	if want := "must have exactly 5 active players, not 1"; conflict.Reason != want {
+		t.Errorf("want reason %q got %q", want, conflict.Reason)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if the title of the roster does not exist
+func TestCreateMissingTitle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT active_size, max_benched FROM titles`).
+		WillReturnRows(sqlmock.NewRows([]string{"active_size", "max_benched"}))
+	mock.ExpectRollback()
+
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = rs.Create(context.Background(), store.Roster{Name: "foo", TitleID: 9})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rosters`).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = rs.Create(context.Background(), store.Roster{Name: "foo"})
+	if !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// fails if the organization of the roster does not exist
+func TestCreateMissingOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rosters`).
+		WillReturnError(&pq.Error{Code: foreignKeyViolation})
+	mock.ExpectRollback()
+
+	rs := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	_, err = rs.Create(context.Background(), store.Roster{Name: "foo", OrgID: 4})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
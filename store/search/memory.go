@@ -0,0 +1,65 @@
+package search
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// MemoryStore searches players kept in memory. It matches, ranks and
+// highlights like the players store of the datastore and stands in for it in
+// tests.
+type MemoryStore struct {
+	players []store.Player
+}
+
+func NewMemoryStore(players ...store.Player) *MemoryStore {
+	return &MemoryStore{
+		players: append([]store.Player(nil), players...),
+	}
+}
+
+// Search returns the players matching the query, best matches first. Players
+// of equal rank are ordered by id.
+func (s *MemoryStore) Search(ctx context.Context, q store.SearchQuery) ([]store.SearchResult, error) {
+	text := Normalize(q.Text)
+	rosters := make(map[uint64]bool, len(q.RosterIDs))
+	for _, id := range q.RosterIDs {
+		rosters[id] = true
+	}
+
+	results := make([]store.SearchResult, 0)
+	for _, p := range s.players {
+		if len(rosters) > 0 && !rosters[p.RosterID] {
+			continue
+		}
+		if q.Status != "" && p.Status != q.Status {
+			continue
+		}
+		r := store.SearchResult{Player: p, Highlights: make(map[string]string)}
+		for name, value := range Fields(p) {
+			rank, ok := Match(value, text)
+			if !ok {
+				continue
+			}
+			if rank > r.Rank {
+				r.Rank = rank
+			}
+			r.Highlights[name] = Highlight(value, text)
+		}
+		if len(r.Highlights) > 0 {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].PlayerID < results[j].PlayerID
+	})
+	if n := Limit(q); len(results) > n {
+		results = results[:n]
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+// Package search implements the fuzzy search of players by their first name,
+// last name and alias. A field matches a search text if it contains the text
+// or if it is similar to the text in terms of trigrams as computed by the
+// pg_trgm extension of Postgres, so that results of the datastore and of the
+// MemoryStore agree.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+// Threshold is the similarity a field must reach to match a search text, the
+// default similarity threshold of pg_trgm.
+const Threshold = 0.3
+
+// Limits of the number of results of a search.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Normalize returns the search text as it is matched against the fields.
+func Normalize(text string) string {
+	return strings.ToLower(strings.TrimSpace(text))
+}
+
+// Fields returns the searched fields of the player by their JSON names.
+func Fields(p store.Player) map[string]string {
+	return map[string]string{
+		"first_name": p.FirstName,
+		"last_name":  p.LastName,
+		"alias":      p.Alias,
+	}
+}
+
+// Match returns the rank of the field for the normalized search text and
+// whether the field matches at all. The rank is the mean of the similarity of
+// the field and 1 if the field contains the text, so that fields containing the
+// text rank above fields that are only similar.
+func Match(field, text string) (float64, bool) {
+	sim := Similarity(field, text)
+	contains := strings.Contains(strings.ToLower(field), text)
+	rank := sim / 2
+	if contains {
+		rank += 0.5
+	}
+	return rank, contains || sim >= Threshold
+}
+
+// Similarity returns the number of trigrams shared by a and b divided by the
+// number of distinct trigrams of both, like similarity of pg_trgm.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the trigrams of the words of s. Words are runs of letters
+// and digits in lower case, padded with two spaces in front and one behind.
+func trigrams(s string) map[string]struct{} {
+	t := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			t[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return t
+}
+
+// Highlight encloses the occurrences of the normalized search text in the
+// value in <em> tags. Values that are only similar to the text are enclosed
+// as a whole. The value is HTML-escaped, so that highlights are safe to be
+// rendered as markup.
+func Highlight(value, text string) string {
+	v, n := []rune(value), len([]rune(text))
+	var b strings.Builder
+	found := false
+	for i := 0; i < len(v); {
+		if n > 0 && i+n <= len(v) && strings.EqualFold(string(v[i:i+n]), text) {
+			b.WriteString("<em>" + html.EscapeString(string(v[i:i+n])) + "</em>")
+			i += n
+			found = true
+			continue
+		}
+		b.WriteString(html.EscapeString(string(v[i])))
+		i++
+	}
+	if !found {
+		return "<em>" + html.EscapeString(value) + "</em>"
+	}
+	return b.String()
+}
+
+// Limit returns the number of results of the query within the limits.
+func Limit(q store.SearchQuery) int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultLimit
+	case q.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return q.Limit
+}
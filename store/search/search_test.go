@@ -0,0 +1,127 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/fgrimme/patrongg/store"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		s    float64 // expected similarity as computed by pg_trgm
+	}{
+		{"word", "two words", 4.0 / 11},
+		{"DataSlayer9", "dataslayer9", 1},
+		{"Dominic", "dominik", 6.0 / 10},
+		{"__Jain", "jain", 1},
+		{"foo", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.a, tt.b), func(t *testing.T) {
+			if want, got := tt.s, Similarity(tt.a, tt.b); math.Abs(want-got) > 1e-9 {
+				t.Errorf("want similarity %f got %f", want, got)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		v string // value of the field
+		q string // normalized search text
+		h string // expected highlight
+	}{
+		{"DataSlayer9", "slay", "Data<em>Slay</em>er9"},
+		{"Banana", "an", "B<em>an</em><em>an</em>a"},
+		{"Dominic", "dominik", "<em>Dominic</em>"},
+		// values are escaped
+		{"<img onerror=x>Slay", "slay", "&lt;img onerror=x&gt;<em>Slay</em>"},
+		{"a<b", "a<b", "<em>a&lt;b</em>"},
+		{"<script>", "scrypt", "<em>&lt;script&gt;</em>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			if want, got := tt.h, Highlight(tt.v, tt.q); want != got {
+				t.Errorf("want highlight %q got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(
+		store.Player{PlayerID: 1, RosterID: 1, FirstName: "Dominic", LastName: "Luklowski", Alias: "DataSlayer9", Status: store.Active},
+		store.Player{PlayerID: 2, RosterID: 1, FirstName: "Jane", LastName: "Beddingfield", Alias: "__Jain", Status: store.Active},
+		store.Player{PlayerID: 3, RosterID: 2, FirstName: "Dominik", LastName: "Grey", Alias: "Klikx", Status: store.Benched},
+		store.Player{PlayerID: 4, RosterID: 2, FirstName: "Zed", LastName: "Grey", Alias: "<b>Zed</b>", Status: store.Benched},
+	)
+
+	tests := []struct {
+		d string            // description of test case
+		q store.SearchQuery // search query
+		p []uint64          // expected players in order
+		h map[string]string // expected highlights of the first result
+	}{
+		{
+			d: "expect substring of alias to match",
+			q: store.SearchQuery{Text: "slay"},
+			p: []uint64{1},
+			h: map[string]string{"alias": "Data<em>Slay</em>er9"},
+		},
+		{
+			d: "expect misspelled name to match, exact match first",
+			q: store.SearchQuery{Text: " Dominik "},
+			p: []uint64{3, 1},
+			h: map[string]string{"first_name": "<em>Dominik</em>"},
+		},
+		{
+			d: "expect escaped highlights",
+			q: store.SearchQuery{Text: "zed"},
+			p: []uint64{4},
+			h: map[string]string{"first_name": "<em>Zed</em>", "alias": "&lt;b&gt;<em>Zed</em>&lt;/b&gt;"},
+		},
+		{
+			d: "expect results of the rosters only",
+			q: store.SearchQuery{Text: "dominik", RosterIDs: []uint64{1}},
+			p: []uint64{1},
+		},
+		{
+			d: "expect results of the status only",
+			q: store.SearchQuery{Text: "dominik", Status: store.Active},
+			p: []uint64{1},
+		},
+		{
+			d: "expect limited results",
+			q: store.SearchQuery{Text: "dominik", Limit: 1},
+			p: []uint64{3},
+		},
+		{
+			d: "expect no results",
+			q: store.SearchQuery{Text: "zzz"},
+			p: []uint64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			results, err := s.Search(context.Background(), tt.q)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			got := make([]uint64, 0, len(results))
+			for _, r := range results {
+				got = append(got, r.PlayerID)
+			}
+			if !reflect.DeepEqual(tt.p, got) {
+				t.Fatalf("want players %v got %v", tt.p, got)
+			}
+			if tt.h != nil && !reflect.DeepEqual(tt.h, results[0].Highlights) {
+				t.Errorf("want highlights %v got %v", tt.h, results[0].Highlights)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+// Package storetest provides helpers for the tests of the datastores.
+package storetest
+
+// Sequence generates ids counting up from its value. It implements
+// store.IDGenerator.
+type Sequence uint64
+
+func (s *Sequence) Next() uint64 {
+	*s++
+	return uint64(*s)
+}
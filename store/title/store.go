@@ -0,0 +1,228 @@
+package title
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const uniqueViolation = "23505"
+
+// TitleStore handles operations on the titles and title_roles tables of the
+// encapsulated datastore.
+type TitleStore struct {
+	db  *database.DB
+	ids store.IDGenerator
+}
+
+func New(db *database.DB, ids store.IDGenerator) *TitleStore {
+	return &TitleStore{
+		db:  db,
+		ids: ids,
+	}
+}
+
+// Create inserts a new game title along with its role requirements in a
+// single transaction. The id of the given title is ignored, a new one is
+// generated. Returns the created title or an error wrapping store.ErrExists
+// if a title with the same name exists.
+func (ts *TitleStore) Create(ctx context.Context, title store.Title) (*store.Title, error) {
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	created, err := create(ctx, tx, ts.ids.Next(), title)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed rollback transaction")
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("title %s: %w", title.Name, store.ErrExists)
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func create(ctx context.Context, tx *sql.Tx, id uint64, title store.Title) (*store.Title, error) {
+	created := store.Title{
+		Requirements: make([]store.RoleRequirement, 0, len(title.Requirements)),
+	}
+	var maxBenched sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+  INSERT INTO titles(id,name,active_size,max_benched)
+  VALUES($1,$2,$3,$4)
+  RETURNING id, name, active_size, max_benched`, id, title.Name, title.ActiveSize, title.MaxBenched).
+		Scan(&created.TitleID, &created.Name, &created.ActiveSize, &maxBenched)
+	if err != nil {
+		return nil, err
+	}
+	created.MaxBenched = intOrNil(maxBenched)
+	for _, r := range title.Requirements {
+		_, err := tx.ExecContext(ctx, `
+  INSERT INTO title_roles(title_id,role,count)
+  VALUES($1,$2,$3)`, created.TitleID, r.Role, r.Count)
+		if err != nil {
+			return nil, err
+		}
+		created.Requirements = append(created.Requirements, r)
+	}
+	return &created, nil
+}
+
+// Get returns the game title with the given id along with its role
+// requirements ordered by role or store.ErrNotFound.
+func (ts *TitleStore) Get(ctx context.Context, titleID uint64) (*store.Title, error) {
+	query := `
+  SELECT id, name, active_size, max_benched
+  FROM titles
+  WHERE id = $1`
+
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	var t store.Title
+	var maxBenched sql.NullInt64
+	err := db.QueryRowContext(ctx, query, titleID).Scan(&t.TitleID, &t.Name, &t.ActiveSize, &maxBenched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.MaxBenched = intOrNil(maxBenched)
+	requirements, err := ts.requirements(ctx, db, titleID)
+	if err != nil {
+		return nil, err
+	}
+	t.Requirements = requirements[titleID]
+	if t.Requirements == nil {
+		t.Requirements = make([]store.RoleRequirement, 0)
+	}
+	return &t, nil
+}
+
+// List returns all game titles along with their role requirements ordered by
+// name.
+func (ts *TitleStore) List(ctx context.Context) ([]store.Title, error) {
+	query := `
+  SELECT id, name, active_size, max_benched
+  FROM titles
+  ORDER BY name`
+
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make([]store.Title, 0)
+	for rows.Next() {
+		var t store.Title
+		var maxBenched sql.NullInt64
+		if err := rows.Scan(&t.TitleID, &t.Name, &t.ActiveSize, &maxBenched); err != nil {
+			return nil, err
+		}
+		t.MaxBenched = intOrNil(maxBenched)
+		titles = append(titles, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	requirements, err := ts.requirements(ctx, db, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range titles {
+		titles[i].Requirements = requirements[titles[i].TitleID]
+		if titles[i].Requirements == nil {
+			titles[i].Requirements = make([]store.RoleRequirement, 0)
+		}
+	}
+	return titles, nil
+}
+
+// requirements returns the role requirements of the title with the given id,
+// or of all titles if titleID is 0, by title id.
+func (ts *TitleStore) requirements(ctx context.Context, db *sql.DB, titleID uint64) (map[uint64][]store.RoleRequirement, error) {
+	query := `
+  SELECT title_id, role, count
+  FROM title_roles
+  WHERE $1 = 0 OR title_id = $1
+  ORDER BY title_id, role`
+
+	rows, err := db.QueryContext(ctx, query, titleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requirements := make(map[uint64][]store.RoleRequirement)
+	for rows.Next() {
+		var id uint64
+		var r store.RoleRequirement
+		if err := rows.Scan(&id, &r.Role, &r.Count); err != nil {
+			return nil, err
+		}
+		requirements[id] = append(requirements[id], r)
+	}
+	return requirements, rows.Err()
+}
+
+// Rules returns the rules of the game title with the given id, locking the
+// title for the transaction. Rosters without title, i.e. titleID 0, follow
+// store.DefaultRules. Returns an error wrapping store.ErrNotFound if the title
+// does not exist.
+func Rules(ctx context.Context, tx *sql.Tx, titleID uint64) (store.Rules, error) {
+	if titleID == 0 {
+		return store.DefaultRules, nil
+	}
+	query := `
+  SELECT active_size, max_benched
+  FROM titles
+  WHERE id = $1
+  FOR SHARE`
+
+	var rules store.Rules
+	var maxBenched sql.NullInt64
+	err := tx.QueryRowContext(ctx, query, titleID).Scan(&rules.ActiveSize, &maxBenched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.Rules{}, fmt.Errorf("title %d: %w", titleID, store.ErrNotFound)
+	}
+	if err != nil {
+		return store.Rules{}, err
+	}
+	rules.MaxBenched = intOrNil(maxBenched)
+	return rules, nil
+}
+
+// intOrNil returns nil for NULL and a pointer to the integer otherwise.
+func intOrNil(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	i := int(n.Int64)
+	return &i
+}
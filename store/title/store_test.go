@@ -0,0 +1,146 @@
+package title
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+func intp(n int) *int { return &n }
+
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"id", "name", "active_size", "max_benched"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO titles(.*)VALUES(.*)RETURNING id, name, active_size, max_benched`).
+		WithArgs(1, "rocket league", 3, 2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "rocket league", 3, 2))
+	mock.ExpectExec(`INSERT INTO title_roles(.*)VALUES(.*)`).
+		WithArgs(1, "striker", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO titles`).
+		WithArgs(2, "rocket league", 3, nil).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	in := store.Title{
+		TitleID:      7,
+		Name:         "rocket league",
+		Rules:        store.Rules{ActiveSize: 3, MaxBenched: intp(2)},
+		Requirements: []store.RoleRequirement{{Role: "striker", Count: 1}},
+	}
+	got, err := ts.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := in
+	want.TitleID = 1
+	if !reflect.DeepEqual(&want, got) {
+		t.Errorf("want %+v got %+v", &want, got)
+	}
+	in.MaxBenched = nil
+	if _, err := ts.Create(ctx, in); !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"id", "name", "active_size", "max_benched"}
+	mock.ExpectQuery(`SELECT id, name, active_size, max_benched FROM titles WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "valorant", 5, nil))
+	mock.ExpectQuery(`SELECT title_id, role, count FROM title_roles WHERE (.+) ORDER BY title_id, role`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"title_id", "role", "count"}).
+			AddRow(1, "controller", 1).
+			AddRow(1, "duelist", 2))
+	mock.ExpectQuery(`SELECT id, name, active_size, max_benched FROM titles WHERE id = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	ctx := context.Background()
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ts.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Title{
+		TitleID: 1,
+		Name:    "valorant",
+		Rules:   store.Rules{ActiveSize: 5},
+		Requirements: []store.RoleRequirement{
+			{Role: "controller", Count: 1},
+			{Role: "duelist", Count: 2},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	if _, err := ts.Get(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want error %v got %v", store.ErrNotFound, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT id, name, active_size, max_benched FROM titles ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "active_size", "max_benched"}).
+			AddRow(2, "rocket league", 3, 2).
+			AddRow(1, "valorant", 5, nil))
+	mock.ExpectQuery(`SELECT title_id, role, count FROM title_roles`).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"title_id", "role", "count"}).
+			AddRow(1, "duelist", 2))
+
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence))
+	got, err := ts.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Title{
+		{TitleID: 2, Name: "rocket league", Rules: store.Rules{ActiveSize: 3, MaxBenched: intp(2)}, Requirements: []store.RoleRequirement{}},
+		{TitleID: 1, Name: "valorant", Rules: store.Rules{ActiveSize: 5}, Requirements: []store.RoleRequirement{{Role: "duelist", Count: 2}}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
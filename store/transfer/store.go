@@ -0,0 +1,422 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres error codes translated to store errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	raiseException      = "P0001" // raised by the roster constraint triggers
+)
+
+const columns = `id, player_id, from_roster_id, to_roster_id, status, requested_by, created_at, expires_at`
+
+// playerUpdater updates players within a transaction.
+type playerUpdater interface {
+	UpdateTx(ctx context.Context, tx *sql.Tx, player store.Player) (*store.Player, error)
+}
+
+// TransferStore handles operations on the transfer_requests and
+// transfer_events tables of the encapsulated datastore. Accepted transfers
+// move their player using the players store.
+type TransferStore struct {
+	db      *database.DB
+	ids     store.IDGenerator
+	players playerUpdater
+}
+
+func New(db *database.DB, ids store.IDGenerator, players playerUpdater) *TransferStore {
+	return &TransferStore{
+		db:      db,
+		ids:     ids,
+		players: players,
+	}
+}
+
+// Propose creates a proposed transfer of the player from its roster to the
+// destination roster. Returns the created transfer, store.ErrNotFound if the
+// player or one of the rosters does not exist or an error wrapping
+// store.ErrExists if a transfer of the player is proposed already.
+func (ts *TransferStore) Propose(ctx context.Context, transfer store.Transfer) (*store.Transfer, error) {
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanTransfer(tx.QueryRowContext(ctx, `
+  INSERT INTO transfer_requests(id,player_id,from_roster_id,to_roster_id,status,requested_by,expires_at)
+  VALUES($1,$2,$3,$4,$5,$6,$7)
+  RETURNING `+columns,
+		ts.ids.Next(),
+		transfer.PlayerID,
+		transfer.FromRosterID,
+		transfer.ToRosterID,
+		store.TransferProposed,
+		transfer.RequestedBy,
+		transfer.ExpiresAt))
+	if err == nil {
+		err = record(ctx, tx, t, transfer.RequestedBy)
+	}
+	if err != nil {
+		rollback(ctx, tx)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case uniqueViolation:
+				return nil, fmt.Errorf("transfer of player %d: %w", transfer.PlayerID, store.ErrExists)
+			case foreignKeyViolation:
+				return nil, store.ErrNotFound
+			}
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Get returns the transfer with the given id along with its history or
+// store.ErrNotFound.
+func (ts *TransferStore) Get(ctx context.Context, transferID uint64) (*store.Transfer, error) {
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	t, err := scanTransfer(db.QueryRowContext(ctx, `
+  SELECT `+columns+`
+  FROM transfer_requests
+  WHERE id = $1`, transferID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+  SELECT status, subject, created_at
+  FROM transfer_events
+  WHERE transfer_id = $1
+  ORDER BY created_at`, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t.History = make([]store.TransferEvent, 0)
+	for rows.Next() {
+		var e store.TransferEvent
+		if err := rows.Scan(&e.Status, &e.Subject, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.History = append(t.History, e)
+	}
+	return t, rows.Err()
+}
+
+// List returns the transfers from and to the roster, latest first. If status
+// is not empty, only transfers of the status are returned. The history of the
+// transfers is not loaded.
+func (ts *TransferStore) List(ctx context.Context, rosterID uint64, status string) ([]store.Transfer, error) {
+	query := `
+  SELECT ` + columns + `
+  FROM transfer_requests
+  WHERE (from_roster_id = $1 OR to_roster_id = $1)
+  AND ($2 = '' OR status = $2)
+  ORDER BY created_at DESC, id DESC`
+
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, rosterID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]store.Transfer, 0)
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *t)
+	}
+	return transfers, rows.Err()
+}
+
+// ListPlayers returns the transfers of the players, latest first. The history
+// of the transfers is not loaded.
+func (ts *TransferStore) ListPlayers(ctx context.Context, playerIDs []uint64) ([]store.Transfer, error) {
+	query := `
+  SELECT ` + columns + `
+  FROM transfer_requests
+  WHERE player_id = ANY($1)
+  ORDER BY created_at DESC, id DESC`
+
+	ids := make([]int64, len(playerIDs))
+	for i, id := range playerIDs {
+		ids[i] = int64(id)
+	}
+
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]store.Transfer, 0)
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *t)
+	}
+	return transfers, rows.Err()
+}
+
+// Accept moves the player of the proposed transfer to the destination roster
+// and marks the transfer accepted in a single transaction. The player is
+// benched in the destination roster. Returns the accepted transfer,
+// store.ErrNotFound, a *store.LockedError if one of the rosters is locked or
+// a *store.ConflictError if the transfer is not proposed, has expired, the
+// player left the source roster or the strict source roster would be left
+// without store.ActiveSize active players.
+func (ts *TransferStore) Accept(ctx context.Context, transferID uint64, subject string) (*store.Transfer, error) {
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, err := proposed(ctx, tx, transferID)
+	if err != nil {
+		return nil, finish(ctx, tx, t, err)
+	}
+
+	var rosterID uint64
+	err = tx.QueryRowContext(ctx, `
+  SELECT roster_id
+  FROM players
+  WHERE id = $1
+  FOR UPDATE`, t.PlayerID).
+		Scan(&rosterID)
+	if err != nil {
+		rollback(ctx, tx)
+		return nil, err
+	}
+	if rosterID != t.FromRosterID {
+		rollback(ctx, tx)
+		return nil, &store.ConflictError{
+			RosterID: t.FromRosterID,
+			Reason:   fmt.Sprintf("player %d is no longer a member of the roster", t.PlayerID),
+		}
+	}
+	_, err = ts.players.UpdateTx(ctx, tx, store.Player{
+		PlayerID: t.PlayerID,
+		RosterID: t.ToRosterID,
+		Status:   store.Benched,
+	})
+	if err != nil {
+		rollback(ctx, tx)
+		return nil, err
+	}
+	if t, err = setStatus(ctx, tx, t, store.TransferAccepted, subject); err != nil {
+		rollback(ctx, tx)
+		return nil, err
+	}
+
+	// the active player constraints are checked by the deferred triggers
+	if err := tx.Commit(); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == raiseException {
+			return nil, &store.ConflictError{RosterID: t.FromRosterID, Reason: pqErr.Message}
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// Decide changes the status of the proposed transfer to rejected or cancelled.
+// Returns the updated transfer, store.ErrNotFound or a *store.ConflictError
+// if the transfer is not proposed or has expired.
+func (ts *TransferStore) Decide(ctx context.Context, transferID uint64, status, subject string) (*store.Transfer, error) {
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, err := proposed(ctx, tx, transferID)
+	if err == nil {
+		t, err = setStatus(ctx, tx, t, status, subject)
+	}
+	if err != nil {
+		return nil, finish(ctx, tx, t, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Expire marks the proposed transfers that have expired. Returns the number
+// of expired transfers.
+func (ts *TransferStore) Expire(ctx context.Context) (int64, error) {
+	query := `
+  WITH expired AS (
+    UPDATE transfer_requests
+    SET status = $1
+    WHERE status = $2
+    AND expires_at <= now()
+    RETURNING id)
+  INSERT INTO transfer_events(transfer_id,status)
+  SELECT id, $1 FROM expired`
+
+	db := ts.db.GetDB()
+	ctx, cancel := ts.db.RequestContext(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, store.TransferExpired, store.TransferProposed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// errExpired is returned by proposed if the transfer has expired. The expiry
+// is recorded, so the transaction must be committed.
+var errExpired = errors.New("transfer has expired")
+
+// proposed locks the transfer with the given id until the end of the
+// transaction. Returns store.ErrNotFound, a *store.ConflictError if the
+// transfer is not proposed or errExpired.
+func proposed(ctx context.Context, tx *sql.Tx, transferID uint64) (*store.Transfer, error) {
+	var expired bool
+	var t store.Transfer
+	err := tx.QueryRowContext(ctx, `
+  SELECT `+columns+`, expires_at <= now()
+  FROM transfer_requests
+  WHERE id = $1
+  FOR UPDATE`, transferID).
+		Scan(
+			&t.TransferID,
+			&t.PlayerID,
+			&t.FromRosterID,
+			&t.ToRosterID,
+			&t.Status,
+			&t.RequestedBy,
+			&t.CreatedAt,
+			&t.ExpiresAt,
+			&expired)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, store.ErrNotFound
+	case err != nil:
+		return nil, err
+	case t.Status != store.TransferProposed:
+		return nil, &store.ConflictError{
+			RosterID: t.ToRosterID,
+			Reason:   fmt.Sprintf("transfer %d is %s", t.TransferID, t.Status),
+		}
+	case expired:
+		if _, err := setStatus(ctx, tx, &t, store.TransferExpired, ""); err != nil {
+			return nil, err
+		}
+		return &t, errExpired
+	}
+	return &t, nil
+}
+
+// finish ends the transaction after err occurred. Expiries of the transfer
+// are committed and reported as a *store.ConflictError, other errors roll back
+// the transaction.
+func finish(ctx context.Context, tx *sql.Tx, t *store.Transfer, err error) error {
+	if !errors.Is(err, errExpired) {
+		rollback(ctx, tx)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return &store.ConflictError{
+		RosterID: t.ToRosterID,
+		Reason:   fmt.Sprintf("transfer %d has expired", t.TransferID),
+	}
+}
+
+// setStatus changes the status of the transfer and records the change.
+func setStatus(ctx context.Context, tx *sql.Tx, t *store.Transfer, status, subject string) (*store.Transfer, error) {
+	updated, err := scanTransfer(tx.QueryRowContext(ctx, `
+  UPDATE transfer_requests
+  SET status = $2
+  WHERE id = $1
+  RETURNING `+columns, t.TransferID, status))
+	if err != nil {
+		return nil, err
+	}
+	if err := record(ctx, tx, updated, subject); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// record adds the current status of the transfer to its history.
+func record(ctx context.Context, tx *sql.Tx, t *store.Transfer, subject string) error {
+	_, err := tx.ExecContext(ctx, `
+  INSERT INTO transfer_events(transfer_id,status,subject)
+  VALUES($1,$2,$3)`, t.TransferID, t.Status, subject)
+	return err
+}
+
+func rollback(ctx context.Context, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed rollback transaction")
+	}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransfer(s scanner) (*store.Transfer, error) {
+	var t store.Transfer
+	if err := s.Scan(
+		&t.TransferID,
+		&t.PlayerID,
+		&t.FromRosterID,
+		&t.ToRosterID,
+		&t.Status,
+		&t.RequestedBy,
+		&t.CreatedAt,
+		&t.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,381 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/fgrimme/patrongg/database"
+	"github.com/fgrimme/patrongg/store"
+	"github.com/fgrimme/patrongg/store/storetest"
+	"github.com/lib/pq"
+)
+
+// mockPlayers records the players updated within transactions.
+type mockPlayers struct {
+	updated []store.Player
+	err     error
+}
+
+func (mp *mockPlayers) UpdateTx(ctx context.Context, tx *sql.Tx, player store.Player) (*store.Player, error) {
+	if mp.err != nil {
+		return nil, mp.err
+	}
+	mp.updated = append(mp.updated, player)
+	return &player, nil
+}
+
+var transferColumns = []string{"id", "player_id", "from_roster_id", "to_roster_id", "status", "requested_by", "created_at", "expires_at"}
+
+var (
+	created = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	expires = created.Add(24 * time.Hour)
+)
+
+// transferRow returns the columns of transfer 1 of player 2 from roster 3 to
+// roster 4 with the given status.
+func transferRow(status string) []driver.Value {
+	return []driver.Value{1, 2, 3, 4, status, "alice", created, expires}
+}
+
+func TestPropose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO transfer_requests(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(1, 2, 3, 4, store.TransferProposed, "alice", expires).
+		WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferProposed)...))
+	mock.ExpectExec(`INSERT INTO transfer_events(.*)VALUES(.*)`).
+		WithArgs(1, store.TransferProposed, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// a transfer of the player is proposed already
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO transfer_requests(.*)VALUES(.*)RETURNING (.+)`).
+		WithArgs(2, 2, 3, 4, store.TransferProposed, "alice", expires).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), &mockPlayers{})
+	transfer := store.Transfer{PlayerID: 2, FromRosterID: 3, ToRosterID: 4, RequestedBy: "alice", ExpiresAt: expires}
+	got, err := ts.Propose(context.Background(), transfer)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := &store.Transfer{TransferID: 1, PlayerID: 2, FromRosterID: 3, ToRosterID: 4, Status: store.TransferProposed, RequestedBy: "alice", CreatedAt: created, ExpiresAt: expires}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+
+	if _, err := ts.Propose(context.Background(), transfer); !errors.Is(err, store.ErrExists) {
+		t.Errorf("want error %v got %v", store.ErrExists, err)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM transfer_requests WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferRejected)...))
+	mock.ExpectQuery(`SELECT status, subject, created_at FROM transfer_events WHERE transfer_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "subject", "created_at"}).
+			AddRow(store.TransferProposed, "alice", created).
+			AddRow(store.TransferRejected, "bob", created.Add(time.Hour)))
+
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), &mockPlayers{})
+	got, err := ts.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.TransferEvent{
+		{Status: store.TransferProposed, Subject: "alice", CreatedAt: created},
+		{Status: store.TransferRejected, Subject: "bob", CreatedAt: created.Add(time.Hour)},
+	}
+	if !reflect.DeepEqual(want, got.History) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got.History)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListPlayers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM transfer_requests WHERE player_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{2, 5})).
+		WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferAccepted)...))
+
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), &mockPlayers{})
+	got, err := ts.ListPlayers(context.Background(), []uint64{2, 5})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []store.Transfer{{
+		TransferID:   1,
+		PlayerID:     2,
+		FromRosterID: 3,
+		ToRosterID:   4,
+		Status:       store.TransferAccepted,
+		RequestedBy:  "alice",
+		CreatedAt:    created,
+		ExpiresAt:    expires,
+	}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want\n%+v\ngot\n%+v", want, got)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	lockTransfer := `SELECT (.+), expires_at <= now\(\) FROM transfer_requests WHERE id = \$1 FOR UPDATE`
+	lockColumns := append(append([]string{}, transferColumns...), "expired")
+	tests := []struct {
+		d string                // description of test case
+		m func(sqlmock.Sqlmock) // mock expectations
+		p error                 // mock players store error
+		s string                // expected status of the transfer
+		u []store.Player        // expected player updates
+		e error                 // expected error
+		c string                // expected conflict reason
+	}{
+		{
+			d: "expect player to be moved when accepting transfer",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferProposed), false)...))
+				mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(3))
+				mock.ExpectQuery(`UPDATE transfer_requests SET status = \$2 WHERE id = \$1 RETURNING (.+)`).
+					WithArgs(1, store.TransferAccepted).
+					WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferAccepted)...))
+				mock.ExpectExec(`INSERT INTO transfer_events(.*)VALUES(.*)`).
+					WithArgs(1, store.TransferAccepted, "bob").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			s: store.TransferAccepted,
+			u: []store.Player{{PlayerID: 2, RosterID: 4, Status: store.Benched}},
+		},
+		{
+			d: "expect expired transfer to be marked expired",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferProposed), true)...))
+				mock.ExpectQuery(`UPDATE transfer_requests SET status = \$2 WHERE id = \$1 RETURNING (.+)`).
+					WithArgs(1, store.TransferExpired).
+					WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferExpired)...))
+				mock.ExpectExec(`INSERT INTO transfer_events(.*)VALUES(.*)`).
+					WithArgs(1, store.TransferExpired, "").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			c: "transfer 1 has expired",
+		},
+		{
+			d: "expect rejected transfer to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferRejected), false)...))
+				mock.ExpectRollback()
+			},
+			c: "transfer 1 is rejected",
+		},
+		{
+			d: "expect missing transfer to result in not found",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns))
+				mock.ExpectRollback()
+			},
+			e: store.ErrNotFound,
+		},
+		{
+			d: "expect player that left the roster to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferProposed), false)...))
+				mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(5))
+				mock.ExpectRollback()
+			},
+			c: "player 2 is no longer a member of the roster",
+		},
+		{
+			d: "expect locked roster to roll back",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferProposed), false)...))
+				mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(3))
+				mock.ExpectRollback()
+			},
+			p: &store.LockedError{RosterID: 4},
+			e: &store.LockedError{RosterID: 4},
+		},
+		{
+			d: "expect active player constraint violation to result in conflict",
+			m: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockTransfer).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(append(transferRow(store.TransferProposed), false)...))
+				mock.ExpectQuery(`SELECT roster_id FROM players WHERE id = \$1 FOR UPDATE`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"roster_id"}).AddRow(3))
+				mock.ExpectQuery(`UPDATE transfer_requests SET status = \$2 WHERE id = \$1 RETURNING (.+)`).
+					WithArgs(1, store.TransferAccepted).
+					WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferAccepted)...))
+				mock.ExpectExec(`INSERT INTO transfer_events(.*)VALUES(.*)`).
+					WithArgs(1, store.TransferAccepted, "bob").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(&pq.Error{Code: raiseException, Message: "roster id=3 must have exactly 5 active players, not 4"})
+			},
+			u: []store.Player{{PlayerID: 2, RosterID: 4, Status: store.Benched}},
+			c: "roster id=3 must have exactly 5 active players, not 4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer db.Close()
+			tt.m(mock)
+
+			players := &mockPlayers{err: tt.p}
+			ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), players)
+			got, err := ts.Accept(context.Background(), 1, "bob")
+			switch {
+			case tt.c != "":
+				var conflict *store.ConflictError
+				if !errors.As(err, &conflict) || conflict.Reason != tt.c {
+					t.Errorf("want conflict %q got %v", tt.c, err)
+				}
+			case tt.e != nil:
+				if !reflect.DeepEqual(tt.e, err) && !errors.Is(err, tt.e) {
+					t.Errorf("want error %v got %v", tt.e, err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error %v", err)
+			default:
+				if got.Status != tt.s {
+					t.Errorf("want status %s got %s", tt.s, got.Status)
+				}
+			}
+			if !reflect.DeepEqual(tt.u, players.updated) {
+				t.Errorf("want updates\n%+v\ngot\n%+v", tt.u, players.updated)
+			}
+			// we make sure that all expectations were met
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM transfer_requests WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(append(append([]string{}, transferColumns...), "expired")).
+			AddRow(append(transferRow(store.TransferProposed), false)...))
+	mock.ExpectQuery(`UPDATE transfer_requests SET status = \$2 WHERE id = \$1 RETURNING (.+)`).
+		WithArgs(1, store.TransferCancelled).
+		WillReturnRows(sqlmock.NewRows(transferColumns).AddRow(transferRow(store.TransferCancelled)...))
+	mock.ExpectExec(`INSERT INTO transfer_events(.*)VALUES(.*)`).
+		WithArgs(1, store.TransferCancelled, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	players := &mockPlayers{}
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), players)
+	got, err := ts.Decide(context.Background(), 1, store.TransferCancelled, "alice")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := store.TransferCancelled; got.Status != want {
+		t.Errorf("want status %s got %s", want, got.Status)
+	}
+	if len(players.updated) != 0 {
+		t.Errorf("unexpected player updates %+v", players.updated)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`WITH expired AS \( UPDATE transfer_requests (.+)\) INSERT INTO transfer_events(.+)`).
+		WithArgs(store.TransferExpired, store.TransferProposed).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	ts := New(database.New(db, "mock-db", 0), new(storetest.Sequence), &mockPlayers{})
+	n, err := ts.Expire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 3 {
+		t.Errorf("want 3 expired transfers got %d", n)
+	}
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
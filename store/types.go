@@ -1,12 +1,47 @@
 package store
 
+import (
+	"fmt"
+	"time"
+)
+
+// Player statuses within a roster. Released players left the roster when
+// their contract expired, they neither count as active nor as benched players.
+const (
+	Active   = "active"
+	Benched  = "benched"
+	Released = "released"
+)
+
+// ActiveSize is the number of active players a roster without game title
+// must have.
+const ActiveSize = 5
+
+// MaxActiveSize is the largest number of active players a game title may
+// require.
+const MaxActiveSize = 10
+
 type Player struct {
-	PlayerID  uint64 `json:"player_id"`
-	RosterID  uint64 `json:"roster_id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Alias     string `json:"alias"`
-	Status    string `json:"status"`
+	PlayerID  uint64   `json:"player_id"`
+	RosterID  uint64   `json:"roster_id"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Alias     string   `json:"alias"`
+	Status    string   `json:"status"`
+	Roles     []string `json:"roles,omitempty"`
+	Profile   *Profile `json:"profile,omitempty"`
+}
+
+// Profile holds the metadata of a player. Nationality is an ISO 3166-1 alpha-2
+// country code, DateOfBirth a date formatted as YYYY-MM-DD. Accounts are the
+// in-game account ids of the player by platform, Socials its handles by social
+// network. Custom holds arbitrary fields.
+type Profile struct {
+	Nationality string                 `json:"nationality,omitempty"`
+	DateOfBirth string                 `json:"date_of_birth,omitempty"`
+	Accounts    map[string]string      `json:"accounts,omitempty"`
+	Socials     map[string]string      `json:"socials,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty"`
 }
 
 type PlayerChange struct {
@@ -14,13 +49,323 @@ type PlayerChange struct {
 	Benched Player `json:"benched"`
 }
 
+// Trade exchanges players between two rosters. Players are moved from the
+// roster to the partner, PartnerPlayers from the partner to the roster. The
+// status of each player is the status it has after the trade.
+type Trade struct {
+	RosterID       uint64   `json:"roster_id"`
+	PartnerID      uint64   `json:"partner_id"`
+	Players        []Player `json:"players"`
+	PartnerPlayers []Player `json:"partner_players"`
+}
+
 type Players struct {
 	Active  []Player `json:"active"`
 	Benched []Player `json:"benched"`
 }
 
 type Roster struct {
-	RosterID uint64  `json:"roster_id"`
-	Name     string  `json:"name"`
-	Players  Players `json:"players"`
+	RosterID     uint64            `json:"roster_id"`
+	OrgID        uint64            `json:"org_id,omitempty"`
+	TitleID      uint64            `json:"title_id,omitempty"`
+	Name         string            `json:"name"`
+	Players      Players           `json:"players"`
+	Rules        *Rules            `json:"rules,omitempty"`
+	Requirements []RoleRequirement `json:"requirements,omitempty"`
+	NonCompliant bool              `json:"non_compliant,omitempty"`
+}
+
+// Rules limit the players of a roster. Rosters have exactly ActiveSize active
+// players and at most MaxBenched benched players, if set.
+type Rules struct {
+	ActiveSize int  `json:"active_size"`
+	MaxBenched *int `json:"max_benched,omitempty"`
+}
+
+// DefaultRules apply to rosters without game title.
+var DefaultRules = Rules{ActiveSize: ActiveSize}
+
+// Violation returns why a roster with the given numbers of active and benched
+// players violates the rules or an empty string.
+func (r Rules) Violation(active, benched int) string {
+	if active != r.ActiveSize {
+		return fmt.Sprintf("must have exactly %d active players, not %d", r.ActiveSize, active)
+	}
+	if r.MaxBenched != nil && benched > *r.MaxBenched {
+		return fmt.Sprintf("must have at most %d benched players, not %d", *r.MaxBenched, benched)
+	}
+	return ""
+}
+
+// Title is a game title. Its rules and role requirements are the defaults of
+// the rosters of the title.
+type Title struct {
+	TitleID uint64 `json:"title_id"`
+	Name    string `json:"name"`
+	Rules
+	Requirements []RoleRequirement `json:"requirements"`
+}
+
+// Organization owns rosters. Roster names are unique within an organization.
+type Organization struct {
+	OrgID uint64 `json:"org_id"`
+	Name  string `json:"name"`
+}
+
+// RoleRequirement requires the active players of a roster to cover the role
+// with at least Count players.
+type RoleRequirement struct {
+	Role  string `json:"role"`
+	Count int    `json:"count"`
+}
+
+// PlayerRow is a player read from a bulk import. Row is the position of the
+// player in the import, starting at 1.
+type PlayerRow struct {
+	Row    int
+	Player Player
+}
+
+// RowError describes why a row of a bulk import was rejected.
+type RowError struct {
+	Row    int    `json:"row"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult reports the outcome of a bulk import. Players are only created
+// if the import is not a dry run and no row was rejected.
+type ImportResult struct {
+	DryRun  bool       `json:"dry_run"`
+	Players []Player   `json:"players"`
+	Errors  []RowError `json:"errors"`
+}
+
+// RosterRow is a player along with the name of its roster. Roster exports are
+// streamed row by row.
+type RosterRow struct {
+	RosterName string
+	Player     Player
+}
+
+// PlayerFilter selects players by roster, status, alias and profile. Empty
+// fields do not restrict the selection. Aliases match case-insensitively by
+// substring. Platform selects players with an account on the platform, Custom
+// players whose custom fields have the given values in their text form.
+type PlayerFilter struct {
+	RosterIDs   []uint64
+	Status      string
+	Alias       string
+	Nationality string
+	Platform    string
+	Custom      map[string]string
+}
+
+// SearchQuery searches players by their first name, last name and alias.
+// Fields match if they contain the text or are similar to it, regardless of
+// case. RosterIDs and Status restrict the results like the PlayerFilter, Limit
+// caps their number.
+type SearchQuery struct {
+	Text      string
+	RosterIDs []uint64
+	Status    string
+	Limit     int
+}
+
+// SearchResult is a player found by a search. Rank is the score of its best
+// matching field between 0 and 1, Highlights hold the matching fields by name
+// with the matching parts enclosed in <em> tags.
+type SearchResult struct {
+	Player
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// AliasMatch is a player found by its current or a former alias. Current is
+// false for players that renamed from the alias at RenamedAt. TitleID is the
+// game title of the roster of the player, aliases are unique per title.
+type AliasMatch struct {
+	Player
+	TitleID   uint64     `json:"title_id,omitempty"`
+	Current   bool       `json:"current"`
+	RenamedAt *time.Time `json:"renamed_at,omitempty"`
+}
+
+// AliasChange is a former alias of a player that was renamed at RenamedAt.
+type AliasChange struct {
+	PlayerID  uint64    `json:"player_id"`
+	Alias     string    `json:"alias"`
+	RenamedAt time.Time `json:"renamed_at"`
+}
+
+// IDGenerator generates the ids of new rosters and players.
+type IDGenerator interface {
+	Next() uint64
+}
+
+// Lock is a window during which the players of a roster must not be swapped
+// or transferred. Locks without end last until the roster is unlocked.
+type Lock struct {
+	LockID   uint64     `json:"lock_id"`
+	RosterID uint64     `json:"roster_id"`
+	StartsAt time.Time  `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Reason   string     `json:"reason"`
+}
+
+// Override records a change of a locked roster permitted by an admin.
+type Override struct {
+	OverrideID uint64    `json:"override_id"`
+	LockID     uint64    `json:"lock_id"`
+	RosterID   uint64    `json:"roster_id"`
+	Subject    string    `json:"subject"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Match statuses. Lineups can only be submitted for scheduled matches.
+const (
+	MatchScheduled = "scheduled"
+	MatchLive      = "live"
+	MatchCompleted = "completed"
+	MatchCancelled = "cancelled"
+)
+
+// Match is a scheduled match of a roster against an opponent roster. Lineups
+// must be submitted until the lineup deadline.
+type Match struct {
+	MatchID        uint64    `json:"match_id"`
+	RosterID       uint64    `json:"roster_id"`
+	OpponentID     uint64    `json:"opponent_id"`
+	ScheduledAt    time.Time `json:"scheduled_at"`
+	LineupDeadline time.Time `json:"lineup_deadline"`
+	Status         string    `json:"status"`
+}
+
+// Lineup is the snapshot of the active players a roster submitted for a
+// match. Submitted lineups can not be changed.
+type Lineup struct {
+	MatchID     uint64    `json:"match_id"`
+	RosterID    uint64    `json:"roster_id"`
+	Players     []Player  `json:"players"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Transfer statuses. Proposed transfers are accepted or rejected by the
+// destination roster, cancelled by the source roster or expire.
+const (
+	TransferProposed  = "proposed"
+	TransferAccepted  = "accepted"
+	TransferRejected  = "rejected"
+	TransferExpired   = "expired"
+	TransferCancelled = "cancelled"
+)
+
+// Transfer is a request to move a player to another roster. The player is
+// moved when the destination roster accepts the transfer.
+type Transfer struct {
+	TransferID   uint64          `json:"transfer_id"`
+	PlayerID     uint64          `json:"player_id"`
+	FromRosterID uint64          `json:"from_roster_id"`
+	ToRosterID   uint64          `json:"to_roster_id"`
+	Status       string          `json:"status"`
+	RequestedBy  string          `json:"requested_by"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+	History      []TransferEvent `json:"history,omitempty"`
+}
+
+// TransferEvent records a change of the status of a transfer and the subject
+// that changed it. Expiries are not attributed to a subject.
+type TransferEvent struct {
+	Status    string    `json:"status"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Contract statuses. Signed contracts bind the player to the roster until the
+// end of EndsOn. Ended contracts release their player, unless it is active and
+// no benched player can replace it. Such contracts are overdue and leave their
+// roster non-compliant until a replacement is found.
+const (
+	ContractSigned   = "signed"
+	ContractReleased = "released"
+	ContractOverdue  = "overdue"
+)
+
+// Contract binds a player to its roster from StartsOn to EndsOn, both dates
+// formatted as YYYY-MM-DD and inclusive. Terms hold arbitrary fields like the
+// salary. ReplacementID is the benched player activated when the active player
+// of the contract was released.
+type Contract struct {
+	ContractID    uint64                 `json:"contract_id"`
+	PlayerID      uint64                 `json:"player_id"`
+	RosterID      uint64                 `json:"roster_id"`
+	StartsOn      string                 `json:"starts_on"`
+	EndsOn        string                 `json:"ends_on"`
+	Terms         map[string]interface{} `json:"terms,omitempty"`
+	Status        string                 `json:"status"`
+	ReplacementID uint64                 `json:"replacement_id,omitempty"`
+	ReleasedAt    *time.Time             `json:"released_at,omitempty"`
+}
+
+// Availability states of players. Injured players may be expected back on a
+// date, unavailable players are.
+const (
+	Available   = "available"
+	Injured     = "injured"
+	Unavailable = "unavailable"
+)
+
+// Availability tells whether a player can play. Until is the date the player
+// is expected back, formatted YYYY-MM-DD.
+type Availability struct {
+	PlayerID uint64 `json:"player_id"`
+	Status   string `json:"status"`
+	Until    string `json:"until,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// Suggestion proposes to swap an active player that is not available with an
+// available benched player. Change is the payload of the swap, Availability
+// the one of the active player and Roles the roles of the active player the
+// benched player takes over.
+type Suggestion struct {
+	Change       PlayerChange `json:"change"`
+	Availability Availability `json:"availability"`
+	Roles        []string     `json:"roles,omitempty"`
+}
+
+// Compliance modes of rosters. Writes violating the rules of strict rosters
+// are rejected, violations of lenient rosters are reported only.
+const (
+	Strict  = "strict"
+	Lenient = "lenient"
+)
+
+// Rules a roster may violate.
+const (
+	RuleActiveSize = "active_size"
+	RuleMaxBenched = "max_benched"
+	RuleRole       = "role"
+	RuleContract   = "contract"
+)
+
+// Violation is a violated rule of a roster. Role violations name the role,
+// contract violations the overdue contract and its player.
+type Violation struct {
+	Rule       string `json:"rule"`
+	Detail     string `json:"detail"`
+	Role       string `json:"role,omitempty"`
+	ContractID uint64 `json:"contract_id,omitempty"`
+	PlayerID   uint64 `json:"player_id,omitempty"`
+}
+
+// Compliance reports the violations of the rules of a roster. Rosters are
+// compliant without violations.
+type Compliance struct {
+	RosterID   uint64      `json:"roster_id"`
+	Mode       string      `json:"mode"`
+	Compliant  bool        `json:"compliant"`
+	Violations []Violation `json:"violations"`
 }